@@ -0,0 +1,254 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params/types/coregeth"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+	"github.com/ethereum/go-ethereum/params/vars"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// generateEtcChainKey is the environment variable enabling the regeneration
+// of the ETC test chain in ./testdata-etc.
+const generateEtcChainKey = "COREGETH_ETHTEST_GENERATE_ETC_CHAIN"
+
+const (
+	etcChainLength   = 96 // Number of blocks in chain.rlp
+	etcChainSenders  = 8  // Number of pre-funded transaction senders
+	etcChainStorages = 3  // Number of storage contracts deployed in block 1
+)
+
+// etcChainConfig is a Mordor-style chain configuration squeezing the ETC
+// hardforks into the first blocks of the test chain, so that each of them
+// contributes to the fork ID. Etchash (ECIP-1099) is enabled from genesis.
+func etcChainConfig() *coregeth.CoreGethChainConfig {
+	var (
+		agharta  = big.NewInt(8)
+		phoenix  = big.NewInt(16)
+		magneto  = big.NewInt(24)
+		mystique = big.NewInt(32)
+		spiral   = big.NewInt(40)
+	)
+	return &coregeth.CoreGethChainConfig{
+		NetworkID: 63,
+		ChainID:   big.NewInt(63),
+		Ethash:    new(ctypes.EthashConfig),
+
+		EIP2FBlock:   big.NewInt(0),
+		EIP7FBlock:   big.NewInt(0),
+		EIP150Block:  big.NewInt(0),
+		EIP155Block:  big.NewInt(0),
+		EIP160FBlock: big.NewInt(0),
+		EIP161FBlock: big.NewInt(0),
+		EIP170FBlock: big.NewInt(0),
+
+		EIP100FBlock: big.NewInt(0),
+		EIP140FBlock: big.NewInt(0),
+		EIP198FBlock: big.NewInt(0),
+		EIP211FBlock: big.NewInt(0),
+		EIP212FBlock: big.NewInt(0),
+		EIP213FBlock: big.NewInt(0),
+		EIP214FBlock: big.NewInt(0),
+		EIP658FBlock: big.NewInt(0),
+
+		EIP145FBlock:  agharta,
+		EIP1014FBlock: agharta,
+		EIP1052FBlock: agharta,
+
+		EIP152FBlock:  phoenix,
+		EIP1108FBlock: phoenix,
+		EIP1344FBlock: phoenix,
+		EIP1884FBlock: phoenix,
+		EIP2028FBlock: phoenix,
+		EIP2200FBlock: phoenix,
+
+		EIP2565FBlock: magneto,
+		EIP2718FBlock: magneto,
+		EIP2929FBlock: magneto,
+		EIP2930FBlock: magneto,
+
+		EIP3529FBlock: mystique,
+		EIP3541FBlock: mystique,
+
+		EIP3651FBlock: spiral,
+		EIP3855FBlock: spiral,
+		EIP3860FBlock: spiral,
+		EIP6049FBlock: spiral,
+
+		ECIP1099FBlock: big.NewInt(0),
+
+		DisposalBlock:     big.NewInt(0),
+		ECIP1017FBlock:    big.NewInt(0),
+		ECIP1017EraRounds: big.NewInt(2000000),
+	}
+}
+
+// storageContract returns the creation code of a contract storing the block
+// number at the slot given in the calldata. The trailing byte makes the code
+// of each deployed instance unique.
+func storageContract(id byte) []byte {
+	// NUMBER PUSH1 0 CALLDATALOAD SSTORE STOP <id>
+	runtime := []byte{0x43, 0x60, 0x00, 0x35, 0x55, 0x00, id}
+
+	// PUSH7 <runtime> PUSH1 0 MSTORE PUSH1 7 PUSH1 25 RETURN
+	code := append([]byte{0x66}, runtime...)
+	return append(code, 0x60, 0x00, 0x52, 0x60, 0x07, 0x60, 0x19, 0xf3)
+}
+
+// TestGenerateEtcChain regenerates the ETC test chain used by the ETC suite.
+// The chain is sealed with fake PoW, just like the default test chain.
+func TestGenerateEtcChain(t *testing.T) {
+	if os.Getenv(generateEtcChainKey) == "" {
+		t.Skip()
+	}
+	var (
+		dir      = "./testdata-etc"
+		config   = etcChainConfig()
+		coinbase = common.HexToAddress("0xe7c0000000000000000000000000000000000001")
+		keys     = make([]*ecdsa.PrivateKey, etcChainSenders)
+		senders  = make([]common.Address, etcChainSenders)
+		alloc    = make(genesisT.GenesisAlloc)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.ToECDSA(crypto.Keccak256([]byte(fmt.Sprintf("etc-sender-%d", i))))
+		senders[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[senders[i]] = genesisT.GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(vars.Ether))}
+	}
+	genesis := &genesisT.Genesis{
+		Config:     config,
+		ExtraData:  []byte("core-geth etc test chain"),
+		GasLimit:   0x2fefd8,
+		Difficulty: big.NewInt(0x20000),
+		Timestamp:  0x5d9676db,
+		Alloc:      alloc,
+	}
+	var contracts []common.Address
+	for i := 0; i < etcChainStorages; i++ {
+		contracts = append(contracts, crypto.CreateAddress(senders[0], uint64(i)))
+	}
+	// Generate one block more than the chain, it's announced by the suite
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), etcChainLength+1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(coinbase)
+
+		signer := types.MakeSigner(config, b.Number(), b.Timestamp())
+		send := func(key *ecdsa.PrivateKey, to *common.Address, value *big.Int, gas uint64, data []byte) {
+			tx, err := types.SignTx(types.NewTx(&types.LegacyTx{
+				Nonce:    b.TxNonce(crypto.PubkeyToAddress(key.PublicKey)),
+				To:       to,
+				Value:    value,
+				Gas:      gas,
+				GasPrice: big.NewInt(vars.GWei),
+				Data:     data,
+			}), signer, key)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			b.AddTx(tx)
+		}
+		if i == 0 {
+			for id := 0; id < etcChainStorages; id++ {
+				send(keys[0], nil, new(big.Int), 100_000, storageContract(byte(id)))
+			}
+			return
+		}
+		// Create a fresh account and write a storage slot in every block
+		recipient := common.BigToAddress(big.NewInt(int64(0x1000 + i)))
+		send(keys[i%etcChainSenders], &recipient, big.NewInt(int64(i)*vars.GWei), vars.TxGas, nil)
+
+		slot := common.BigToHash(big.NewInt(int64(i % 10)))
+		send(keys[(i+1)%etcChainSenders], &contracts[i%etcChainStorages], new(big.Int), 50_000, slot[:])
+	})
+	// Import the chain to collect the head state, along with the preimages
+	// needed to resolve the addresses of the dump.
+	cacheConfig := core.DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.Preimages = true
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks[:etcChainLength]); err != nil {
+		t.Fatalf("failed to import block %d: %v", n, err)
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	dump := statedb.RawDump(&state.DumpConfig{OnlyWithAddresses: true})
+	for addr, account := range dump.Accounts {
+		// The dump keys storage by slot, the suite expects the hashed slot
+		storage := make(map[common.Hash]string, len(account.Storage))
+		for slot, value := range account.Storage {
+			storage[crypto.Keccak256Hash(slot[:])] = value
+		}
+		account.Storage = storage
+		dump.Accounts[addr] = account
+	}
+	accounts := make(map[common.Address]map[string]hexutil.Bytes)
+	for i, key := range keys {
+		accounts[senders[i]] = map[string]hexutil.Bytes{"key": crypto.FromECDSA(key)}
+	}
+	// Write out the fixture files
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSON := func(name string, v any) {
+		blob, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			t.Fatalf("failed to encode %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), blob, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRLP := func(name string, blocks []*types.Block) {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		for _, block := range blocks {
+			if err := rlp.Encode(f, block); err != nil {
+				t.Fatalf("failed to encode block %d: %v", block.NumberU64(), err)
+			}
+		}
+	}
+	writeJSON("genesis.json", genesis)
+	writeJSON("headstate.json", dump)
+	writeJSON("accounts.json", accounts)
+	writeRLP("chain.rlp", blocks[:etcChainLength])
+	writeRLP("newblock.rlp", blocks[etcChainLength:])
+}
@@ -0,0 +1,639 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+	"github.com/ethereum/go-ethereum/internal/utesting"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params/confp"
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/exp/slices"
+)
+
+// NewEtcSuite creates a test suite for Ethereum Classic style (proof-of-work,
+// no beacon engine) nodes. Besides the regular chain files, the chain
+// directory contains a block extending the chain (newblock.rlp), which the
+// suite announces to the node.
+func NewEtcSuite(dest *enode.Node, chainDir string) (*Suite, error) {
+	chain, err := NewChain(chainDir)
+	if err != nil {
+		return nil, err
+	}
+	newBlock, err := readBlock(path.Join(chainDir, "newblock.rlp"))
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{
+		Dest:     dest,
+		chain:    chain,
+		newBlock: newBlock,
+	}, nil
+}
+
+// EtcTests returns the eth and snap protocol tests applicable to proof-of-work
+// chains. The tests requiring the engine API are left out.
+//
+// The NewBlock test advances the chain of the node, so it comes last.
+func (s *Suite) EtcTests() []utesting.Test {
+	return []utesting.Test{
+		// status
+		{Name: "Status", Fn: s.TestStatus},
+		{Name: "ForkID", Fn: s.TestEtcForkID},
+		// get block headers
+		{Name: "GetBlockHeaders", Fn: s.TestGetBlockHeaders},
+		{Name: "SimultaneousRequests", Fn: s.TestSimultaneousRequests},
+		{Name: "SameRequestID", Fn: s.TestSameRequestID},
+		{Name: "ZeroRequestID", Fn: s.TestZeroRequestID},
+		// get block bodies
+		{Name: "GetBlockBodies", Fn: s.TestGetBlockBodies},
+		// malicious handshakes + status
+		{Name: "MaliciousHandshake", Fn: s.TestMaliciousHandshake},
+		{Name: "MaliciousStatus", Fn: s.TestMaliciousStatus},
+		// snap serving of the head state
+		{Name: "SnapAccountRange", Fn: s.TestEtcSnapAccountRange},
+		{Name: "SnapStorageRanges", Fn: s.TestEtcSnapStorageRanges},
+		{Name: "SnapByteCodes", Fn: s.TestEtcSnapByteCodes},
+		{Name: "SnapTrieNodes", Fn: s.TestEtcSnapTrieNodes},
+		// block propagation
+		{Name: "NewBlock", Fn: s.TestEtcNewBlock, Slow: true},
+	}
+}
+
+// readBlock reads a single RLP encoded block from a file.
+func readBlock(file string) (*types.Block, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(blob, block); err != nil {
+		return nil, fmt.Errorf("invalid block in %s: %v", file, err)
+	}
+	return block, nil
+}
+
+type forkIDTest struct {
+	id     forkid.ID
+	accept bool
+
+	desc string
+}
+
+// TestEtcForkID peers with the node announcing fork IDs of remote nodes at
+// various positions of the fork schedule, checking that the node enforces the
+// EIP-2124 rules against the ETC forks.
+func (s *Suite) TestEtcForkID(t *utesting.T) {
+	var (
+		genesis = s.chain.GetBlock(0)
+		head    = s.chain.Head().NumberU64()
+		forks   = confp.BlockForks(s.chain.config)
+	)
+	if len(forks) < 2 || forks[len(forks)-1] >= head {
+		t.Fatalf("test chain must pass at least two forks, have %v at head %d", forks, head)
+	}
+	var (
+		last   = forks[len(forks)-1]
+		behind = forkid.NewID(s.chain.config, genesis, last-1, 0)
+		stale  = forkid.ID{Hash: behind.Hash}
+		skewed = forkid.ID{Hash: behind.Hash, Next: last + 1}
+		passed = forkid.ID{Hash: s.chain.ForkID().Hash, Next: head}
+	)
+	// Fork the schedule with a fork ETC never activated
+	config, err := confp.CloneChainConfigurator(s.chain.config)
+	if err != nil {
+		t.Fatalf("failed to copy chain config: %v", err)
+	}
+	london := last + 1
+	if err := config.SetEIP1559Transition(&london); err != nil {
+		t.Fatalf("failed to configure EIP-1559: %v", err)
+	}
+	foreign := forkid.NewID(config, genesis, head, 0)
+
+	tests := []forkIDTest{
+		{
+			desc:   `The remote node is synced to the same fork as the node. The node should keep the connection.`,
+			id:     s.chain.ForkID(),
+			accept: true,
+		},
+		{
+			desc:   `The remote node is syncing from genesis and knows about the first fork. The node should keep the connection.`,
+			id:     forkid.NewID(s.chain.config, genesis, 0, 0),
+			accept: true,
+		},
+		{
+			desc:   `The remote node is syncing before the last fork and knows about it. The node should keep the connection.`,
+			id:     behind,
+			accept: true,
+		},
+		{
+			desc: `The remote node stopped before the last fork and does not know about it.
+The node should disconnect.`,
+			id: stale,
+		},
+		{
+			desc: `The remote node is before the last fork, but schedules it at a different block.
+The node should disconnect.`,
+			id: skewed,
+		},
+		{
+			desc: `The remote node schedules a fork at a block the node already passed without it.
+The node should disconnect.`,
+			id: passed,
+		},
+		{
+			desc: `The remote node activated EIP-1559 after the last ETC fork.
+The node should disconnect.`,
+			id: foreign,
+		},
+	}
+	for i, tc := range tests {
+		if i > 0 {
+			t.Log("\n")
+		}
+		t.Logf("-- Test %d", i)
+		t.Log(tc.desc)
+		t.Logf("  fork ID: %#x, next %d", tc.id.Hash, tc.id.Next)
+
+		accepted, err := s.peerWithForkID(tc.id)
+		switch {
+		case err != nil:
+			t.Errorf("test %d failed: %v", i, err)
+		case accepted && !tc.accept:
+			t.Errorf("test %d failed: node kept the connection", i)
+		case !accepted && tc.accept:
+			t.Errorf("test %d failed: node disconnected", i)
+		}
+	}
+}
+
+// peerWithForkID performs the status exchange announcing the given fork ID and
+// reports whether the node kept the connection afterwards.
+func (s *Suite) peerWithForkID(id forkid.ID) (bool, error) {
+	conn, err := s.dial()
+	if err != nil {
+		return false, fmt.Errorf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.handshake(); err != nil {
+		return false, fmt.Errorf("handshake failed: %v", err)
+	}
+	status := &eth.StatusPacket{
+		ProtocolVersion: uint32(conn.negotiatedProtoVersion),
+		NetworkID:       s.chain.config.GetChainID().Uint64(),
+		TD:              s.chain.TD(),
+		Head:            s.chain.Head().Hash(),
+		Genesis:         s.chain.GetBlock(0).Hash(),
+		ForkID:          id,
+	}
+	if err := conn.statusExchange(s.chain, status); err != nil {
+		return false, fmt.Errorf("status exchange failed: %v", err)
+	}
+	// Probe the connection with a header request. A rejected peer is sent a
+	// disconnect instead, so a failed write is left for the read to tell.
+	req := &eth.GetBlockHeadersPacket{
+		RequestId: 1,
+		GetBlockHeadersRequest: &eth.GetBlockHeadersRequest{
+			Origin: eth.HashOrNumber{Number: 0},
+			Amount: 1,
+		},
+	}
+	conn.Write(ethProto, eth.GetBlockHeadersMsg, req)
+	for {
+		code, _, err := conn.Read()
+		if err != nil {
+			return false, fmt.Errorf("error reading from connection: %v", err)
+		}
+		switch code {
+		case discMsg:
+			return false, nil
+		case pingMsg:
+			conn.Write(baseProto, pongMsg, []byte{})
+		case protoOffset(ethProto) + eth.BlockHeadersMsg:
+			return true, nil
+		}
+	}
+}
+
+// TestEtcNewBlock announces a block extending the chain along with its total
+// difficulty, and expects the node to import it and relay it to another peer.
+func (s *Suite) TestEtcNewBlock(t *utesting.T) {
+	t.Log(`This test announces a new proof-of-work block to the node with a NewBlock message.
+The node should import the block, either directly or by syncing from the announcing peer
+as it has the highest total difficulty, and relay the block to another peer.`)
+
+	block := s.newBlock
+	if block == nil || block.ParentHash() != s.chain.Head().Hash() {
+		t.Fatalf("no block extending the test chain available")
+	}
+	// Peer the receiving connection first, so the node has someone to relay to.
+	recv, err := s.dial()
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer recv.Close()
+	if err := recv.peer(s.chain, nil); err != nil {
+		t.Fatalf("peering failed: %v", err)
+	}
+	// Peer the announcing connection with the new block as its head. A node which
+	// doesn't consider itself synced yet discards propagated blocks, so the
+	// connection serves the chain for the node to sync from.
+	send, err := s.dial()
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer send.Close()
+	if err := send.handshake(); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	td := new(big.Int).Add(s.chain.TD(), block.Difficulty())
+	status := &eth.StatusPacket{
+		ProtocolVersion: uint32(send.negotiatedProtoVersion),
+		NetworkID:       s.chain.config.GetChainID().Uint64(),
+		TD:              td,
+		Head:            block.Hash(),
+		Genesis:         s.chain.GetBlock(0).Hash(),
+		ForkID:          s.chain.ForkID(),
+	}
+	if err := send.statusExchange(s.chain, status); err != nil {
+		t.Fatalf("status exchange failed: %v", err)
+	}
+	if err := send.Write(ethProto, eth.NewBlockMsg, &eth.NewBlockPacket{Block: block, TD: td}); err != nil {
+		t.Fatalf("failed to write to connection: %v", err)
+	}
+	var (
+		blocks = append(slices.Clone(s.chain.blocks), block)
+		stop   = make(chan struct{})
+		done   = make(chan error, 1)
+	)
+	go func() { done <- send.serveChain(blocks, stop) }()
+	defer func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Errorf("failed to serve chain: %v", err)
+		}
+	}()
+	// Wait for the block to be relayed, either in full or as an announcement,
+	// and poll the node until it's imported. Syncing is not immediate, so allow
+	// for a couple of sync cycles.
+	var (
+		deadline = time.Now().Add(30 * time.Second)
+		polled   time.Time
+		relayed  bool
+		imported bool
+	)
+	req := &eth.GetBlockHeadersPacket{
+		GetBlockHeadersRequest: &eth.GetBlockHeadersRequest{
+			Origin: eth.HashOrNumber{Hash: block.Hash()},
+			Amount: 1,
+		},
+	}
+	for !relayed || !imported {
+		if time.Now().After(deadline) {
+			t.Fatalf("block %d not propagated: relayed %v, imported %v", block.NumberU64(), relayed, imported)
+		}
+		if !imported && time.Since(polled) > 500*time.Millisecond {
+			req.RequestId++
+			if err := recv.Write(ethProto, eth.GetBlockHeadersMsg, req); err != nil {
+				t.Fatalf("failed to write to connection: %v", err)
+			}
+			polled = time.Now()
+		}
+		msg, err := recv.ReadEth()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("error reading from connection: %v", err)
+		}
+		// The node may fetch the block from any peer while syncing.
+		if err := recv.serveRequest(blocks, msg); err != nil {
+			t.Fatalf("failed to serve request: %v", err)
+		}
+		switch msg := msg.(type) {
+		case *eth.NewBlockPacket:
+			if msg.Block.Hash() != block.Hash() {
+				continue
+			}
+			if msg.TD.Cmp(td) != 0 {
+				t.Fatalf("wrong total difficulty relayed: have %v, want %v", msg.TD, td)
+			}
+			relayed = true
+		case *eth.NewBlockHashesPacket:
+			for _, announce := range *msg {
+				if announce.Hash == block.Hash() {
+					relayed = true
+				}
+			}
+		case *eth.BlockHeadersPacket:
+			headers := msg.BlockHeadersRequest
+			if msg.RequestId == req.RequestId && len(headers) == 1 && headers[0].Hash() == block.Hash() {
+				imported = true
+			}
+		}
+	}
+	// Extend the test chain, so the status of the node matches again.
+	s.chain.blocks = blocks
+	s.newBlock = nil
+}
+
+// serveChain answers the header and body requests of the node from the given
+// blocks until stopped, allowing the node to sync from the connection.
+func (c *Conn) serveChain(blocks []*types.Block, stop chan struct{}) error {
+	for {
+		msg, err := c.ReadEth()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			select {
+			case <-stop:
+				return nil
+			default:
+				continue
+			}
+		}
+		if err != nil {
+			select {
+			case <-stop:
+				return nil // connection closed by the node after the test
+			default:
+				return err
+			}
+		}
+		if err := c.serveRequest(blocks, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// serveRequest answers a header or body request of the node from the given
+// blocks, ignoring any other message.
+func (c *Conn) serveRequest(blocks []*types.Block, msg any) error {
+	switch msg := msg.(type) {
+	case *eth.GetBlockHeadersPacket:
+		res := &eth.BlockHeadersPacket{
+			RequestId:           msg.RequestId,
+			BlockHeadersRequest: serveHeaders(blocks, msg.GetBlockHeadersRequest),
+		}
+		return c.Write(ethProto, eth.BlockHeadersMsg, res)
+	case *eth.GetBlockBodiesPacket:
+		res := &eth.BlockBodiesPacket{RequestId: msg.RequestId}
+		for _, hash := range msg.GetBlockBodiesRequest {
+			for _, block := range blocks {
+				if block.Hash() == hash {
+					res.BlockBodiesResponse = append(res.BlockBodiesResponse, &eth.BlockBody{
+						Transactions: block.Transactions(),
+						Uncles:       block.Uncles(),
+						Withdrawals:  block.Withdrawals(),
+					})
+					break
+				}
+			}
+		}
+		return c.Write(ethProto, eth.BlockBodiesMsg, res)
+	}
+	return nil
+}
+
+// serveHeaders collects the headers matching a request from a chain of blocks
+// indexed by number.
+func serveHeaders(blocks []*types.Block, req *eth.GetBlockHeadersRequest) []*types.Header {
+	var number uint64
+	if req.Origin.Hash != (common.Hash{}) {
+		number = uint64(len(blocks))
+		for _, block := range blocks {
+			if block.Hash() == req.Origin.Hash {
+				number = block.NumberU64()
+				break
+			}
+		}
+	} else {
+		number = req.Origin.Number
+	}
+	var headers []*types.Header
+	for number < uint64(len(blocks)) && uint64(len(headers)) < req.Amount {
+		headers = append(headers, blocks[number].Header())
+		if req.Reverse {
+			if number < req.Skip+1 {
+				break
+			}
+			number -= req.Skip + 1
+		} else {
+			number += req.Skip + 1
+		}
+	}
+	return headers
+}
+
+// TestEtcSnapAccountRange requests account ranges of the head state, checking
+// the responses against the state of the test chain.
+func (s *Suite) TestEtcSnapAccountRange(t *utesting.T) {
+	var (
+		root     = s.chain.Head().Root()
+		accounts = s.chain.AccountsInHashOrder()
+		first    = common.BytesToHash(accounts[0].AddressHash)
+		middle   = common.BytesToHash(accounts[len(accounts)/2].AddressHash)
+		last     = common.BytesToHash(accounts[len(accounts)-1].AddressHash)
+	)
+	tests := []accRangeTest{
+		{
+			desc: `This request covers the whole key space of the head state.
+The server should return all accounts.`,
+			nBytes:       100_000,
+			root:         root,
+			startingHash: common.Hash{},
+			limitHash:    common.MaxHash,
+			expAccounts:  len(accounts),
+			expFirst:     first,
+			expLast:      last,
+		},
+		{
+			desc: `This request starts at an account in the middle of the key space.
+The server should return the accounts from there on.`,
+			nBytes:       100_000,
+			root:         root,
+			startingHash: middle,
+			limitHash:    common.MaxHash,
+			expAccounts:  len(accounts) - len(accounts)/2,
+			expFirst:     middle,
+			expLast:      last,
+		},
+		{
+			desc: `This request has a bytes limit of one.
+The server should return the first account.`,
+			nBytes:       1,
+			root:         root,
+			startingHash: common.Hash{},
+			limitHash:    common.MaxHash,
+			expAccounts:  1,
+			expFirst:     first,
+			expLast:      first,
+		},
+		{
+			desc: `This request is for an unknown state root.
+The server should return an empty response.`,
+			nBytes:       100_000,
+			root:         common.Hash{0x01},
+			startingHash: common.Hash{},
+			limitHash:    common.MaxHash,
+			expAccounts:  0,
+		},
+	}
+	for i, tc := range tests {
+		tc := tc
+		if i > 0 {
+			t.Log("\n")
+		}
+		t.Logf("-- Test %d", i)
+		t.Log(tc.desc)
+		if err := s.snapGetAccountRange(t, &tc); err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+		}
+	}
+}
+
+// TestEtcSnapStorageRanges requests the storage of the contracts in the head
+// state, checking the responses against the state of the test chain.
+func (s *Suite) TestEtcSnapStorageRanges(t *utesting.T) {
+	var (
+		root      = s.chain.Head().Root()
+		contracts []common.Hash
+		slots     [][]*snap.StorageData
+	)
+	for _, account := range s.chain.AccountsInHashOrder() {
+		if len(account.Storage) == 0 {
+			continue
+		}
+		var list []*snap.StorageData
+		for hash, value := range account.Storage {
+			body, _ := rlp.EncodeToBytes(common.FromHex(value))
+			list = append(list, &snap.StorageData{Hash: hash, Body: body})
+		}
+		slices.SortFunc(list, func(a, b *snap.StorageData) int {
+			return bytes.Compare(a.Hash[:], b.Hash[:])
+		})
+		contracts = append(contracts, common.BytesToHash(account.AddressHash))
+		slots = append(slots, list)
+	}
+	if len(contracts) < 2 || len(slots[0]) < 2 {
+		t.Fatalf("test chain must contain at least two contracts with storage")
+	}
+	tests := []stRangesTest{
+		{
+			desc: `This request covers the whole storage of all contracts.
+The server should return all storage slots.`,
+			root:     root,
+			accounts: contracts,
+			origin:   common.Hash{}.Bytes(),
+			limit:    common.MaxHash.Bytes(),
+			nBytes:   100_000,
+			expSlots: slots,
+		},
+		{
+			desc: `This request starts at the second slot of a single contract.
+The server should return the slots from there on.`,
+			root:     root,
+			accounts: contracts[:1],
+			origin:   slots[0][1].Hash.Bytes(),
+			limit:    common.MaxHash.Bytes(),
+			nBytes:   100_000,
+			expSlots: [][]*snap.StorageData{slots[0][1:]},
+		},
+	}
+	for i, tc := range tests {
+		tc := tc
+		if i > 0 {
+			t.Log("\n")
+		}
+		t.Logf("-- Test %d", i)
+		t.Log(tc.desc)
+		if err := s.snapGetStorageRanges(t, &tc); err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+		}
+	}
+}
+
+// TestEtcSnapByteCodes requests the contract codes of the head state.
+func (s *Suite) TestEtcSnapByteCodes(t *utesting.T) {
+	hashes := s.chain.CodeHashes()
+	tests := []byteCodesTest{
+		{
+			desc:      `Here we request all contract codes. The server should deliver them all in one response.`,
+			nBytes:    100_000,
+			hashes:    hashes,
+			expHashes: len(hashes),
+		},
+		{
+			desc:      `Here we request the head state root as a code hash. The server should deliver an empty response.`,
+			nBytes:    100_000,
+			hashes:    []common.Hash{s.chain.Head().Root()},
+			expHashes: 0,
+		},
+	}
+	for i, tc := range tests {
+		tc := tc
+		if i > 0 {
+			t.Log("\n")
+		}
+		t.Logf("-- Test %d", i)
+		t.Log(tc.desc)
+		if err := s.snapGetByteCodes(t, &tc); err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+		}
+	}
+}
+
+// TestEtcSnapTrieNodes requests the root nodes of the account trie and of a
+// storage trie of the head state.
+func (s *Suite) TestEtcSnapTrieNodes(t *utesting.T) {
+	t.Log(`Here we request the root nodes of the account trie and of a storage trie.
+The server should respond with both nodes.`)
+
+	var contract *state.DumpAccount
+	for _, account := range s.chain.AccountsInHashOrder() {
+		if len(account.Storage) > 0 {
+			contract = &account
+			break
+		}
+	}
+	if contract == nil {
+		t.Fatalf("test chain must contain a contract with storage")
+	}
+	tc := trieNodesTest{
+		root: s.chain.Head().Root(),
+		paths: []snap.TrieNodePathSet{
+			{[]byte{0}},
+			{contract.AddressHash, []byte{0}},
+		},
+		nBytes:    5000,
+		expHashes: []common.Hash{s.chain.Head().Root(), common.BytesToHash(contract.Root)},
+	}
+	if err := s.snapGetTrieNodes(t, &tc); err != nil {
+		t.Errorf("test failed: %v", err)
+	}
+}
@@ -0,0 +1,4 @@
+#!/bin/sh
+
+# Regenerates the ETC test chain in ./testdata-etc.
+env COREGETH_ETHTEST_GENERATE_ETC_CHAIN=on go test -run TestGenerateEtcChain .
@@ -36,9 +36,10 @@ import (
 // Suite represents a structure used to test a node's conformance
 // to the eth protocol.
 type Suite struct {
-	Dest   *enode.Node
-	chain  *Chain
-	engine *EngineClient
+	Dest     *enode.Node
+	chain    *Chain
+	engine   *EngineClient
+	newBlock *types.Block // Block extending the chain, announced by the ETC suite
 }
 
 // NewSuite creates and returns a new eth-test suite that can
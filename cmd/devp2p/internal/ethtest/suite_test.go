@@ -102,6 +102,34 @@ func TestSnapSuite(t *testing.T) {
 	}
 }
 
+func TestEtcSuite(t *testing.T) {
+	jwtPath, _, err := makeJWTSecret()
+	if err != nil {
+		t.Fatalf("could not make jwt secret: %v", err)
+	}
+	geth, err := runGeth("./testdata-etc", jwtPath)
+	if err != nil {
+		t.Fatalf("could not run geth: %v", err)
+	}
+	defer geth.Close()
+
+	suite, err := NewEtcSuite(geth.Server().Self(), "./testdata-etc")
+	if err != nil {
+		t.Fatalf("could not create new test suite: %v", err)
+	}
+	for _, test := range suite.EtcTests() {
+		t.Run(test.Name, func(t *testing.T) {
+			if test.Slow && testing.Short() {
+				t.Skipf("%s: skipping in -short mode", test.Name)
+			}
+			result := utesting.RunTests([]utesting.Test{{Name: test.Name, Fn: test.Fn}}, os.Stdout)
+			if result[0].Failed {
+				t.Fatal()
+			}
+		})
+	}
+}
+
 // runGeth creates and starts a geth node
 func runGeth(dir string, jwtPath string) (*node.Node, error) {
 	stack, err := node.New(&node.Config{
@@ -168,8 +196,11 @@ func setupGeth(stack *node.Node, dir string) error {
 	if err != nil {
 		return err
 	}
-	if err := catalyst.Register(stack, backend); err != nil {
-		return fmt.Errorf("failed to register catalyst service: %v", err)
+	// Proof-of-work chains (i.e. the ETC test chain) run without a beacon engine.
+	if chain.config.GetEthashTerminalTotalDifficulty() != nil {
+		if err := catalyst.Register(stack, backend); err != nil {
+			return fmt.Errorf("failed to register catalyst service: %v", err)
+		}
 	}
 	_, err = backend.BlockChain().InsertChain(chain.blocks[1:])
 	return err
@@ -38,6 +38,7 @@ var (
 			rlpxPingCommand,
 			rlpxEthTestCommand,
 			rlpxSnapTestCommand,
+			rlpxEtcTestCommand,
 		},
 	}
 	rlpxPingCommand = &cli.Command{
@@ -73,6 +74,18 @@ var (
 			testNodeEngineFlag,
 		},
 	}
+	rlpxEtcTestCommand = &cli.Command{
+		Name:      "etc-test",
+		Usage:     "Runs eth and snap protocol tests against an Ethereum Classic (proof-of-work) node",
+		ArgsUsage: "",
+		Action:    rlpxEtcTest,
+		Flags: []cli.Flag{
+			testPatternFlag,
+			testTAPFlag,
+			testChainDirFlag,
+			testNodeFlag,
+		},
+	}
 )
 
 func rlpxPing(ctx *cli.Context) error {
@@ -134,6 +147,27 @@ func rlpxSnapTest(ctx *cli.Context) error {
 	return runTests(ctx, suite.SnapTests())
 }
 
+// rlpxEtcTest runs the eth and snap protocol tests of proof-of-work chains.
+func rlpxEtcTest(ctx *cli.Context) error {
+	nodeStr := ctx.String(testNodeFlag.Name)
+	if nodeStr == "" {
+		exit(fmt.Errorf("missing -%s", testNodeFlag.Name))
+	}
+	node, err := parseNode(nodeStr)
+	if err != nil {
+		exit(err)
+	}
+	chainDir := ctx.String(testChainDirFlag.Name)
+	if chainDir == "" {
+		exit(fmt.Errorf("missing -%s", testChainDirFlag.Name))
+	}
+	suite, err := ethtest.NewEtcSuite(node, chainDir)
+	if err != nil {
+		exit(err)
+	}
+	return runTests(ctx, suite.EtcTests())
+}
+
 type testParams struct {
 	node      *enode.Node
 	engineAPI string
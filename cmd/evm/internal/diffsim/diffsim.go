@@ -0,0 +1,278 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+// Package diffsim simulates the reaction of the ethash difficulty adjustment
+// of a chain configuration to changes of the network hashrate.
+package diffsim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/confp/generic"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/urfave/cli/v2"
+)
+
+// timestampIterations is the number of rounds used to settle the timestamp of a
+// simulated block and the difficulty it is mined at, which depend on each other.
+const timestampIterations = 4
+
+// Point is a step of the hashrate time series.
+type Point struct {
+	Offset   uint64  // Seconds since the starting header from which the hashrate applies
+	Hashrate float64 // Network hashrate in hashes per second
+}
+
+// Schedule is a hashrate time series, ordered by offset.
+type Schedule []Point
+
+// At returns the hashrate effective at the given number of seconds since the
+// starting header.
+func (s Schedule) At(offset float64) float64 {
+	i := sort.Search(len(s), func(i int) bool { return float64(s[i].Offset) > offset })
+	if i == 0 {
+		return s[0].Hashrate
+	}
+	return s[i-1].Hashrate
+}
+
+// Sample is a simulated block.
+type Sample struct {
+	Number     uint64   `json:"number"`
+	Timestamp  uint64   `json:"timestamp"`
+	BlockTime  uint64   `json:"blockTime"`
+	Difficulty *big.Int `json:"difficulty"`
+	Hashrate   float64  `json:"hashrate"`
+}
+
+// Simulate mines the given number of blocks on top of parent at the hashrates of
+// the schedule, computing difficulties with the consensus rules of config. If rng
+// is nil, each block takes exactly its expected time, otherwise block times are
+// drawn from an exponential distribution.
+func Simulate(config ctypes.ChainConfigurator, parent *types.Header, schedule Schedule, blocks uint64, rng *rand.Rand) []Sample {
+	var (
+		start   = parent.Time
+		elapsed float64 // Precise seconds since the starting header
+		samples = make([]Sample, 0, blocks)
+	)
+	for i := uint64(0); i < blocks; i++ {
+		hashrate := schedule.At(elapsed)
+		luck := 1.0
+		if rng != nil {
+			luck = rng.ExpFloat64()
+		}
+		// The difficulty depends on the timestamp, which in turn depends on
+		// how long it takes to mine a block at that difficulty. Iterate a few
+		// rounds starting from the parent difficulty to settle both.
+		var (
+			difficulty = parent.Difficulty
+			interval   float64
+			timestamp  uint64
+		)
+		for round := 0; round < timestampIterations; round++ {
+			d, _ := new(big.Float).SetInt(difficulty).Float64()
+			interval = luck * d / hashrate
+			timestamp = start + uint64(elapsed+interval)
+			if timestamp <= parent.Time {
+				timestamp = parent.Time + 1
+			}
+			difficulty = ethash.CalcDifficulty(config, timestamp, parent)
+		}
+		elapsed = max(elapsed+interval, float64(timestamp-start))
+
+		header := &types.Header{
+			Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+			Time:       timestamp,
+			Difficulty: difficulty,
+			UncleHash:  types.EmptyUncleHash,
+		}
+		samples = append(samples, Sample{
+			Number:     header.Number.Uint64(),
+			Timestamp:  header.Time,
+			BlockTime:  header.Time - parent.Time,
+			Difficulty: header.Difficulty,
+			Hashrate:   hashrate,
+		})
+		parent = header
+	}
+	return samples
+}
+
+// Run is the entry point of the difficulty simulator command.
+func Run(ctx *cli.Context) error {
+	config, err := loadChainConfig(ctx.String(ChainFlag.Name))
+	if err != nil {
+		return err
+	}
+	parent, err := loadHeader(ctx)
+	if err != nil {
+		return err
+	}
+	if !ctx.IsSet(HashrateFlag.Name) {
+		return errors.New("hashrate time series not specified")
+	}
+	f, err := os.Open(ctx.String(HashrateFlag.Name))
+	if err != nil {
+		return err
+	}
+	schedule, err := ReadSchedule(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	var rng *rand.Rand
+	if ctx.IsSet(SeedFlag.Name) {
+		rng = rand.New(rand.NewSource(ctx.Int64(SeedFlag.Name)))
+	}
+	samples := Simulate(config, parent, schedule, ctx.Uint64(BlocksFlag.Name), rng)
+
+	switch ctx.String(OutputFlag.Name) {
+	case "csv":
+		return WriteCSV(os.Stdout, samples)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(samples)
+	default:
+		return fmt.Errorf("unknown output format %q", ctx.String(OutputFlag.Name))
+	}
+}
+
+// loadChainConfig resolves a built-in network by name or reads a genesis or bare
+// chain configuration from the given file.
+func loadChainConfig(name string) (ctypes.ChainConfigurator, error) {
+	switch name {
+	case "classic":
+		return params.ClassicChainConfig, nil
+	case "mordor":
+		return params.MordorChainConfig, nil
+	case "mainnet":
+		return params.MainnetChainConfig, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var genesis struct {
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("invalid chain configuration file: %v", err)
+	}
+	if len(genesis.Config) > 0 {
+		data = genesis.Config
+	}
+	config, err := generic.UnmarshalChainConfigurator(data)
+	if err != nil {
+		return nil, err
+	}
+	if config.GetConsensusEngineType() != ctypes.ConsensusEngineT_Ethash {
+		return nil, fmt.Errorf("unsupported consensus engine %v", config.GetConsensusEngineType())
+	}
+	return config, nil
+}
+
+// loadHeader reads the starting header from a file, or assembles it from the
+// individual header flags.
+func loadHeader(ctx *cli.Context) (*types.Header, error) {
+	if ctx.IsSet(HeaderFlag.Name) {
+		data, err := os.ReadFile(ctx.String(HeaderFlag.Name))
+		if err != nil {
+			return nil, err
+		}
+		header := new(types.Header)
+		if err := json.Unmarshal(data, header); err != nil {
+			return nil, fmt.Errorf("invalid header file: %v", err)
+		}
+		return header, nil
+	}
+	difficulty, ok := new(big.Int).SetString(ctx.String(DifficultyFlag.Name), 0)
+	if !ok || difficulty.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid starting difficulty %q", ctx.String(DifficultyFlag.Name))
+	}
+	return &types.Header{
+		Number:     new(big.Int).SetUint64(ctx.Uint64(NumberFlag.Name)),
+		Time:       ctx.Uint64(TimestampFlag.Name),
+		Difficulty: difficulty,
+		UncleHash:  types.EmptyUncleHash,
+	}, nil
+}
+
+// ReadSchedule parses a hashrate time series from CSV, ignoring empty lines,
+// comments and a leading header row.
+func ReadSchedule(r io.Reader) (Schedule, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var schedule Schedule
+	for i, record := range records {
+		offset, err := strconv.ParseUint(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			if i == 0 {
+				continue // header row
+			}
+			return nil, fmt.Errorf("line %d: invalid offset: %v", i+1, err)
+		}
+		hashrate, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil || hashrate <= 0 {
+			return nil, fmt.Errorf("line %d: invalid hashrate %q", i+1, record[1])
+		}
+		if n := len(schedule); n > 0 && schedule[n-1].Offset >= offset {
+			return nil, fmt.Errorf("line %d: offsets not increasing", i+1)
+		}
+		schedule = append(schedule, Point{Offset: offset, Hashrate: hashrate})
+	}
+	if len(schedule) == 0 {
+		return nil, errors.New("empty hashrate time series")
+	}
+	return schedule, nil
+}
+
+// WriteCSV writes the simulated series as CSV, preceded by a header row.
+func WriteCSV(w io.Writer, samples []Sample) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"number", "timestamp", "blocktime", "difficulty", "hashrate"})
+	for _, s := range samples {
+		out.Write([]string{
+			strconv.FormatUint(s.Number, 10),
+			strconv.FormatUint(s.Timestamp, 10),
+			strconv.FormatUint(s.BlockTime, 10),
+			s.Difficulty.String(),
+			strconv.FormatFloat(s.Hashrate, 'g', -1, 64),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package diffsim
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestReadSchedule(t *testing.T) {
+	schedule, err := ReadSchedule(strings.NewReader("seconds,hashrate\n# steady\n0, 1e12\n\n600,5e11\n"))
+	if err != nil {
+		t.Fatalf("failed to read schedule: %v", err)
+	}
+	want := Schedule{{0, 1e12}, {600, 5e11}}
+	if len(schedule) != len(want) || schedule[0] != want[0] || schedule[1] != want[1] {
+		t.Fatalf("schedule mismatch: have %v, want %v", schedule, want)
+	}
+	for offset, rate := range map[float64]float64{0: 1e12, 599.9: 1e12, 600: 5e11, 1e6: 5e11} {
+		if have := schedule.At(offset); have != rate {
+			t.Errorf("hashrate at %v mismatch: have %v, want %v", offset, have, rate)
+		}
+	}
+	for _, input := range []string{"", "0,1e12\n0,2e12\n", "0,-1\n", "0,1e12\nx,1\n"} {
+		if _, err := ReadSchedule(strings.NewReader(input)); err == nil {
+			t.Errorf("invalid schedule %q accepted", input)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	parent := &types.Header{
+		Number:     big.NewInt(20_000_000),
+		Time:       1_700_000_000,
+		Difficulty: big.NewInt(13_000_000_000_000),
+		UncleHash:  types.EmptyUncleHash,
+	}
+	// Halve the hashrate after a while; difficulty must drop and block
+	// times must grow until the adjustment settles.
+	schedule := Schedule{{0, 1e12}, {13_000, 5e11}}
+	samples := Simulate(params.ClassicChainConfig, parent, schedule, 3000, nil)
+	if len(samples) != 3000 {
+		t.Fatalf("sample count mismatch: have %d, want 3000", len(samples))
+	}
+	prev := parent
+	for i, s := range samples {
+		if s.Number != prev.Number.Uint64()+1 || s.Timestamp <= prev.Time {
+			t.Fatalf("sample %d not chained to its parent: %+v", i, s)
+		}
+		// Every difficulty must be the one the consensus rules mandate.
+		if want := ethash.CalcDifficulty(params.ClassicChainConfig, s.Timestamp, prev); want.Cmp(s.Difficulty) != 0 {
+			t.Fatalf("sample %d difficulty mismatch: have %v, want %v", i, s.Difficulty, want)
+		}
+		prev = &types.Header{Number: new(big.Int).SetUint64(s.Number), Time: s.Timestamp, Difficulty: s.Difficulty, UncleHash: types.EmptyUncleHash}
+	}
+	if first, last := samples[0], samples[len(samples)-1]; last.Difficulty.Cmp(first.Difficulty) >= 0 {
+		t.Errorf("difficulty did not drop after hashrate loss: first %v, last %v", first.Difficulty, last.Difficulty)
+	}
+	if last := samples[len(samples)-1]; last.Hashrate != 5e11 || last.BlockTime < 9 || last.BlockTime > 17 {
+		t.Errorf("block time not settled within the adjustment band: %+v", last)
+	}
+	// Seeded simulations must be reproducible.
+	a := Simulate(params.ClassicChainConfig, parent, schedule, 100, rand.New(rand.NewSource(1)))
+	b := Simulate(params.ClassicChainConfig, parent, schedule, 100, rand.New(rand.NewSource(1)))
+	var bufA, bufB bytes.Buffer
+	if err := WriteCSV(&bufA, a); err != nil {
+		t.Fatal(err)
+	}
+	WriteCSV(&bufB, b)
+	if bufA.String() != bufB.String() {
+		t.Errorf("seeded simulations differ")
+	}
+	if lines := strings.Count(bufA.String(), "\n"); lines != 101 {
+		t.Errorf("csv line count mismatch: have %d, want 101", lines)
+	}
+}
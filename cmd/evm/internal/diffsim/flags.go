@@ -0,0 +1,65 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package diffsim
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	ChainFlag = &cli.StringFlag{
+		Name: "chain",
+		Usage: "Chain configuration to simulate. Either the name of a built-in network\n" +
+			"\t(classic, mordor, mainnet) or a genesis or chain configuration JSON file",
+		Value: "classic",
+	}
+	HeaderFlag = &cli.StringFlag{
+		Name:  "header",
+		Usage: "JSON file containing the starting header, e.g. as returned by eth_getBlockByNumber",
+	}
+	NumberFlag = &cli.Uint64Flag{
+		Name:  "number",
+		Usage: "Number of the starting header, if no header file is given",
+	}
+	DifficultyFlag = &cli.StringFlag{
+		Name:  "difficulty",
+		Usage: "Difficulty of the starting header, if no header file is given",
+	}
+	TimestampFlag = &cli.Uint64Flag{
+		Name:  "timestamp",
+		Usage: "Timestamp of the starting header, if no header file is given",
+	}
+	HashrateFlag = &cli.StringFlag{
+		Name: "hashrate",
+		Usage: "CSV file of the hashrate time series. Each line holds the number of seconds\n" +
+			"\tsince the starting header and the hashrate (H/s) effective from that moment on",
+	}
+	BlocksFlag = &cli.Uint64Flag{
+		Name:  "blocks",
+		Usage: "Number of blocks to simulate",
+		Value: 10000,
+	}
+	SeedFlag = &cli.Int64Flag{
+		Name:  "seed",
+		Usage: "Seed for sampling exponentially distributed block times. If unset, every block takes its expected time",
+	}
+	OutputFlag = &cli.StringFlag{
+		Name:  "output",
+		Usage: "Output format of the simulated series (csv or json)",
+		Value: "csv",
+	}
+)
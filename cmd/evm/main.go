@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"os"
 
+	"github.com/ethereum/go-ethereum/cmd/evm/internal/diffsim"
 	"github.com/ethereum/go-ethereum/cmd/evm/internal/t8ntool"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/internal/debug"
@@ -201,6 +202,24 @@ var blockBuilderCommand = &cli.Command{
 	},
 }
 
+var difficultySimulatorCommand = &cli.Command{
+	Name:    "difficulty-simulator",
+	Aliases: []string{"diffsim"},
+	Usage:   "Simulates difficulty and block times of an ethash chain under a hashrate time series",
+	Action:  diffsim.Run,
+	Flags: []cli.Flag{
+		diffsim.ChainFlag,
+		diffsim.HeaderFlag,
+		diffsim.NumberFlag,
+		diffsim.DifficultyFlag,
+		diffsim.TimestampFlag,
+		diffsim.HashrateFlag,
+		diffsim.BlocksFlag,
+		diffsim.SeedFlag,
+		diffsim.OutputFlag,
+	},
+}
+
 // vmFlags contains flags related to running the EVM.
 var vmFlags = []cli.Flag{
 	CodeFlag,
@@ -243,6 +262,8 @@ func init() {
 		stateTransitionCommand,
 		transactionCommand,
 		blockBuilderCommand,
+		difficultySimulatorCommand,
+		statelessCommand,
 	}
 	app.Before = func(ctx *cli.Context) error {
 		flags.MigrateGlobalFlags(ctx)
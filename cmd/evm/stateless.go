@@ -0,0 +1,177 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/confp/generic"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/urfave/cli/v2"
+)
+
+var StatelessChainFlag = &cli.StringFlag{
+	Name: "chain",
+	Usage: "Chain configuration of the block. Either the name of a built-in network\n" +
+		"\t(classic, mordor, mainnet) or a genesis or chain configuration JSON file",
+	Value: "classic",
+}
+
+var statelessCommand = &cli.Command{
+	Action:    statelessCmd,
+	Name:      "stateless",
+	Usage:     "Verifies a block by executing it solely from its witness and parent header",
+	ArgsUsage: "<block> <witness>",
+	Description: `
+The block file contains the RLP encoded block, either binary or hex (as returned by
+debug_getRawBlock). The witness file contains the block witness, either JSON (as
+returned by debug_executionWitness) or RLP encoded. The parent header is the first
+header of the witness.`,
+	Flags: []cli.Flag{StatelessChainFlag},
+}
+
+// statelessResult is the verification report of a stateless block execution.
+type statelessResult struct {
+	Number      uint64      `json:"number"`
+	Hash        common.Hash `json:"hash"`
+	StateRoot   common.Hash `json:"stateRoot"`
+	ReceiptRoot common.Hash `json:"receiptsRoot"`
+	Valid       bool        `json:"valid"`
+	Error       string      `json:"error,omitempty"`
+}
+
+func statelessCmd(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		return errors.New("block and witness file arguments required")
+	}
+	config, err := loadStatelessChainConfig(ctx.String(StatelessChainFlag.Name))
+	if err != nil {
+		return err
+	}
+	block, err := loadStatelessBlock(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	witness, err := loadStatelessWitness(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	result := &statelessResult{Number: block.NumberU64(), Hash: block.Hash()}
+
+	stateRoot, receiptRoot, err := core.ExecuteStateless(config, ethash.NewFaker(), vm.Config{}, block, witness)
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case stateRoot != block.Root():
+		result.Error = fmt.Sprintf("state root mismatch: have %x, want %x", stateRoot, block.Root())
+	case receiptRoot != block.ReceiptHash():
+		result.Error = fmt.Sprintf("receipt root mismatch: have %x, want %x", receiptRoot, block.ReceiptHash())
+	default:
+		result.Valid = true
+	}
+	result.StateRoot, result.ReceiptRoot = stateRoot, receiptRoot
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+	if !result.Valid {
+		return errors.New("block verification failed")
+	}
+	return nil
+}
+
+// loadStatelessChainConfig resolves a built-in network name or reads the chain
+// configuration from a genesis or chain configuration file.
+func loadStatelessChainConfig(name string) (ctypes.ChainConfigurator, error) {
+	switch name {
+	case "classic":
+		return params.ClassicChainConfig, nil
+	case "mordor":
+		return params.MordorChainConfig, nil
+	case "mainnet":
+		return params.MainnetChainConfig, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var genesis struct {
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("invalid chain configuration file: %v", err)
+	}
+	if len(genesis.Config) > 0 {
+		data = genesis.Config
+	}
+	config, err := generic.UnmarshalChainConfigurator(data)
+	if err != nil {
+		return nil, err
+	}
+	if config.GetConsensusEngineType() != ctypes.ConsensusEngineT_Ethash {
+		return nil, fmt.Errorf("unsupported consensus engine %v", config.GetConsensusEngineType())
+	}
+	return config, nil
+}
+
+// loadStatelessBlock reads an RLP encoded block, in binary or hex form.
+func loadStatelessBlock(path string) (*types.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if text := bytes.TrimSpace(data); bytes.HasPrefix(text, []byte("0x")) || bytes.HasPrefix(text, []byte(`"0x`)) {
+		if data, err = hexutil.Decode(string(bytes.Trim(text, `"`))); err != nil {
+			return nil, fmt.Errorf("invalid block hex: %v", err)
+		}
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(data, block); err != nil {
+		return nil, fmt.Errorf("invalid block: %v", err)
+	}
+	return block, nil
+}
+
+// loadStatelessWitness reads a block witness, in JSON or RLP form.
+func loadStatelessWitness(path string) (*stateless.Witness, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	witness := new(stateless.Witness)
+	if text := bytes.TrimSpace(data); len(text) > 0 && text[0] == '{' {
+		err = json.Unmarshal(text, witness)
+	} else {
+		err = rlp.DecodeBytes(data, witness)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid witness: %v", err)
+	}
+	return witness, nil
+}
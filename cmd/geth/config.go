@@ -185,6 +185,27 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 			cfg.Eth.OverrideECBP1100Deactivate = &n
 		}
 	}
+	if ctx.IsSet(utils.ECBP1100MinPeersFlag.Name) || ctx.IsSet(utils.ECBP1100MinSubnetsFlag.Name) ||
+		ctx.IsSet(utils.ECBP1100MinClientsFlag.Name) || ctx.IsSet(utils.ECBP1100StaleIntervalFlag.Name) {
+		// Flags override single conditions of the configured, or default ones
+		safety := ethconfig.DefaultArtificialFinalitySafety
+		if cfg.Eth.ECBP1100Safety != nil {
+			safety = *cfg.Eth.ECBP1100Safety
+		}
+		if ctx.IsSet(utils.ECBP1100MinPeersFlag.Name) {
+			safety.MinPeers = ctx.Int(utils.ECBP1100MinPeersFlag.Name)
+		}
+		if ctx.IsSet(utils.ECBP1100MinSubnetsFlag.Name) {
+			safety.MinSubnets = ctx.Int(utils.ECBP1100MinSubnetsFlag.Name)
+		}
+		if ctx.IsSet(utils.ECBP1100MinClientsFlag.Name) {
+			safety.MinClients = ctx.Int(utils.ECBP1100MinClientsFlag.Name)
+		}
+		if ctx.IsSet(utils.ECBP1100StaleIntervalFlag.Name) {
+			safety.StaleInterval = ctx.Duration(utils.ECBP1100StaleIntervalFlag.Name)
+		}
+		cfg.Eth.ECBP1100Safety = &safety
+	}
 	if ctx.IsSet(utils.OverrideShanghai.Name) {
 		v := ctx.Uint64(utils.OverrideShanghai.Name)
 		cfg.Eth.OverrideShanghai = &v
@@ -215,9 +236,17 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 		})
 	}
 
+	// Reload the chain configuration file when asked to by the operator.
+	if eth != nil && cfg.Eth.ChainConfigFile != "" {
+		utils.ReloadChainConfigOnHangup(eth)
+	}
+
 	// Configure log filter RPC API.
 	filterSystem := utils.RegisterFilterAPI(stack, backend, &cfg.Eth)
 
+	// Host any additional networks requested alongside the primary one.
+	utils.RegisterExtraNetworks(ctx, stack, &cfg.Eth)
+
 	// Configure GraphQL if requested.
 	if ctx.IsSet(utils.GraphQLEnabledFlag.Name) {
 		utils.RegisterGraphQLService(stack, backend, filterSystem, &cfg.Node)
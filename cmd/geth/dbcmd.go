@@ -79,6 +79,10 @@ Remove blockchain and state databases`,
 			dbExportCmd,
 			dbMetadataCmd,
 			dbCheckStateContentCmd,
+			dbVerifyFreezerCmd,
+			dbOffloadHistoryCmd,
+			dbMigrateCmd,
+			dbConvertStateCmd,
 		},
 	}
 	dbInspectCmd = &cli.Command{
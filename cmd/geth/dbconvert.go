@@ -0,0 +1,55 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/urfave/cli/v2"
+)
+
+var dbConvertStateCmd = &cli.Command{
+	Action: dbConvertState,
+	Name:   "convert-state",
+	Usage:  "Convert the state from the hash-based scheme to the path-based scheme",
+	Flags: flags.Merge([]cli.Flag{
+		utils.CacheFlag,
+		utils.CacheDatabaseFlag,
+	}, utils.NetworkFlags, utils.DatabaseFlags),
+	Description: `This command converts the head state of a node running the hash-based state
+scheme into the path-based scheme, so that the node can switch over without a resync.
+
+The trie nodes are rebuilt in the path-based layout from the snapshot if available
+or from the hash-based tries otherwise. The converted state is verified against the
+state root before the path-based metadata and journal are written, then the legacy
+trie nodes are deleted. All historical states are dropped in the process.
+
+The conversion is resumable: if it's interrupted, running the command again picks
+it up at the last checkpoint. The node must not be running during the conversion.`,
+}
+
+// dbConvertState converts the state of the chain database to the path scheme.
+func dbConvertState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	return pruner.ConvertToPathScheme(db)
+}
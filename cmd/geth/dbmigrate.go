@@ -0,0 +1,67 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	migrateToFlag = &cli.StringFlag{
+		Name:  "to",
+		Usage: "Database engine to migrate the key-value store to ('leveldb' or 'pebble')",
+		Value: "pebble",
+	}
+	migrateKeepOldFlag = &cli.BoolFlag{
+		Name:  "keep-old",
+		Usage: "Keep the old key-value store next to the migrated one instead of deleting it",
+	}
+	dbMigrateCmd = &cli.Command{
+		Action: dbMigrate,
+		Name:   "migrate",
+		Usage:  "Migrate the key-value store to a different database engine",
+		Flags: flags.Merge([]cli.Flag{
+			migrateToFlag,
+			migrateKeepOldFlag,
+			utils.CacheFlag,
+			utils.CacheDatabaseFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command streams every key of the key-value store into a new store of the
+engine given by --to, verifies the key counts and a sample of the values, and then
+atomically swaps the new store in place of the old one. The freezer is kept as is.
+
+The migration is resumable: if it's interrupted, running the command again picks it
+up where it left off. The node must not be running during the migration.`,
+	}
+)
+
+// dbMigrate migrates the chain database into a different key-value store engine.
+func dbMigrate(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	return rawdb.MigrateDatabase(rawdb.OpenOptions{
+		Type:              ctx.String(migrateToFlag.Name),
+		Directory:         stack.ResolvePath("chaindata"),
+		AncientsDirectory: stack.ResolveAncient("chaindata", ctx.String(utils.AncientFlag.Name)),
+		Cache:             ctx.Int(utils.CacheFlag.Name) * ctx.Int(utils.CacheDatabaseFlag.Name) / 100,
+		Handles:           utils.MakeDatabaseHandles(ctx.Int(utils.FDLimitFlag.Name)),
+	}, ctx.Bool(migrateKeepOldFlag.Name))
+}
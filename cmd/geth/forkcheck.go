@@ -0,0 +1,181 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/forkcheck"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/urfave/cli/v2"
+)
+
+var forkCheckCommand = &cli.Command{
+	Action:    forkCheck,
+	Name:      "forkcheck",
+	Usage:     "Report the readiness of the node for upcoming forks",
+	ArgsUsage: "[endpoint]",
+	Flags:     flags.Merge(utils.DatabaseFlags, utils.NetworkFlags),
+	Description: `
+The forkcheck command lists the upcoming transitions of the configured chain,
+the local and next fork IDs and the estimated time to activation of each fork.
+It warns if the chain configuration stored in the database differs from the one
+built into this binary.
+
+If the node is running, the report is retrieved from it over the given endpoint
+(or the IPC endpoint of the datadir) and includes the share of connected peers
+advertising a compatible fork ID. Otherwise it is assembled from the database.`,
+}
+
+// forkCheck prints a fork readiness report, retrieved from a running node if
+// one is reachable or assembled from the database otherwise.
+func forkCheck(ctx *cli.Context) error {
+	if ctx.Args().Len() > 1 {
+		utils.Fatalf("invalid command-line: too many arguments")
+	}
+	endpoint := ctx.Args().First()
+	if endpoint == "" {
+		cfg := defaultNodeConfig()
+		utils.SetDataDir(ctx, &cfg)
+		if ipc := cfg.IPCEndpoint(); ipc != "" {
+			if _, err := os.Stat(ipc); err == nil {
+				endpoint = ipc
+			}
+		}
+	}
+	var report *forkcheck.Report
+	if endpoint != "" {
+		client, err := utils.DialRPCWithHeaders(endpoint, ctx.StringSlice(utils.HttpHeaderFlag.Name))
+		if err != nil {
+			utils.Fatalf("Unable to attach to remote geth: %v", err)
+		}
+		defer client.Close()
+
+		if err := client.Call(&report, "admin_forkCheck"); err != nil {
+			return err
+		}
+	} else {
+		stack, _ := makeConfigNode(ctx)
+		defer stack.Close()
+
+		db := utils.MakeChainDatabase(ctx, stack, true)
+		defer db.Close()
+
+		chain, err := newDatabaseChain(db)
+		if err != nil {
+			return err
+		}
+		var builtin ctypes.ChainConfigurator
+		if utils.IsNetworkPreset(ctx) {
+			builtin = utils.MakeGenesis(ctx).Config
+		} else {
+			builtin = forkcheck.BuiltinConfig(chain.genesis.Hash(), chain.config.GetChainID())
+		}
+		report = forkcheck.New(chain, chain.config, builtin)
+	}
+	printForkCheck(os.Stdout, report)
+	return nil
+}
+
+// printForkCheck writes the report in a human readable form.
+func printForkCheck(w io.Writer, report *forkcheck.Report) {
+	fmt.Fprintf(w, "Head block:     %d (%s)\n", report.Head, time.Unix(int64(report.HeadTime), 0).UTC().Format(time.RFC3339))
+	if report.BlockTime > 0 {
+		fmt.Fprintf(w, "Block time:     %.2fs\n", report.BlockTime)
+	}
+	fmt.Fprintf(w, "Fork ID:        %s, next %d\n", report.ForkID.Hash, report.ForkID.Next)
+	if report.NextForkID != nil {
+		fmt.Fprintf(w, "Next fork ID:   %s, next %d\n", report.NextForkID.Hash, report.NextForkID.Next)
+	}
+	if report.Peers != nil {
+		fmt.Fprintf(w, "Peers:          %d connected, %d compatible, %d announcing the next fork\n", report.Peers.Total, report.Peers.Compatible, report.Peers.Ready)
+	}
+	if len(report.Upcoming) == 0 {
+		fmt.Fprintln(w, "\nNo upcoming transitions scheduled.")
+	} else {
+		fmt.Fprintln(w, "\nUpcoming transitions:")
+		for _, t := range report.Upcoming {
+			var at, remaining string
+			if t.Block != nil {
+				at, remaining = fmt.Sprintf("block %d", *t.Block), fmt.Sprintf("%d blocks", t.Remaining)
+			} else {
+				at, remaining = fmt.Sprintf("time %d", *t.Time), common.PrettyDuration(time.Duration(t.Remaining)*time.Second).String()
+			}
+			var estimate string
+			if t.Estimate != nil {
+				estimate = ", around " + t.Estimate.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "  %-16s in %s%s: %s\n", at, remaining, estimate, strings.Join(t.Names, ", "))
+		}
+	}
+	if report.ConfigMismatch != "" {
+		fmt.Fprintf(w, "\nWARNING: the stored chain configuration differs from the built-in one: %s\n", report.ConfigMismatch)
+	}
+}
+
+// databaseChain gives access to the chain stored in a database for assembling
+// reports without a running node.
+type databaseChain struct {
+	db      ethdb.Database
+	config  ctypes.ChainConfigurator
+	genesis *types.Block
+}
+
+func newDatabaseChain(db ethdb.Database) (*databaseChain, error) {
+	hash := rawdb.ReadCanonicalHash(db, 0)
+	if hash == (common.Hash{}) {
+		return nil, errors.New("database not initialized")
+	}
+	genesis := rawdb.ReadBlock(db, hash, 0)
+	if genesis == nil {
+		return nil, errors.New("genesis block missing")
+	}
+	config := rawdb.ReadChainConfig(db, hash)
+	if config == nil {
+		return nil, errors.New("chain configuration missing")
+	}
+	return &databaseChain{db: db, config: config, genesis: genesis}, nil
+}
+
+func (c *databaseChain) Config() ctypes.ChainConfigurator { return c.config }
+func (c *databaseChain) Genesis() *types.Block            { return c.genesis }
+
+func (c *databaseChain) CurrentHeader() *types.Header {
+	if head := rawdb.ReadHeadHeader(c.db); head != nil {
+		return head
+	}
+	return c.genesis.Header()
+}
+
+func (c *databaseChain) GetHeaderByNumber(number uint64) *types.Header {
+	hash := rawdb.ReadCanonicalHash(c.db, number)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return rawdb.ReadHeader(c.db, hash, number)
+}
@@ -90,10 +90,19 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPolicyTrustedOnlyFlag,
+		utils.TxPolicyLocalDelayFlag,
+		utils.TxPolicyInvalidLimitFlag,
+		utils.TxPolicyPeerBandwidthFlag,
 		utils.BlobPoolDataDirFlag,
 		utils.BlobPoolDataCapFlag,
 		utils.BlobPoolPriceBumpFlag,
 		utils.SyncModeFlag,
+		utils.SyncHeaderFirstFlag,
+		utils.CheckpointFlag,
+		utils.CheckpointListFlag,
+		utils.CheckpointSignersFlag,
+		utils.CheckpointThresholdFlag,
 		utils.SyncTargetFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
@@ -101,6 +110,7 @@ var (
 		utils.TxLookupLimitFlag, // deprecated
 		utils.TransactionHistoryFlag,
 		utils.StateHistoryFlag,
+		utils.ReceiptHistoryFlag,
 		utils.LightServeFlag,    // deprecated
 		utils.LightIngressFlag,  // deprecated
 		utils.LightEgressFlag,   // deprecated
@@ -149,7 +159,15 @@ var (
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DNSDiscoveryFlag,
+		utils.DNSTreeDomainFlag,
+		utils.DNSTreeKeyFlag,
+		utils.DNSTreeListenFlag,
+		utils.DNSTreeZoneFileFlag,
+		utils.DNSTreeLinksFlag,
+		utils.DNSTreeIntervalFlag,
+		utils.DNSTreeTTLFlag,
 		utils.EthProtocolsFlag,
+		utils.ExtraNetworksFlag,
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
 		utils.DeveloperPoWFlag,
@@ -170,6 +188,11 @@ var (
 		utils.ECBP1100Flag,
 		utils.ECBP1100NoDisableFlag,
 		utils.OverrideECBP1100DeactivateFlag,
+		utils.ECBP1100MinPeersFlag,
+		utils.ECBP1100MinSubnetsFlag,
+		utils.ECBP1100MinClientsFlag,
+		utils.ECBP1100StaleIntervalFlag,
+		utils.ChainConfigFileFlag,
 		configFileFlag,
 		utils.LogDebugFlag,
 		utils.LogBacktraceAtFlag,
@@ -202,6 +225,9 @@ var (
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalEVMTimeoutFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCResultCacheFlag,
+		utils.RPCResultCacheDepthFlag,
+		utils.RPCResultCacheDiskFlag,
 		utils.AllowUnprotectedTxs,
 		utils.BatchRequestLimit,
 		utils.BatchResponseMaxSize,
@@ -264,6 +290,8 @@ func init() {
 		snapshotCommand,
 		// See verkle.go
 		verkleCommand,
+		// See forkcheck.go
+		forkCheckCommand,
 	}
 	if logTestCommand != nil {
 		app.Commands = append(app.Commands, logTestCommand)
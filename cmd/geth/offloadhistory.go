@@ -0,0 +1,84 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/internal/era"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+var dbOffloadHistoryCmd = &cli.Command{
+	Action:    offloadHistory,
+	Name:      "offload-history",
+	Usage:     "Move old chain segments from the ancient store into era1 files",
+	ArgsUsage: "<block>",
+	Flags:     flags.Merge(utils.NetworkFlags, utils.DatabaseFlags),
+	Description: fmt.Sprintf(`This command moves the headers, bodies, receipts and total difficulties of
+the blocks below the given number from the ancient store into era1 files in the
+directory set by --%s, which may be a mounted remote storage.
+
+Only complete epochs of %d blocks are moved. The node serves the offloaded chain
+segments from the era1 files transparently, as long as it's started with the
+same --%s directory.`, utils.EraFlag.Name, era.MaxEra1Size, utils.EraFlag.Name),
+}
+
+// offloadHistory moves the chain segments below the given block into era1 files.
+func offloadHistory(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	before, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	dir := stack.Config().AncientEraDir
+	if dir == "" {
+		return fmt.Errorf("the era1 directory must be set with --%s", utils.EraFlag.Name)
+	}
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	genesis := rawdb.ReadCanonicalHash(db, 0)
+	config := rawdb.ReadChainConfig(db, genesis)
+	if config == nil {
+		return errors.New("chain config not found")
+	}
+	network := "unknown"
+	if id := config.GetChainID(); id != nil {
+		if name, ok := params.NetworkNames[id.String()]; ok {
+			network = name
+		}
+	}
+	offloaded, err := rawdb.OffloadChainHistory(db, dir, network, before)
+	if err != nil {
+		return err
+	}
+	log.Info("Chain history offloaded", "dir", dir, "blocks", offloaded)
+	return nil
+}
@@ -17,15 +17,21 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/state/pruner"
@@ -34,12 +40,33 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/olekukonko/tablewriter"
 	cli "github.com/urfave/cli/v2"
 )
 
 var (
+	importStateHashFlag = &cli.StringFlag{
+		Name:  "hash",
+		Usage: "Hash of the trusted block the imported state must belong to",
+	}
+	storageStatsBaseFlag = &cli.StringFlag{
+		Name:  "base",
+		Usage: "Root of an older state to compute the storage growth against",
+	}
+	storageStatsTopFlag = &cli.IntFlag{
+		Name:  "top",
+		Usage: "Number of accounts listed in each ranking",
+		Value: 20,
+	}
+	storageStatsJSONFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the report as JSON instead of tables",
+	}
+
 	snapshotCommand = &cli.Command{
 		Name:        "snapshot",
 		Usage:       "A set of commands based on the snapshot",
@@ -159,6 +186,55 @@ block is used.
 				Description: `
 The export-preimages command exports hash preimages to a flat file, in exactly
 the expected order for the overlay tree migration.
+`,
+			},
+			{
+				Action:    snapshotExportState,
+				Name:      "export-state",
+				Usage:     "Export the flat state of the snapshot into a portable file",
+				ArgsUsage: "<dumpfile> [<root>]",
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `
+geth snapshot export-state <dumpfile> [<root>]
+exports the accounts, storage slots and contract codes of the state with the
+given root, or of the head state if the root is not specified, into a chunked
+and checksummed file along with the header of the block the state belongs to.
+The file is gzipped if its name ends with .gz.
+`,
+			},
+			{
+				Action:    snapshotImportState,
+				Name:      "import-state",
+				Usage:     "Import the state from a file written by export-state",
+				ArgsUsage: "<dumpfile>",
+				Flags: flags.Merge([]cli.Flag{
+					importStateHashFlag,
+				}, utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `
+geth snapshot import-state <dumpfile>
+imports the state exported by export-state into a fresh datadir. The state
+snapshot is written out, the state trie is regenerated from it in the configured
+state scheme and its root is verified against the block header in the file. The
+header can be pinned to a trusted block with --hash.
+`,
+			},
+			{
+				Action:    snapshotStorageStats,
+				Name:      "storage-stats",
+				Usage:     "Rank the accounts by storage slot count and size",
+				ArgsUsage: "[<root>]",
+				Flags: flags.Merge([]cli.Flag{
+					storageStatsBaseFlag,
+					storageStatsTopFlag,
+					storageStatsJSONFlag,
+				}, utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `
+geth snapshot storage-stats [<root>]
+traverses the state with the given root, or the head state if the root is not
+specified, and ranks the accounts by the number of storage slots and the size of
+their storage. If --base is given, the growth of every account since the base
+state is computed and ranked too. States are read from the snapshot, falling
+back to the tries if a state is not covered by it.
 `,
 			},
 		},
@@ -658,6 +734,144 @@ func snapshotExportPreimages(ctx *cli.Context) error {
 	return utils.ExportSnapshotPreimages(chaindb, snaptree, ctx.Args().First(), root)
 }
 
+// snapshotExportState exports the flat state of the snapshot at the given root
+// into a portable file.
+func snapshotExportState(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, true)
+	defer chaindb.Close()
+
+	triedb := utils.MakeTrieDatabase(ctx, chaindb, false, true, false)
+	defer triedb.Close()
+
+	header := rawdb.ReadHeadHeader(chaindb)
+	if header == nil {
+		return errors.New("no head header")
+	}
+	if ctx.NArg() > 1 {
+		root, err := parseRoot(ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("invalid root: %v", err)
+		}
+		// Find the canonical block the state belongs to
+		for header.Root != root {
+			if header.Number.Uint64() == 0 {
+				return fmt.Errorf("no canonical block with state root %x", root)
+			}
+			header = rawdb.ReadHeader(chaindb, header.ParentHash, header.Number.Uint64()-1)
+			if header == nil {
+				return errors.New("missing parent header")
+			}
+		}
+	}
+	snapConfig := snapshot.Config{
+		CacheSize:  256,
+		Recovery:   false,
+		NoBuild:    true,
+		AsyncBuild: false,
+	}
+	snaptree, err := snapshot.New(snapConfig, chaindb, triedb, header.Root)
+	if err != nil {
+		return err
+	}
+	block := rawdb.ReadBlock(chaindb, header.Hash(), header.Number.Uint64())
+	if block == nil {
+		return fmt.Errorf("missing block %d", header.Number)
+	}
+	td := rawdb.ReadTd(chaindb, header.Hash(), header.Number.Uint64())
+	if td == nil {
+		return fmt.Errorf("missing total difficulty of block %d", header.Number)
+	}
+	fn := ctx.Args().First()
+	log.Info("Exporting state", "number", header.Number, "root", header.Root, "file", fn)
+
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		gz := gzip.NewWriter(writer)
+		defer gz.Close()
+		writer = gz
+	}
+	buf := bufio.NewWriter(writer)
+	if err := snapshot.ExportState(buf, snaptree, chaindb, block, td); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// snapshotImportState imports the state from a file written by export-state.
+func snapshotImportState(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	defer chaindb.Close()
+
+	scheme, err := rawdb.ParseStateScheme(ctx.String(utils.StateSchemeFlag.Name), chaindb)
+	if err != nil {
+		return err
+	}
+	fn := ctx.Args().First()
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = bufio.NewReader(fh)
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	stream := rlp.NewStream(reader, 0)
+	block, td, err := snapshot.ReadStateExportHeader(stream)
+	if err != nil {
+		return err
+	}
+	if ctx.IsSet(importStateHashFlag.Name) {
+		hash, err := parseRoot(ctx.String(importStateHashFlag.Name))
+		if err != nil {
+			return fmt.Errorf("invalid hash: %v", err)
+		}
+		if block.Hash() != hash {
+			return fmt.Errorf("block hash mismatch: have %x, want %x", block.Hash(), hash)
+		}
+	}
+	if local := rawdb.ReadHeader(chaindb, rawdb.ReadCanonicalHash(chaindb, block.NumberU64()), block.NumberU64()); local != nil && local.Hash() != block.Hash() {
+		return fmt.Errorf("block %d mismatches the local chain: have %x, want %x", block.Number(), block.Hash(), local.Hash())
+	}
+	// The chain is opened on top of its genesis block, commit it first unless
+	// the database was initialized already. Its state is written in the hash
+	// scheme, which doesn't clash with a path-based import.
+	if rawdb.ReadCanonicalHash(chaindb, 0) == (common.Hash{}) {
+		genesis := utils.MakeGenesis(ctx)
+		if genesis == nil {
+			genesis = params.DefaultGenesisBlock()
+		}
+		tdb := triedb.NewDatabase(chaindb, triedb.HashDefaults)
+		defer tdb.Close()
+		if _, err := core.CommitGenesis(genesis, chaindb, tdb); err != nil {
+			return err
+		}
+	}
+	log.Info("Importing state", "number", block.Number(), "hash", block.Hash(), "root", block.Root(), "scheme", scheme)
+	return snapshot.ImportState(stream, chaindb, scheme, block, td)
+}
+
 // checkAccount iterates the snap data layers, and looks up the given account
 // across all layers.
 func checkAccount(ctx *cli.Context) error {
@@ -689,3 +903,120 @@ func checkAccount(ctx *cli.Context) error {
 	log.Info("Checked the snapshot journalled storage", "time", common.PrettyDuration(time.Since(start)))
 	return nil
 }
+
+// snapshotStorageStats ranks the accounts of a state by their storage footprint
+// and optionally by their storage growth since an older state.
+func snapshotStorageStats(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errors.New("too many arguments")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, true)
+	defer chaindb.Close()
+
+	triedb := utils.MakeTrieDatabase(ctx, chaindb, false, true, false)
+	defer triedb.Close()
+
+	header := rawdb.ReadHeadHeader(chaindb)
+	if header == nil {
+		return errors.New("no head header")
+	}
+	root := header.Root
+	if ctx.NArg() == 1 {
+		var err error
+		if root, err = parseRoot(ctx.Args().First()); err != nil {
+			return fmt.Errorf("invalid root: %v", err)
+		}
+	}
+	var base *common.Hash
+	if ctx.IsSet(storageStatsBaseFlag.Name) {
+		hash, err := parseRoot(ctx.String(storageStatsBaseFlag.Name))
+		if err != nil {
+			return fmt.Errorf("invalid base root: %v", err)
+		}
+		base = &hash
+	}
+	snapConfig := snapshot.Config{
+		CacheSize:  256,
+		Recovery:   false,
+		NoBuild:    true,
+		AsyncBuild: false,
+	}
+	snaptree, err := snapshot.New(snapConfig, chaindb, triedb, header.Root)
+	if err != nil {
+		log.Warn("Snapshot unavailable, reading the tries", "err", err)
+		snaptree = nil
+	}
+	var (
+		stats = snapshot.NewStorageStats(ctx.Int(storageStatsTopFlag.Name))
+		start = time.Now()
+		done  = make(chan struct{})
+	)
+	go func() {
+		ticker := time.NewTicker(8 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				position, accounts := stats.Progress()
+				log.Info("Collecting storage statistics", "accounts", accounts, "at", position, "elapsed", common.PrettyDuration(time.Since(start)))
+			case <-done:
+				return
+			}
+		}
+	}()
+	log.Info("Collecting storage statistics", "root", root, "base", base)
+	err = stats.Collect(snaptree, triedb, root, base, nil)
+	close(done)
+	if err != nil {
+		return err
+	}
+	_, accounts := stats.Progress()
+	log.Info("Collected storage statistics", "accounts", accounts, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	report := stats.Report(chaindb)
+	if ctx.Bool(storageStatsJSONFlag.Name) {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	fmt.Printf("State %x: %d contracts, %d slots, %v\n", report.Root, report.Contracts, report.Slots, common.StorageSize(report.Bytes))
+	if report.Base != nil {
+		fmt.Printf("Since %x: %+d slots, %+d bytes\n", *report.Base, report.SlotsDelta, report.BytesDelta)
+	}
+	printStorageStats("Accounts by slot count", report.BySlots, report.Base != nil)
+	printStorageStats("Accounts by storage size", report.ByBytes, report.Base != nil)
+	if report.Base != nil {
+		printStorageStats("Accounts by storage growth", report.ByGrowth, true)
+	}
+	return nil
+}
+
+// printStorageStats renders a ranking of accounts as a table.
+func printStorageStats(title string, stats []*snapshot.StorageStat, deltas bool) {
+	fmt.Printf("\n%s\n", title)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	header := []string{"#", "Account", "Slots", "Size"}
+	if deltas {
+		header = append(header, "Slots delta", "Size delta")
+	}
+	table.SetHeader(header)
+	for i, stat := range stats {
+		account := stat.Account.Hex()
+		if stat.Address != nil {
+			account = stat.Address.Hex()
+		}
+		row := []string{strconv.Itoa(i + 1), account, strconv.FormatUint(stat.Slots, 10), common.StorageSize(stat.Bytes).String()}
+		if deltas {
+			row = append(row, fmt.Sprintf("%+d", stat.SlotsDelta), fmt.Sprintf("%+d", stat.BytesDelta))
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
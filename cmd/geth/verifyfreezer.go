@@ -0,0 +1,416 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of core-geth.
+//
+// core-geth is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// core-geth is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with core-geth. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	verifyFreezerRefetchFlag = &cli.BoolFlag{
+		Name:  "refetch",
+		Usage: "Repair the corrupted chain items, re-fetching them from the network if needed",
+	}
+	verifyFreezerPeerTimeoutFlag = &cli.DurationFlag{
+		Name:  "refetch.timeout",
+		Usage: "Maximum time to wait for peers to re-fetch the corrupted items from",
+		Value: 5 * time.Minute,
+	}
+
+	dbVerifyFreezerCmd = &cli.Command{
+		Action: verifyFreezer,
+		Name:   "verify-freezer",
+		Usage:  "Scan the ancient stores for corrupted items",
+		Flags: flags.Merge([]cli.Flag{
+			verifyFreezerRefetchFlag,
+			verifyFreezerPeerTimeoutFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command reads every item of the ancient stores, reporting the ones
+which can't be read (e.g. checksum mismatches or decompression failures). The
+items of the chain freezer are also verified against each other: the headers
+against the canonical hashes, the bodies and receipts against the roots in the
+headers and the total difficulties against the ones of the parents.
+
+With --refetch, the corrupted chain items are repaired. Canonical hashes and
+total difficulties are recomputed locally, headers, bodies and receipts are
+re-fetched from the network and validated before they replace the corrupted
+items. State histories can't be re-fetched.`,
+	}
+)
+
+// verifyFreezer scans the ancient stores for corrupted items and optionally
+// repairs them.
+func verifyFreezer(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	var corrupted []rawdb.CorruptAncient
+	onCorrupt := func(item rawdb.CorruptAncient) {
+		log.Warn("Corrupted ancient item", "freezer", item.Freezer, "table", item.Kind, "number", item.Number, "err", item.Err)
+		corrupted = append(corrupted, item)
+	}
+	err := verifyChainFreezer(db, onCorrupt)
+	if err == nil {
+		err = rawdb.VerifyStateFreezer(db, onCorrupt)
+	}
+	db.Close()
+	if err != nil {
+		return err
+	}
+	if len(corrupted) == 0 {
+		log.Info("No corrupted ancient items found")
+		return nil
+	}
+	sort.Slice(corrupted, func(i, j int) bool {
+		if corrupted[i].Freezer != corrupted[j].Freezer {
+			return corrupted[i].Freezer < corrupted[j].Freezer
+		}
+		if corrupted[i].Number != corrupted[j].Number {
+			return corrupted[i].Number < corrupted[j].Number
+		}
+		return corrupted[i].Kind < corrupted[j].Kind
+	})
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Freezer", "Table", "Number", "Error"})
+	for _, item := range corrupted {
+		table.Append([]string{item.Freezer, item.Kind, fmt.Sprint(item.Number), item.Err.Error()})
+	}
+	table.Render()
+
+	if !ctx.Bool(verifyFreezerRefetchFlag.Name) {
+		return fmt.Errorf("found %d corrupted ancient items", len(corrupted))
+	}
+	// Start the node to re-fetch the corrupted chain items from the network
+	_, backend := utils.RegisterEthService(stack, &cfg.Eth)
+	if err := stack.Start(); err != nil {
+		return err
+	}
+	var (
+		chaindb  = backend.ChainDb()
+		repairs  = make(map[uint64]map[string]bool)
+		numbers  []uint64
+		failures int
+	)
+	for _, item := range corrupted {
+		if item.Freezer != rawdb.ChainFreezerName {
+			log.Error("Ancient item can't be re-fetched", "freezer", item.Freezer, "table", item.Kind, "number", item.Number)
+			failures++
+			continue
+		}
+		if repairs[item.Number] == nil {
+			repairs[item.Number] = make(map[string]bool)
+			numbers = append(numbers, item.Number)
+		}
+		repairs[item.Number][item.Kind] = true
+	}
+	waitCtx, cancel := context.WithTimeout(context.Background(), ctx.Duration(verifyFreezerPeerTimeoutFlag.Name))
+	defer cancel()
+
+	// Repair in ascending order, the total difficulties build on the parents
+	for _, number := range numbers {
+		kinds := repairs[number]
+		if kinds[rawdb.ChainFreezerHeaderTable] || kinds[rawdb.ChainFreezerBodiesTable] || kinds[rawdb.ChainFreezerReceiptTable] {
+			if err := refetchAncient(waitCtx, stack.Server().PeerCount, backend.RefetchBlock, chaindb, number, kinds); err != nil {
+				log.Error("Failed to re-fetch ancient block", "number", number, "err", err)
+				failures++
+				continue
+			}
+		}
+		if kinds[rawdb.ChainFreezerHashTable] {
+			if err := repairAncientHash(chaindb, number); err != nil {
+				log.Error("Failed to repair canonical hash", "number", number, "err", err)
+				failures++
+			}
+		}
+		if kinds[rawdb.ChainFreezerDifficultyTable] {
+			if err := repairAncientTd(chaindb, number); err != nil {
+				log.Error("Failed to repair total difficulty", "number", number, "err", err)
+				failures++
+			}
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("failed to repair %d corrupted ancient items", failures)
+	}
+	log.Info("Repaired all corrupted ancient items", "count", len(corrupted))
+	return nil
+}
+
+// refetchAncient re-fetches the block with the given number from the network,
+// replacing the corrupted header, body and receipts.
+func refetchAncient(ctx context.Context, peers func() int, fetch func(context.Context, common.Hash) (*types.Header, *types.Body, types.Receipts, error), db ethdb.Database, number uint64, kinds map[string]bool) error {
+	hash, err := ancientHash(db, number, kinds)
+	if err != nil {
+		return err
+	}
+	for peers() == 0 {
+		log.Info("Waiting for peers to re-fetch from")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+	header, body, receipts, err := fetch(ctx, hash)
+	if err != nil {
+		return err
+	}
+	items := make(map[string]interface{})
+	if kinds[rawdb.ChainFreezerHeaderTable] {
+		items[rawdb.ChainFreezerHeaderTable] = header
+	}
+	if kinds[rawdb.ChainFreezerBodiesTable] {
+		items[rawdb.ChainFreezerBodiesTable] = body
+	}
+	if kinds[rawdb.ChainFreezerReceiptTable] {
+		stored := make([]*types.ReceiptForStorage, len(receipts))
+		for i, receipt := range receipts {
+			stored[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		items[rawdb.ChainFreezerReceiptTable] = stored
+	}
+	for kind, item := range items {
+		blob, err := rlp.EncodeToBytes(item)
+		if err != nil {
+			return err
+		}
+		if err := db.RepairAncient(kind, number, blob); err != nil {
+			return err
+		}
+		log.Info("Re-fetched ancient item", "table", kind, "number", number, "hash", hash)
+	}
+	return nil
+}
+
+// ancientHash determines the canonical hash of the block with the given number,
+// from the hash table if it's intact or from the parent hash of the child.
+func ancientHash(db ethdb.Database, number uint64, kinds map[string]bool) (common.Hash, error) {
+	if !kinds[rawdb.ChainFreezerHashTable] {
+		if blob, err := db.Ancient(rawdb.ChainFreezerHashTable, number); err == nil && len(blob) == common.HashLength {
+			return common.BytesToHash(blob), nil
+		}
+	}
+	if header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, number+1), number+1); header != nil {
+		return header.ParentHash, nil
+	}
+	return common.Hash{}, errors.New("canonical hash unknown")
+}
+
+// repairAncientHash recomputes the corrupted canonical hash from the header.
+func repairAncientHash(db ethdb.Database, number uint64) error {
+	blob, err := db.Ancient(rawdb.ChainFreezerHeaderTable, number)
+	if err != nil {
+		return err
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(blob, header); err != nil {
+		return err
+	}
+	return db.RepairAncient(rawdb.ChainFreezerHashTable, number, header.Hash().Bytes())
+}
+
+// repairAncientTd recomputes the corrupted total difficulty from the one of the
+// parent and the difficulty of the header.
+func repairAncientTd(db ethdb.Database, number uint64) error {
+	if number == 0 {
+		return errors.New("genesis total difficulty can't be recomputed")
+	}
+	blob, err := db.Ancient(rawdb.ChainFreezerHeaderTable, number)
+	if err != nil {
+		return err
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(blob, header); err != nil {
+		return err
+	}
+	if blob, err = db.Ancient(rawdb.ChainFreezerDifficultyTable, number-1); err != nil {
+		return err
+	}
+	td := new(big.Int)
+	if err := rlp.DecodeBytes(blob, td); err != nil {
+		return err
+	}
+	if blob, err = rlp.EncodeToBytes(td.Add(td, header.Difficulty)); err != nil {
+		return err
+	}
+	return db.RepairAncient(rawdb.ChainFreezerDifficultyTable, number, blob)
+}
+
+// verifyChainFreezer checks the items of the chain freezer for read errors and
+// inconsistencies, calling onCorrupt for each corrupted item.
+func verifyChainFreezer(db ethdb.Database, onCorrupt func(rawdb.CorruptAncient)) error {
+	tail, err := db.Tail()
+	if err != nil {
+		return err
+	}
+	head, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		prevTd *big.Int // Total difficulty of the previous block, if valid
+	)
+	report := func(kind string, number uint64, err error) {
+		onCorrupt(rawdb.CorruptAncient{Freezer: rawdb.ChainFreezerName, Kind: kind, Number: number, Err: err})
+	}
+	for first := tail; first < head; first += 1024 {
+		count := min(1024, head-first)
+
+		// Read one header more, its parent hash tells whether the header or the
+		// canonical hash is corrupted if they don't match.
+		extra := uint64(0)
+		if first+count < head {
+			extra = 1
+		}
+		var (
+			hashes, hashErrs      = rawdb.ReadAncientItems(db, rawdb.ChainFreezerHashTable, first, count)
+			headers, headerErrs   = rawdb.ReadAncientItems(db, rawdb.ChainFreezerHeaderTable, first, count+extra)
+			bodies, bodyErrs      = rawdb.ReadAncientItems(db, rawdb.ChainFreezerBodiesTable, first, count)
+			receipts, receiptErrs = rawdb.ReadAncientItems(db, rawdb.ChainFreezerReceiptTable, first, count)
+			tds, tdErrs           = rawdb.ReadAncientItems(db, rawdb.ChainFreezerDifficultyTable, first, count)
+		)
+		for i := uint64(0); i < count; i++ {
+			number := first + i
+
+			// Check the canonical hash and the header against each other
+			hashOK := hashErrs[number] == nil && len(hashes[i]) == common.HashLength
+			if err := hashErrs[number]; err != nil {
+				report(rawdb.ChainFreezerHashTable, number, err)
+			} else if !hashOK {
+				report(rawdb.ChainFreezerHashTable, number, fmt.Errorf("invalid hash length %d", len(hashes[i])))
+			}
+			header := new(types.Header)
+			if err := headerErrs[number]; err != nil {
+				report(rawdb.ChainFreezerHeaderTable, number, err)
+				header = nil
+			} else if err := rlp.DecodeBytes(headers[i], header); err != nil {
+				report(rawdb.ChainFreezerHeaderTable, number, err)
+				header = nil
+			} else if hashOK && header.Hash() != common.BytesToHash(hashes[i]) {
+				// Blame the canonical hash if the child links to the header
+				var child types.Header
+				mismatch := fmt.Errorf("header hash %x, canonical hash %x", header.Hash(), hashes[i])
+				if i+1 < uint64(len(headers)) && rlp.DecodeBytes(headers[i+1], &child) == nil && child.ParentHash == header.Hash() {
+					report(rawdb.ChainFreezerHashTable, number, mismatch)
+				} else {
+					report(rawdb.ChainFreezerHeaderTable, number, mismatch)
+					header = nil
+				}
+			}
+			// Check the body and the receipts against the header
+			body := new(types.Body)
+			if err := bodyErrs[number]; err != nil {
+				report(rawdb.ChainFreezerBodiesTable, number, err)
+				body = nil
+			} else if err := rlp.DecodeBytes(bodies[i], body); err != nil {
+				report(rawdb.ChainFreezerBodiesTable, number, err)
+				body = nil
+			} else if header != nil {
+				if err := verifyAncientBody(header, body); err != nil {
+					report(rawdb.ChainFreezerBodiesTable, number, err)
+					body = nil
+				}
+			}
+			if err := receiptErrs[number]; err != nil {
+				report(rawdb.ChainFreezerReceiptTable, number, err)
+			} else if err := verifyAncientReceipts(header, body, receipts[i]); err != nil {
+				report(rawdb.ChainFreezerReceiptTable, number, err)
+			}
+			// Check the total difficulty against the one of the parent
+			td := new(big.Int)
+			if err := tdErrs[number]; err != nil {
+				report(rawdb.ChainFreezerDifficultyTable, number, err)
+				td = nil
+			} else if err := rlp.DecodeBytes(tds[i], td); err != nil {
+				report(rawdb.ChainFreezerDifficultyTable, number, err)
+				td = nil
+			} else if header != nil && prevTd != nil {
+				if want := new(big.Int).Add(prevTd, header.Difficulty); want.Cmp(td) != 0 {
+					report(rawdb.ChainFreezerDifficultyTable, number, fmt.Errorf("total difficulty %v, want %v", td, want))
+					td = nil
+				}
+			}
+			prevTd = td
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying ancient chain data", "number", first+count, "head", head, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Verified ancient chain data", "items", head-tail, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verifyAncientBody checks the transactions and uncles of the body against the
+// roots in the header.
+func verifyAncientBody(header *types.Header, body *types.Body) error {
+	if hash := types.DeriveSha(types.Transactions(body.Transactions), trie.NewStackTrie(nil)); hash != header.TxHash {
+		return fmt.Errorf("transaction root %x, want %x", hash, header.TxHash)
+	}
+	if hash := types.CalcUncleHash(body.Uncles); hash != header.UncleHash {
+		return fmt.Errorf("uncle hash %x, want %x", hash, header.UncleHash)
+	}
+	return nil
+}
+
+// verifyAncientReceipts decodes the receipts and, if the header and the body
+// are valid, checks them against the receipt root of the header.
+func verifyAncientReceipts(header *types.Header, body *types.Body, blob []byte) error {
+	var stored []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(blob, &stored); err != nil {
+		return err
+	}
+	if header == nil || body == nil {
+		return nil
+	}
+	if len(stored) != len(body.Transactions) {
+		return fmt.Errorf("%d receipts for %d transactions", len(stored), len(body.Transactions))
+	}
+	// The receipt root covers the consensus fields, the type and the bloom are
+	// not stored but derived.
+	receipts := make(types.Receipts, len(stored))
+	for i, receipt := range stored {
+		receipts[i] = (*types.Receipt)(receipt)
+		receipts[i].Type = body.Transactions[i].Type()
+		receipts[i].Bloom = types.CreateBloom(types.Receipts{receipts[i]})
+	}
+	if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != header.ReceiptHash {
+		return fmt.Errorf("receipt root %x, want %x", hash, header.ReceiptHash)
+	}
+	return nil
+}
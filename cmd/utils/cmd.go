@@ -39,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/debug"
@@ -123,6 +124,23 @@ func StartNode(ctx *cli.Context, stack *node.Node, isConsole bool) {
 	}()
 }
 
+// ReloadChainConfigOnHangup re-applies the configured chain configuration file to
+// the given backend whenever the process receives SIGHUP.
+func ReloadChainConfigOnHangup(backend *eth.Ethereum) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sigc)
+		for range sigc {
+			log.Info("Got hangup, reloading chain configuration")
+			if err := backend.ReloadChainConfig(""); err != nil {
+				log.Error("Failed to reload chain configuration", "err", err)
+			}
+		}
+	}()
+}
+
 func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritical uint64) {
 	if path == "" {
 		return
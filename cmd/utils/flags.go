@@ -21,6 +21,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -32,6 +33,7 @@ import (
 	"path/filepath"
 	"runtime"
 	godebug "runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -73,6 +75,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/types/genesisT"
 	"github.com/ethereum/go-ethereum/params/vars"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -115,11 +118,27 @@ var (
 		Value:    node.DefaultConfig.DBEngine,
 		Category: flags.EthCategory,
 	}
+	AncientCompressionFlag = &cli.StringFlag{
+		Name:     "db.ancient.compression",
+		Usage:    "Compression of newly created ancient tables ('snappy' or 'zstd')",
+		Value:    rawdb.FreezerSnappy,
+		Category: flags.EthCategory,
+	}
+	AncientChecksumFlag = &cli.BoolFlag{
+		Name:     "db.ancient.checksum",
+		Usage:    "Store a checksum of each item in newly created ancient tables",
+		Category: flags.EthCategory,
+	}
 	AncientFlag = &flags.DirectoryFlag{
 		Name:     "datadir.ancient",
 		Usage:    "Root directory for ancient data (default = inside chaindata)",
 		Category: flags.EthCategory,
 	}
+	EraFlag = &flags.DirectoryFlag{
+		Name:     "datadir.era",
+		Usage:    "Root directory for era1 files holding chain history offloaded from the ancient data",
+		Category: flags.EthCategory,
+	}
 	MinFreeDiskSpaceFlag = &flags.DirectoryFlag{
 		Name:     "datadir.minfreedisk",
 		Usage:    "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
@@ -151,6 +170,11 @@ var (
 		Value:    ethconfig.Defaults.NetworkId,
 		Category: flags.EthCategory,
 	}
+	ExtraNetworksFlag = &cli.StringFlag{
+		Name:     "networks.extra",
+		Usage:    "Comma separated list of additional networks hosted by this node (classic, mordor, mintme, mainnet, sepolia, holesky). Their RPC namespaces are prefixed with '<network>.'",
+		Category: flags.EthCategory,
+	}
 	EthProtocolsFlag = &cli.StringFlag{
 		Name:     "eth.protocols",
 		Usage:    "Sets the Ethereum Protocol versions (first is primary)",
@@ -278,6 +302,27 @@ var (
 		Usage:    "Comma separated block number-to-hash mappings to require for peering (<number>=<hash>)",
 		Category: flags.EthCategory,
 	}
+	CheckpointFlag = &cli.StringFlag{
+		Name:     "checkpoint",
+		Usage:    "Comma separated trusted block number-to-hash mappings the synced chain must include (<number>=<hash>)",
+		Category: flags.EthCategory,
+	}
+	CheckpointListFlag = &cli.StringFlag{
+		Name:     "checkpoint.list",
+		Usage:    "JSON file of a signed sync checkpoint list to enforce",
+		Category: flags.EthCategory,
+	}
+	CheckpointSignersFlag = &cli.StringFlag{
+		Name:     "checkpoint.signers",
+		Usage:    "Comma separated addresses of the keys trusted to sign checkpoint lists (default: the release signers, also enforcing the list bundled for the network)",
+		Category: flags.EthCategory,
+	}
+	CheckpointThresholdFlag = &cli.IntFlag{
+		Name:     "checkpoint.threshold",
+		Usage:    "Minimum number of trusted signatures required to accept a checkpoint list",
+		Value:    1,
+		Category: flags.EthCategory,
+	}
 	BloomFilterSizeFlag = &cli.Uint64Flag{
 		Name:     "bloomfilter.size",
 		Usage:    "Megabytes of memory allocated to bloom-filter for pruning",
@@ -305,6 +350,11 @@ var (
 		Value:    &defaultSyncMode,
 		Category: flags.StateCategory,
 	}
+	SyncHeaderFirstFlag = &cli.BoolFlag{
+		Name:     "syncmode.headerfirst",
+		Usage:    "Download and verify the full PoW header chain before snap syncing block content and state",
+		Category: flags.StateCategory,
+	}
 	GCModeFlag = &cli.StringFlag{
 		Name:     "gcmode",
 		Usage:    `Blockchain garbage collection mode, only relevant in state.scheme=hash ("full", "archive")`,
@@ -328,6 +378,12 @@ var (
 		Value:    ethconfig.Defaults.TransactionHistory,
 		Category: flags.StateCategory,
 	}
+	ReceiptHistoryFlag = &cli.Uint64Flag{
+		Name:     "history.receipts",
+		Usage:    "Number of recent blocks to store receipts for, older ones are regenerated on demand (default = 0, entire chain)",
+		Value:    ethconfig.Defaults.ReceiptHistory,
+		Category: flags.StateCategory,
+	}
 	// Light server and client settings
 	LightServeFlag = &cli.IntFlag{
 		Name:     "light.serve",
@@ -499,6 +555,30 @@ var (
 		Value:    ethconfig.Defaults.TxPool.Lifetime,
 		Category: flags.TxPoolCategory,
 	}
+	// Transaction propagation policy settings
+	TxPolicyTrustedOnlyFlag = &cli.BoolFlag{
+		Name:     "txpolicy.trustedonly",
+		Usage:    "Propagate transactions to trusted peers only",
+		Category: flags.TxPoolCategory,
+	}
+	TxPolicyLocalDelayFlag = &cli.DurationFlag{
+		Name:     "txpolicy.localdelay",
+		Usage:    "Delay before propagating local transactions to the network",
+		Value:    ethconfig.Defaults.TxPolicy.LocalDelay,
+		Category: flags.TxPoolCategory,
+	}
+	TxPolicyInvalidLimitFlag = &cli.IntFlag{
+		Name:     "txpolicy.invalidlimit",
+		Usage:    "Number of invalid transactions a peer may send before its transactions are ignored (0 = unlimited)",
+		Value:    ethconfig.Defaults.TxPolicy.InvalidLimit,
+		Category: flags.TxPoolCategory,
+	}
+	TxPolicyPeerBandwidthFlag = &cli.Uint64Flag{
+		Name:     "txpolicy.peerbandwidth",
+		Usage:    "Bytes per second of transactions broadcast in full to each peer, the rest is announced (0 = unlimited)",
+		Value:    ethconfig.Defaults.TxPolicy.PeerBandwidth,
+		Category: flags.TxPoolCategory,
+	}
 	// Blob transaction pool settings
 	BlobPoolDataDirFlag = &cli.StringFlag{
 		Name:     "blobpool.datadir",
@@ -690,6 +770,23 @@ var (
 		Value:    ethconfig.Defaults.RPCTxFeeCap,
 		Category: flags.APICategory,
 	}
+	RPCResultCacheFlag = &cli.IntFlag{
+		Name:     "rpc.cache",
+		Usage:    "Megabytes of memory allocated to caching results of historical RPC queries (0 = disabled)",
+		Value:    ethconfig.Defaults.RPCResultCache,
+		Category: flags.APICategory,
+	}
+	RPCResultCacheDepthFlag = &cli.Uint64Flag{
+		Name:     "rpc.cache.depth",
+		Usage:    "Number of blocks below the head after which RPC results are considered immutable and cached",
+		Value:    ethconfig.Defaults.RPCResultCacheDepth,
+		Category: flags.APICategory,
+	}
+	RPCResultCacheDiskFlag = &cli.BoolFlag{
+		Name:     "rpc.cache.disk",
+		Usage:    "Persist cached historical RPC results to disk",
+		Category: flags.APICategory,
+	}
 	// Authenticated RPC HTTP settings
 	AuthListenFlag = &cli.StringFlag{
 		Name:     "authrpc.addr",
@@ -787,7 +884,7 @@ var (
 	}
 	HTTPApiFlag = &cli.StringFlag{
 		Name:     "http.api",
-		Usage:    "API's offered over the HTTP-RPC interface",
+		Usage:    "API's offered over the HTTP-RPC interface, also enabling them for the additional networks (e.g. eth enables mordor.eth)",
 		Value:    "",
 		Category: flags.APICategory,
 	}
@@ -833,7 +930,7 @@ var (
 	}
 	WSApiFlag = &cli.StringFlag{
 		Name:     "ws.api",
-		Usage:    "API's offered over the WS-RPC interface",
+		Usage:    "API's offered over the WS-RPC interface, also enabling them for the additional networks (e.g. eth enables mordor.eth)",
 		Value:    "",
 		Category: flags.APICategory,
 	}
@@ -957,6 +1054,43 @@ var (
 		Value:    30303,
 		Category: flags.NetworkingCategory,
 	}
+	DNSTreeDomainFlag = &cli.StringFlag{
+		Name:     "dnstree.domain",
+		Usage:    "Crawls the network and publishes its nodes as a DNS node tree under the given domain",
+		Category: flags.NetworkingCategory,
+	}
+	DNSTreeKeyFlag = &cli.StringFlag{
+		Name:     "dnstree.key",
+		Usage:    "File holding the private key signing the DNS node tree",
+		Category: flags.NetworkingCategory,
+	}
+	DNSTreeListenFlag = &cli.StringFlag{
+		Name:     "dnstree.listen",
+		Usage:    "Serves the DNS node tree from a built-in DNS server listening on the given address (e.g. :53)",
+		Category: flags.NetworkingCategory,
+	}
+	DNSTreeZoneFileFlag = &cli.StringFlag{
+		Name:     "dnstree.zonefile",
+		Usage:    "Writes the records of the DNS node tree to the given zone file on every update",
+		Category: flags.NetworkingCategory,
+	}
+	DNSTreeLinksFlag = &cli.StringFlag{
+		Name:     "dnstree.links",
+		Usage:    "Comma separated enrtree:// URLs of other node trees to link from the DNS node tree",
+		Category: flags.NetworkingCategory,
+	}
+	DNSTreeIntervalFlag = &cli.DurationFlag{
+		Name:     "dnstree.interval",
+		Usage:    "Time between updates of the DNS node tree",
+		Value:    ethconfig.Defaults.DNSTree.Interval,
+		Category: flags.NetworkingCategory,
+	}
+	DNSTreeTTLFlag = &cli.UintFlag{
+		Name:     "dnstree.ttl",
+		Usage:    "TTL of the DNS node tree records, in seconds",
+		Value:    uint(ethconfig.Defaults.DNSTree.TTL),
+		Category: flags.NetworkingCategory,
+	}
 
 	// Console
 	JSpathFlag = &flags.DirectoryFlag{
@@ -1085,11 +1219,40 @@ Please note that --` + MetricsHTTPFlag.Name + ` must be set to start the server.
 		Usage:    "Manually specify the ECBP-1100 (MESS) deactivation block number, overriding the bundled setting",
 		Category: flags.EthCategory,
 	}
+	ChainConfigFileFlag = &cli.PathFlag{
+		Name:     "chainconfig.file",
+		Usage:    "Genesis or chain configuration JSON file applied at startup and reloaded on SIGHUP or admin_reloadChainConfig (only future transitions may change)",
+		Category: flags.EthCategory,
+	}
 	ECBP1100NoDisableFlag = &cli.BoolFlag{
 		Name:     "ecbp1100.nodisable",
 		Usage:    "Short-circuit ECBP-1100 (MESS) disable mechanisms; (yields a permanent-once-activated state, deactivating auto-shutoff mechanisms)",
 		Category: flags.DeprecatedCategory,
 	}
+	ECBP1100MinPeersFlag = &cli.IntFlag{
+		Name:     "ecbp1100.minpeers",
+		Usage:    "Minimum number of peers required for ECBP-1100 (MESS) to be enabled (0 = no limit)",
+		Value:    ethconfig.DefaultArtificialFinalitySafety.MinPeers,
+		Category: flags.EthCategory,
+	}
+	ECBP1100MinSubnetsFlag = &cli.IntFlag{
+		Name:     "ecbp1100.minsubnets",
+		Usage:    "Minimum number of distinct /16 peer subnets required for ECBP-1100 (MESS) to be enabled (0 = no limit)",
+		Value:    ethconfig.DefaultArtificialFinalitySafety.MinSubnets,
+		Category: flags.EthCategory,
+	}
+	ECBP1100MinClientsFlag = &cli.IntFlag{
+		Name:     "ecbp1100.minclients",
+		Usage:    "Minimum number of distinct peer client implementations required for ECBP-1100 (MESS) to be enabled (0 = no limit)",
+		Value:    ethconfig.DefaultArtificialFinalitySafety.MinClients,
+		Category: flags.EthCategory,
+	}
+	ECBP1100StaleIntervalFlag = &cli.DurationFlag{
+		Name:     "ecbp1100.staleinterval",
+		Usage:    "Maximum local head age before ECBP-1100 (MESS) is disabled (0 = never)",
+		Value:    ethconfig.DefaultArtificialFinalitySafety.StaleInterval,
+		Category: flags.EthCategory,
+	}
 
 	MetricsEnableInfluxDBV2Flag = &cli.BoolFlag{
 		Name:     "metrics.influxdbv2",
@@ -1137,8 +1300,11 @@ var (
 	DatabaseFlags = []cli.Flag{
 		DataDirFlag,
 		AncientFlag,
+		EraFlag,
 		RemoteDBFlag,
 		DBEngineFlag,
+		AncientCompressionFlag,
+		AncientChecksumFlag,
 		StateSchemeFlag,
 		HttpHeaderFlag,
 	}
@@ -1654,6 +1820,19 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 		log.Info(fmt.Sprintf("Using %s as db engine", dbEngine))
 		cfg.DBEngine = dbEngine
 	}
+	if ctx.IsSet(AncientCompressionFlag.Name) {
+		compression := ctx.String(AncientCompressionFlag.Name)
+		if compression != rawdb.FreezerSnappy && compression != rawdb.FreezerZstd {
+			Fatalf("Invalid choice for db.ancient.compression '%s', allowed '%s' or '%s'", compression, rawdb.FreezerSnappy, rawdb.FreezerZstd)
+		}
+		cfg.AncientCompression = compression
+	}
+	if ctx.IsSet(AncientChecksumFlag.Name) {
+		cfg.AncientChecksum = ctx.Bool(AncientChecksumFlag.Name)
+	}
+	if ctx.IsSet(EraFlag.Name) {
+		cfg.AncientEraDir = ctx.String(EraFlag.Name)
+	}
 	// deprecation notice for log debug flags (TODO: find a more appropriate place to put these?)
 	if ctx.IsSet(LogBacktraceAtFlag.Name) {
 		log.Warn("log.backtrace flag is deprecated")
@@ -1770,6 +1949,49 @@ func setTxPool(ctx *cli.Context, cfg *legacypool.Config) {
 	}
 }
 
+// setDNSTree configures publishing the nodes of the network as a DNS node tree.
+func setDNSTree(ctx *cli.Context, cfg *ethconfig.DNSTreeConfig) {
+	if ctx.IsSet(DNSTreeDomainFlag.Name) {
+		cfg.Domain = ctx.String(DNSTreeDomainFlag.Name)
+	}
+	if ctx.IsSet(DNSTreeKeyFlag.Name) {
+		cfg.KeyFile = ctx.String(DNSTreeKeyFlag.Name)
+	}
+	if ctx.IsSet(DNSTreeListenFlag.Name) {
+		cfg.Listen = ctx.String(DNSTreeListenFlag.Name)
+	}
+	if ctx.IsSet(DNSTreeZoneFileFlag.Name) {
+		cfg.ZoneFile = ctx.String(DNSTreeZoneFileFlag.Name)
+	}
+	if ctx.IsSet(DNSTreeLinksFlag.Name) {
+		cfg.Links = SplitAndTrim(ctx.String(DNSTreeLinksFlag.Name))
+	}
+	if ctx.IsSet(DNSTreeIntervalFlag.Name) {
+		cfg.Interval = ctx.Duration(DNSTreeIntervalFlag.Name)
+	}
+	if ctx.IsSet(DNSTreeTTLFlag.Name) {
+		cfg.TTL = uint32(ctx.Uint(DNSTreeTTLFlag.Name))
+	}
+	if cfg.Domain != "" && ctx.Bool(NoDiscoverFlag.Name) {
+		Fatalf("Publishing a DNS node tree requires node discovery, remove --%s", NoDiscoverFlag.Name)
+	}
+}
+
+func setTxPolicy(ctx *cli.Context, cfg *ethconfig.TxPolicyConfig) {
+	if ctx.IsSet(TxPolicyTrustedOnlyFlag.Name) {
+		cfg.TrustedOnly = ctx.Bool(TxPolicyTrustedOnlyFlag.Name)
+	}
+	if ctx.IsSet(TxPolicyLocalDelayFlag.Name) {
+		cfg.LocalDelay = ctx.Duration(TxPolicyLocalDelayFlag.Name)
+	}
+	if ctx.IsSet(TxPolicyInvalidLimitFlag.Name) {
+		cfg.InvalidLimit = ctx.Int(TxPolicyInvalidLimitFlag.Name)
+	}
+	if ctx.IsSet(TxPolicyPeerBandwidthFlag.Name) {
+		cfg.PeerBandwidth = ctx.Uint64(TxPolicyPeerBandwidthFlag.Name)
+	}
+}
+
 func homeDir() string {
 	if home := os.Getenv("HOME"); home != "" {
 		return home
@@ -1901,6 +2123,68 @@ func setRequiredBlocks(ctx *cli.Context, cfg *ethconfig.Config) {
 	}
 }
 
+// setSyncCheckpoints configures the trusted checkpoints and the signed checkpoint
+// list from the CLI. Unless other signers are configured, the checkpoint lists
+// must be signed by the default signers, and the list bundled for the selected
+// network is enforced if none is given.
+func setSyncCheckpoints(ctx *cli.Context, cfg *ethconfig.Config) {
+	if ctx.IsSet(CheckpointFlag.Name) {
+		checkpoints := slices.Clone(cfg.SyncCheckpoints)
+		for _, entry := range strings.Split(ctx.String(CheckpointFlag.Name), ",") {
+			parts := strings.Split(entry, "=")
+			if len(parts) != 2 {
+				Fatalf("Invalid checkpoint entry: %s", entry)
+			}
+			number, err := strconv.ParseUint(parts[0], 0, 64)
+			if err != nil {
+				Fatalf("Invalid checkpoint number %s: %v", parts[0], err)
+			}
+			var hash common.Hash
+			if err = hash.UnmarshalText([]byte(parts[1])); err != nil {
+				Fatalf("Invalid checkpoint hash %s: %v", parts[1], err)
+			}
+			checkpoints = append(checkpoints, ctypes.SyncCheckpoint{Number: number, Hash: hash})
+		}
+		cfg.SyncCheckpoints = checkpoints
+	}
+	if ctx.IsSet(CheckpointListFlag.Name) {
+		blob, err := os.ReadFile(ctx.String(CheckpointListFlag.Name))
+		if err != nil {
+			Fatalf("Failed to read checkpoint list: %v", err)
+		}
+		list := new(ctypes.SignedCheckpoints)
+		if err := json.Unmarshal(blob, list); err != nil {
+			Fatalf("Invalid checkpoint list: %v", err)
+		}
+		cfg.CheckpointList = list
+	}
+	if ctx.IsSet(CheckpointSignersFlag.Name) {
+		cfg.CheckpointSigners = nil
+		for _, signer := range SplitAndTrim(ctx.String(CheckpointSignersFlag.Name)) {
+			if !common.IsHexAddress(signer) {
+				Fatalf("Invalid checkpoint signer: %s", signer)
+			}
+			cfg.CheckpointSigners = append(cfg.CheckpointSigners, common.HexToAddress(signer))
+		}
+	}
+	if ctx.IsSet(CheckpointThresholdFlag.Name) {
+		cfg.CheckpointThreshold = ctx.Int(CheckpointThresholdFlag.Name)
+	}
+	if cfg.CheckpointSigners == nil {
+		cfg.CheckpointSigners = params.CheckpointSigners
+		if cfg.CheckpointList == nil {
+			switch {
+			case ctx.Bool(ClassicFlag.Name):
+				cfg.CheckpointList = params.ClassicCheckpoints
+			case ctx.Bool(MordorFlag.Name):
+				cfg.CheckpointList = params.MordorCheckpoints
+			case ctx.Bool(MintMeFlag.Name):
+				cfg.CheckpointList = params.MintMeCheckpoints
+			}
+		}
+	}
+}
+
 // CheckExclusive verifies that only a single instance of the provided flags was
 // set by the user. Each flag might optionally be followed by a string type to
 // specialize it further.
@@ -1952,9 +2236,11 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setEtherbase(ctx, cfg)
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
+	setTxPolicy(ctx, &cfg.TxPolicy)
 	setEthash(ctx, cfg)
 	setMiner(ctx, &cfg.Miner)
 	setRequiredBlocks(ctx, cfg)
+	setSyncCheckpoints(ctx, cfg)
 	setLes(ctx, cfg)
 
 	// Cap the cache allowance and tune the garbage collector
@@ -1982,6 +2268,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	} else if ctx.IsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *flags.GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.IsSet(SyncHeaderFirstFlag.Name) {
+		cfg.HeaderFirstSync = ctx.Bool(SyncHeaderFirstFlag.Name)
+	}
 
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheDatabaseFlag.Name) {
 		cfg.DatabaseCache = ctx.Int(CacheFlag.Name) * ctx.Int(CacheDatabaseFlag.Name) / 100
@@ -2009,6 +2298,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(StateHistoryFlag.Name) {
 		cfg.StateHistory = ctx.Uint64(StateHistoryFlag.Name)
 	}
+	if ctx.IsSet(ReceiptHistoryFlag.Name) {
+		cfg.ReceiptHistory = ctx.Uint64(ReceiptHistoryFlag.Name)
+	}
 	if ctx.IsSet(StateSchemeFlag.Name) {
 		cfg.StateScheme = ctx.String(StateSchemeFlag.Name)
 	}
@@ -2028,6 +2320,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		cfg.TransactionHistory = 0
 		log.Warn("Disabled transaction unindexing for archive node")
 	}
+	// Pruned receipts are regenerated on top of historical states, which only
+	// archive nodes and path scheme nodes with the entire state history retain.
+	if cfg.ReceiptHistory != 0 && !cfg.NoPruning {
+		if cfg.StateScheme == rawdb.HashScheme || cfg.StateHistory != 0 {
+			Fatalf("--%s requires --%s=%s, or --%s=%s with --%s=0", ReceiptHistoryFlag.Name, GCModeFlag.Name, gcModeArchive, StateSchemeFlag.Name, rawdb.PathScheme, StateHistoryFlag.Name)
+		}
+	}
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.Int(CacheFlag.Name) * ctx.Int(CacheTrieFlag.Name) / 100
 	}
@@ -2090,6 +2389,18 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.Float64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.IsSet(RPCResultCacheFlag.Name) {
+		cfg.RPCResultCache = ctx.Int(RPCResultCacheFlag.Name)
+	}
+	if ctx.IsSet(RPCResultCacheDepthFlag.Name) {
+		cfg.RPCResultCacheDepth = ctx.Uint64(RPCResultCacheDepthFlag.Name)
+	}
+	if ctx.IsSet(RPCResultCacheDiskFlag.Name) {
+		cfg.RPCResultCacheDisk = ctx.Bool(RPCResultCacheDiskFlag.Name)
+	}
+	if ctx.IsSet(ChainConfigFileFlag.Name) {
+		cfg.ChainConfigFile = ctx.Path(ChainConfigFileFlag.Name)
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.IsSet(DNSDiscoveryFlag.Name) {
@@ -2100,6 +2411,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 			cfg.EthDiscoveryURLs = SplitAndTrim(urls)
 		}
 	}
+	setDNSTree(ctx, &cfg.DNSTree)
 
 	// Override any default configs for hard coded networks.
 
@@ -2305,10 +2617,96 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 	if err != nil {
 		Fatalf("Failed to register the Ethereum service: %v", err)
 	}
-	stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
+	stack.RegisterAPIs(cfg.Namespaced(tracers.APIs(backend.APIBackend)))
 	return backend.APIBackend, backend
 }
 
+// networkPreset returns the genesis, bootnodes and DNS discovery tree of a built-in
+// network by its name.
+func networkPreset(name string) (genesis *genesisT.Genesis, bootnodes []string, dns string) {
+	switch name {
+	case "mainnet":
+		return params.DefaultGenesisBlock(), params.MainnetBootnodes, params.KnownDNSNetwork(params.MainnetGenesisHash, "all")
+	case "classic":
+		return params.DefaultClassicGenesisBlock(), params.ClassicBootnodes, params.ClassicDNSNetwork1
+	case "mordor":
+		return params.DefaultMordorGenesisBlock(), params.MordorBootnodes, params.MordorDNSNetwork1
+	case "mintme":
+		return params.DefaultMintMeGenesisBlock(), params.MintMeBootnodes, ""
+	case "sepolia":
+		return params.DefaultSepoliaGenesisBlock(), params.SepoliaBootnodes, params.KnownDNSNetwork(params.SepoliaGenesisHash, "all")
+	case "holesky":
+		return params.DefaultHoleskyGenesisBlock(), params.HoleskyBootnodes, params.KnownDNSNetwork(params.HoleskyGenesisHash, "all")
+	}
+	return nil, nil, ""
+}
+
+// RegisterExtraNetworks adds an Ethereum backend for every network requested with
+// --networks.extra to the stack. Each one inherits the settings of the primary
+// backend, but keeps its data in a datadir subdirectory named after the network,
+// serves its RPC APIs under namespaces prefixed with the network name and peers
+// through a dedicated P2P server listening on the ports following the main one.
+//
+// The account manager remains configured for the primary network. Settings kept
+// in process-wide state, the hardware wallet derivation path and the external
+// EVMC interpreters, can't differ between the networks and are rejected.
+func RegisterExtraNetworks(ctx *cli.Context, stack *node.Node, base *ethconfig.Config) {
+	if !ctx.IsSet(ExtraNetworksFlag.Name) {
+		return
+	}
+	for _, flag := range []cli.Flag{USBFlag, EVMInterpreterFlag, EWASMInterpreterFlag} {
+		if ctx.IsSet(flag.Names()[0]) {
+			Fatalf("Flag --%s can't be used with --%s", flag.Names()[0], ExtraNetworksFlag.Name)
+		}
+	}
+	host, port, err := net.SplitHostPort(stack.Config().P2P.ListenAddr)
+	if err != nil && stack.Config().P2P.ListenAddr != "" {
+		Fatalf("Invalid P2P listen address %q: %v", stack.Config().P2P.ListenAddr, err)
+	}
+	for i, name := range SplitAndTrim(ctx.String(ExtraNetworksFlag.Name)) {
+		genesis, bootnodes, dns := networkPreset(name)
+		if genesis == nil {
+			Fatalf("Unknown network %q in --%s", name, ExtraNetworksFlag.Name)
+		}
+		if base.Genesis != nil && core.GenesisToBlock(base.Genesis, nil).Hash() == core.GenesisToBlock(genesis, nil).Hash() {
+			Fatalf("Network %q in --%s is already the primary network", name, ExtraNetworksFlag.Name)
+		}
+		cfg := *base
+		cfg.Instance = name
+		cfg.Genesis = genesis
+		cfg.NetworkId = *genesis.GetNetworkID()
+		cfg.ProtocolVersions = genesis.GetSupportedProtocolVersions()
+		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = nil, nil
+		if dns != "" {
+			SetDNSDiscoveryDefaults2(&cfg, dns)
+		}
+		cfg.RequiredBlocks, cfg.SyncCheckpoints = nil, nil
+		cfg.OverrideECBP1100, cfg.OverrideECBP1100Deactivate = nil, nil
+		cfg.DNSTree = ethconfig.Defaults.DNSTree
+
+		p2pConfig := stack.Config().P2P
+		p2pConfig.PrivateKey = nil
+		p2pConfig.NodeDatabase = ""
+		p2pConfig.StaticNodes, p2pConfig.TrustedNodes = nil, nil
+		p2pConfig.BootstrapNodes = mustParseBootnodes(bootnodes)
+		p2pConfig.BootstrapNodesV5 = p2pConfig.BootstrapNodes
+		if port != "" {
+			base, err := strconv.Atoi(port)
+			if err != nil {
+				Fatalf("Invalid P2P listen port %q: %v", port, err)
+			}
+			if base != 0 {
+				base += i + 1
+			}
+			p2pConfig.ListenAddr = net.JoinHostPort(host, strconv.Itoa(base))
+		}
+		cfg.InstanceP2P = &p2pConfig
+
+		backend, _ := RegisterEthService(stack, &cfg)
+		RegisterFilterAPI(stack, backend, &cfg)
+	}
+}
+
 // RegisterEthStatsService configures the Ethereum Stats daemon and adds it to the node.
 func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url string) {
 	if err := ethstats.New(stack, backend, backend.Engine(), url); err != nil {
@@ -2329,10 +2727,10 @@ func RegisterFilterAPI(stack *node.Node, backend ethapi.Backend, ethcfg *ethconf
 	filterSystem := filters.NewFilterSystem(backend, filters.Config{
 		LogCacheSize: ethcfg.FilterLogCacheSize,
 	})
-	stack.RegisterAPIs([]rpc.API{{
+	stack.RegisterAPIs(ethcfg.Namespaced([]rpc.API{{
 		Namespace: "eth",
 		Service:   filters.NewFilterAPI(filterSystem, false),
-	}})
+	}}))
 	return filterSystem
 }
 
@@ -2572,6 +2970,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 		Preimages:           ctx.Bool(CachePreimagesFlag.Name),
 		StateScheme:         scheme,
 		StateHistory:        ctx.Uint64(StateHistoryFlag.Name),
+		ReceiptHistory:      ctx.Uint64(ReceiptHistoryFlag.Name),
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
 		cache.Preimages = true
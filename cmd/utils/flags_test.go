@@ -18,8 +18,15 @@
 package utils
 
 import (
+	"flag"
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/urfave/cli/v2"
 )
 
 func Test_SplitTagsFlag(t *testing.T) {
@@ -65,3 +72,45 @@ func Test_SplitTagsFlag(t *testing.T) {
 		})
 	}
 }
+
+// Tests that the checkpoint list bundled for the selected network is enforced,
+// unless other checkpoint signers are configured.
+func TestSetSyncCheckpoints(t *testing.T) {
+	t.Parallel()
+	signer := common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+	tests := []struct {
+		name    string
+		args    []string
+		list    *ctypes.SignedCheckpoints
+		signers []common.Address
+	}{
+		{"mainnet", nil, nil, params.CheckpointSigners},
+		{"classic", []string{"--" + ClassicFlag.Name}, params.ClassicCheckpoints, params.CheckpointSigners},
+		{"mordor", []string{"--" + MordorFlag.Name}, params.MordorCheckpoints, params.CheckpointSigners},
+		{"mintme", []string{"--" + MintMeFlag.Name}, params.MintMeCheckpoints, params.CheckpointSigners},
+		{"signers", []string{"--" + ClassicFlag.Name, "--" + CheckpointSignersFlag.Name, signer.Hex()}, nil, []common.Address{signer}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			set := flag.NewFlagSet("test", flag.ContinueOnError)
+			for _, f := range []*cli.BoolFlag{ClassicFlag, MordorFlag, MintMeFlag} {
+				set.Bool(f.Name, false, "")
+			}
+			set.String(CheckpointSignersFlag.Name, "", "")
+			if err := set.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			cfg := new(ethconfig.Config)
+			setSyncCheckpoints(cli.NewContext(nil, set, nil), cfg)
+
+			if cfg.CheckpointList != tt.list {
+				t.Errorf("checkpoint list mismatch: have %v, want %v", cfg.CheckpointList, tt.list)
+			}
+			if !reflect.DeepEqual(cfg.CheckpointSigners, tt.signers) {
+				t.Errorf("checkpoint signers mismatch: have %v, want %v", cfg.CheckpointSigners, tt.signers)
+			}
+		})
+	}
+}
@@ -0,0 +1,531 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package powsim simulates proof-of-work networks to test consensus rules such as
+// ECBP1100 (MESS) under partitions and attacks.
+//
+// A simulation runs a set of nodes, each one with its own blockchain and a fake
+// PoW miner, on a virtual clock. Blocks travel between the nodes which can reach
+// each other with a fixed latency, new nodes and healed partitions exchange their
+// heads the way peers sync after the handshake. Tests script partitions, hashrate
+// changes and the release of withheld private chains, run the clock and assert on
+// the chains of the nodes. Everything runs on the goroutine calling Run, so a
+// simulation with the same seed always produces the same chains. A node failing
+// to import a block stops the simulation, with Run returning the error.
+//
+// The nodes are bare blockchains, not full eth.Ethereum instances: blocks travel
+// through the simulation instead of the eth protocol, so the block fetcher, the
+// downloader and the peer handling are not exercised. Artificial finality follows
+// the rules of the eth handler though. It is enabled on the nodes activating
+// ECBP1100 once they are in sync with their peers and meet the safety conditions,
+// and disabled as soon as a condition fails, checked at every step of the clock.
+package powsim
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+)
+
+// Config contains the settings of a simulation.
+type Config struct {
+	Genesis *genesisT.Genesis // Genesis of the simulated network (default: messnet)
+	PowMode ethash.Mode       // Fake PoW mode of the miners, ModeFake or ModePoissonFake (default: ModePoissonFake)
+	Latency time.Duration     // Block propagation delay between two nodes (default: 250ms)
+	Step    time.Duration     // Resolution of the virtual clock (default: 100ms)
+	Seed    int64             // Seed of the randomness of the simulation
+}
+
+// Defaults contains the default settings of a simulation.
+var Defaults = Config{
+	PowMode: ethash.ModePoissonFake,
+	Latency: 250 * time.Millisecond,
+	Step:    100 * time.Millisecond,
+}
+
+// NodeConfig contains the settings of a simulated node. For the safety conditions
+// of artificial finality, every peer counts as a distinct subnet, all of them
+// running the same client.
+type NodeConfig struct {
+	Name               string                             // Name of the node, used in errors
+	Hashrate           float64                            // Hashes per second of the miner, zero if not mining
+	ArtificialFinality bool                               // Whether ECBP1100 is activated on the node
+	Safety             ethconfig.ArtificialFinalitySafety // Conditions for artificial finality (default: none)
+}
+
+// Simulation is a simulated PoW network.
+type Simulation struct {
+	config Config
+	clock  *mclock.Simulated
+	rand   *rand.Rand
+	start  mclock.AbsTime
+	nodes  []*Node
+	err    error // First failure of a node, stopping the simulation
+}
+
+// New creates an empty simulation.
+func New(config Config) *Simulation {
+	if config.Genesis == nil {
+		config.Genesis = params.DefaultMessNetGenesisBlock()
+	}
+	if config.PowMode != ethash.ModeFake && config.PowMode != ethash.ModePoissonFake {
+		config.PowMode = Defaults.PowMode
+	}
+	if config.Latency <= 0 {
+		config.Latency = Defaults.Latency
+	}
+	if config.Step <= 0 {
+		config.Step = Defaults.Step
+	}
+	clock := new(mclock.Simulated)
+	return &Simulation{
+		config: config,
+		clock:  clock,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+		start:  clock.Now(),
+	}
+}
+
+// Close stops the chains of all nodes.
+func (s *Simulation) Close() {
+	for _, n := range s.nodes {
+		n.chain.Stop()
+		n.db.Close()
+	}
+}
+
+// AddNode adds a node to the simulation. The node starts on the genesis block,
+// can reach all nodes outside of partitions and syncs with them right away.
+func (s *Simulation) AddNode(config NodeConfig) (*Node, error) {
+	if config.Name == "" {
+		config.Name = fmt.Sprintf("node-%d", len(s.nodes))
+	}
+	if config.Hashrate < 0 {
+		return nil, errors.New("negative hashrate")
+	}
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		engine = ethash.NewFaker()
+		cache  = core.DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	)
+	// Keep the state of every block, the miner builds on top of it directly
+	cache.TrieDirtyDisabled = true
+	cache.SnapshotLimit = 0
+
+	// Resolve ties in favour of the first block seen. The default coin toss
+	// would make the simulation nondeterministic.
+	firstSeen := func(*types.Header) bool { return true }
+
+	chain, err := core.NewBlockChain(db, cache, s.config.Genesis, nil, engine, vm.Config{}, firstSeen, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", config.Name, err)
+	}
+	n := &Node{
+		sim:      s,
+		name:     config.Name,
+		hashrate: config.Hashrate,
+		finality: config.ArtificialFinality,
+		safety:   config.Safety,
+		coinbase: common.BytesToAddress([]byte(config.Name)),
+		db:       db,
+		chain:    chain,
+		engine:   engine,
+	}
+	s.nodes = append(s.nodes, n)
+	for _, peer := range s.nodes {
+		if n.reaches(peer) {
+			s.exchangeHeads(n, peer)
+		}
+	}
+	n.schedule()
+	return n, nil
+}
+
+// Nodes returns the nodes of the simulation.
+func (s *Simulation) Nodes() []*Node {
+	return s.nodes
+}
+
+// Elapsed returns the simulated time since the start of the simulation.
+func (s *Simulation) Elapsed() time.Duration {
+	return time.Duration(s.clock.Now() - s.start)
+}
+
+// Run advances the simulation by the given duration. It returns the failure of
+// a node stopping the simulation, if any.
+func (s *Simulation) Run(d time.Duration) error {
+	_, _, err := s.RunUntil(d, nil)
+	return err
+}
+
+// RunUntil advances the simulation until the condition holds, for at most the
+// given duration. The condition is checked at every step of the clock. It returns
+// the time it took, whether the condition was met and the failure of a node
+// stopping the simulation, if any.
+func (s *Simulation) RunUntil(max time.Duration, cond func() bool) (time.Duration, bool, error) {
+	begin := s.clock.Now()
+	for {
+		elapsed := time.Duration(s.clock.Now() - begin)
+		if s.err != nil {
+			return elapsed, false, s.err
+		}
+		for _, n := range s.nodes {
+			n.updateFinality()
+		}
+		if cond != nil && cond() {
+			return elapsed, true, nil
+		}
+		if elapsed >= max {
+			return elapsed, false, nil
+		}
+		s.clock.Run(min(s.config.Step, max-elapsed))
+	}
+}
+
+// RunUntilConverged advances the simulation until all nodes agree on the head
+// block, for at most the given duration. It returns the time it took, whether the
+// nodes converged and the failure of a node stopping the simulation, if any.
+func (s *Simulation) RunUntilConverged(max time.Duration) (time.Duration, bool, error) {
+	return s.RunUntil(max, s.Converged)
+}
+
+// fail stops the simulation with the failure of a node. Only the first failure
+// is kept, the later ones are likely caused by it.
+func (s *Simulation) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Converged reports whether all nodes have the same head block.
+func (s *Simulation) Converged() bool {
+	for _, n := range s.nodes[1:] {
+		if n.Head().Hash() != s.nodes[0].Head().Hash() {
+			return false
+		}
+	}
+	return true
+}
+
+// Partition splits the network into the given groups of nodes. Nodes in different
+// groups can't reach each other, nodes not listed form a group of their own.
+// Blocks in flight across the partition are lost.
+func (s *Simulation) Partition(groups ...[]*Node) {
+	for _, n := range s.nodes {
+		n.group = 0
+	}
+	for i, group := range groups {
+		for _, n := range group {
+			n.group = i + 1
+		}
+	}
+}
+
+// Heal removes all partitions. The nodes which couldn't reach each other before
+// exchange their heads.
+func (s *Simulation) Heal() {
+	var pairs [][2]*Node
+	for i, a := range s.nodes {
+		for _, b := range s.nodes[i+1:] {
+			if !a.reaches(b) {
+				pairs = append(pairs, [2]*Node{a, b})
+			}
+		}
+	}
+	for _, n := range s.nodes {
+		n.group = 0
+	}
+	for _, pair := range pairs {
+		s.exchangeHeads(pair[0], pair[1])
+	}
+}
+
+// exchangeHeads sends the heads of two nodes to each other, unless they are
+// withholding them.
+func (s *Simulation) exchangeHeads(a, b *Node) {
+	if !a.withhold {
+		s.send(a, b, a.headBlock())
+	}
+	if !b.withhold {
+		s.send(b, a, b.headBlock())
+	}
+}
+
+// send delivers a block to a node after the propagation latency. The total
+// difficulty of the block is announced to all peers of the sender right away.
+func (s *Simulation) send(from, to *Node, block *types.Block) {
+	if td := from.chain.GetTd(block.Hash(), block.NumberU64()); td != nil && (from.announced == nil || td.Cmp(from.announced) > 0) {
+		from.announced = td
+	}
+	s.clock.AfterFunc(s.config.Latency, func() {
+		if s.err != nil || !from.reaches(to) {
+			return
+		}
+		if err := to.receive(from, block); err != nil {
+			s.fail(err)
+		}
+	})
+}
+
+// now returns the current time of the simulated network as a block timestamp.
+func (s *Simulation) now() uint64 {
+	return s.config.Genesis.Timestamp + uint64(s.Elapsed()/time.Second)
+}
+
+// Node is a simulated PoW node.
+type Node struct {
+	sim      *Simulation
+	name     string
+	coinbase common.Address
+	db       ethdb.Database
+	chain    *core.BlockChain
+	engine   *ethash.Ethash
+
+	hashrate  float64
+	finality  bool                               // Whether ECBP1100 is activated
+	safety    ethconfig.ArtificialFinalitySafety // Conditions for artificial finality
+	announced *big.Int                           // Highest total difficulty sent to the peers
+	withhold  bool
+	group     int
+	timer     mclock.Timer // Scheduled mining event
+	mining    uint64       // Generation of the scheduled mining event, bumped to cancel it
+	mined     int
+}
+
+// Name returns the name of the node.
+func (n *Node) Name() string {
+	return n.name
+}
+
+// String implements fmt.Stringer.
+func (n *Node) String() string {
+	return n.name
+}
+
+// Chain returns the blockchain of the node.
+func (n *Node) Chain() *core.BlockChain {
+	return n.chain
+}
+
+// Head returns the head block header of the node.
+func (n *Node) Head() *types.Header {
+	return n.chain.CurrentBlock()
+}
+
+// TD returns the total difficulty of the head block of the node.
+func (n *Node) TD() *big.Int {
+	head := n.Head()
+	return n.chain.GetTd(head.Hash(), head.Number.Uint64())
+}
+
+// Mined returns the number of blocks mined by the node.
+func (n *Node) Mined() int {
+	return n.mined
+}
+
+// HasBlock reports whether the node knows the block, canonical or not.
+func (n *Node) HasBlock(hash common.Hash) bool {
+	return n.chain.GetHeaderByHash(hash) != nil
+}
+
+// Canonical reports whether the block is part of the canonical chain of the node.
+func (n *Node) Canonical(hash common.Hash) bool {
+	header := n.chain.GetHeaderByHash(hash)
+	return header != nil && n.chain.GetCanonicalHash(header.Number.Uint64()) == hash
+}
+
+// RejectedReorg reports whether the node refused to reorg to the given block,
+// even though it knows the block and its total difficulty exceeds the one of
+// the head. Only artificial finality rejects such reorgs.
+func (n *Node) RejectedReorg(hash common.Hash) bool {
+	header := n.chain.GetHeaderByHash(hash)
+	if header == nil || n.Canonical(hash) {
+		return false
+	}
+	td := n.chain.GetTd(hash, header.Number.Uint64())
+	return td != nil && td.Cmp(n.TD()) > 0
+}
+
+// SetHashrate changes the hashrate of the miner of the node, zero stops mining.
+func (n *Node) SetHashrate(hashrate float64) {
+	n.hashrate = max(hashrate, 0)
+	n.schedule()
+}
+
+// Withhold makes the node keep the blocks it mines and imports to itself, like a
+// private chain attacker.
+func (n *Node) Withhold() {
+	n.withhold = true
+}
+
+// Release publishes the chain withheld by the node, sending its head to the
+// reachable nodes.
+func (n *Node) Release() {
+	n.withhold = false
+	n.broadcast(n.headBlock(), nil)
+}
+
+// updateFinality enables or disables artificial finality the way the eth handler
+// does after a sync cycle: it is disabled when a safety condition fails, and
+// enabled when all of them hold and no peer announced a heavier chain.
+func (n *Node) updateFinality() {
+	if !n.finality {
+		return
+	}
+	var (
+		td     = n.TD()
+		peers  int
+		synced = true
+	)
+	for _, peer := range n.sim.nodes {
+		if !n.reaches(peer) {
+			continue
+		}
+		peers++
+		if peer.announced != nil && peer.announced.Cmp(td) > 0 {
+			synced = false
+		}
+	}
+	var (
+		age   = time.Duration(int64(n.sim.now())-int64(n.Head().Time)) * time.Second
+		unmet = n.safety.Unmet(peers, peers, min(peers, 1), age)
+	)
+	switch enabled := n.chain.IsArtificialFinalityEnabled(); {
+	case enabled && len(unmet) > 0:
+		n.chain.EnableArtificialFinality(false, "node", n.name, "reason", strings.Join(unmet, ", "))
+	case !enabled && len(unmet) == 0 && synced && peers > 0:
+		n.chain.EnableArtificialFinality(true, "node", n.name, "reason", "synced")
+	}
+}
+
+// reaches reports whether the node can send blocks to another one.
+func (n *Node) reaches(peer *Node) bool {
+	return n != peer && n.group == peer.group
+}
+
+// headBlock returns the head block of the node.
+func (n *Node) headBlock() *types.Block {
+	head := n.Head()
+	return n.chain.GetBlock(head.Hash(), head.Number.Uint64())
+}
+
+// broadcast sends a block to all reachable nodes except the one it came from.
+func (n *Node) broadcast(block *types.Block, origin *Node) {
+	for _, peer := range n.sim.nodes {
+		if peer != origin && n.reaches(peer) {
+			n.sim.send(n, peer, block)
+		}
+	}
+}
+
+// schedule plans the next block of the miner on top of the current head,
+// cancelling the previous one. The time to find a block is the difficulty of the
+// head over the hashrate, fixed with ModeFake and exponentially distributed with
+// ModePoissonFake, making block discovery a Poisson process.
+func (n *Node) schedule() {
+	// Timers due in the current step of the clock can't be stopped anymore, the
+	// generation check below drops them.
+	n.mining++
+	if n.timer != nil {
+		n.timer.Stop()
+	}
+	if n.hashrate == 0 {
+		return
+	}
+	var (
+		generation = n.mining
+		mean       = new(big.Float).Quo(new(big.Float).SetInt(n.Head().Difficulty), big.NewFloat(n.hashrate))
+		seconds, _ = mean.Float64()
+	)
+	if n.sim.config.PowMode == ethash.ModePoissonFake {
+		seconds *= n.sim.rand.ExpFloat64()
+	}
+	n.timer = n.sim.clock.AfterFunc(time.Duration(seconds*float64(time.Second)), func() {
+		if n.sim.err != nil || n.mining != generation {
+			return
+		}
+		if err := n.mine(); err != nil {
+			n.sim.fail(err)
+		}
+	})
+}
+
+// mine seals a block on top of the head of the node and publishes it.
+func (n *Node) mine() error {
+	var (
+		parent = n.headBlock()
+		now    = max(n.sim.now(), parent.Time()+1)
+		nonce  = n.sim.rand.Uint64()
+	)
+	blocks, _ := core.GenerateChain(n.chain.Config(), parent, n.engine, n.db, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(n.coinbase)
+		b.SetNonce(types.EncodeNonce(nonce))
+		b.OffsetTime(int64(now) - int64(b.Timestamp()))
+	})
+	if _, err := n.chain.InsertChain(blocks); err != nil {
+		return fmt.Errorf("%s: mined invalid block: %v", n.name, err)
+	}
+	n.mined++
+	if !n.withhold {
+		n.broadcast(blocks[0], nil)
+	}
+	n.schedule()
+	return nil
+}
+
+// receive imports a block sent by another node, fetching the ancestors it
+// doesn't know from the sender. New heads are relayed to the other nodes.
+func (n *Node) receive(from *Node, block *types.Block) error {
+	if n.HasBlock(block.Hash()) {
+		return nil
+	}
+	blocks := []*types.Block{block}
+	for parent := block; !n.HasBlock(parent.ParentHash()); {
+		if parent = from.chain.GetBlock(parent.ParentHash(), parent.NumberU64()-1); parent == nil {
+			return fmt.Errorf("%s: ancestor of block %d missing on %s", n.name, block.NumberU64(), from.name)
+		}
+		blocks = append(blocks, parent)
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	head := n.Head().Hash()
+	if _, err := n.chain.InsertChain(blocks); err != nil {
+		return fmt.Errorf("%s: invalid block from %s: %v", n.name, from.name, err)
+	}
+	if n.Head().Hash() != head {
+		if !n.withhold {
+			n.broadcast(n.headBlock(), from)
+		}
+		n.schedule()
+	}
+	return nil
+}
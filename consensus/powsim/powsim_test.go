@@ -0,0 +1,259 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package powsim
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// networkHashrate is the hashrate giving 13 second blocks at the messnet genesis
+// difficulty.
+var networkHashrate = float64(params.DefaultMessNetGenesisBlock().Difficulty.Uint64()) / 13
+
+// newTestNetwork creates a simulation with honest nodes sharing the network
+// hashrate, and an attacker with the given multiple of it.
+func newTestNetwork(t *testing.T, config Config, honest int, finality bool, attack float64) (*Simulation, []*Node, *Node) {
+	sim := New(config)
+	t.Cleanup(sim.Close)
+
+	var nodes []*Node
+	for i := 0; i < honest; i++ {
+		n, err := sim.AddNode(NodeConfig{Hashrate: networkHashrate / float64(honest), ArtificialFinality: finality})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, n)
+	}
+	attacker, err := sim.AddNode(NodeConfig{Name: "attacker", Hashrate: attack * networkHashrate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim, nodes, attacker
+}
+
+// run advances the simulation, failing the test if a node fails.
+func run(t *testing.T, sim *Simulation, d time.Duration) {
+	t.Helper()
+	if err := sim.Run(d); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runAttack lets the network run, then has the attacker mine a private chain for
+// the given duration before releasing it. It returns the released head.
+func runAttack(t *testing.T, sim *Simulation, attacker *Node, private time.Duration) *types.Header {
+	// Run past the ECBP1100 activation together
+	run(t, sim, 5*time.Minute)
+	if _, ok, err := sim.RunUntilConverged(time.Minute); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("network not converged before the attack")
+	}
+	attacker.Withhold()
+	sim.Partition([]*Node{attacker})
+	run(t, sim, private)
+
+	sim.Heal()
+	attacker.Release()
+	head := attacker.Head()
+	run(t, sim, time.Second)
+	return head
+}
+
+// Tests that nodes on both sides of a partition agree on a chain once it heals.
+// The miners have different hashrates, with equal ones fake PoW miners would
+// find their blocks at the same time forever.
+func TestPartitionConvergence(t *testing.T) {
+	for _, mode := range []ethash.Mode{ethash.ModeFake, ethash.ModePoissonFake} {
+		t.Run(mode.String(), func(t *testing.T) {
+			sim := New(Config{PowMode: mode, Seed: 1})
+			defer sim.Close()
+
+			for i := 1; i <= 4; i++ {
+				if _, err := sim.AddNode(NodeConfig{Hashrate: networkHashrate * float64(i) / 10, ArtificialFinality: true}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			nodes := sim.Nodes()
+			run(t, sim, time.Minute)
+
+			sim.Partition(nodes[:2], nodes[2:])
+			run(t, sim, 10*time.Minute)
+			if sim.Converged() {
+				t.Fatal("partitioned network converged")
+			}
+			heavy := nodes[2]
+			if nodes[0].TD().Cmp(heavy.TD()) > 0 {
+				heavy = nodes[0]
+			}
+			head := heavy.Head().Hash()
+
+			sim.Heal()
+			took, ok, err := sim.RunUntilConverged(time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Fatal("network not converged after healing the partition")
+			}
+			t.Logf("converged in %v", took)
+
+			// The chain of the heavier side of the partition must have won
+			for _, n := range nodes {
+				if !n.Canonical(head) {
+					t.Errorf("%s: chain of the heavier side not adopted", n)
+				}
+			}
+		})
+	}
+}
+
+// Tests that ECBP1100 makes the honest nodes reject the release of a private
+// chain mined during an hour with twice their hashrate, while nodes without it
+// reorg to the attacker's chain.
+func TestDeepReorg(t *testing.T) {
+	for _, finality := range []bool{true, false} {
+		sim, nodes, attacker := newTestNetwork(t, Config{Seed: 2}, 3, finality, 0)
+		run(t, sim, time.Second)
+		attacker.SetHashrate(2 * networkHashrate)
+		head := runAttack(t, sim, attacker, time.Hour)
+		for _, n := range nodes {
+			if finality && (n.Canonical(head.Hash()) || !n.RejectedReorg(head.Hash())) {
+				t.Errorf("%s: reorg to private chain not rejected with ECBP1100", n)
+			}
+			if !finality && !n.Canonical(head.Hash()) {
+				t.Errorf("%s: no reorg to heavier private chain without ECBP1100", n)
+			}
+		}
+	}
+}
+
+// Tests that artificial finality follows the safety conditions: a node isolated
+// for an hour keeps its own chain with ECBP1100, unless losing its peers disabled
+// it, in which case it reorgs to the heavier chain of the network once healed.
+func TestFinalitySafety(t *testing.T) {
+	for _, minPeers := range []int{0, 1} {
+		sim := New(Config{Seed: 7})
+		t.Cleanup(sim.Close)
+
+		var nodes []*Node
+		for i := 0; i < 3; i++ {
+			n, err := sim.AddNode(NodeConfig{
+				Hashrate:           networkHashrate / 3,
+				ArtificialFinality: true,
+				Safety:             ethconfig.ArtificialFinalitySafety{MinPeers: minPeers},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes = append(nodes, n)
+		}
+		run(t, sim, 5*time.Minute)
+		for _, n := range nodes {
+			if !n.Chain().IsArtificialFinalityEnabled() {
+				t.Fatalf("min peers %d: %s: artificial finality not enabled on a synced node", minPeers, n)
+			}
+		}
+		isolated := nodes[0]
+		sim.Partition([]*Node{isolated})
+		run(t, sim, time.Hour)
+		if enabled := isolated.Chain().IsArtificialFinalityEnabled(); enabled != (minPeers == 0) {
+			t.Fatalf("min peers %d: isolated node finality mismatch: have %v, want %v", minPeers, enabled, minPeers == 0)
+		}
+		head := nodes[1].Head().Hash()
+		if nodes[2].TD().Cmp(nodes[1].TD()) > 0 {
+			head = nodes[2].Head().Hash()
+		}
+		sim.Heal()
+		run(t, sim, time.Second)
+
+		if minPeers == 0 && (isolated.Canonical(head) || !isolated.RejectedReorg(head)) {
+			t.Errorf("min peers %d: reorg to the network chain not rejected", minPeers)
+		}
+		if minPeers > 0 && !isolated.Canonical(head) {
+			t.Errorf("min peers %d: no reorg to the network chain", minPeers)
+		}
+	}
+}
+
+// Tests that ECBP1100 lets a short private chain with a better difficulty win.
+func TestShallowReorg(t *testing.T) {
+	sim, nodes, attacker := newTestNetwork(t, Config{Seed: 3}, 3, true, 2)
+	head := runAttack(t, sim, attacker, 2*time.Minute)
+	for _, n := range nodes {
+		if !n.Canonical(head.Hash()) {
+			t.Errorf("%s: shallow reorg to heavier chain rejected", n)
+		}
+	}
+}
+
+// Tests that miners stop producing blocks without hashrate.
+func TestHashrateChange(t *testing.T) {
+	sim, nodes, _ := newTestNetwork(t, Config{Seed: 4}, 2, true, 0)
+	run(t, sim, 5*time.Minute)
+	if nodes[0].Mined() == 0 || nodes[1].Mined() == 0 {
+		t.Fatalf("miners idle: mined %d and %d", nodes[0].Mined(), nodes[1].Mined())
+	}
+	nodes[0].SetHashrate(0)
+	mined := nodes[0].Mined()
+	run(t, sim, 5*time.Minute)
+	if nodes[0].Mined() != mined {
+		t.Fatalf("miner without hashrate produced %d blocks", nodes[0].Mined()-mined)
+	}
+}
+
+// Tests that simulations with the same seed produce the same chains.
+func TestDeterminism(t *testing.T) {
+	run := func() *Node {
+		sim, nodes, _ := newTestNetwork(t, Config{Seed: 5}, 3, true, 0)
+		run(t, sim, 2*time.Minute)
+		sim.Partition(nodes[:1])
+		run(t, sim, 2*time.Minute)
+		sim.Heal()
+		run(t, sim, time.Minute)
+		return nodes[0]
+	}
+	a, b := run(), run()
+	if a.Head().Hash() != b.Head().Hash() {
+		t.Fatalf("head mismatch: %d %x, %d %x", a.Head().Number, a.Head().Hash(), b.Head().Number, b.Head().Hash())
+	}
+}
+
+// Tests that a node failing to import a block stops the simulation with an error.
+func TestInvalidBlock(t *testing.T) {
+	sim, nodes, _ := newTestNetwork(t, Config{Seed: 6}, 2, true, 0)
+	run(t, sim, time.Minute)
+
+	header := types.CopyHeader(nodes[0].Head())
+	header.ParentHash, header.Number = header.Hash(), new(big.Int).Add(header.Number, common.Big1)
+	header.Root = common.Hash{0x01}
+	sim.send(nodes[0], nodes[1], types.NewBlockWithHeader(header))
+	if err := sim.Run(time.Minute); err == nil {
+		t.Fatal("invalid block accepted")
+	}
+	if err := sim.Run(time.Minute); err == nil {
+		t.Fatal("simulation resumed after a failure")
+	}
+}
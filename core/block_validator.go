@@ -23,7 +23,6 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/vars"
 	"github.com/ethereum/go-ethereum/trie"
 )
@@ -33,15 +32,13 @@ import (
 //
 // BlockValidator implements Validator.
 type BlockValidator struct {
-	config ctypes.ChainConfigurator // Chain configuration options
-	bc     *BlockChain              // Canonical block chain
-	engine consensus.Engine         // Consensus engine used for validating
+	bc     *BlockChain      // Canonical block chain
+	engine consensus.Engine // Consensus engine used for validating
 }
 
 // NewBlockValidator returns a new block validator which is safe for re-use
-func NewBlockValidator(config ctypes.ChainConfigurator, blockchain *BlockChain, engine consensus.Engine) *BlockValidator {
+func NewBlockValidator(blockchain *BlockChain, engine consensus.Engine) *BlockValidator {
 	validator := &BlockValidator{
-		config: config,
 		engine: engine,
 		bc:     blockchain,
 	}
@@ -139,7 +136,8 @@ func (v *BlockValidator) ValidateState(block *types.Block, statedb *state.StateD
 		return fmt.Errorf("invalid receipt root hash (remote: %x local: %x)", header.ReceiptHash, receiptSha)
 	}
 	// Validate the state root against the received state root and throw
-	if root := statedb.IntermediateRoot(v.config.IsEnabled(v.config.GetEIP161dTransition, header.Number)); header.Root != root {
+	config := v.bc.Config()
+	if root := statedb.IntermediateRoot(config.IsEnabled(config.GetEIP161dTransition, header.Number)); header.Root != root {
 		return fmt.Errorf("invalid merkle root (remote: %x local: %x) dberr: %w", header.Root, root, statedb.Error())
 	}
 	return nil
@@ -48,6 +48,7 @@ import (
 	"github.com/ethereum/go-ethereum/params/confp"
 	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/types/genesisT"
+	"github.com/ethereum/go-ethereum/params/vars"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/triedb"
 	"github.com/ethereum/go-ethereum/triedb/hashdb"
@@ -144,6 +145,7 @@ type CacheConfig struct {
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
 	StateHistory        uint64        // Number of blocks from head whose state histories are reserved.
+	ReceiptHistory      uint64        // Number of blocks from head whose receipts are stored, older ones are regenerated on demand (0 = all)
 	StateScheme         string        // Scheme used to store ethereum states and merkle tree nodes on top
 
 	SnapshotNoBuild bool // Whether the background generation is allowed
@@ -209,8 +211,7 @@ type txLookup struct {
 // included in the canonical one where as GetBlockByNumber always represents the
 // canonical chain.
 type BlockChain struct {
-	chainConfig ctypes.ChainConfigurator // Chain & network configuration
-	cacheConfig *CacheConfig             // Cache configuration for pruning
+	cacheConfig *CacheConfig // Cache configuration for pruning
 
 	db            ethdb.Database                   // Low level persistent database to store final content in
 	snaps         *snapshot.Tree                   // Snapshot tree for fast trie leaf access
@@ -221,6 +222,7 @@ type BlockChain struct {
 	triedb        *triedb.Database                 // The database handler for maintaining trie nodes.
 	stateCache    state.Database                   // State database to reuse between imports (contains state cache)
 	txIndexer     *txIndexer                       // Transaction indexer, might be nil if not enabled
+	receiptTail   atomic.Uint64                    // Number of the first block whose receipts are not pruned yet, 0 if unknown
 
 	hc            *HeaderChain
 	rmLogsFeed    event.Feed
@@ -273,6 +275,20 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *genesis
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
+	// Receipts can only be pruned before they are moved into the ancient store
+	if cacheConfig.ReceiptHistory > vars.FullImmutabilityThreshold {
+		log.Warn("Receipt history exceeds the ancient store threshold, capping", "history", cacheConfig.ReceiptHistory, "threshold", vars.FullImmutabilityThreshold)
+		capped := *cacheConfig
+		capped.ReceiptHistory = vars.FullImmutabilityThreshold
+		cacheConfig = &capped
+	}
+	// Pruned receipts are regenerated on top of the state of the parent block,
+	// which must be retained for the whole pruned range
+	if cacheConfig.ReceiptHistory != 0 && !cacheConfig.TrieDirtyDisabled {
+		if cacheConfig.StateScheme != rawdb.PathScheme || cacheConfig.StateHistory != 0 {
+			return nil, fmt.Errorf("receipt history limit requires an archive node or the %s state scheme with the entire state history", rawdb.PathScheme)
+		}
+	}
 	// Open trie database with provided config
 	triedb := triedb.NewDatabase(db, cacheConfig.triedbConfig())
 
@@ -293,7 +309,6 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *genesis
 	log.Info("")
 
 	bc := &BlockChain{
-		chainConfig:   chainConfig,
 		cacheConfig:   cacheConfig,
 		db:            db,
 		triedb:        triedb,
@@ -312,9 +327,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *genesis
 	bc.flushInterval.Store(int64(cacheConfig.TrieTimeLimit))
 	bc.forker = NewForkChoice(bc, shouldPreserve)
 	bc.stateCache = state.NewDatabaseWithNodeDB(bc.db, bc.triedb)
-	bc.validator = NewBlockValidator(chainConfig, bc, engine)
-	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
-	bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	bc.validator = NewBlockValidator(bc, engine)
+	bc.prefetcher = newStatePrefetcher(bc, engine)
+	bc.processor = NewStateProcessor(bc, engine)
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
@@ -332,7 +347,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *genesis
 	bc.currentSafeBlock.Store(nil)
 
 	// Update chain info data metrics
-	chainInfoGauge.Update(metrics.GaugeInfoValue{"chain_id": bc.chainConfig.GetChainID().String()})
+	chainInfoGauge.Update(metrics.GaugeInfoValue{"chain_id": bc.Config().GetChainID().String()})
 
 	// If Geth is initialized with an external ancient store, re-initialize the
 	// missing chain indexes and chain flags. This procedure can survive crash
@@ -852,6 +867,42 @@ func (bc *BlockChain) SnapSyncCommitHead(hash common.Hash) error {
 	return nil
 }
 
+// SetChainConfig replaces the chain configuration with the given one and
+// persists it. Only changes to transitions not yet reached by the current head
+// are permitted, so the already processed chain remains valid under the new
+// rules. The live configuration is never modified, readers holding it keep a
+// consistent view while the update is swapped in.
+func (bc *BlockChain) SetChainConfig(config ctypes.ChainConfigurator) error {
+	if !bc.chainmu.TryLock() {
+		return errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	current := bc.Config()
+	if have, want := current.GetConsensusEngineType(), config.GetConsensusEngineType(); have != want {
+		return fmt.Errorf("consensus engine mismatch: have %v, want %v", have, want)
+	}
+	if have, want := current.GetChainID(), config.GetChainID(); (have == nil) != (want == nil) || (have != nil && have.Cmp(want) != 0) {
+		return fmt.Errorf("chain ID mismatch: have %v, want %v", have, want)
+	}
+	// Convert the new configuration into a copy of the live one, so that the
+	// update keeps its type and any fields the new one can't express.
+	updated, err := confp.CloneChainConfigurator(current)
+	if err != nil {
+		return err
+	}
+	if err := confp.Crush(updated, config, true); err != nil {
+		return err
+	}
+	head := bc.CurrentBlock()
+	if compatErr := confp.Compatible(head.Number, &head.Time, current, updated); compatErr != nil {
+		return compatErr
+	}
+	rawdb.WriteChainConfig(bc.db, bc.genesisBlock.Hash(), updated)
+	bc.hc.setConfig(updated)
+	return nil
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -940,6 +991,7 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 	rawdb.WriteCanonicalHash(batch, block.Hash(), block.NumberU64())
 	rawdb.WriteTxLookupEntriesByBlock(batch, block)
 	rawdb.WriteHeadBlockHash(batch, block.Hash())
+	bc.pruneReceipts(batch, block.NumberU64())
 
 	// Flush the whole batch into the disk, exit the node if failed
 	if err := batch.Write(); err != nil {
@@ -1017,7 +1069,10 @@ func (bc *BlockChain) Stop() {
 			for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
 				if number := bc.CurrentBlock().Number.Uint64(); number > offset {
 					recent := bc.GetBlockByNumber(number - offset)
-
+					if recent == nil {
+						// The ancestors of a chain started from an imported state are missing
+						continue
+					}
 					log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
 					if err := triedb.Commit(recent.Root(), true); err != nil {
 						log.Error("Failed to commit recent state trie", "err", err)
@@ -1110,6 +1165,8 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 			}
 		}
 		if block.NumberU64() <= ancientLimit {
+			// The receipts are stored even if the receipt history is limited, the
+			// states of the blocks below the sync pivot are missing to regenerate them
 			ancientBlocks, ancientReceipts = append(ancientBlocks, block), append(ancientReceipts, receiptChain[i])
 		} else {
 			liveBlocks, liveReceipts = append(liveBlocks, block), append(liveReceipts, receiptChain[i])
@@ -1375,7 +1432,8 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		log.Crit("Failed to write block into disk", "err", err)
 	}
 	// Commit all cached state changes into underlying memory database.
-	root, err := state.Commit(block.NumberU64(), bc.chainConfig.IsEnabled(bc.chainConfig.GetEIP161dTransition, block.Number()))
+	config := bc.Config()
+	root, err := state.Commit(block.NumberU64(), config.IsEnabled(config.GetEIP161dTransition, block.Number()))
 	if err != nil {
 		return err
 	}
@@ -1567,13 +1625,14 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 	}
 
 	// Start a parallel signature recovery (signer will fluke on fork transition, minimal perf loss)
-	SenderCacher.RecoverFromBlocks(types.MakeSigner(bc.chainConfig, chain[0].Number(), chain[0].Time()), chain)
+	config := bc.Config()
+	SenderCacher.RecoverFromBlocks(types.MakeSigner(config, chain[0].Number(), chain[0].Time()), chain)
 
 	var (
 		stats = insertStats{
 			startTime: mclock.Now(),
 			artificialFinality: bc.IsArtificialFinalityEnabled() &&
-				bc.chainConfig.IsEnabled(bc.chainConfig.GetECBP1100Transition, bc.CurrentBlock().Number),
+				config.IsEnabled(config.GetECBP1100Transition, bc.CurrentBlock().Number),
 		}
 		lastCanon *types.Block
 	)
@@ -1717,7 +1776,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 		// snapshot layer is missing, forcibly rerun the execution to build it.
 		if bc.skipBlock(err, it) {
 			logger := log.Debug
-			if !bc.chainConfig.GetConsensusEngineType().IsClique() {
+			if !bc.Config().GetConsensusEngineType().IsClique() {
 				logger = log.Warn
 			}
 			logger("Inserted known block", "number", block.Number(), "hash", block.Hash(),
@@ -2102,7 +2161,7 @@ func (bc *BlockChain) collectLogs(b *types.Block, removed bool) []*types.Log {
 		blobGasPrice = eip4844.CalcBlobFee(*excessBlobGas)
 	}
 	receipts := rawdb.ReadRawReceipts(bc.db, b.Hash(), b.NumberU64())
-	if err := receipts.DeriveFields(bc.chainConfig, b.Hash(), b.NumberU64(), b.Time(), b.BaseFee(), blobGasPrice, b.Transactions()); err != nil {
+	if err := receipts.DeriveFields(bc.Config(), b.Hash(), b.NumberU64(), b.Time(), b.BaseFee(), blobGasPrice, b.Transactions()); err != nil {
 		log.Error("Failed to derive block receipts fields", "hash", b.Hash(), "number", b.NumberU64(), "err", err)
 	}
 	var logs []*types.Log
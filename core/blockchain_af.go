@@ -25,12 +25,12 @@ func (bc *BlockChain) ArtificialFinalityNoDisable(n int32) {
 	atomic.StoreInt32(bc.artificialFinalityNoDisable, n)
 
 	if n == 1 {
-		deactivateTransition := bc.chainConfig.GetECBP1100DeactivateTransition()
+		deactivateTransition := bc.Config().GetECBP1100DeactivateTransition()
 		if deactivateTransition != nil && big.NewInt(int64(*deactivateTransition)).Cmp(big.NewInt(0)) > 0 {
 			// Log the activation block as well as the deactivation block.
 			// Context is nice to have for the user.
 			var logActivationBlock uint64
-			logActivationBlockRaw := bc.chainConfig.GetECBP1100Transition()
+			logActivationBlockRaw := bc.Config().GetECBP1100Transition()
 			if logActivationBlockRaw == nil {
 				// panic("impossible")
 				logActivationBlock = *deactivateTransition
@@ -72,7 +72,7 @@ func (bc *BlockChain) EnableArtificialFinality(enable bool, logValues ...interfa
 		statusLog = "Disabled"
 		atomic.StoreInt32(&bc.artificialFinalityEnabledStatus, 0)
 	}
-	if !bc.chainConfig.IsEnabled(bc.chainConfig.GetECBP1100Transition, bc.CurrentHeader().Number) {
+	if config := bc.Config(); !config.IsEnabled(config.GetECBP1100Transition, bc.CurrentHeader().Number) {
 		// Don't log anything if the config hasn't enabled it yet.
 		return
 	}
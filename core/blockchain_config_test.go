@@ -0,0 +1,88 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/confp"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+)
+
+// Tests that the chain configuration can be updated on a live chain as long as
+// only transitions beyond the current head change.
+func TestSetChainConfig(t *testing.T) {
+	config, err := confp.CloneChainConfigurator(params.ClassicChainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gspec := &genesisT.Genesis{Config: config}
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 10, nil)
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	update := func(fn func(ctypes.ChainConfigurator)) ctypes.ChainConfigurator {
+		c, err := confp.CloneChainConfigurator(chain.Config())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(c)
+		return c
+	}
+	// Scheduling a future transition must be accepted and persisted.
+	previous := chain.Config()
+	deactivate := uint64(100)
+	if err := chain.SetChainConfig(update(func(c ctypes.ChainConfigurator) { c.SetECBP1100DeactivateTransition(&deactivate) })); err != nil {
+		t.Fatalf("failed to schedule future transition: %v", err)
+	}
+	if have := chain.Config().GetECBP1100DeactivateTransition(); have == nil || *have != deactivate {
+		t.Errorf("live configuration not updated: have %v, want %d", have, deactivate)
+	}
+	if have := previous.GetECBP1100DeactivateTransition(); have != nil && *have == deactivate {
+		t.Errorf("previous configuration modified in place")
+	}
+	stored := rawdb.ReadChainConfig(db, chain.Genesis().Hash())
+	if have := stored.GetECBP1100DeactivateTransition(); have == nil || *have != deactivate {
+		t.Errorf("stored configuration not updated: have %v, want %d", have, deactivate)
+	}
+	// Moving a transition below the current head must be rejected.
+	passed := uint64(5)
+	if err := chain.SetChainConfig(update(func(c ctypes.ChainConfigurator) { c.SetEIP150Transition(&passed) })); err == nil {
+		t.Errorf("transition below head accepted")
+	}
+	if have := chain.Config().GetEIP150Transition(); have != nil && *have == passed {
+		t.Errorf("rejected configuration applied")
+	}
+	// Changing the chain ID must be rejected.
+	if err := chain.SetChainConfig(update(func(c ctypes.ChainConfigurator) { c.SetChainID(big.NewInt(1)) })); err == nil {
+		t.Errorf("chain ID change accepted")
+	}
+}
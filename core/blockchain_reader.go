@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/triedb"
@@ -222,7 +223,20 @@ func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
 	if header == nil {
 		return nil
 	}
-	receipts := rawdb.ReadReceipts(bc.db, hash, *number, header.Time, bc.chainConfig)
+	var receipts types.Receipts
+	if bc.ReceiptsPruned(hash, *number) {
+		block := bc.GetBlock(hash, *number)
+		if block == nil {
+			return nil
+		}
+		var err error
+		if receipts, err = bc.regenerateReceipts(block); err != nil {
+			log.Debug("Failed to regenerate pruned receipts", "number", *number, "hash", hash, "err", err)
+			return nil
+		}
+	} else {
+		receipts = rawdb.ReadReceipts(bc.db, hash, *number, header.Time, bc.Config())
+	}
 	if receipts == nil {
 		return nil
 	}
@@ -305,8 +319,8 @@ func (bc *BlockChain) GetTd(hash common.Hash, number uint64) *big.Int {
 
 // HasState checks if state trie is fully present in the database or not.
 func (bc *BlockChain) HasState(hash common.Hash) bool {
-	_, err := bc.stateCache.OpenTrie(hash)
-	return err == nil
+	tr, err := bc.stateCache.OpenTrie(hash)
+	return err == nil && !state.IsHistoricTrie(tr)
 }
 
 // HasBlockAndState checks if a block and associated state trie is fully present
@@ -357,7 +371,7 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 }
 
 // Config retrieves the chain's fork configuration.
-func (bc *BlockChain) Config() ctypes.ChainConfigurator { return bc.chainConfig }
+func (bc *BlockChain) Config() ctypes.ChainConfigurator { return bc.hc.Config() }
 
 // Engine retrieves the blockchain's consensus engine.
 func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
@@ -0,0 +1,128 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// If the receipt history is limited, the receipts of the canonical blocks which
+// fall out of it are replaced with empty lists in the database, which are then
+// moved into the ancient store as such. A pruned block is recognized by an empty
+// receipt list stored for a block whose header commits to a non-empty one. The
+// receipts of pruned blocks are regenerated on demand by re-executing the block
+// on top of the state of its parent, which is available on archive nodes and in
+// the path scheme from the state histories. The blocks synced below the pivot of
+// a snap sync have no states, their receipts are never pruned.
+
+// receiptPruneLimit is the maximum number of blocks whose receipts are pruned
+// along with a new head, so that enabling the pruning on an existing chain
+// catches up gradually instead of in a single huge batch.
+const receiptPruneLimit = 1024
+
+// pruneReceipts prunes the receipts of the canonical blocks which fell out of
+// the receipt history with the given block as the head. Only blocks which were
+// not moved into the ancient store yet can be pruned. The progress is written
+// into the given batch, so that the pruning resumes there after a restart.
+func (bc *BlockChain) pruneReceipts(db ethdb.KeyValueWriter, head uint64) {
+	history := bc.cacheConfig.ReceiptHistory
+	if history == 0 || head <= history {
+		return
+	}
+	from := bc.receiptTail.Load()
+	if from == 0 {
+		if tail := rawdb.ReadReceiptPruneTail(bc.db); tail != nil {
+			from = *tail
+		}
+		frozen, _ := bc.db.Ancients()
+		from = max(from, frozen, 1)
+
+		// The parent states of the blocks up to a snap sync pivot are missing
+		if pivot := rawdb.ReadLastPivotNumber(bc.db); pivot != nil {
+			from = max(from, *pivot+1)
+		}
+	}
+	limit := min(head-history, from+receiptPruneLimit)
+	if from >= limit {
+		return
+	}
+	for number := from; number < limit; number++ {
+		hash := rawdb.ReadCanonicalHash(bc.db, number)
+		if hash == (common.Hash{}) {
+			continue
+		}
+		rawdb.WriteReceipts(db, hash, number, nil)
+	}
+	rawdb.WriteReceiptPruneTail(db, limit)
+	bc.receiptTail.Store(limit)
+	log.Debug("Pruned block receipts", "from", from, "to", limit)
+}
+
+// ReceiptsPruned reports whether the receipts of the given block were pruned
+// from the database, they are regenerated on demand by GetReceiptsByHash.
+func (bc *BlockChain) ReceiptsPruned(hash common.Hash, number uint64) bool {
+	header := bc.GetHeader(hash, number)
+	if header == nil || header.ReceiptHash == types.EmptyReceiptsHash {
+		return false
+	}
+	return bytes.Equal(rawdb.ReadReceiptsRLP(bc.db, hash, number), rlp.EmptyList)
+}
+
+// regenerateReceipts re-executes the block on top of the state of its parent to
+// regenerate its pruned receipts.
+func (bc *BlockChain) regenerateReceipts(block *types.Block) (types.Receipts, error) {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	statedb, err := bc.StateAt(parent.Root)
+	if err != nil {
+		return nil, fmt.Errorf("state of block %d unavailable: %w", parent.Number, err)
+	}
+	receipts, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig)
+	if err != nil {
+		return nil, err
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != block.ReceiptHash() {
+		return nil, fmt.Errorf("receipt root mismatch: have %x, want %x", root, block.ReceiptHash())
+	}
+	// Derive the fields the same way as for the stored receipts
+	baseFee := block.BaseFee()
+	if baseFee == nil {
+		baseFee = big.NewInt(0)
+	}
+	var blobGasPrice *big.Int
+	if excess := block.ExcessBlobGas(); excess != nil {
+		blobGasPrice = eip4844.CalcBlobFee(*excess)
+	}
+	if err := receipts.DeriveFields(bc.Config(), block.Hash(), block.NumberU64(), block.Time(), baseFee, blobGasPrice, block.Transactions()); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
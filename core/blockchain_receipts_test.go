@@ -0,0 +1,164 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+	"github.com/ethereum/go-ethereum/params/vars"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Tests that the receipts of blocks falling out of the receipt history are
+// pruned, and regenerated on demand by re-executing the blocks.
+func TestReceiptHistory(t *testing.T) {
+	testReceiptHistory(t, rawdb.HashScheme)
+	testReceiptHistory(t, rawdb.PathScheme)
+}
+
+func testReceiptHistory(t *testing.T, scheme string) {
+	var (
+		logger = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		engine = ethash.NewFaker()
+
+		// A sender who makes transactions, has some funds
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000000000)
+		gspec   = &genesisT.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: genesisT.GenesisAlloc{
+				address: {Balance: funds},
+				// The address 0xAAAA emits an empty log
+				logger: {
+					Code: []byte{
+						byte(vm.PUSH1), 0,
+						byte(vm.PUSH1), 0,
+						byte(vm.LOG0),
+					},
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(gspec, engine, 10, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), logger, big.NewInt(1), 50000, b.header.BaseFee, nil), signer, key)
+		b.AddTx(tx)
+	})
+	// Keep all states around, so that all blocks can be re-executed
+	config := *DefaultCacheConfigWithScheme(scheme)
+	config.TrieDirtyDisabled = true
+	config.StateHistory = 0
+	config.ReceiptHistory = 3
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), &config, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	for i, block := range blocks {
+		var (
+			number = block.NumberU64()
+			pruned = number < 10-config.ReceiptHistory
+			raw    = rawdb.ReadReceiptsRLP(chain.db, block.Hash(), number)
+		)
+		if have := chain.ReceiptsPruned(block.Hash(), number); have != pruned {
+			t.Fatalf("%s: block %d: pruned mismatch: have %v, want %v", scheme, number, have, pruned)
+		}
+		if pruned != bytes.Equal(raw, rlp.EmptyList) {
+			t.Fatalf("%s: block %d: stored receipts mismatch: %x", scheme, number, raw)
+		}
+		have := chain.GetReceiptsByHash(block.Hash())
+		if len(have) != len(receipts[i]) {
+			t.Fatalf("%s: block %d: receipt count mismatch: have %d, want %d", scheme, number, len(have), len(receipts[i]))
+		}
+		if root := types.DeriveSha(have, trie.NewStackTrie(nil)); root != block.ReceiptHash() {
+			t.Fatalf("%s: block %d: receipt root mismatch: have %x, want %x", scheme, number, root, block.ReceiptHash())
+		}
+		for j, receipt := range have {
+			if receipt.TxHash != block.Transactions()[j].Hash() || receipt.BlockHash != block.Hash() || receipt.GasUsed != receipts[i][j].GasUsed {
+				t.Fatalf("%s: block %d: receipt %d fields mismatch", scheme, number, j)
+			}
+			if len(receipt.Logs) != 1 || receipt.Logs[0].Address != logger || receipt.Logs[0].BlockNumber != number {
+				t.Fatalf("%s: block %d: receipt %d logs mismatch", scheme, number, j)
+			}
+		}
+	}
+	if tail := rawdb.ReadReceiptPruneTail(chain.db); tail == nil || *tail != 10-config.ReceiptHistory {
+		t.Fatalf("%s: receipt prune tail mismatch: have %v, want %d", scheme, tail, 10-config.ReceiptHistory)
+	}
+	// The receipt history is capped to the ancient store threshold
+	config.ReceiptHistory = vars.FullImmutabilityThreshold + 1
+	capped, err := NewBlockChain(rawdb.NewMemoryDatabase(), &config, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer capped.Stop()
+	if capped.cacheConfig.ReceiptHistory != vars.FullImmutabilityThreshold {
+		t.Fatalf("receipt history not capped: %d", capped.cacheConfig.ReceiptHistory)
+	}
+}
+
+// Tests that the receipt history can only be limited if the states needed to
+// regenerate the pruned receipts are retained.
+func TestReceiptHistoryStateRequirement(t *testing.T) {
+	gspec := &genesisT.Genesis{Config: params.TestChainConfig}
+	for _, test := range []struct {
+		scheme   string
+		archive  bool
+		states   uint64
+		receipts uint64
+		valid    bool
+	}{
+		{scheme: rawdb.HashScheme, archive: true, receipts: 10, valid: true},
+		{scheme: rawdb.HashScheme, receipts: 10},
+		{scheme: rawdb.PathScheme, receipts: 10, valid: true},
+		{scheme: rawdb.PathScheme, states: 10, receipts: 10},
+		{scheme: rawdb.PathScheme, states: 90000, receipts: 10},
+		{scheme: rawdb.PathScheme, states: 9, receipts: 10},
+		{scheme: rawdb.HashScheme, valid: true},
+	} {
+		config := *DefaultCacheConfigWithScheme(test.scheme)
+		config.TrieDirtyDisabled = test.archive
+		config.StateHistory = test.states
+		config.ReceiptHistory = test.receipts
+
+		chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err == nil {
+			chain.Stop()
+		}
+		if (err == nil) != test.valid {
+			t.Errorf("%+v: error mismatch: have %v, want valid %v", test, err, test.valid)
+		}
+	}
+}
@@ -29,15 +29,20 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/params/types/genesisT"
 	"github.com/ethereum/go-ethereum/params/vars"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/triedb"
 )
 
 // snapshotTestBasic wraps the common testing fields in the snapshot tests.
@@ -713,3 +718,95 @@ func TestRecoverSnapshotFromWipingCrash(t *testing.T) {
 		test.teardown()
 	}
 }
+
+// Tests that a chain can be opened on a database the state of its head block
+// was imported into, with the state read from the imported snapshot.
+func TestImportStateSnapshot(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		engine   = ethash.NewFaker()
+		gspec    = &genesisT.Genesis{
+			Config: params.AllEthashProtocolChanges,
+			Alloc: genesisT.GenesisAlloc{
+				sender:   {Balance: big.NewInt(vars.Ether)},
+				contract: {Balance: big.NewInt(1), Code: []byte{0x60, 0x00, 0x60, 0x00, 0x55}, Storage: map[common.Hash]common.Hash{{0x01}: {0x02}}},
+			},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 8, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), common.Address{byte(i + 1)}, big.NewInt(int64(i+1)), vars.TxGas, b.header.BaseFee, nil), signer, key)
+		b.AddTx(tx)
+	})
+	config := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	config.SnapshotWait = true
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), config, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("Failed to insert chain: %v", err)
+	}
+	head := chain.CurrentBlock()
+	var export bytes.Buffer
+	if err := snapshot.ExportState(&export, chain.Snapshots(), chain.db, chain.GetBlockByHash(head.Hash()), chain.GetTd(head.Hash(), head.Number.Uint64())); err != nil {
+		t.Fatalf("Failed to export state: %v", err)
+	}
+	want, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, scheme := range []string{rawdb.HashScheme, rawdb.PathScheme} {
+		// Import the state into a database initialized with the genesis only
+		db := rawdb.NewMemoryDatabase()
+		if _, err := CommitGenesis(gspec, db, triedb.NewDatabase(db, triedb.HashDefaults)); err != nil {
+			t.Fatal(err)
+		}
+		s := rlp.NewStream(bytes.NewReader(export.Bytes()), 0)
+		block, td, err := snapshot.ReadStateExportHeader(s)
+		if err != nil {
+			t.Fatalf("%s: failed to read export header: %v", scheme, err)
+		}
+		if err := snapshot.ImportState(s, db, scheme, block, td); err != nil {
+			t.Fatalf("%s: failed to import state: %v", scheme, err)
+		}
+		imported, err := NewBlockChain(db, DefaultCacheConfigWithScheme(scheme), gspec, nil, engine, vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to open chain on imported state: %v", scheme, err)
+		}
+		if have := imported.CurrentBlock(); have.Hash() != head.Hash() {
+			t.Errorf("%s: head block mismatch: have %d, want %d", scheme, have.Number, head.Number)
+		}
+		if have := imported.CurrentSnapBlock(); have.Hash() != head.Hash() {
+			t.Errorf("%s: head snap block mismatch: have %d, want %d", scheme, have.Number, head.Number)
+		}
+		if have := imported.CurrentHeader(); have.Hash() != head.Hash() {
+			t.Errorf("%s: head header mismatch: have %d, want %d", scheme, have.Number, head.Number)
+		}
+		if have := imported.GetTd(head.Hash(), head.Number.Uint64()); have == nil || have.Cmp(td) != 0 {
+			t.Errorf("%s: total difficulty mismatch: have %v, want %v", scheme, have, td)
+		}
+		if imported.Snapshots() == nil || imported.Snapshots().Snapshot(head.Root) == nil {
+			t.Errorf("%s: imported snapshot not loaded", scheme)
+		}
+		state, err := imported.State()
+		if err != nil {
+			t.Fatalf("%s: failed to open head state: %v", scheme, err)
+		}
+		for _, addr := range []common.Address{sender, contract, {0x01}, {0x08}} {
+			if have, want := state.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+				t.Errorf("%s: balance mismatch for %x: have %v, want %v", scheme, addr, have, want)
+			}
+		}
+		if have := state.GetState(contract, common.Hash{0x01}); have != (common.Hash{0x02}) {
+			t.Errorf("%s: storage mismatch: have %x", scheme, have)
+		}
+		if have := state.GetCode(contract); !bytes.Equal(have, gspec.Alloc[contract].Code) {
+			t.Errorf("%s: code mismatch: have %x", scheme, have)
+		}
+		imported.Stop()
+	}
+}
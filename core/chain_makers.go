@@ -119,8 +119,13 @@ func (b *BlockGen) addTx(bc *BlockChain, vmConfig vm.Config, tx *types.Transacti
 	if b.gasPool == nil {
 		b.SetCoinbase(common.Address{})
 	}
+	// Without a chain, BLOCKHASH is served from the generated blocks
+	var chain ChainContext = b.cm
+	if bc != nil {
+		chain = bc
+	}
 	b.statedb.SetTxContext(tx.Hash(), len(b.txs))
-	receipt, err := ApplyTransaction(b.cm.config, bc, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vmConfig)
+	receipt, err := ApplyTransaction(b.cm.config, chain, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vmConfig)
 	if err != nil {
 		panic(err)
 	}
@@ -137,8 +142,7 @@ func (b *BlockGen) addTx(bc *BlockChain, vmConfig vm.Config, tx *types.Transacti
 // AddTx panics if the transaction cannot be executed. In addition to the protocol-imposed
 // limitations (gas limit, etc.), there are some further limitations on the content of
 // transactions that can be added. Notably, contract code relying on the BLOCKHASH
-// instruction will panic during execution if it attempts to access a block number outside
-// of the range created by GenerateChain.
+// instruction will only see the blocks in the range created by GenerateChain.
 func (b *BlockGen) AddTx(tx *types.Transaction) {
 	b.addTx(nil, vm.Config{}, tx)
 }
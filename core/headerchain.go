@@ -55,7 +55,7 @@ const (
 // It is not thread safe either, the encapsulating chain structures should do
 // the necessary mutex locking/unlocking.
 type HeaderChain struct {
-	config        ctypes.ChainConfigurator
+	config        atomic.Pointer[ctypes.ChainConfigurator] // Chain & network configuration, swapped on updates
 	chainDb       ethdb.Database
 	genesisHeader *types.Header
 
@@ -75,7 +75,6 @@ type HeaderChain struct {
 // to the parent's interrupt semaphore.
 func NewHeaderChain(chainDb ethdb.Database, config ctypes.ChainConfigurator, engine consensus.Engine, procInterrupt func() bool) (*HeaderChain, error) {
 	hc := &HeaderChain{
-		config:        config,
 		chainDb:       chainDb,
 		headerCache:   lru.NewCache[common.Hash, *types.Header](headerCacheLimit),
 		tdCache:       lru.NewCache[common.Hash, *big.Int](tdCacheLimit),
@@ -83,6 +82,7 @@ func NewHeaderChain(chainDb ethdb.Database, config ctypes.ChainConfigurator, eng
 		procInterrupt: procInterrupt,
 		engine:        engine,
 	}
+	hc.config.Store(&config)
 	hc.genesisHeader = hc.GetHeaderByNumber(0)
 	if hc.genesisHeader == nil {
 		return nil, ErrNoGenesis
@@ -686,7 +686,10 @@ func (hc *HeaderChain) SetGenesis(head *types.Header) {
 }
 
 // Config retrieves the header chain's chain configuration.
-func (hc *HeaderChain) Config() ctypes.ChainConfigurator { return hc.config }
+func (hc *HeaderChain) Config() ctypes.ChainConfigurator { return *hc.config.Load() }
+
+// setConfig replaces the header chain's chain configuration.
+func (hc *HeaderChain) setConfig(config ctypes.ChainConfigurator) { hc.config.Store(&config) }
 
 // Engine retrieves the header chain's consensus engine.
 func (hc *HeaderChain) Engine() consensus.Engine { return hc.engine }
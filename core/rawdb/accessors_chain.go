@@ -278,6 +278,25 @@ func WriteTxIndexTail(db ethdb.KeyValueWriter, number uint64) {
 	}
 }
 
+// ReadReceiptPruneTail retrieves the number of the oldest block whose receipts
+// haven't been pruned yet.
+func ReadReceiptPruneTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(receiptPruneTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteReceiptPruneTail stores the number of the oldest block whose receipts
+// haven't been pruned yet into database.
+func WriteReceiptPruneTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(receiptPruneTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the receipt prune tail", "err", err)
+	}
+}
+
 // ReadHeaderRange returns the rlp-encoded headers, starting at 'number', and going
 // backwards towards genesis. This method assumes that the caller already has
 // placed a cap on count, to prevent DoS issues.
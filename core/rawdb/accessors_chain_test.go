@@ -496,6 +496,57 @@ func TestAncientStorage(t *testing.T) {
 	}
 }
 
+func TestAncientStorageOptions(t *testing.T) {
+	opts := FreezerOptions{Compression: FreezerZstd, Checksum: true}
+	if !zstdSupported {
+		opts.Compression = FreezerSnappy
+	}
+	frdir := t.TempDir()
+	db, err := NewDatabaseWithFreezerOptions(NewMemoryDatabase(), frdir, "", false, opts)
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend: %v", err)
+	}
+	blocks := makeTestBlocks(100, 5)
+	receipts := make([]types.Receipts, len(blocks))
+	for i := range receipts {
+		for j := 0; j < 5; j++ {
+			receipts[i] = append(receipts[i], &types.Receipt{
+				Status:            types.ReceiptStatusSuccessful,
+				CumulativeGasUsed: uint64(j),
+				Logs:              []*types.Log{},
+			})
+		}
+	}
+	if _, err := WriteAncientBlocks(db, blocks, receipts, big.NewInt(100)); err != nil {
+		t.Fatalf("failed to write ancient blocks: %v", err)
+	}
+	db.Close()
+
+	// The tables keep their format when reopened with the defaults.
+	db, err = NewDatabaseWithFreezer(NewMemoryDatabase(), frdir, "", false)
+	if err != nil {
+		t.Fatalf("failed to reopen database with ancient backend: %v", err)
+	}
+	defer db.Close()
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		if body := ReadBody(db, hash, number); body == nil || len(body.Transactions) != 5 || body.Transactions[4].Hash() != block.Transactions()[4].Hash() {
+			t.Fatalf("invalid body of block %d", number)
+		}
+		if receipts := ReadRawReceipts(db, hash, number); len(receipts) != 5 {
+			t.Fatalf("invalid receipts of block %d", number)
+		}
+	}
+	// Repaired items are served instead of the stored ones.
+	td, _ := rlp.EncodeToBytes(big.NewInt(42))
+	if err := db.RepairAncient(ChainFreezerDifficultyTable, 10, td); err != nil {
+		t.Fatalf("failed to repair item: %v", err)
+	}
+	if have := ReadTd(db, blocks[10].Hash(), 10); have == nil || have.Uint64() != 42 {
+		t.Fatalf("repaired td not served: %v", have)
+	}
+}
+
 func TestCanonicalHashIteration(t *testing.T) {
 	var cases = []struct {
 		from, to uint64
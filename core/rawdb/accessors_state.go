@@ -160,6 +160,131 @@ func DeleteTrieJournal(db ethdb.KeyValueWriter) {
 	}
 }
 
+// ReadStateHistoryIndexTail retrieves the id of the oldest state history from
+// which on all state histories have been indexed. Nil is returned if the index
+// has never been initialized.
+func ReadStateHistoryIndexTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateHistoryIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryIndexTail stores the id of the oldest indexed state history.
+func WriteStateHistoryIndexTail(db ethdb.KeyValueWriter, id uint64) {
+	if err := db.Put(stateHistoryIndexTailKey, encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store the state history index tail", "err", err)
+	}
+}
+
+// ReadStateConversion retrieves the serialized progress of an interrupted state
+// scheme conversion.
+func ReadStateConversion(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(stateConversionKey)
+	return data
+}
+
+// WriteStateConversion stores the serialized progress of a state scheme conversion.
+func WriteStateConversion(db ethdb.KeyValueWriter, progress []byte) {
+	if err := db.Put(stateConversionKey, progress); err != nil {
+		log.Crit("Failed to store state conversion progress", "err", err)
+	}
+}
+
+// DeleteStateConversion deletes the progress of a finished state scheme conversion.
+func DeleteStateConversion(db ethdb.KeyValueWriter) {
+	if err := db.Delete(stateConversionKey); err != nil {
+		log.Crit("Failed to remove state conversion progress", "err", err)
+	}
+}
+
+// WriteStateHistoryAccountIndex records that the given state history modifies
+// the specified account.
+func WriteStateHistoryAccountIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Put(stateHistoryAccountIndexKey(address, id), nil); err != nil {
+		log.Crit("Failed to store account history index", "err", err)
+	}
+}
+
+// DeleteStateHistoryAccountIndex removes the specified account history index entry.
+func DeleteStateHistoryAccountIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Delete(stateHistoryAccountIndexKey(address, id)); err != nil {
+		log.Crit("Failed to delete account history index", "err", err)
+	}
+}
+
+// WriteStateHistoryStorageIndex records that the given state history modifies
+// the specified storage slot.
+func WriteStateHistoryStorageIndex(db ethdb.KeyValueWriter, address common.Address, slot common.Hash, id uint64) {
+	if err := db.Put(stateHistoryStorageIndexKey(address, slot, id), nil); err != nil {
+		log.Crit("Failed to store storage history index", "err", err)
+	}
+}
+
+// DeleteStateHistoryStorageIndex removes the specified storage history index entry.
+func DeleteStateHistoryStorageIndex(db ethdb.KeyValueWriter, address common.Address, slot common.Hash, id uint64) {
+	if err := db.Delete(stateHistoryStorageIndexKey(address, slot, id)); err != nil {
+		log.Crit("Failed to delete storage history index", "err", err)
+	}
+}
+
+// WriteStateHistoryIncompleteIndex records that the storage changes of the given
+// account are incomplete in the specified state history.
+func WriteStateHistoryIncompleteIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Put(stateHistoryIncompleteIndexKey(address, id), nil); err != nil {
+		log.Crit("Failed to store incomplete history index", "err", err)
+	}
+}
+
+// DeleteStateHistoryIncompleteIndex removes the specified incomplete history index entry.
+func DeleteStateHistoryIncompleteIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Delete(stateHistoryIncompleteIndexKey(address, id)); err != nil {
+		log.Crit("Failed to delete incomplete history index", "err", err)
+	}
+}
+
+// IterateStateHistoryAccountIndex invokes fn with the ids of the state histories
+// modifying the given account in ascending order, starting from the given id,
+// until fn returns false.
+func IterateStateHistoryAccountIndex(db ethdb.Iteratee, address common.Address, start uint64, fn func(id uint64) bool) error {
+	return iterateStateHistoryIndex(db, stateHistoryAccountIndexKey(address, start), fn)
+}
+
+// IterateStateHistoryStorageIndex invokes fn with the ids of the state histories
+// modifying the given storage slot in ascending order, starting from the given
+// id, until fn returns false.
+func IterateStateHistoryStorageIndex(db ethdb.Iteratee, address common.Address, slot common.Hash, start uint64, fn func(id uint64) bool) error {
+	return iterateStateHistoryIndex(db, stateHistoryStorageIndexKey(address, slot, start), fn)
+}
+
+// IterateStateHistoryIncompleteIndex invokes fn with the ids of the state histories
+// with incomplete storage changes of the given account in ascending order, starting
+// from the given id, until fn returns false.
+func IterateStateHistoryIncompleteIndex(db ethdb.Iteratee, address common.Address, start uint64, fn func(id uint64) bool) error {
+	return iterateStateHistoryIndex(db, stateHistoryIncompleteIndexKey(address, start), fn)
+}
+
+// iterateStateHistoryIndex iterates the index entries sharing the prefix of the
+// given start key, i.e. the key without the trailing id.
+func iterateStateHistoryIndex(db ethdb.Iteratee, startKey []byte, fn func(id uint64) bool) error {
+	prefix := startKey[:len(startKey)-8]
+	it := db.NewIterator(prefix, startKey[len(prefix):])
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(startKey) {
+			continue
+		}
+		if !fn(binary.BigEndian.Uint64(key[len(prefix):])) {
+			break
+		}
+	}
+	return it.Error()
+}
+
 // ReadStateHistoryMeta retrieves the metadata corresponding to the specified
 // state history. Compute the position of state history in freezer by minus
 // one since the id of first state history starts from one(zero for initial
@@ -44,6 +44,26 @@ func DeleteSkeletonSyncStatus(db ethdb.KeyValueWriter) {
 	}
 }
 
+// ReadPoWSkeletonSyncStatus retrieves the serialized header-first PoW sync status.
+func ReadPoWSkeletonSyncStatus(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(powSkeletonSyncStatusKey)
+	return data
+}
+
+// WritePoWSkeletonSyncStatus stores the serialized header-first PoW sync status.
+func WritePoWSkeletonSyncStatus(db ethdb.KeyValueWriter, status []byte) {
+	if err := db.Put(powSkeletonSyncStatusKey, status); err != nil {
+		log.Crit("Failed to store PoW skeleton sync status", "err", err)
+	}
+}
+
+// DeletePoWSkeletonSyncStatus deletes the serialized header-first PoW sync status.
+func DeletePoWSkeletonSyncStatus(db ethdb.KeyValueWriter) {
+	if err := db.Delete(powSkeletonSyncStatusKey); err != nil {
+		log.Crit("Failed to remove PoW skeleton sync status", "err", err)
+	}
+}
+
 // ReadSkeletonHeader retrieves a block header from the skeleton sync store,
 func ReadSkeletonHeader(db ethdb.KeyValueReader, number uint64) *types.Header {
 	data, _ := db.Get(skeletonHeaderKey(number))
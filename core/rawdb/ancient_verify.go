@@ -0,0 +1,95 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// verifyBatchSize is the number of items read at once when verifying a freezer.
+const verifyBatchSize = 1024
+
+// CorruptAncient describes an item of an ancient store which can't be read or
+// is inconsistent with the rest of the data.
+type CorruptAncient struct {
+	Freezer string // Name of the ancient store, ChainFreezerName or StateFreezerName
+	Kind    string // Table of the item
+	Number  uint64 // Number of the item
+	Err     error  // Reason the item is considered corrupted
+}
+
+// VerifyStateFreezer checks that all items of the state history freezer of the
+// database can be read, calling onCorrupt for each item that can't.
+func VerifyStateFreezer(db ethdb.Database, onCorrupt func(CorruptAncient)) error {
+	if ReadStateScheme(db) != PathScheme {
+		return nil
+	}
+	datadir, err := db.AncientDatadir()
+	if err != nil {
+		return err
+	}
+	f, err := NewStateFreezer(datadir, true)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return verifyFreezerReads(StateFreezerName, stateFreezerNoSnappy, f, onCorrupt)
+}
+
+// ReadAncientItems reads a range of items of a table, falling back to reading
+// the items one by one if the range can't be read, so that the failures can be
+// attributed to the individual items. Unreadable items are left nil, with the
+// error reported in errs.
+func ReadAncientItems(reader ethdb.AncientReader, kind string, start, count uint64) ([][]byte, map[uint64]error) {
+	items, err := reader.AncientRange(kind, start, count, 0)
+	if err == nil && uint64(len(items)) == count {
+		return items, nil
+	}
+	items = make([][]byte, count)
+	errs := make(map[uint64]error)
+	for i := uint64(0); i < count; i++ {
+		item, err := reader.Ancient(kind, start+i)
+		if err != nil {
+			errs[start+i] = err
+			continue
+		}
+		items[i] = item
+	}
+	return items, errs
+}
+
+// verifyFreezerReads checks that all items of the given tables can be read.
+func verifyFreezerReads(name string, tables map[string]bool, reader ethdb.AncientReader, onCorrupt func(CorruptAncient)) error {
+	tail, err := reader.Tail()
+	if err != nil {
+		return err
+	}
+	head, err := reader.Ancients()
+	if err != nil {
+		return err
+	}
+	for kind := range tables {
+		for start := tail; start < head; start += verifyBatchSize {
+			_, errs := ReadAncientItems(reader, kind, start, min(verifyBatchSize, head-start))
+			for number, err := range errs {
+				onCorrupt(CorruptAncient{Freezer: name, Kind: kind, Number: number, Err: err})
+			}
+		}
+	}
+	return nil
+}
@@ -46,14 +46,15 @@ type chainFreezer struct {
 	threshold atomic.Uint64 // Number of recent blocks not to freeze (params.FullImmutabilityThreshold apart from tests)
 
 	*Freezer
+	history *eraHistory // Chain segments offloaded into era1 files, nil if not configured
 	quit    chan struct{}
 	wg      sync.WaitGroup
 	trigger chan chan struct{} // Manual blocking freeze trigger, test determinism
 }
 
 // newChainFreezer initializes the freezer for ancient chain data.
-func newChainFreezer(datadir string, namespace string, readonly bool) (*chainFreezer, error) {
-	freezer, err := NewChainFreezer(datadir, namespace, readonly)
+func newChainFreezer(datadir string, namespace string, readonly bool, opts FreezerOptions) (*chainFreezer, error) {
+	freezer, err := NewChainFreezerWithOptions(datadir, namespace, readonly, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -62,6 +63,12 @@ func newChainFreezer(datadir string, namespace string, readonly bool) (*chainFre
 		quit:    make(chan struct{}),
 		trigger: make(chan chan struct{}),
 	}
+	if opts.EraDir != "" {
+		if cf.history, err = newEraHistory(opts.EraDir); err != nil {
+			freezer.Close()
+			return nil, err
+		}
+	}
 	cf.threshold.Store(vars.FullImmutabilityThreshold)
 	return &cf, nil
 }
@@ -74,6 +81,9 @@ func (f *chainFreezer) Close() error {
 		close(f.quit)
 	}
 	f.wg.Wait()
+	if f.history != nil {
+		f.history.close()
+	}
 	return f.Freezer.Close()
 }
 
@@ -0,0 +1,495 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/era"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// eraOpenLimit is the maximum number of era1 files kept open at once.
+const eraOpenLimit = 16
+
+// errReceiptsPruned is returned if chain history whose receipts were pruned is
+// offloaded, the era1 files must contain them.
+var errReceiptsPruned = errors.New("receipts pruned, chain history can't be offloaded")
+
+// eraHistory serves the chain segments which were offloaded from the chain
+// freezer into era1 files. The files are expected to cover the chain from the
+// genesis block without gaps, one file per epoch of era.MaxEra1Size blocks.
+//
+// The items are converted back into the format of the freezer tables, so that
+// the history is served transparently through the ethdb.AncientReader interface.
+type eraHistory struct {
+	dir   string
+	files []string         // File names of the era1 files, indexed by epoch
+	head  uint64           // Number of blocks covered by the files
+	open  map[int]*era.Era // Currently opened era1 files, indexed by epoch
+	lock  sync.Mutex
+}
+
+// newEraHistory opens the era1 files in the given directory, creating it if
+// it doesn't exist yet.
+func newEraHistory(dir string) (*eraHistory, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	h := &eraHistory{
+		dir:  dir,
+		open: make(map[int]*era.Era),
+	}
+	if err := h.scan(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// scan lists the era1 files of the directory, picking up the ones which were
+// added since the last scan. The caller must hold the lock.
+func (h *eraHistory) scan() error {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return err
+	}
+	epochs := make(map[int]string)
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".era1" {
+			continue
+		}
+		// Format: <network>-<epoch>-<hexroot>.era1, the network name might
+		// contain dashes itself.
+		parts := strings.Split(entry.Name(), "-")
+		if len(parts) < 3 {
+			continue
+		}
+		epoch, err := strconv.Atoi(parts[len(parts)-2])
+		if err != nil {
+			return fmt.Errorf("malformed era1 filename: %s", entry.Name())
+		}
+		if prev, ok := epochs[epoch]; ok {
+			return fmt.Errorf("duplicate era1 files for epoch %d: %s, %s", epoch, prev, entry.Name())
+		}
+		epochs[epoch] = entry.Name()
+	}
+	var files []string
+	for epoch := 0; ; epoch++ {
+		name, ok := epochs[epoch]
+		if !ok {
+			break
+		}
+		files = append(files, name)
+	}
+	if len(files) < len(epochs) {
+		log.Warn("Ignoring era1 files after gap", "dir", h.dir, "epoch", len(files))
+	}
+	if len(files) == len(h.files) {
+		return nil
+	}
+	// Only the last file may contain less than a full epoch of blocks.
+	head := uint64(0)
+	if len(files) > 0 {
+		last := len(files) - 1
+		e, err := era.Open(filepath.Join(h.dir, files[last]))
+		if err != nil {
+			return err
+		}
+		defer e.Close()
+		if e.Start() != uint64(last)*uint64(era.MaxEra1Size) {
+			return fmt.Errorf("era1 file %s starts at block %d", files[last], e.Start())
+		}
+		head = e.Start() + e.Count()
+	}
+	h.files, h.head = files, head
+	return nil
+}
+
+// items returns the number of blocks covered by the history.
+func (h *eraHistory) items() uint64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.head
+}
+
+// covers reports whether the history covers all blocks below the given number,
+// rescanning the directory for new files if it doesn't.
+func (h *eraHistory) covers(number uint64) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if number <= h.head {
+		return true
+	}
+	if err := h.scan(); err != nil {
+		log.Error("Failed to scan era1 files", "dir", h.dir, "err", err)
+	}
+	return number <= h.head
+}
+
+// era returns the era1 file holding the given block. The caller must hold the
+// lock.
+func (h *eraHistory) era(number uint64) (*era.Era, error) {
+	if number >= h.head {
+		if err := h.scan(); err != nil {
+			return nil, err
+		}
+		if number >= h.head {
+			return nil, errOutOfBounds
+		}
+	}
+	epoch := int(number / uint64(era.MaxEra1Size))
+	if e, ok := h.open[epoch]; ok {
+		return e, nil
+	}
+	e, err := era.Open(filepath.Join(h.dir, h.files[epoch]))
+	if err != nil {
+		return nil, err
+	}
+	if e.Start() != uint64(epoch)*uint64(era.MaxEra1Size) {
+		e.Close()
+		return nil, fmt.Errorf("era1 file %s starts at block %d", h.files[epoch], e.Start())
+	}
+	if len(h.open) >= eraOpenLimit {
+		for n, e := range h.open {
+			e.Close()
+			delete(h.open, n)
+			break
+		}
+	}
+	h.open[epoch] = e
+	return e, nil
+}
+
+// item retrieves an item of the given chain freezer table from the history,
+// in the format it's stored in the freezer.
+func (h *eraHistory) item(kind string, number uint64) ([]byte, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	e, err := h.era(number)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case ChainFreezerHeaderTable:
+		return e.GetRawHeaderByNumber(number)
+	case ChainFreezerHashTable:
+		header, err := e.GetRawHeaderByNumber(number)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(header), nil
+	case ChainFreezerBodiesTable:
+		return e.GetRawBodyByNumber(number)
+	case ChainFreezerReceiptTable:
+		blob, err := e.GetRawReceiptsByNumber(number)
+		if err != nil {
+			return nil, err
+		}
+		return storageReceipts(blob)
+	case ChainFreezerDifficultyTable:
+		td, err := e.GetTotalDifficultyByNumber(number)
+		if err != nil {
+			return nil, err
+		}
+		return rlp.EncodeToBytes(td)
+	default:
+		return nil, errUnknownTable
+	}
+}
+
+// close closes the opened era1 files.
+func (h *eraHistory) close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for epoch, e := range h.open {
+		e.Close()
+		delete(h.open, epoch)
+	}
+}
+
+// storageReceipts converts the consensus encoded receipts of an era1 file into
+// the storage encoding of the freezer.
+func storageReceipts(blob []byte) ([]byte, error) {
+	var receipts types.Receipts
+	if err := rlp.DecodeBytes(blob, &receipts); err != nil {
+		return nil, err
+	}
+	stored := make([]*types.ReceiptForStorage, len(receipts))
+	for i, receipt := range receipts {
+		stored[i] = (*types.ReceiptForStorage)(receipt)
+	}
+	return rlp.EncodeToBytes(stored)
+}
+
+// consensusReceipts converts the storage encoded receipts of the freezer into
+// the consensus encoding of era1 files. The body is needed to recover the types
+// of the receipts, which are not stored.
+func consensusReceipts(blob []byte, body *types.Body) ([]byte, error) {
+	var stored []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(blob, &stored); err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 && len(body.Transactions) > 0 {
+		return nil, errReceiptsPruned
+	}
+	if len(stored) != len(body.Transactions) {
+		return nil, fmt.Errorf("receipt count mismatch: have %d, want %d", len(stored), len(body.Transactions))
+	}
+	receipts := make(types.Receipts, len(stored))
+	for i, receipt := range stored {
+		receipts[i] = (*types.Receipt)(receipt)
+		receipts[i].Type = body.Transactions[i].Type()
+	}
+	return rlp.EncodeToBytes(receipts)
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists,
+// either in the freezer or in the offloaded history.
+func (f *chainFreezer) HasAncient(kind string, number uint64) (bool, error) {
+	if f.history != nil && number < f.Freezer.tail.Load() {
+		if _, ok := chainFreezerNoSnappy[kind]; !ok {
+			return false, nil
+		}
+		return f.history.covers(number + 1), nil
+	}
+	return f.Freezer.HasAncient(kind, number)
+}
+
+// Ancient retrieves an ancient binary blob, either from the freezer or from the
+// offloaded history.
+func (f *chainFreezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if f.history != nil && number < f.Freezer.tail.Load() {
+		return f.history.item(kind, number)
+	}
+	return f.Freezer.Ancient(kind, number)
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index
+// 'start', with the same semantics as the one of the freezer. Items below the
+// tail of the freezer are served from the offloaded history.
+func (f *chainFreezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	tail := f.Freezer.tail.Load()
+	if f.history == nil || start >= tail {
+		return f.Freezer.AncientRange(kind, start, count, maxBytes)
+	}
+	var (
+		items [][]byte
+		size  uint64
+	)
+	for number := start; number < start+count && number < tail; number++ {
+		item, err := f.history.item(kind, number)
+		if err != nil {
+			// Stop silently at the end of the range only, any other failure is
+			// reported, as is the lack of any item.
+			if len(items) == 0 || !errors.Is(err, errOutOfBounds) {
+				return nil, err
+			}
+			return items, nil
+		}
+		if maxBytes != 0 && len(items) > 0 && size+uint64(len(item)) > maxBytes {
+			return items, nil
+		}
+		items = append(items, item)
+		size += uint64(len(item))
+	}
+	remaining := count - uint64(len(items))
+	if remaining == 0 || (maxBytes != 0 && size >= maxBytes) {
+		return items, nil
+	}
+	budget := uint64(0)
+	if maxBytes != 0 {
+		budget = maxBytes - size
+	}
+	more, err := f.Freezer.AncientRange(kind, tail, remaining, budget)
+	if err != nil {
+		if !errors.Is(err, errOutOfBounds) {
+			return nil, err
+		}
+		return items, nil
+	}
+	for _, item := range more {
+		if budget != 0 && uint64(len(item)) > budget {
+			break
+		}
+		items = append(items, item)
+		if budget != 0 {
+			budget -= uint64(len(item))
+		}
+	}
+	return items, nil
+}
+
+// Tail returns the number of the first item available, which is the genesis if
+// the offloaded history reaches up to the tail of the freezer.
+func (f *chainFreezer) Tail() (uint64, error) {
+	tail := f.Freezer.tail.Load()
+	if f.history != nil && f.history.covers(tail) {
+		return 0, nil
+	}
+	return tail, nil
+}
+
+// ReadAncients runs the given read operation while ensuring that no writes take
+// place on the underlying freezer, serving the offloaded history as well.
+func (f *chainFreezer) ReadAncients(fn func(ethdb.AncientReaderOp) error) (err error) {
+	f.writeLock.RLock()
+	defer f.writeLock.RUnlock()
+
+	return fn(f)
+}
+
+// OffloadChainHistory moves the chain segments below the given block from the
+// ancient store into era1 files in the given directory, which the chain freezer
+// serves them from afterwards if opened with the same directory. Only complete
+// epochs of era.MaxEra1Size blocks are moved, continuing from the last era1 file
+// in the directory. The number of blocks covered by the era1 files is returned.
+func OffloadChainHistory(db ethdb.Database, dir, network string, before uint64) (uint64, error) {
+	history, err := newEraHistory(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer history.close()
+
+	var (
+		step  = uint64(era.MaxEra1Size)
+		first = history.items()
+	)
+	if first%step != 0 {
+		return 0, fmt.Errorf("last era1 file is incomplete, covering %d blocks", first)
+	}
+	frozen, err := db.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	limit := min(before, frozen)
+	limit -= limit % step
+
+	// Era1 files must contain the receipts, which a limited receipt history
+	// pruned from the ancient store
+	if tail := ReadReceiptPruneTail(db); tail != nil && *tail > first && limit > first {
+		return 0, fmt.Errorf("%w: receipts were pruned up to block %d", errReceiptsPruned, *tail)
+	}
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for number := first; number < limit; number += step {
+		if err := writeEra(db, dir, network, number, step); err != nil {
+			return number, err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Offloading chain history", "number", number+step, "limit", limit, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	// Release the offloaded segments from the freezer only after all the era1
+	// files have been persisted.
+	if limit > first {
+		if _, err := db.TruncateTail(limit); err != nil {
+			return limit, err
+		}
+		log.Info("Offloaded chain history", "dir", dir, "blocks", limit-first, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return max(first, limit), nil
+}
+
+// writeEra writes the given chain segment of the ancient store into an era1
+// file. The file is written under a temporary name and only renamed once it's
+// complete, so that a partial file is never picked up.
+func writeEra(db ethdb.AncientReader, dir, network string, first, count uint64) error {
+	tables := make(map[string][][]byte)
+	for kind := range chainFreezerNoSnappy {
+		items, err := db.AncientRange(kind, first, count, 0)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from ancients: %v", kind, err)
+		}
+		if uint64(len(items)) != count {
+			return fmt.Errorf("missing %s in ancients: have %d, want %d", kind, len(items), count)
+		}
+		tables[kind] = items
+	}
+	var (
+		epoch = int(first / uint64(era.MaxEra1Size))
+		path  = filepath.Join(dir, era.Filename(network, epoch, common.Hash{})) + ".tmp"
+	)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(path)
+	}()
+	builder := era.NewBuilder(f)
+	for i := uint64(0); i < count; i++ {
+		var (
+			header types.Header
+			body   types.Body
+			td     = new(big.Int)
+		)
+		if err := rlp.DecodeBytes(tables[ChainFreezerHeaderTable][i], &header); err != nil {
+			return fmt.Errorf("invalid header %d: %v", first+i, err)
+		}
+		if err := rlp.DecodeBytes(tables[ChainFreezerBodiesTable][i], &body); err != nil {
+			return fmt.Errorf("invalid body %d: %v", first+i, err)
+		}
+		if err := rlp.DecodeBytes(tables[ChainFreezerDifficultyTable][i], td); err != nil {
+			return fmt.Errorf("invalid total difficulty %d: %v", first+i, err)
+		}
+		receipts, err := consensusReceipts(tables[ChainFreezerReceiptTable][i], &body)
+		if err != nil {
+			return fmt.Errorf("invalid receipts %d: %w", first+i, err)
+		}
+		hash := common.BytesToHash(tables[ChainFreezerHashTable][i])
+		if hash != header.Hash() {
+			return fmt.Errorf("hash mismatch %d: have %x, want %x", first+i, hash, header.Hash())
+		}
+		err = builder.AddRLP(tables[ChainFreezerHeaderTable][i], tables[ChainFreezerBodiesTable][i], receipts, first+i, hash, td, header.Difficulty)
+		if err != nil {
+			return err
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path, filepath.Join(dir, era.Filename(network, epoch, root)))
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/era"
+)
+
+func TestOffloadChainHistory(t *testing.T) {
+	var (
+		frdir  = t.TempDir()
+		eradir = t.TempDir() // Stands in for a mounted remote storage
+		opts   = FreezerOptions{EraDir: eradir}
+		blocks = makeTestBlocks(era.MaxEra1Size+10, 1)
+	)
+	db, err := NewDatabaseWithFreezerOptions(NewMemoryDatabase(), frdir, "", false, opts)
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend: %v", err)
+	}
+	receipts := make([]types.Receipts, len(blocks))
+	for i := range receipts {
+		receipts[i] = types.Receipts{{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: uint64(i),
+			Logs:              []*types.Log{{Data: []byte{byte(i)}}},
+		}}
+	}
+	if _, err := WriteAncientBlocks(db, blocks, receipts, big.NewInt(100)); err != nil {
+		t.Fatalf("failed to write ancient blocks: %v", err)
+	}
+	// Snapshot the items to verify the offloaded ones against.
+	want := make(map[string][][]byte)
+	for kind := range chainFreezerNoSnappy {
+		if want[kind], err = db.AncientRange(kind, 0, uint64(len(blocks)), 0); err != nil {
+			t.Fatalf("failed to read %s: %v", kind, err)
+		}
+	}
+	offloaded, err := OffloadChainHistory(db, eradir, "test", uint64(len(blocks)))
+	if err != nil {
+		t.Fatalf("failed to offload history: %v", err)
+	}
+	if offloaded != uint64(era.MaxEra1Size) {
+		t.Fatalf("offloaded blocks mismatch: have %d, want %d", offloaded, era.MaxEra1Size)
+	}
+	check := func(db ethdb.Database) {
+		t.Helper()
+
+		if tail, _ := db.(*freezerdb).AncientStore.(*chainFreezer).Freezer.Tail(); tail != uint64(era.MaxEra1Size) {
+			t.Fatalf("freezer tail mismatch: have %d, want %d", tail, era.MaxEra1Size)
+		}
+		if tail, _ := db.Tail(); tail != 0 {
+			t.Fatalf("tail mismatch: have %d, want 0", tail)
+		}
+		for _, number := range []uint64{0, 1, uint64(era.MaxEra1Size) - 1, uint64(era.MaxEra1Size)} {
+			for kind := range chainFreezerNoSnappy {
+				have, err := db.Ancient(kind, number)
+				if err != nil {
+					t.Fatalf("failed to read %s %d: %v", kind, number, err)
+				}
+				if !bytes.Equal(have, want[kind][number]) {
+					t.Fatalf("%s %d mismatch: have %x, want %x", kind, number, have, want[kind][number])
+				}
+			}
+			if block := ReadBlock(db, blocks[number].Hash(), number); block == nil || block.Hash() != blocks[number].Hash() {
+				t.Fatalf("block %d not served", number)
+			}
+			if receipts := ReadRawReceipts(db, blocks[number].Hash(), number); len(receipts) != 1 || receipts[0].CumulativeGasUsed != number {
+				t.Fatalf("receipts %d not served", number)
+			}
+		}
+		// Ranges spanning the era1 files and the freezer are served seamlessly.
+		start := uint64(era.MaxEra1Size) - 2
+		items, err := db.AncientRange(ChainFreezerBodiesTable, start, 4, 0)
+		if err != nil || len(items) != 4 {
+			t.Fatalf("failed to read range: %d items, err %v", len(items), err)
+		}
+		for i, item := range items {
+			if !bytes.Equal(item, want[ChainFreezerBodiesTable][start+uint64(i)]) {
+				t.Fatalf("range item %d mismatch", start+uint64(i))
+			}
+		}
+		if items, _ := db.AncientRange(ChainFreezerBodiesTable, start, 4, 1); len(items) != 1 {
+			t.Fatalf("range size limit not applied: %d items", len(items))
+		}
+		// Ranges exceeding the chain end with its last item.
+		if items, err := db.AncientRange(ChainFreezerBodiesTable, start, uint64(len(blocks)), 0); err != nil || len(items) != len(blocks)-int(start) {
+			t.Fatalf("failed to read range beyond the head: %d items, err %v", len(items), err)
+		}
+		if _, err := db.AncientRange("unknown", 0, 4, 0); err == nil {
+			t.Fatal("range of unknown table served")
+		}
+	}
+	check(db)
+	db.Close()
+
+	// The offloaded history is picked up again after a restart.
+	db, err = NewDatabaseWithFreezerOptions(NewMemoryDatabase(), frdir, "", false, opts)
+	if err != nil {
+		t.Fatalf("failed to reopen database with ancient backend: %v", err)
+	}
+	defer db.Close()
+	check(db)
+}
+
+// Tests that chain history whose receipts were pruned is not offloaded.
+func TestOffloadPrunedReceipts(t *testing.T) {
+	for _, recorded := range []bool{true, false} {
+		var (
+			eradir = t.TempDir()
+			blocks = makeTestBlocks(era.MaxEra1Size+10, 1)
+		)
+		db, err := NewDatabaseWithFreezerOptions(NewMemoryDatabase(), t.TempDir(), "", false, FreezerOptions{EraDir: eradir})
+		if err != nil {
+			t.Fatalf("failed to create database with ancient backend: %v", err)
+		}
+		// Prune the receipts of a block in the middle of the first epoch
+		receipts := make([]types.Receipts, len(blocks))
+		for i := range receipts {
+			if i != 100 {
+				receipts[i] = types.Receipts{{Status: types.ReceiptStatusSuccessful}}
+			}
+		}
+		if _, err := WriteAncientBlocks(db, blocks, receipts, big.NewInt(100)); err != nil {
+			t.Fatalf("failed to write ancient blocks: %v", err)
+		}
+		if recorded {
+			WriteReceiptPruneTail(db, 101)
+		}
+		if _, err := OffloadChainHistory(db, eradir, "test", uint64(len(blocks))); !errors.Is(err, errReceiptsPruned) {
+			t.Errorf("pruned receipts offloaded (prune tail recorded: %v): %v", recorded, err)
+		}
+		if tail, _ := db.Tail(); tail != 0 {
+			t.Errorf("freezer truncated after failed offload: tail %d", tail)
+		}
+		db.Close()
+	}
+}
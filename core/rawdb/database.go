@@ -160,6 +160,11 @@ func (db *nofreezedb) MigrateTable(kind string, convert convertLegacyFn) error {
 	return errNotSupported
 }
 
+// RepairAncient returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) RepairAncient(kind string, number uint64, item []byte) error {
+	return errNotSupported
+}
+
 // AncientDatadir returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) AncientDatadir() (string, error) {
 	return "", errNotSupported
@@ -199,8 +204,15 @@ func resolveChainFreezerDir(ancient string) string {
 // storage. The passed ancient indicates the path of root ancient directory
 // where the chain freezer can be opened.
 func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace string, readonly bool) (ethdb.Database, error) {
+	return NewDatabaseWithFreezerOptions(db, ancient, namespace, readonly, FreezerOptions{})
+}
+
+// NewDatabaseWithFreezerOptions creates a high level database like
+// NewDatabaseWithFreezer, creating the missing freezer tables in the format
+// specified by the options.
+func NewDatabaseWithFreezerOptions(db ethdb.KeyValueStore, ancient string, namespace string, readonly bool, opts FreezerOptions) (ethdb.Database, error) {
 	// Create the idle freezer instance
-	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly)
+	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly, opts)
 	if err != nil {
 		printChainMetadata(db)
 		return nil, err
@@ -363,6 +375,7 @@ type OpenOptions struct {
 	Cache             int    // the capacity(in megabytes) of the data caching
 	Handles           int    // number of files to be open simultaneously
 	ReadOnly          bool
+	AncientOptions    FreezerOptions // the format of newly created ancient tables and the offloaded history
 	// Ephemeral means that filesystem sync operations should be avoided: data integrity in the face of
 	// a crash is not important. This option should typically be used in tests.
 	Ephemeral bool
@@ -411,7 +424,7 @@ func Open(o OpenOptions) (ethdb.Database, error) {
 	if len(o.AncientsDirectory) == 0 {
 		return kvdb, nil
 	}
-	frdb, err := NewDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.Namespace, o.ReadOnly)
+	frdb, err := NewDatabaseWithFreezerOptions(kvdb, o.AncientsDirectory, o.Namespace, o.ReadOnly, o.AncientOptions)
 	if err != nil {
 		kvdb.Close()
 		return nil, err
@@ -469,6 +482,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		hashNumPairings stat
 		legacyTries     stat
 		stateLookups    stat
+		historyIndexes  stat
 		accountTries    stat
 		storageTries    stat
 		codes           stat
@@ -515,6 +529,12 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			legacyTries.Add(size)
 		case bytes.HasPrefix(key, stateIDPrefix) && len(key) == len(stateIDPrefix)+common.HashLength:
 			stateLookups.Add(size)
+		case bytes.HasPrefix(key, stateHistoryAccountIndexPrefix) && len(key) == len(stateHistoryAccountIndexPrefix)+common.AddressLength+8:
+			historyIndexes.Add(size)
+		case bytes.HasPrefix(key, stateHistoryStorageIndexPrefix) && len(key) == len(stateHistoryStorageIndexPrefix)+common.AddressLength+common.HashLength+8:
+			historyIndexes.Add(size)
+		case bytes.HasPrefix(key, stateHistoryIncompleteIndexPrefix) && len(key) == len(stateHistoryIncompleteIndexPrefix)+common.AddressLength+8:
+			historyIndexes.Add(size)
 		case IsAccountTrieNode(key):
 			accountTries.Add(size)
 		case IsStorageTrieNode(key):
@@ -557,6 +577,8 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
 				uncleanShutdownKey, badBlockKey, transitionStatusKey, skeletonSyncStatusKey,
 				persistentStateIDKey, trieJournalKey, snapshotSyncStatusKey, snapSyncStatusFlagKey,
+				stateHistoryIndexTailKey, stateConversionKey, powSkeletonSyncStatusKey,
+				receiptPruneTailKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -587,6 +609,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Hash trie nodes", legacyTries.Size(), legacyTries.Count()},
 		{"Key-Value store", "Path trie state lookups", stateLookups.Size(), stateLookups.Count()},
+		{"Key-Value store", "Path state history index", historyIndexes.Size(), historyIndexes.Count()},
 		{"Key-Value store", "Path trie account nodes", accountTries.Size(), accountTries.Count()},
 		{"Key-Value store", "Path trie storage nodes", storageTries.Size(), storageTries.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
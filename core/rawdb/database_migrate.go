@@ -0,0 +1,280 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// migrateSampleRate is the interval of the keys whose values are compared
+// between the old and the new key-value store after the migration.
+const migrateSampleRate = 1024
+
+// migrationProgress is the persisted progress of a key-value store migration,
+// which allows resuming it if it was interrupted.
+type migrationProgress struct {
+	Type     string        `json:"type"`     // Engine of the new key-value store
+	Last     hexutil.Bytes `json:"last"`     // Last key copied into the new key-value store
+	Copied   uint64        `json:"copied"`   // Number of keys copied so far
+	Verified bool          `json:"verified"` // Whether the copy was verified, only the swap is left
+}
+
+// migrationPaths returns the paths of the new key-value store, of the old one
+// after the swap and of the progress file of migrating the given directory.
+func migrationPaths(dir string) (tmp, old, progress string) {
+	dir = filepath.Clean(dir)
+	return dir + ".migrate", dir + ".old", dir + ".migrate.json"
+}
+
+// loadMigrationProgress loads the progress of an interrupted migration, or
+// returns nil if there is none.
+func loadMigrationProgress(path string) (*migrationProgress, error) {
+	blob, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var progress migrationProgress
+	if err := json.Unmarshal(blob, &progress); err != nil {
+		return nil, fmt.Errorf("invalid migration progress %s: %v", path, err)
+	}
+	return &progress, nil
+}
+
+// store persists the progress of the migration, replacing the file atomically.
+func (p *migrationProgress) store(path string) error {
+	blob, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// MigrateDatabase moves the key-value store in o.Directory to the engine given
+// by o.Type. All keys are copied into a new store next to the old one, which is
+// verified against the old one and then swapped in place of it. The freezer is
+// kept as is, it's only moved along if it's located inside the old directory.
+//
+// The migration is resumable: the progress is persisted periodically, and an
+// interrupted migration is picked up where it left off. The old key-value store
+// is moved aside and only deleted if keepOld is false.
+func MigrateDatabase(o OpenOptions, keepOld bool) error {
+	if o.Type != dbLeveldb && o.Type != dbPebble {
+		return fmt.Errorf("unknown db.engine %v", o.Type)
+	}
+	tmp, old, path := migrationPaths(o.Directory)
+	progress, err := loadMigrationProgress(path)
+	if err != nil {
+		return err
+	}
+	if progress != nil && progress.Type != o.Type {
+		return fmt.Errorf("interrupted migration to %s found, remove %s and %s to start over", progress.Type, tmp, path)
+	}
+	if progress == nil || !progress.Verified {
+		existing := PreexistingDatabase(o.Directory)
+		if existing == "" {
+			return fmt.Errorf("no database found in %s", o.Directory)
+		}
+		if existing == o.Type {
+			return fmt.Errorf("database is already using %s", o.Type)
+		}
+		if progress == nil {
+			// Start from scratch, discarding any leftover of an unrecorded run
+			if err := os.RemoveAll(tmp); err != nil {
+				return err
+			}
+			progress = &migrationProgress{Type: o.Type}
+		}
+		if err := migrateKeyValues(o, tmp, progress, path); err != nil {
+			return err
+		}
+	}
+	return swapDatabase(o.Directory, o.AncientsDirectory, tmp, old, path, keepOld)
+}
+
+// migrateKeyValues copies the keys of the key-value store into the new one and
+// verifies the copy, recording the progress into the given file.
+func migrateKeyValues(o OpenOptions, tmp string, progress *migrationProgress, path string) error {
+	src, err := openKeyValueDatabase(OpenOptions{Directory: o.Directory, Cache: o.Cache / 2, Handles: o.Handles / 2, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := openKeyValueDatabase(OpenOptions{Type: o.Type, Directory: tmp, Cache: o.Cache / 2, Handles: o.Handles / 2})
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if progress.Copied > 0 {
+		log.Info("Resuming database migration", "type", o.Type, "copied", progress.Copied, "last", progress.Last)
+	} else {
+		log.Info("Migrating database", "type", o.Type, "dir", o.Directory)
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		batch  = dst.NewBatch()
+		it     = src.NewIterator(nil, progress.Last)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		// The iteration is resumed from the last copied key, which is included
+		if progress.Last != nil && bytes.Equal(it.Key(), progress.Last) {
+			continue
+		}
+		if err := batch.Put(it.Key(), it.Value()); err != nil {
+			return err
+		}
+		progress.Copied++
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+
+			progress.Last = common.CopyBytes(it.Key())
+			if err := progress.store(path); err != nil {
+				return err
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Migrating database", "copied", progress.Copied, "key", hexutil.Bytes(it.Key()), "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Copied database keys", "count", progress.Copied, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	if err := verifyMigration(src, dst); err != nil {
+		// The copy can't be trusted, start over on the next run
+		os.Remove(path)
+		return fmt.Errorf("database migration verification failed, please retry: %v", err)
+	}
+	progress.Verified = true
+	return progress.store(path)
+}
+
+// verifyMigration checks that the new key-value store holds as many keys as the
+// old one, and compares the hashes of the values of a sample of the keys.
+func verifyMigration(src, dst ethdb.KeyValueStore) error {
+	var (
+		start   = time.Now()
+		count   uint64
+		sampled uint64
+	)
+	it := src.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		count++
+		if count%migrateSampleRate != 0 {
+			continue
+		}
+		value, err := dst.Get(it.Key())
+		if err != nil {
+			return fmt.Errorf("key %#x missing: %v", it.Key(), err)
+		}
+		if crypto.Keccak256Hash(value) != crypto.Keccak256Hash(it.Value()) {
+			return fmt.Errorf("value of key %#x mismatch", it.Key())
+		}
+		sampled++
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	var copied uint64
+	dit := dst.NewIterator(nil, nil)
+	defer dit.Release()
+	for dit.Next() {
+		copied++
+	}
+	if err := dit.Error(); err != nil {
+		return err
+	}
+	if copied != count {
+		return fmt.Errorf("key count mismatch: have %d, want %d", copied, count)
+	}
+	log.Info("Verified database migration", "keys", count, "sampled", sampled, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// swapDatabase moves the verified new key-value store in place of the old one,
+// moving the freezer along if it's located inside the old directory. Each step
+// is skipped if it was already done, so that an interrupted swap can be resumed.
+func swapDatabase(dir, ancient, tmp, old, path string, keepOld bool) error {
+	dir = filepath.Clean(dir)
+	if ancient != "" {
+		if rel, err := filepath.Rel(dir, filepath.Clean(ancient)); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			from, to := filepath.Join(dir, rel), filepath.Join(tmp, rel)
+			if common.FileExist(from) && !common.FileExist(to) {
+				if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+					return err
+				}
+				if err := os.Rename(from, to); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if common.FileExist(dir) && common.FileExist(tmp) {
+		if err := os.Rename(dir, old); err != nil {
+			return err
+		}
+	}
+	if common.FileExist(tmp) {
+		if err := os.Rename(tmp, dir); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if keepOld {
+		log.Info("Database migrated, the old database was kept", "dir", dir, "old", old)
+		return nil
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	log.Info("Database migrated", "dir", dir)
+	return nil
+}
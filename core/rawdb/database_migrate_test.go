@@ -0,0 +1,104 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMigrateDatabase(t *testing.T) {
+	var (
+		dir     = filepath.Join(t.TempDir(), "chaindata")
+		ancient = filepath.Join(dir, "ancient")
+		opts    = OpenOptions{Type: dbLeveldb, Directory: dir, AncientsDirectory: ancient, Cache: 16, Handles: 16}
+		keys    = 10000
+	)
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte(i)}, 1+i%100)
+	}
+	batch := db.NewBatch()
+	for i := 0; i < keys; i++ {
+		key := binary.BigEndian.AppendUint64([]byte("key"), uint64(i))
+		batch.Put(key, value(i))
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write keys: %v", err)
+	}
+	db.Close()
+
+	// Simulate an interrupted migration which copied a part of the keys.
+	tmp, old, path := migrationPaths(dir)
+	dst, err := openKeyValueDatabase(OpenOptions{Type: dbPebble, Directory: tmp})
+	if err != nil {
+		t.Fatalf("failed to open new database: %v", err)
+	}
+	last := binary.BigEndian.AppendUint64([]byte("key"), uint64(keys/2))
+	for i := 0; i <= keys/2; i++ {
+		dst.Put(binary.BigEndian.AppendUint64([]byte("key"), uint64(i)), value(i))
+	}
+	dst.Close()
+	if err := (&migrationProgress{Type: dbPebble, Last: last, Copied: uint64(keys/2 + 1)}).store(path); err != nil {
+		t.Fatalf("failed to store progress: %v", err)
+	}
+	// Resuming to a different engine is refused.
+	opts.Type = dbLeveldb
+	if err := MigrateDatabase(opts, false); err == nil {
+		t.Fatal("migration to a different engine resumed")
+	}
+	opts.Type = dbPebble
+	if err := MigrateDatabase(opts, true); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	if have := PreexistingDatabase(dir); have != dbPebble {
+		t.Fatalf("database engine mismatch: have %q, want %q", have, dbPebble)
+	}
+	if common.FileExist(tmp) || common.FileExist(path) {
+		t.Fatal("migration leftovers not cleaned up")
+	}
+	if have := PreexistingDatabase(old); have != dbLeveldb {
+		t.Fatalf("old database not kept: %q", have)
+	}
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatalf("failed to reopen migrated database: %v", err)
+	}
+	for i := 0; i < keys; i++ {
+		have, err := db.Get(binary.BigEndian.AppendUint64([]byte("key"), uint64(i)))
+		if err != nil || !bytes.Equal(have, value(i)) {
+			t.Fatalf("key %d mismatch: have %x, err %v", i, have, err)
+		}
+	}
+	// The freezer inside the database directory was moved along.
+	if _, err := os.Stat(filepath.Join(ancient, ChainFreezerName, "FLOCK")); err != nil {
+		t.Fatalf("freezer not moved along: %v", err)
+	}
+	// Migrating into the same engine is refused.
+	db.Close()
+	if err := MigrateDatabase(opts, false); err == nil {
+		t.Fatal("migration into the current engine succeeded")
+	}
+}
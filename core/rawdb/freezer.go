@@ -82,12 +82,24 @@ func NewChainFreezer(datadir string, namespace string, readonly bool) (*Freezer,
 	return NewFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
 }
 
+// NewChainFreezerWithOptions is like NewChainFreezer, creating the missing
+// tables in the format specified by the options.
+func NewChainFreezerWithOptions(datadir string, namespace string, readonly bool, opts FreezerOptions) (*Freezer, error) {
+	return NewFreezerWithOptions(datadir, namespace, readonly, freezerTableSize, chainFreezerNoSnappy, opts)
+}
+
 // NewFreezer creates a freezer instance for maintaining immutable ordered
 // data according to the given parameters.
 //
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return NewFreezerWithOptions(datadir, namespace, readonly, maxTableSize, tables, FreezerOptions{})
+}
+
+// NewFreezerWithOptions creates a freezer instance like NewFreezer, creating the
+// missing tables in the format specified by the options.
+func NewFreezerWithOptions(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool, opts FreezerOptions) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -126,7 +138,7 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 
 	// Create the tables.
 	for name, disableSnappy := range tables {
-		table, err := newTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly)
+		table, err := newTableWithOptions(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly, opts)
 		if err != nil {
 			for _, table := range freezer.tables {
 				table.Close()
@@ -321,6 +333,22 @@ func (f *Freezer) TruncateTail(tail uint64) (uint64, error) {
 	return old, nil
 }
 
+// RepairAncient replaces a corrupted item of the given kind with the given
+// uncompressed item. The replacement is served on all subsequent reads.
+func (f *Freezer) RepairAncient(kind string, number uint64, item []byte) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	table, ok := f.tables[kind]
+	if !ok {
+		return errUnknownTable
+	}
+	return table.repairItem(number, item)
+}
+
 // Sync flushes all data tables to disk.
 func (f *Freezer) Sync() error {
 	var errs []error
@@ -448,7 +476,11 @@ func (f *Freezer) MigrateTable(kind string, convert convertLegacyFn) error {
 	// Set up new dir for the migrated table, the content of which
 	// we'll at the end move over to the ancients dir.
 	migrationPath := filepath.Join(ancientsPath, "migration")
-	newTable, err := newFreezerTable(migrationPath, kind, table.noCompression, false)
+	opts := FreezerOptions{Compression: FreezerSnappy, Checksum: table.checksum}
+	if table.zstd != nil {
+		opts.Compression = FreezerZstd
+	}
+	newTable, err := newTableWithOptions(migrationPath, kind, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, freezerTableSize, table.noCompression, false, opts)
 	if err != nil {
 		return err
 	}
@@ -505,5 +537,10 @@ func (f *Freezer) MigrateTable(kind string, convert convertLegacyFn) error {
 	if err := os.Remove(migrationPath); err != nil {
 		return err
 	}
+	// The repaired items were migrated along with the others, drop the stale
+	// replacements.
+	if err := os.Remove(table.repairedPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
 	return nil
 }
@@ -18,7 +18,9 @@ package rawdb
 
 import (
 	"fmt"
+	"hash/crc32"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/golang/snappy"
@@ -85,17 +87,20 @@ type freezerTableBatch struct {
 	t *freezerTable
 
 	sb          *snappyBuffer
+	zb          []byte // buffer for zstd-compressed items
 	encBuffer   writeBuffer
 	dataBuffer  []byte
 	indexBuffer []byte
-	curItem     uint64 // expected index of next append
-	totalBytes  int64  // counts written bytes since reset
+	samples     [][]byte // uncompressed items held back until the zstd dictionary is trained
+	sampleBytes int      // total size of the held back items
+	curItem     uint64   // expected index of next append
+	totalBytes  int64    // counts written bytes since reset
 }
 
 // newBatch creates a new batch for the freezer table.
 func (t *freezerTable) newBatch() *freezerTableBatch {
 	batch := &freezerTableBatch{t: t}
-	if !t.noCompression {
+	if !t.noCompression && t.zstd == nil {
 		batch.sb = new(snappyBuffer)
 	}
 	batch.reset()
@@ -106,6 +111,8 @@ func (t *freezerTable) newBatch() *freezerTableBatch {
 func (batch *freezerTableBatch) reset() {
 	batch.dataBuffer = batch.dataBuffer[:0]
 	batch.indexBuffer = batch.indexBuffer[:0]
+	batch.samples = batch.samples[:0]
+	batch.sampleBytes = 0
 	batch.curItem = batch.t.items.Load()
 	batch.totalBytes = 0
 }
@@ -123,11 +130,7 @@ func (batch *freezerTableBatch) Append(item uint64, data interface{}) error {
 	if err := rlp.Encode(&batch.encBuffer, data); err != nil {
 		return err
 	}
-	encItem := batch.encBuffer.data
-	if batch.sb != nil {
-		encItem = batch.sb.compress(encItem)
-	}
-	return batch.appendItem(encItem)
+	return batch.compressItem(batch.encBuffer.data)
 }
 
 // AppendRaw injects a binary blob at the end of the freezer table. The item number is a
@@ -138,11 +141,58 @@ func (batch *freezerTableBatch) AppendRaw(item uint64, blob []byte) error {
 		return fmt.Errorf("%w: have %d want %d", errOutOrderInsertion, item, batch.curItem)
 	}
 
-	encItem := blob
-	if batch.sb != nil {
-		encItem = batch.sb.compress(blob)
+	return batch.compressItem(blob)
+}
+
+// compressItem compresses the item according to the codec of the table and
+// adds it to the batch.
+func (batch *freezerTableBatch) compressItem(item []byte) error {
+	switch {
+	case batch.sb != nil:
+		item = batch.sb.compress(item)
+	case batch.t.zstd != nil:
+		if !batch.t.zstd.trained {
+			return batch.holdSample(item)
+		}
+		var err error
+		if batch.zb, err = batch.t.zstd.compress(batch.zb[:0], item); err != nil {
+			return err
+		}
+		item = batch.zb
 	}
-	return batch.appendItem(encItem)
+	return batch.appendItem(item)
+}
+
+// holdSample keeps back an item of a zstd table without a dictionary, the
+// dictionary is trained on the held back items once there are enough of them,
+// or the batch is committed.
+func (batch *freezerTableBatch) holdSample(item []byte) error {
+	batch.samples = append(batch.samples, common.CopyBytes(item))
+	batch.sampleBytes += len(item)
+	batch.curItem++
+
+	if batch.sampleBytes >= zstdTrainingSize {
+		return batch.train()
+	}
+	return nil
+}
+
+// train trains the dictionary of the zstd table on the held back items, then
+// adds the items to the batch.
+func (batch *freezerTableBatch) train() error {
+	samples := batch.samples
+	batch.samples, batch.sampleBytes = nil, 0
+	batch.curItem -= uint64(len(samples))
+
+	if err := batch.t.setDictionary(trainDictionary(samples, zstdDictSize)); err != nil {
+		return err
+	}
+	for _, sample := range samples {
+		if err := batch.compressItem(sample); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (batch *freezerTableBatch) appendItem(data []byte) error {
@@ -166,7 +216,10 @@ func (batch *freezerTableBatch) appendItem(data []byte) error {
 
 	// Put index entry to buffer.
 	entry := indexEntry{filenum: batch.t.headId, offset: uint32(itemOffset + itemSize)}
-	batch.indexBuffer = entry.append(batch.indexBuffer)
+	if batch.t.checksum {
+		entry.checksum = crc32.Checksum(data, crcTable)
+	}
+	batch.indexBuffer = batch.t.encodeEntry(batch.indexBuffer, &entry)
 	batch.curItem++
 
 	return batch.maybeCommit()
@@ -182,6 +235,12 @@ func (batch *freezerTableBatch) maybeCommit() error {
 
 // commit writes the batched items to the backing freezerTable.
 func (batch *freezerTableBatch) commit() error {
+	// Train the dictionary of a new zstd table on whatever was appended.
+	if len(batch.samples) > 0 {
+		if err := batch.train(); err != nil {
+			return err
+		}
+	}
 	// Write data. The head file is fsync'd after write to ensure the
 	// data is truly transferred to disk.
 	_, err := batch.t.head.Write(batch.dataBuffer)
@@ -0,0 +1,247 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// zstdLevel is the compression level used for zstd tables.
+	zstdLevel = 9
+
+	// zstdDictSize is the maximum size of the trained dictionaries.
+	zstdDictSize = 64 * 1024
+
+	// zstdTrainingSize is the amount of item data the dictionary of a zstd
+	// table is trained on. The items are buffered uncompressed until either
+	// this limit is reached or the batch is committed.
+	zstdTrainingSize = 4 * 1024 * 1024
+)
+
+// zstdCodec compresses the items of a freezer table with zstd, using a
+// dictionary trained on the first items written into the table. Items of the
+// same table share a lot of structure (e.g. RLP prefixes, addresses of popular
+// contracts, log topics), which per-item compression can't exploit without a
+// dictionary.
+type zstdCodec struct {
+	dict    []byte     // Raw content dictionary, may be empty if there was too little to train on
+	digest  zstdDigest // Dictionary prepared once for all items, instead of for each one
+	trained bool       // Whether the dictionary is established, items can't be compressed before
+}
+
+// setDictionary establishes the dictionary of the codec.
+func (c *zstdCodec) setDictionary(dict []byte) error {
+	digest, err := digestDictionary(dict)
+	if err != nil {
+		return err
+	}
+	c.dict, c.digest, c.trained = dict, digest, true
+	return nil
+}
+
+// tableCodec reports whether the freezer table with the given name should use
+// zstd compression. Existing tables keep the codec they were created with, the
+// configured one is only used for new tables.
+func tableCodec(path, name string, noCompression bool, compression string) (bool, error) {
+	if noCompression {
+		return false, nil
+	}
+	switch compression {
+	case "", FreezerSnappy, FreezerZstd:
+	default:
+		return false, fmt.Errorf("unknown freezer compression %q", compression)
+	}
+	useZstd := compression == FreezerZstd
+	if common.FileExist(filepath.Join(path, fmt.Sprintf("%s.cidx", name))) {
+		useZstd = false
+	} else if common.FileExist(filepath.Join(path, fmt.Sprintf("%s.zidx", name))) {
+		useZstd = true
+	}
+	if useZstd && !zstdSupported {
+		return false, fmt.Errorf("freezer table %s: zstd compression requires cgo", name)
+	}
+	return useZstd, nil
+}
+
+// dictionaryPath returns the path of the zstd dictionary of the table.
+func (t *freezerTable) dictionaryPath() string {
+	return filepath.Join(t.path, fmt.Sprintf("%s.zdict", t.name))
+}
+
+// loadDictionary loads the zstd dictionary of the table. A missing dictionary
+// is only valid if the table doesn't contain any items yet, it's trained on the
+// first items appended.
+func (t *freezerTable) loadDictionary() error {
+	dict, err := os.ReadFile(t.dictionaryPath())
+	switch {
+	case err == nil:
+		return t.zstd.setDictionary(dict)
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	case t.items.Load() > t.itemOffset.Load():
+		return fmt.Errorf("freezer table %s: missing zstd dictionary", t.name)
+	default:
+		return nil
+	}
+}
+
+// setDictionary persists the trained dictionary of the table. It's called by
+// the first batch appending items, before any of them is written.
+func (t *freezerTable) setDictionary(dict []byte) error {
+	path := t.dictionaryPath()
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(dict); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	if err := t.zstd.setDictionary(dict); err != nil {
+		return err
+	}
+	t.logger.Info("Trained zstd dictionary", "size", len(dict))
+	return nil
+}
+
+const (
+	dictDmerSize    = 8  // Size of the substrings whose frequencies are counted
+	dictSegmentSize = 64 // Size of the segments the dictionary is assembled from
+)
+
+// dictSegment is a candidate piece of the dictionary.
+type dictSegment struct {
+	data  []byte
+	score uint64
+}
+
+// dictSegments is a max-heap of segments ordered by score.
+type dictSegments []*dictSegment
+
+func (h dictSegments) Len() int            { return len(h) }
+func (h dictSegments) Less(i, j int) bool  { return h[i].score > h[j].score }
+func (h dictSegments) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *dictSegments) Push(x interface{}) { *h = append(*h, x.(*dictSegment)) }
+func (h *dictSegments) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// trainDictionary builds a raw content dictionary of at most size bytes from
+// the given samples, following the idea of the COVER algorithm of zstd.
+//
+// The samples are split into fixed size segments, each scored by the number of
+// samples containing the d-mers (short substrings) of the segment. Segments are
+// then picked greedily by score. Once a segment is picked, its d-mers no longer
+// contribute to the score of the others, so that the dictionary doesn't contain
+// the same content twice. The best segments are placed at the end, where zstd
+// can reference them with the shortest offsets.
+func trainDictionary(samples [][]byte, size int) []byte {
+	// Count in how many samples each d-mer occurs
+	var (
+		freqs = make(map[uint64]uint32)
+		seen  = make(map[uint64]struct{})
+	)
+	for _, sample := range samples {
+		clear(seen)
+		for i := 0; i+dictDmerSize <= len(sample); i++ {
+			dmer := binary.LittleEndian.Uint64(sample[i:])
+			if _, ok := seen[dmer]; !ok {
+				seen[dmer] = struct{}{}
+				freqs[dmer]++
+			}
+		}
+	}
+	// score sums up the frequencies of the d-mers of a segment, ignoring the
+	// ones occurring in a single sample only as they are useless for others.
+	score := func(segment []byte) uint64 {
+		var total uint64
+		for i := 0; i+dictDmerSize <= len(segment); i++ {
+			if freq := freqs[binary.LittleEndian.Uint64(segment[i:])]; freq > 1 {
+				total += uint64(freq)
+			}
+		}
+		return total
+	}
+	var candidates dictSegments
+	for _, sample := range samples {
+		for i := 0; i+dictDmerSize <= len(sample); i += dictSegmentSize {
+			segment := sample[i:min(i+dictSegmentSize, len(sample))]
+			if s := score(segment); s > 0 {
+				candidates = append(candidates, &dictSegment{data: segment, score: s})
+			}
+		}
+	}
+	heap.Init(&candidates)
+
+	// Pick the segments lazily: the score of the best candidate might be stale,
+	// so it's recomputed and only accepted if it's still the best.
+	var (
+		picked [][]byte
+		total  int
+	)
+	for candidates.Len() > 0 && total < size {
+		best := candidates[0]
+		if s := score(best.data); s != best.score {
+			if s == 0 {
+				heap.Pop(&candidates)
+			} else {
+				best.score = s
+				heap.Fix(&candidates, 0)
+			}
+			continue
+		}
+		heap.Pop(&candidates)
+
+		segment := best.data
+		if total+len(segment) > size {
+			segment = segment[:size-total]
+		}
+		picked = append(picked, segment)
+		total += len(segment)
+
+		for i := 0; i+dictDmerSize <= len(segment); i++ {
+			delete(freqs, binary.LittleEndian.Uint64(segment[i:]))
+		}
+	}
+	dict := make([]byte, 0, total)
+	for i := len(picked) - 1; i >= 0; i-- {
+		dict = append(dict, picked[i]...)
+	}
+	return dict
+}
@@ -24,7 +24,10 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
-const freezerVersion = 1 // The initial version tag of freezer table metadata
+const (
+	freezerVersion         = 1 // The initial version tag of freezer table metadata
+	freezerChecksumVersion = 2 // The version tag of tables storing item checksums in the index
+)
 
 // freezerTableMeta wraps all the metadata of the freezer table.
 type freezerTableMeta struct {
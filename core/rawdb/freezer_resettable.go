@@ -206,6 +206,15 @@ func (f *ResettableFreezer) MigrateTable(kind string, convert convertLegacyFn) e
 	return f.freezer.MigrateTable(kind, convert)
 }
 
+// RepairAncient replaces a corrupted item of the given kind with the given
+// uncompressed item.
+func (f *ResettableFreezer) RepairAncient(kind string, number uint64, item []byte) error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.freezer.RepairAncient(kind, number, item)
+}
+
 // cleanup removes the directory located in the specified path
 // has the name with deletion marker suffix.
 func cleanup(path string) error {
@@ -21,6 +21,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
@@ -44,17 +45,45 @@ var (
 
 	// errNotSupported is returned if the database doesn't support the required operation.
 	errNotSupported = errors.New("this operation is not supported")
+
+	// errChecksumMismatch is returned if the checksum of an item read from the
+	// freezer table doesn't match the one recorded in the index.
+	errChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// crcTable is the polynomial used for the item checksums stored in the index.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+const (
+	// FreezerSnappy is the default codec of the compressed freezer tables.
+	FreezerSnappy = "snappy"
+
+	// FreezerZstd is the codec compressing the items of a table with zstd, using
+	// a dictionary trained on the first items written into the table.
+	FreezerZstd = "zstd"
 )
 
+// FreezerOptions configures the freezer. The format options only apply to newly
+// created tables, existing tables keep the format they were created with.
+type FreezerOptions struct {
+	Compression string // Codec of the compressed tables, FreezerSnappy if empty
+	Checksum    bool   // Whether to store a checksum of each item in the index
+	EraDir      string // Directory of the era1 files holding offloaded chain history, chain freezer only
+}
+
 // indexEntry contains the number/id of the file that the data resides in, as well as the
 // offset within the file to the end of the data.
 // In serialized form, the filenum is stored as uint16.
 type indexEntry struct {
-	filenum uint32 // stored as uint16 ( 2 bytes )
-	offset  uint32 // stored as uint32 ( 4 bytes )
+	filenum  uint32 // stored as uint16 ( 2 bytes )
+	offset   uint32 // stored as uint32 ( 4 bytes )
+	checksum uint32 // stored as uint32 ( 4 bytes ), only in checksummed tables
 }
 
-const indexEntrySize = 6
+const (
+	indexEntrySize         = 6  // Size of the index entries in tables without checksums
+	checksumIndexEntrySize = 10 // Size of the index entries in checksummed tables
+)
 
 // unmarshalBinary deserializes binary b into the rawIndex entry.
 func (i *indexEntry) unmarshalBinary(b []byte) {
@@ -71,6 +100,17 @@ func (i *indexEntry) append(b []byte) []byte {
 	return out
 }
 
+// unmarshalChecksummed deserializes binary b into the checksummed index entry.
+func (i *indexEntry) unmarshalChecksummed(b []byte) {
+	i.unmarshalBinary(b)
+	i.checksum = binary.BigEndian.Uint32(b[6:10])
+}
+
+// appendChecksummed adds the encoded checksummed entry to the end of b.
+func (i *indexEntry) appendChecksummed(b []byte) []byte {
+	return binary.BigEndian.AppendUint32(i.append(b), i.checksum)
+}
+
 // bounds returns the start- and end- offsets, and the file number of where to
 // read there data item marked by the two index entries. The two entries are
 // assumed to be sequential.
@@ -98,7 +138,10 @@ type freezerTable struct {
 	// should never be lower than itemOffset.
 	itemHidden atomic.Uint64
 
-	noCompression bool // if true, disables snappy compression. Note: does not work retroactively
+	noCompression bool       // if true, disables snappy compression. Note: does not work retroactively
+	zstd          *zstdCodec // if set, items are compressed with zstd instead of snappy
+	checksum      bool       // if true, the index entries carry a checksum of the items
+	entrySize     int64      // size of the index entries, depends on the checksums
 	readonly      bool
 	maxFileSize   uint32 // Max file size for data-files
 	name          string
@@ -111,6 +154,8 @@ type freezerTable struct {
 	headId uint32              // number of the currently active head file
 	tailId uint32              // number of the earliest file
 
+	repaired map[uint64][]byte // Items replaced after being found corrupted, served instead of the stored ones
+
 	headBytes  int64         // Number of bytes written to the head file
 	readMeter  metrics.Meter // Meter for measuring the effective amount of data read
 	writeMeter metrics.Meter // Meter for measuring the effective amount of data written
@@ -129,18 +174,30 @@ func newFreezerTable(path, name string, disableSnappy, readonly bool) (*freezerT
 // non-existent. Both files are truncated to the shortest common length to ensure
 // they don't go out of sync.
 func newTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly bool) (*freezerTable, error) {
+	return newTableWithOptions(path, name, readMeter, writeMeter, sizeGauge, maxFilesize, noCompression, readonly, FreezerOptions{})
+}
+
+// newTableWithOptions opens a freezer table like newTable, creating it in the
+// format specified by the options if it's non-existent.
+func newTableWithOptions(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly bool, opts FreezerOptions) (*freezerTable, error) {
 	// Ensure the containing directory exists and open the indexEntry file
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, err
 	}
+	useZstd, err := tableCodec(path, name, noCompression, opts.Compression)
+	if err != nil {
+		return nil, err
+	}
 	var idxName string
-	if noCompression {
+	switch {
+	case noCompression:
 		idxName = fmt.Sprintf("%s.ridx", name) // raw index file
-	} else {
+	case useZstd:
+		idxName = fmt.Sprintf("%s.zidx", name) // zstd compressed index file
+	default:
 		idxName = fmt.Sprintf("%s.cidx", name) // compressed index file
 	}
 	var (
-		err   error
 		index *os.File
 		meta  *os.File
 	)
@@ -176,13 +233,27 @@ func newTable(path string, name string, readMeter metrics.Meter, writeMeter metr
 		path:          path,
 		logger:        log.New("database", path, "table", name),
 		noCompression: noCompression,
+		checksum:      opts.Checksum,
 		readonly:      readonly,
 		maxFileSize:   maxFilesize,
 	}
+	if useZstd {
+		tab.zstd = new(zstdCodec)
+	}
 	if err := tab.repair(); err != nil {
 		tab.Close()
 		return nil, err
 	}
+	if err := tab.loadRepaired(); err != nil {
+		tab.Close()
+		return nil, err
+	}
+	if tab.zstd != nil {
+		if err := tab.loadDictionary(); err != nil {
+			tab.Close()
+			return nil, err
+		}
+	}
 	// Initialize the starting size counter
 	size, err := tab.sizeNolock()
 	if err != nil {
@@ -197,23 +268,27 @@ func newTable(path string, name string, readMeter metrics.Meter, writeMeter metr
 // repair cross-checks the head and the index file and truncates them to
 // be in sync with each other after a potential crash / data loss.
 func (t *freezerTable) repair() error {
-	// Create a temporary offset buffer to init files with and read indexEntry into
-	buffer := make([]byte, indexEntrySize)
-
-	// If we've just created the files, initialize the index with the 0 indexEntry
 	stat, err := t.index.Stat()
 	if err != nil {
 		return err
 	}
+	// Determine the layout of the index entries before reading any of them
+	if err := t.initVersion(stat.Size() == 0); err != nil {
+		return err
+	}
+	// Create a temporary offset buffer to init files with and read indexEntry into
+	buffer := make([]byte, t.entrySize)
+
+	// If we've just created the files, initialize the index with the 0 indexEntry
 	if stat.Size() == 0 {
 		if _, err := t.index.Write(buffer); err != nil {
 			return err
 		}
 	}
-	// Ensure the index is a multiple of indexEntrySize bytes
-	if overflow := stat.Size() % indexEntrySize; overflow != 0 {
+	// Ensure the index is a multiple of the entry size
+	if overflow := stat.Size() % t.entrySize; overflow != 0 {
 		if t.readonly {
-			return fmt.Errorf("index file(path: %s, name: %s) size is not a multiple of %d", t.path, t.name, indexEntrySize)
+			return fmt.Errorf("index file(path: %s, name: %s) size is not a multiple of %d", t.path, t.name, t.entrySize)
 		}
 		if err := truncateFreezerFile(t.index, stat.Size()-overflow); err != nil {
 			return err
@@ -236,7 +311,7 @@ func (t *freezerTable) repair() error {
 	// Read index zero, determine what file is the earliest
 	// and what item offset to use
 	t.index.ReadAt(buffer, 0)
-	firstIndex.unmarshalBinary(buffer)
+	t.decodeEntry(&firstIndex, buffer)
 
 	// Assign the tail fields with the first stored index.
 	// The total removed items is represented with an uint32,
@@ -253,17 +328,17 @@ func (t *freezerTable) repair() error {
 	t.itemHidden.Store(meta.VirtualTail)
 
 	// Read the last index, use the default value in case the freezer is empty
-	if offsetsSize == indexEntrySize {
+	if offsetsSize == t.entrySize {
 		lastIndex = indexEntry{filenum: t.tailId, offset: 0}
 	} else {
-		t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
-		lastIndex.unmarshalBinary(buffer)
+		t.index.ReadAt(buffer, offsetsSize-t.entrySize)
+		t.decodeEntry(&lastIndex, buffer)
 	}
 	// Print an error log if the index is corrupted due to an incorrect
 	// last index item. While it is theoretically possible to have a zero offset
 	// by storing all zero-size items, it is highly unlikely to occur in practice.
-	if lastIndex.offset == 0 && offsetsSize/indexEntrySize > 1 {
-		log.Error("Corrupted index file detected", "lastOffset", lastIndex.offset, "indexes", offsetsSize/indexEntrySize)
+	if lastIndex.offset == 0 && offsetsSize/t.entrySize > 1 {
+		log.Error("Corrupted index file detected", "lastOffset", lastIndex.offset, "indexes", offsetsSize/t.entrySize)
 	}
 	if t.readonly {
 		t.head, err = t.openFile(lastIndex.filenum, openFreezerFileForReadOnly)
@@ -295,20 +370,20 @@ func (t *freezerTable) repair() error {
 		}
 		// Truncate the index to point within the head file
 		if contentExp > contentSize {
-			t.logger.Warn("Truncating dangling indexes", "indexes", offsetsSize/indexEntrySize, "indexed", contentExp, "stored", contentSize)
-			if err := truncateFreezerFile(t.index, offsetsSize-indexEntrySize); err != nil {
+			t.logger.Warn("Truncating dangling indexes", "indexes", offsetsSize/t.entrySize, "indexed", contentExp, "stored", contentSize)
+			if err := truncateFreezerFile(t.index, offsetsSize-t.entrySize); err != nil {
 				return err
 			}
-			offsetsSize -= indexEntrySize
+			offsetsSize -= t.entrySize
 
 			// Read the new head index, use the default value in case
 			// the freezer is already empty.
 			var newLastIndex indexEntry
-			if offsetsSize == indexEntrySize {
+			if offsetsSize == t.entrySize {
 				newLastIndex = indexEntry{filenum: t.tailId, offset: 0}
 			} else {
-				t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
-				newLastIndex.unmarshalBinary(buffer)
+				t.index.ReadAt(buffer, offsetsSize-t.entrySize)
+				t.decodeEntry(&newLastIndex, buffer)
 			}
 			// We might have slipped back into an earlier head-file here
 			if newLastIndex.filenum != lastIndex.filenum {
@@ -342,7 +417,7 @@ func (t *freezerTable) repair() error {
 		}
 	}
 	// Update the item and byte counters and return
-	t.items.Store(t.itemOffset.Load() + uint64(offsetsSize/indexEntrySize-1)) // last indexEntry points to the end of the data file
+	t.items.Store(t.itemOffset.Load() + uint64(offsetsSize/t.entrySize-1)) // last indexEntry points to the end of the data file
 	t.headBytes = contentSize
 	t.headId = lastIndex.filenum
 
@@ -364,6 +439,55 @@ func (t *freezerTable) repair() error {
 	return nil
 }
 
+// initVersion determines the version of the table from its metadata, and with
+// that whether the index entries carry checksums. For a new table the version
+// is picked according to the options, legacy tables without metadata have no
+// checksums.
+func (t *freezerTable) initVersion(fresh bool) error {
+	stat, err := t.meta.Stat()
+	if err != nil {
+		return err
+	}
+	switch {
+	case stat.Size() != 0:
+		meta, err := readMetadata(t.meta)
+		if err != nil {
+			return err
+		}
+		t.checksum = meta.Version >= freezerChecksumVersion
+	case fresh && t.checksum && !t.readonly:
+		meta := newMetadata(0)
+		meta.Version = freezerChecksumVersion
+		if err := writeMetadata(t.meta, meta); err != nil {
+			return err
+		}
+	default:
+		t.checksum = false
+	}
+	t.entrySize = indexEntrySize
+	if t.checksum {
+		t.entrySize = checksumIndexEntrySize
+	}
+	return nil
+}
+
+// decodeEntry deserializes an index entry in the layout of the table.
+func (t *freezerTable) decodeEntry(entry *indexEntry, b []byte) {
+	if t.checksum {
+		entry.unmarshalChecksummed(b)
+	} else {
+		entry.unmarshalBinary(b)
+	}
+}
+
+// encodeEntry appends the index entry in the layout of the table to b.
+func (t *freezerTable) encodeEntry(b []byte, entry *indexEntry) []byte {
+	if t.checksum {
+		return entry.appendChecksummed(b)
+	}
+	return entry.append(b)
+}
+
 // preopen opens all files that the freezer will need. This method should be called from an init-context,
 // since it assumes that it doesn't have to bother with locking
 // The rationale for doing preopen is to not have to do it from within Retrieve, thus not needing to ever
@@ -415,7 +539,7 @@ func (t *freezerTable) truncateHead(items uint64) error {
 	// Truncate the index file first, the tail position is also considered
 	// when calculating the new freezer table length.
 	length := items - t.itemOffset.Load()
-	if err := truncateFreezerFile(t.index, int64(length+1)*indexEntrySize); err != nil {
+	if err := truncateFreezerFile(t.index, int64(length+1)*t.entrySize); err != nil {
 		return err
 	}
 	if err := t.index.Sync(); err != nil {
@@ -426,11 +550,11 @@ func (t *freezerTable) truncateHead(items uint64) error {
 	if length == 0 {
 		expected = indexEntry{filenum: t.tailId, offset: 0}
 	} else {
-		buffer := make([]byte, indexEntrySize)
-		if _, err := t.index.ReadAt(buffer, int64(length*indexEntrySize)); err != nil {
+		buffer := make([]byte, t.entrySize)
+		if _, err := t.index.ReadAt(buffer, int64(length)*t.entrySize); err != nil {
 			return err
 		}
-		expected.unmarshalBinary(buffer)
+		t.decodeEntry(&expected, buffer)
 	}
 	// We might need to truncate back to older files
 	if expected.filenum != t.headId {
@@ -458,6 +582,12 @@ func (t *freezerTable) truncateHead(items uint64) error {
 	t.headBytes = int64(expected.offset)
 	t.items.Store(items)
 
+	// Drop the replacements of the truncated items, new ones will be appended
+	// in their place.
+	if err := t.dropRepaired(t.itemHidden.Load(), items); err != nil {
+		return err
+	}
+
 	// Retrieve the new size and update the total size counter
 	newSize, err := t.sizeNolock()
 	if err != nil {
@@ -496,17 +626,17 @@ func (t *freezerTable) truncateTail(items uint64) error {
 	// Load the new tail index by the given new tail position
 	var (
 		newTailId uint32
-		buffer    = make([]byte, indexEntrySize)
+		buffer    = make([]byte, t.entrySize)
 	)
 	if t.items.Load() == items {
 		newTailId = t.headId
 	} else {
 		offset := items - t.itemOffset.Load()
-		if _, err := t.index.ReadAt(buffer, int64((offset+1)*indexEntrySize)); err != nil {
+		if _, err := t.index.ReadAt(buffer, int64(offset+1)*t.entrySize); err != nil {
 			return err
 		}
 		var newTail indexEntry
-		newTail.unmarshalBinary(buffer)
+		t.decodeEntry(&newTail, buffer)
 		newTailId = newTail.filenum
 	}
 	// Save the old size for metrics tracking. This needs to be done
@@ -517,7 +647,14 @@ func (t *freezerTable) truncateTail(items uint64) error {
 	}
 	// Update the virtual tail marker and hidden these entries in table.
 	t.itemHidden.Store(items)
-	if err := writeMetadata(t.meta, newMetadata(items)); err != nil {
+	if err := t.dropRepaired(items, t.items.Load()); err != nil {
+		return err
+	}
+	meta := newMetadata(items)
+	if t.checksum {
+		meta.Version = freezerChecksumVersion
+	}
+	if err := writeMetadata(t.meta, meta); err != nil {
 		return err
 	}
 	// Hidden items still fall in the current tail file, no data file
@@ -536,11 +673,11 @@ func (t *freezerTable) truncateTail(items uint64) error {
 	)
 	// Hidden items exceed the current tail file, drop the relevant data files.
 	for current := items - 1; current >= deleted; current -= 1 {
-		if _, err := t.index.ReadAt(buffer, int64((current-deleted+1)*indexEntrySize)); err != nil {
+		if _, err := t.index.ReadAt(buffer, int64(current-deleted+1)*t.entrySize); err != nil {
 			return err
 		}
 		var pre indexEntry
-		pre.unmarshalBinary(buffer)
+		t.decodeEntry(&pre, buffer)
 		if pre.filenum != newTailId {
 			break
 		}
@@ -556,12 +693,12 @@ func (t *freezerTable) truncateTail(items uint64) error {
 		return err
 	}
 	// Truncate the deleted index entries from the index file.
-	err = copyFrom(t.index.Name(), t.index.Name(), indexEntrySize*(newDeleted-deleted+1), func(f *os.File) error {
+	err = copyFrom(t.index.Name(), t.index.Name(), uint64(t.entrySize)*(newDeleted-deleted+1), func(f *os.File) error {
 		tailIndex := indexEntry{
 			filenum: newTailId,
 			offset:  uint32(newDeleted),
 		}
-		_, err := f.Write(tailIndex.append(nil))
+		_, err := f.Write(t.encodeEntry(nil, &tailIndex))
 		return err
 	})
 	if err != nil {
@@ -636,9 +773,12 @@ func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error
 	var exist bool
 	if f, exist = t.files[num]; !exist {
 		var name string
-		if t.noCompression {
+		switch {
+		case t.noCompression:
 			name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
-		} else {
+		case t.zstd != nil:
+			name = fmt.Sprintf("%s.%04d.zdat", t.name, num)
+		default:
 			name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
 		}
 		f, err = opener(filepath.Join(t.path, name))
@@ -696,18 +836,18 @@ func (t *freezerTable) getIndices(from, count uint64) ([]*indexEntry, error) {
 	from = from - t.itemOffset.Load()
 
 	// For reading N items, we need N+1 indices.
-	buffer := make([]byte, (count+1)*indexEntrySize)
-	if _, err := t.index.ReadAt(buffer, int64(from*indexEntrySize)); err != nil {
+	buffer := make([]byte, int64(count+1)*t.entrySize)
+	if _, err := t.index.ReadAt(buffer, int64(from)*t.entrySize); err != nil {
 		return nil, err
 	}
 	var (
 		indices []*indexEntry
-		offset  int
+		offset  int64
 	)
 	for i := from; i <= from+count; i++ {
 		index := new(indexEntry)
-		index.unmarshalBinary(buffer[offset:])
-		offset += indexEntrySize
+		t.decodeEntry(index, buffer[offset:])
+		offset += t.entrySize
 		indices = append(indices, index)
 	}
 	if from == 0 {
@@ -738,7 +878,7 @@ func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
 // item, it _will_ return one element and possibly overflow the maxBytes.
 func (t *freezerTable) RetrieveItems(start, count, maxBytes uint64) ([][]byte, error) {
 	// First we read the 'raw' data, which might be compressed.
-	diskData, sizes, err := t.retrieveItems(start, count, maxBytes)
+	diskData, sizes, checksums, err := t.retrieveItems(start, count, maxBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -751,23 +891,44 @@ func (t *freezerTable) RetrieveItems(start, count, maxBytes uint64) ([][]byte, e
 	for i, diskSize := range sizes {
 		item := diskData[offset : offset+diskSize]
 		offset += diskSize
-		decompressedSize := diskSize
-		if !t.noCompression {
-			decompressedSize, _ = snappy.DecodedLen(item)
+
+		// Serve the replacement if the item was repaired, the stored one is
+		// known to be corrupted.
+		if repaired := t.repairedItem(start + uint64(i)); repaired != nil {
+			if i > 0 && maxBytes != 0 && uint64(outputSize+len(repaired)) > maxBytes {
+				break
+			}
+			output = append(output, repaired)
+			outputSize += len(repaired)
+			continue
 		}
-		if i > 0 && maxBytes != 0 && uint64(outputSize+decompressedSize) > maxBytes {
-			break
+		if checksums != nil && crc32.Checksum(item, crcTable) != checksums[i] {
+			return nil, fmt.Errorf("%w: table %s, item %d", errChecksumMismatch, t.name, start+uint64(i))
 		}
-		if !t.noCompression {
-			data, err := snappy.Decode(nil, item)
-			if err != nil {
+		var data []byte
+		switch {
+		case t.noCompression:
+			data = item
+		case t.zstd != nil:
+			// The decompressed size of zstd items isn't known upfront, so
+			// they are decompressed before applying the size limit.
+			if data, err = t.zstd.decompress(item); err != nil {
+				return nil, fmt.Errorf("%w: table %s, item %d", err, t.name, start+uint64(i))
+			}
+		default:
+			decompressedSize, _ := snappy.DecodedLen(item)
+			if i > 0 && maxBytes != 0 && uint64(outputSize+decompressedSize) > maxBytes {
+				return output, nil
+			}
+			if data, err = snappy.Decode(nil, item); err != nil {
 				return nil, err
 			}
-			output = append(output, data)
-		} else {
-			output = append(output, item)
 		}
-		outputSize += decompressedSize
+		if i > 0 && maxBytes != 0 && uint64(outputSize+len(data)) > maxBytes {
+			break
+		}
+		output = append(output, data)
+		outputSize += len(data)
 	}
 	return output, nil
 }
@@ -775,15 +936,15 @@ func (t *freezerTable) RetrieveItems(start, count, maxBytes uint64) ([][]byte, e
 // retrieveItems reads up to 'count' items from the table. It reads at least
 // one item, but otherwise avoids reading more than maxBytes bytes. Freezer
 // will ignore the size limitation and continuously allocate memory to store
-// data if maxBytes is 0. It returns the (potentially compressed) data, and
-// the sizes.
-func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []int, error) {
+// data if maxBytes is 0. It returns the (potentially compressed) data, the
+// sizes and, if the table stores them, the checksums of the items.
+func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []int, []uint32, error) {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
 	// Ensure the table and the item are accessible
 	if t.index == nil || t.head == nil || t.meta == nil {
-		return nil, nil, errClosed
+		return nil, nil, nil, errClosed
 	}
 	var (
 		items  = t.items.Load()      // the total items(head + 1)
@@ -792,7 +953,7 @@ func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []i
 	// Ensure the start is written, not deleted from the tail, and that the
 	// caller actually wants something
 	if items <= start || hidden > start || count == 0 {
-		return nil, nil, errOutOfBounds
+		return nil, nil, nil, errOutOfBounds
 	}
 	if start+count > items {
 		count = items - start
@@ -818,10 +979,11 @@ func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []i
 	// Read all the indexes in one go
 	indices, err := t.getIndices(start, count)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	var (
 		sizes      []int               // The sizes for each element
+		checksums  []uint32            // The checksums for each element
 		totalSize  = 0                 // The total size of all data read so far
 		readStart  = indices[0].offset // Where, in the file, to start reading
 		unreadSize = 0                 // The size of the as-yet-unread data
@@ -837,7 +999,7 @@ func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []i
 			// If we have unread data in the first file, we need to do that read now.
 			if unreadSize > 0 {
 				if err := readData(firstIndex.filenum, readStart, unreadSize); err != nil {
-					return nil, nil, err
+					return nil, nil, nil, err
 				}
 				unreadSize = 0
 			}
@@ -848,7 +1010,7 @@ func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []i
 			// read this last item, but we need to do the deferred reads now.
 			if unreadSize > 0 {
 				if err := readData(secondIndex.filenum, readStart, unreadSize); err != nil {
-					return nil, nil, err
+					return nil, nil, nil, err
 				}
 			}
 			break
@@ -857,10 +1019,13 @@ func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []i
 		unreadSize += size
 		totalSize += size
 		sizes = append(sizes, size)
+		if t.checksum {
+			checksums = append(checksums, secondIndex.checksum)
+		}
 		if i == len(indices)-2 || (uint64(totalSize) > maxBytes && maxBytes != 0) {
 			// Last item, need to do the read now
 			if err := readData(secondIndex.filenum, readStart, unreadSize); err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 			break
 		}
@@ -868,7 +1033,7 @@ func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []i
 
 	// Update metrics.
 	t.readMeter.Mark(int64(totalSize))
-	return output, sizes, nil
+	return output, sizes, checksums, nil
 }
 
 // has returns an indicator whether the specified number data is still accessible
@@ -970,17 +1135,17 @@ func (t *freezerTable) dumpIndex(w io.Writer, start, stop int64) {
 	fmt.Fprintf(w, "Version %d count %d, deleted %d, hidden %d\n", meta.Version,
 		t.items.Load(), t.itemOffset.Load(), t.itemHidden.Load())
 
-	buf := make([]byte, indexEntrySize)
+	buf := make([]byte, t.entrySize)
 
 	fmt.Fprintf(w, "| number | fileno | offset |\n")
 	fmt.Fprintf(w, "|--------|--------|--------|\n")
 
 	for i := uint64(start); ; i++ {
-		if _, err := t.index.ReadAt(buf, int64((i+1)*indexEntrySize)); err != nil {
+		if _, err := t.index.ReadAt(buf, int64(i+1)*t.entrySize); err != nil {
 			break
 		}
 		var entry indexEntry
-		entry.unmarshalBinary(buf)
+		t.decodeEntry(&entry, buf)
 		fmt.Fprintf(w, "|  %03d   |  %03d   |  %03d   | \n", i, entry.filenum, entry.offset)
 		if stop > 0 && i >= uint64(stop) {
 			break
@@ -0,0 +1,158 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// repairedEntry is the persisted form of a repaired item.
+type repairedEntry struct {
+	Number uint64
+	Item   []byte
+}
+
+// The items of a freezer table can't be rewritten in place, as they are packed
+// back to back in the data files. Items found to be corrupted are therefore
+// repaired by storing a replacement in a side file, which is served instead of
+// the stored item. Corruptions are rare, so the replacements are kept in memory.
+
+// repairedPath returns the path of the file holding the repaired items.
+func (t *freezerTable) repairedPath() string {
+	return filepath.Join(t.path, fmt.Sprintf("%s.repair", t.name))
+}
+
+// loadRepaired loads the repaired items of the table.
+func (t *freezerTable) loadRepaired() error {
+	blob, err := os.ReadFile(t.repairedPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []repairedEntry
+	if err := rlp.DecodeBytes(blob, &entries); err != nil {
+		return fmt.Errorf("freezer table %s: invalid repaired items: %v", t.name, err)
+	}
+	t.repaired = make(map[uint64][]byte, len(entries))
+	for _, entry := range entries {
+		t.repaired[entry.Number] = entry.Item
+	}
+	return nil
+}
+
+// writeRepaired persists the repaired items of the table, replacing the file
+// atomically. The caller must hold the write lock.
+func (t *freezerTable) writeRepaired() error {
+	path := t.repairedPath()
+	if len(t.repaired) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	entries := make([]repairedEntry, 0, len(t.repaired))
+	for number, item := range t.repaired {
+		entries = append(entries, repairedEntry{Number: number, Item: item})
+	}
+	slices.SortFunc(entries, func(a, b repairedEntry) int {
+		if a.Number < b.Number {
+			return -1
+		}
+		return 1
+	})
+	blob, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// repairedItem returns the replacement of the item with the given number, or
+// nil if it was never repaired.
+func (t *freezerTable) repairedItem(number uint64) []byte {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if item, ok := t.repaired[number]; ok {
+		return common.CopyBytes(item)
+	}
+	return nil
+}
+
+// repairItem replaces the item with the given number with the uncompressed
+// item. The replacement is served on all subsequent reads of the item.
+func (t *freezerTable) repairItem(number uint64, item []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.readonly {
+		return errReadOnly
+	}
+	if !t.has(number) {
+		return errOutOfBounds
+	}
+	if t.repaired == nil {
+		t.repaired = make(map[uint64][]byte)
+	}
+	t.repaired[number] = common.CopyBytes(item)
+	if err := t.writeRepaired(); err != nil {
+		delete(t.repaired, number)
+		return err
+	}
+	t.logger.Warn("Repaired freezer item", "number", number)
+	return nil
+}
+
+// dropRepaired discards the replacements of the items which are no longer in
+// the range [tail, head). The caller must hold the write lock.
+func (t *freezerTable) dropRepaired(tail, head uint64) error {
+	var dropped bool
+	for number := range t.repaired {
+		if number < tail || number >= head {
+			delete(t.repaired, number)
+			dropped = true
+		}
+	}
+	if !dropped {
+		return nil
+	}
+	return t.writeRepaired()
+}
@@ -19,6 +19,7 @@ package rawdb
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -1367,3 +1368,164 @@ func TestRandom(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFreezerChecksum(t *testing.T) {
+	t.Parallel()
+	var (
+		dir   = t.TempDir()
+		fname = fmt.Sprintf("checksum-%d", rand.Uint64())
+		opts  = FreezerOptions{Checksum: true}
+	)
+	f, err := newTableWithOptions(dir, fname, metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 50, false, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeChunks(t, f, 30, 15)
+	f.Close()
+
+	// The checksums are kept after reopening, even without the option.
+	f, err = newTable(dir, fname, metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 50, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.checksum {
+		t.Fatal("Checksums are not detected")
+	}
+	for i := 0; i < 30; i++ {
+		checkRetrieve(t, f, map[uint64][]byte{uint64(i): getChunk(15, i)})
+	}
+	f.Close()
+
+	// Flip a bit of the first item, it must be detected.
+	path := filepath.Join(dir, fmt.Sprintf("%s.0000.cdat", fname))
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob[2] ^= 0x1
+	if err := os.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err = newTable(dir, fname, metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 50, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Retrieve(0); !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("Corruption not detected: %v", err)
+	}
+	checkRetrieve(t, f, map[uint64][]byte{1: getChunk(15, 1)})
+
+	// Repair the item, the replacement must be served and persisted.
+	if err := f.repairItem(0, getChunk(15, 0)); err != nil {
+		t.Fatal(err)
+	}
+	checkRetrieve(t, f, map[uint64][]byte{0: getChunk(15, 0)})
+	f.Close()
+
+	f, err = newTable(dir, fname, metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 50, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	checkRetrieve(t, f, map[uint64][]byte{0: getChunk(15, 0)})
+	items, err := f.RetrieveItems(0, 30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, item := range items {
+		if !bytes.Equal(item, getChunk(15, i)) {
+			t.Fatalf("Item %d mismatch: have %x", i, item)
+		}
+	}
+	// Truncating the repaired item drops the replacement.
+	if err := f.truncateHead(0); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.repaired) != 0 {
+		t.Fatal("Replacement of the truncated item is kept")
+	}
+}
+
+func TestFreezerZstd(t *testing.T) {
+	if !zstdSupported {
+		t.Skip("zstd is not supported in this build")
+	}
+	t.Parallel()
+	var (
+		dir   = t.TempDir()
+		fname = fmt.Sprintf("zstd-%d", rand.Uint64())
+		opts  = FreezerOptions{Compression: FreezerZstd, Checksum: true}
+		items [][]byte
+	)
+	// Items sharing some content, as the items of the chain tables do.
+	for i := 0; i < 500; i++ {
+		item := append(bytes.Repeat([]byte("shared content of the items "), 4), getChunk(32, i)...)
+		items = append(items, binary.BigEndian.AppendUint64(item, uint64(i)))
+	}
+	f, err := newTableWithOptions(dir, fname, metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 4096, false, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := f.newBatch()
+	for i, item := range items {
+		if err := batch.AppendRaw(uint64(i), item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batch.commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.zstd.dict) == 0 {
+		t.Fatal("No dictionary was trained")
+	}
+	f.Close()
+
+	// Reopen with the default codec, the table must stay zstd-compressed.
+	f, err = newTable(dir, fname, metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 4096, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.zstd == nil {
+		t.Fatal("Zstd compression is not detected")
+	}
+	got, err := f.RetrieveItems(0, uint64(len(items)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, items) {
+		t.Fatal("Retrieved items mismatch")
+	}
+	// The byte limit applies to the decompressed items.
+	got, err = f.RetrieveItems(10, 100, uint64(3*len(items[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Wrong number of items retrieved: have %d, want 3", len(got))
+	}
+	size, err := f.size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw := len(items) * len(items[0]); size > uint64(raw/2) {
+		t.Fatalf("Poor compression: %d bytes for %d raw bytes", size, raw)
+	}
+}
+
+func TestTrainDictionary(t *testing.T) {
+	var (
+		common  = []byte("this substring is shared by all the samples, it belongs into the dictionary")
+		samples [][]byte
+	)
+	for i := 0; i < 100; i++ {
+		samples = append(samples, append(append(getChunk(20, i), common...), getChunk(20, i+1)...))
+	}
+	dict := trainDictionary(samples, 1024)
+	if !bytes.Contains(dict, common[:dictSegmentSize/2]) {
+		t.Fatalf("Shared content is not in the dictionary: %q", dict)
+	}
+	if dict := trainDictionary(samples, 16); len(dict) != 16 {
+		t.Fatalf("Dictionary exceeds the size limit: %d", len(dict))
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build cgo
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/DataDog/zstd"
+)
+
+// zstdSupported reports whether the zstd codec is available in this build.
+const zstdSupported = true
+
+// zstdDigest is a dictionary digested once for compressing and decompressing
+// all items of a table. It's safe for concurrent use.
+type zstdDigest = *zstd.BulkProcessor
+
+// digestDictionary prepares the given dictionary for reuse. An empty dictionary
+// results in a nil digest, the items are compressed without one then.
+func digestDictionary(dict []byte) (zstdDigest, error) {
+	if len(dict) == 0 {
+		return nil, nil
+	}
+	return zstd.NewBulkProcessor(dict, zstdLevel)
+}
+
+// compress zstd-compresses the data, appending it to dst.
+func (c *zstdCodec) compress(dst []byte, data []byte) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+	if c.digest == nil {
+		out, err = zstd.CompressLevel(dst[len(dst):], data, zstdLevel)
+	} else {
+		out, err = c.digest.Compress(dst[len(dst):], data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, out...), nil
+}
+
+// decompress decompresses a zstd-compressed item.
+func (c *zstdCodec) decompress(data []byte) ([]byte, error) {
+	if c.digest == nil {
+		return zstd.Decompress(nil, data)
+	}
+	// The decompressor guesses the size of well compressible items too small,
+	// allocate the content size recorded in the frame header instead.
+	size, err := zstdContentSize(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.digest.Decompress(make([]byte, 0, size), data)
+}
+
+// errZstdContentSize is returned if a compressed item doesn't record the size
+// of its content.
+var errZstdContentSize = errors.New("zstd frame without content size")
+
+// zstdContentSize parses the content size from the header of a zstd frame.
+func zstdContentSize(frame []byte) (uint64, error) {
+	if len(frame) < 5 || binary.LittleEndian.Uint32(frame) != 0xfd2fb528 {
+		return 0, errors.New("invalid zstd frame")
+	}
+	var (
+		desc   = frame[4]
+		single = desc&0x20 != 0
+		offset = 5 + [4]int{0, 1, 2, 4}[desc&0x03]
+	)
+	if !single {
+		offset++ // window descriptor
+	}
+	var size int
+	switch desc >> 6 {
+	case 0:
+		if !single {
+			return 0, errZstdContentSize
+		}
+		size = 1
+	case 1:
+		size = 2
+	case 2:
+		size = 4
+	case 3:
+		size = 8
+	}
+	if len(frame) < offset+size {
+		return 0, errors.New("truncated zstd frame header")
+	}
+	field := frame[offset : offset+size]
+	switch size {
+	case 1:
+		return uint64(field[0]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(field)) + 256, nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(field)), nil
+	default:
+		return binary.LittleEndian.Uint64(field), nil
+	}
+}
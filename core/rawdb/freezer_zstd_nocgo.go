@@ -0,0 +1,46 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !cgo
+
+package rawdb
+
+import "errors"
+
+// zstdSupported reports whether the zstd codec is available in this build.
+const zstdSupported = false
+
+// errZstdUnsupported is returned if a zstd table is accessed in a build
+// without cgo, which the zstd library depends on.
+var errZstdUnsupported = errors.New("zstd compression requires cgo")
+
+// zstdDigest is a placeholder for the digested dictionary of cgo builds.
+type zstdDigest struct{}
+
+// digestDictionary always fails, zstd is not available.
+func digestDictionary(dict []byte) (zstdDigest, error) {
+	return zstdDigest{}, errZstdUnsupported
+}
+
+// compress always fails, zstd is not available.
+func (c *zstdCodec) compress(dst []byte, data []byte) ([]byte, error) {
+	return nil, errZstdUnsupported
+}
+
+// decompress always fails, zstd is not available.
+func (c *zstdCodec) decompress(data []byte) ([]byte, error) {
+	return nil, errZstdUnsupported
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build cgo
+
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/DataDog/zstd"
+)
+
+// Tests that items of any size round-trip through the codec, both with and
+// without a dictionary.
+func TestZstdItems(t *testing.T) {
+	var dicted zstdCodec
+	if err := dicted.setDictionary(bytes.Repeat([]byte("dictionary content "), 64)); err != nil {
+		t.Fatal(err)
+	}
+	for _, codec := range []*zstdCodec{new(zstdCodec), &dicted} {
+		for _, item := range [][]byte{
+			[]byte("short item with dictionary content"),
+			bytes.Repeat([]byte("dictionary content of a large, well compressible item "), 1<<16),
+		} {
+			enc, err := codec.compress([]byte{0xff}, item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if enc[0] != 0xff {
+				t.Fatal("compressed item not appended")
+			}
+			got, err := codec.decompress(enc[1:])
+			if err != nil {
+				t.Fatalf("failed to decompress item of %d bytes: %v", len(item), err)
+			}
+			if !bytes.Equal(got, item) {
+				t.Fatalf("item of %d bytes mismatch", len(item))
+			}
+		}
+	}
+}
+
+// Tests that items not recording their content size, like those written by the
+// streaming encoder, are rejected.
+func TestZstdStreamedItems(t *testing.T) {
+	var codec zstdCodec
+	if err := codec.setDictionary(bytes.Repeat([]byte("dictionary content "), 64)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := zstd.NewWriterLevelDict(&buf, zstdLevel, codec.dict)
+	if _, err := w.Write([]byte("streamed item with dictionary content")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := codec.decompress(buf.Bytes()); !errors.Is(err, errZstdContentSize) {
+		t.Fatalf("streamed item error mismatch: have %v, want %v", err, errZstdContentSize)
+	}
+}
@@ -73,12 +73,27 @@ var (
 	// skeletonSyncStatusKey tracks the skeleton sync status across restarts.
 	skeletonSyncStatusKey = []byte("SkeletonSyncStatus")
 
+	// powSkeletonSyncStatusKey tracks the header-first PoW sync status across restarts.
+	powSkeletonSyncStatusKey = []byte("PoWSkeletonSyncStatus")
+
 	// trieJournalKey tracks the in-memory trie node layers across restarts.
 	trieJournalKey = []byte("TrieJournal")
 
+	// stateHistoryIndexTailKey tracks the oldest state history from which on all
+	// state histories have been indexed (for path-based only).
+	stateHistoryIndexTailKey = []byte("StateHistoryIndexTail")
+
+	// stateConversionKey tracks the progress of converting the state from the
+	// hash-based scheme into the path-based one.
+	stateConversionKey = []byte("StateConversion")
+
 	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
 	txIndexTailKey = []byte("TransactionIndexTail")
 
+	// receiptPruneTailKey tracks the oldest block whose receipts haven't been
+	// pruned yet.
+	receiptPruneTailKey = []byte("ReceiptPruneTail")
+
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	// This flag is deprecated, it's kept to avoid reporting errors when inspect
 	// database.
@@ -117,6 +132,11 @@ var (
 	trieNodeStoragePrefix = []byte("O") // trieNodeStoragePrefix + accountHash + hexPath -> trie node
 	stateIDPrefix         = []byte("L") // stateIDPrefix + state root -> state id
 
+	// Path-based index of the state histories modifying an account or slot.
+	stateHistoryAccountIndexPrefix    = []byte("ma") // stateHistoryAccountIndexPrefix + address + id (uint64 big endian) -> nil
+	stateHistoryStorageIndexPrefix    = []byte("ms") // stateHistoryStorageIndexPrefix + address + slot hash + id (uint64 big endian) -> nil
+	stateHistoryIncompleteIndexPrefix = []byte("mi") // stateHistoryIncompleteIndexPrefix + address + id (uint64 big endian) -> nil
+
 	PreimagePrefix = []byte("secure-key-")       // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-")  // config prefix for the db
 	genesisPrefix  = []byte("ethereum-genesis-") // genesis state prefix for the db
@@ -269,6 +289,31 @@ func stateIDKey(root common.Hash) []byte {
 	return append(stateIDPrefix, root.Bytes()...)
 }
 
+// stateHistoryAccountIndexKey = stateHistoryAccountIndexPrefix + address + id (uint64 big endian)
+func stateHistoryAccountIndexKey(address common.Address, id uint64) []byte {
+	buf := make([]byte, 0, len(stateHistoryAccountIndexPrefix)+common.AddressLength+8)
+	buf = append(buf, stateHistoryAccountIndexPrefix...)
+	buf = append(buf, address.Bytes()...)
+	return append(buf, encodeBlockNumber(id)...)
+}
+
+// stateHistoryStorageIndexKey = stateHistoryStorageIndexPrefix + address + slot hash + id (uint64 big endian)
+func stateHistoryStorageIndexKey(address common.Address, slot common.Hash, id uint64) []byte {
+	buf := make([]byte, 0, len(stateHistoryStorageIndexPrefix)+common.AddressLength+common.HashLength+8)
+	buf = append(buf, stateHistoryStorageIndexPrefix...)
+	buf = append(buf, address.Bytes()...)
+	buf = append(buf, slot.Bytes()...)
+	return append(buf, encodeBlockNumber(id)...)
+}
+
+// stateHistoryIncompleteIndexKey = stateHistoryIncompleteIndexPrefix + address + id (uint64 big endian)
+func stateHistoryIncompleteIndexKey(address common.Address, id uint64) []byte {
+	buf := make([]byte, 0, len(stateHistoryIncompleteIndexPrefix)+common.AddressLength+8)
+	buf = append(buf, stateHistoryIncompleteIndexPrefix...)
+	buf = append(buf, address.Bytes()...)
+	return append(buf, encodeBlockNumber(id)...)
+}
+
 // accountTrieNodeKey = trieNodeAccountPrefix + nodePath.
 func accountTrieNodeKey(path []byte) []byte {
 	return append(trieNodeAccountPrefix, path...)
@@ -118,6 +118,10 @@ type Trie interface {
 	// can be used even if the trie doesn't have one.
 	Hash() common.Hash
 
+	// Witness returns a set containing all trie nodes that have been accessed.
+	// The returned set may be nil if the trie does not track accessed nodes.
+	Witness() map[string]struct{}
+
 	// Commit collects all dirty nodes in the trie and replace them with the
 	// corresponding node hash. All collected nodes(including dirty leaves if
 	// collectLeaf is true) will be encapsulated into a nodeset for return.
@@ -184,6 +188,13 @@ func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
 	}
 	tr, err := trie.NewStateTrie(trie.StateTrieID(root), db.triedb)
 	if err != nil {
+		// The trie nodes of historic states are not retained in the path-based
+		// scheme, try to serve the state from the state histories instead.
+		if db.triedb.Scheme() == rawdb.PathScheme {
+			if htr, herr := newHistoricTrie(root, db.triedb); herr == nil {
+				return htr, nil
+			}
+		}
 		return nil, err
 	}
 	return tr, nil
@@ -197,6 +208,9 @@ func (db *cachingDB) OpenStorageTrie(stateRoot common.Hash, address common.Addre
 	if db.triedb.IsVerkle() {
 		return self, nil
 	}
+	if htr, ok := self.(*historicTrie); ok {
+		return htr.storageTrie(address, root), nil
+	}
 	tr, err := trie.NewStateTrie(trie.StorageTrieID(stateRoot, crypto.Keccak256Hash(address.Bytes()), root), db.triedb)
 	if err != nil {
 		return nil, err
@@ -209,6 +223,8 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 	switch t := t.(type) {
 	case *trie.StateTrie:
 		return t.Copy()
+	case *historicTrie:
+		return t.copy()
 	default:
 		panic(fmt.Errorf("unknown trie type %T", t))
 	}
@@ -0,0 +1,257 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+	"maps"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// errHistoricTrie is returned for the operations a historic trie can't support
+// as it has no access to the trie nodes of the state.
+var errHistoricTrie = errors.New("not supported by historic state")
+
+// historicTrie is a view of a historic state in the path-based scheme, which
+// has no trie nodes available. Accounts and storage slots are resolved from the
+// state histories instead.
+//
+// Mutations are kept in memory, so that the state can be used for executing
+// transactions (e.g. tracing), but they are neither hashed nor committed.
+type historicTrie struct {
+	db      *triedb.Database
+	reader  *pathdb.HistoricalStateReader
+	state   common.Hash     // Root of the historic state
+	root    common.Hash     // Root of the trie, equal to state for the account trie
+	address *common.Address // Owner of the storage, nil for the account trie
+
+	base     *trie.StateTrie // Trie of the base state the reader falls back to
+	accounts map[common.Address]*types.StateAccount
+	storages map[common.Hash][]byte
+}
+
+// newHistoricTrie opens the historic account trie with the given state root.
+func newHistoricTrie(root common.Hash, db *triedb.Database) (*historicTrie, error) {
+	reader, err := db.HistoricReader(root)
+	if err != nil {
+		return nil, err
+	}
+	return &historicTrie{
+		db:       db,
+		reader:   reader,
+		state:    root,
+		root:     root,
+		accounts: make(map[common.Address]*types.StateAccount),
+	}, nil
+}
+
+// IsHistoricTrie reports whether the trie is served from the state histories
+// rather than from trie nodes, in which case it can't be committed.
+func IsHistoricTrie(tr Trie) bool {
+	_, ok := tr.(*historicTrie)
+	return ok
+}
+
+// storageTrie opens the historic storage trie of the given account.
+func (t *historicTrie) storageTrie(address common.Address, root common.Hash) *historicTrie {
+	return &historicTrie{
+		db:       t.db,
+		reader:   t.reader,
+		state:    t.state,
+		root:     root,
+		address:  &address,
+		storages: make(map[common.Hash][]byte),
+	}
+}
+
+// refresh reopens the reader if the base state has been flattened since it was
+// created, reporting whether it did so.
+func (t *historicTrie) refresh() bool {
+	reader, err := t.db.HistoricReader(t.state)
+	if err != nil || reader.Base() == t.reader.Base() {
+		return false
+	}
+	t.reader, t.base = reader, nil
+	return true
+}
+
+// baseAccount retrieves the account from the base state.
+func (t *historicTrie) baseAccount(address common.Address) (*types.StateAccount, error) {
+	if t.base == nil {
+		tr, err := trie.NewStateTrie(trie.StateTrieID(t.reader.Base()), t.db)
+		if err != nil {
+			return nil, err
+		}
+		t.base = tr
+	}
+	return t.base.GetAccount(address)
+}
+
+// baseStorage retrieves the storage slot from the base state.
+func (t *historicTrie) baseStorage(key []byte) ([]byte, error) {
+	if t.base == nil {
+		tr, err := trie.NewStateTrie(trie.StateTrieID(t.reader.Base()), t.db)
+		if err != nil {
+			return nil, err
+		}
+		acc, err := tr.GetAccount(*t.address)
+		if err != nil {
+			return nil, err
+		}
+		if acc == nil || acc.Root == types.EmptyRootHash {
+			return nil, nil
+		}
+		id := trie.StorageTrieID(t.reader.Base(), crypto.Keccak256Hash(t.address.Bytes()), acc.Root)
+		if t.base, err = trie.NewStateTrie(id, t.db); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.GetStorage(*t.address, key)
+}
+
+// GetKey returns the sha3 preimage of a hashed key that was previously used
+// to store a value.
+func (t *historicTrie) GetKey(shaKey []byte) []byte {
+	return t.db.Preimage(common.BytesToHash(shaKey))
+}
+
+// GetAccount retrieves the account with the given address from the historic state.
+func (t *historicTrie) GetAccount(address common.Address) (*types.StateAccount, error) {
+	if acc, ok := t.accounts[address]; ok {
+		return acc, nil
+	}
+	for {
+		blob, found, err := t.reader.Account(address)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			if len(blob) == 0 {
+				return nil, nil
+			}
+			return types.FullAccount(blob)
+		}
+		acc, err := t.baseAccount(address)
+		if err != nil && t.refresh() {
+			continue
+		}
+		return acc, err
+	}
+}
+
+// GetStorage retrieves the storage slot with the given key from the historic state.
+func (t *historicTrie) GetStorage(_ common.Address, key []byte) ([]byte, error) {
+	slot := crypto.Keccak256Hash(key)
+	if value, ok := t.storages[slot]; ok {
+		return value, nil
+	}
+	for {
+		blob, found, err := t.reader.Storage(*t.address, slot)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			if len(blob) == 0 {
+				return nil, nil
+			}
+			_, content, _, err := rlp.Split(blob)
+			return content, err
+		}
+		value, err := t.baseStorage(key)
+		if err != nil && t.refresh() {
+			continue
+		}
+		return value, err
+	}
+}
+
+// UpdateAccount records the account in memory.
+func (t *historicTrie) UpdateAccount(address common.Address, account *types.StateAccount) error {
+	t.accounts[address] = account
+	return nil
+}
+
+// UpdateStorage records the storage slot in memory.
+func (t *historicTrie) UpdateStorage(_ common.Address, key, value []byte) error {
+	slot := crypto.Keccak256Hash(key)
+	if len(value) == 0 {
+		t.storages[slot] = nil
+	} else {
+		t.storages[slot] = common.CopyBytes(value)
+	}
+	return nil
+}
+
+// DeleteAccount records the account deletion in memory.
+func (t *historicTrie) DeleteAccount(address common.Address) error {
+	t.accounts[address] = nil
+	return nil
+}
+
+// DeleteStorage records the storage slot deletion in memory.
+func (t *historicTrie) DeleteStorage(_ common.Address, key []byte) error {
+	t.storages[crypto.Keccak256Hash(key)] = nil
+	return nil
+}
+
+// UpdateContractCode is a no-op, codes are not part of the trie.
+func (t *historicTrie) UpdateContractCode(_ common.Address, _ common.Hash, _ []byte) error {
+	return nil
+}
+
+// Hash returns the root of the historic trie. Mutations are not reflected.
+func (t *historicTrie) Hash() common.Hash {
+	return t.root
+}
+
+// Witness returns nil, historic tries are not backed by trie nodes.
+func (t *historicTrie) Witness() map[string]struct{} {
+	return nil
+}
+
+// Commit is not supported by historic tries.
+func (t *historicTrie) Commit(_ bool) (common.Hash, *trienode.NodeSet, error) {
+	return common.Hash{}, nil, errHistoricTrie
+}
+
+// NodeIterator is not supported by historic tries.
+func (t *historicTrie) NodeIterator(_ []byte) (trie.NodeIterator, error) {
+	return nil, errHistoricTrie
+}
+
+// Prove is not supported by historic tries.
+func (t *historicTrie) Prove(_ []byte, _ ethdb.KeyValueWriter) error {
+	return errHistoricTrie
+}
+
+// copy returns an independent copy of the trie.
+func (t *historicTrie) copy() *historicTrie {
+	cpy := *t
+	cpy.base = nil
+	cpy.accounts = maps.Clone(t.accounts)
+	cpy.storages = maps.Clone(t.storages)
+	return &cpy
+}
@@ -0,0 +1,472 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// Phases of the state scheme conversion.
+const (
+	conversionCopying  = iota // Trie nodes are being copied into the path-based scheme
+	conversionCleaning        // Path-based state is in place, legacy trie nodes are being deleted
+)
+
+// conversionProgress is the checkpoint of a state scheme conversion, which is
+// persisted atomically with the trie nodes converted so far.
+type conversionProgress struct {
+	Root     common.Hash // State root being converted
+	Phase    uint64      // Phase of the conversion
+	Account  common.Hash // Last account whose trie nodes were all converted
+	Accounts uint64      // Number of accounts converted
+	Nodes    uint64      // Number of trie nodes converted
+}
+
+// stateConverter rebuilds a state stored in the hash-based scheme in the
+// path-based scheme.
+type stateConverter struct {
+	db       ethdb.Database
+	triedb   *triedb.Database
+	snaptree *snapshot.Tree // Snapshot to rebuild storage tries from, nil if unavailable
+	progress conversionProgress
+	batch    ethdb.Batch
+}
+
+// ConvertToPathScheme converts the state of a database in the hash-based scheme
+// into the path-based scheme offline, without resyncing. The workflow is:
+//
+//   - pick the most recent block whose state is available
+//   - copy the trie nodes of the state into the path-based layout, rebuilding
+//     the storage tries from the snapshot if possible and iterating the legacy
+//     tries otherwise; the account trie root is written last, so the database
+//     keeps being recognized as hash-based until the copy is complete
+//   - verify the converted state by iterating it entirely through the path-based
+//     database, which checks every node against the state root
+//   - write the path-based metadata and layer journal
+//   - delete the legacy trie nodes
+//
+// The progress is checkpointed along with the converted nodes, an interrupted
+// conversion is resumed when called again.
+func ConvertToPathScheme(db ethdb.Database) error {
+	c := &stateConverter{
+		db:     db,
+		triedb: triedb.NewDatabase(db, triedb.HashDefaults),
+		batch:  db.NewBatch(),
+	}
+	if blob := rawdb.ReadStateConversion(db); len(blob) > 0 {
+		if err := rlp.DecodeBytes(blob, &c.progress); err != nil {
+			return fmt.Errorf("invalid state conversion progress: %v", err)
+		}
+		log.Info("Resuming state conversion", "root", c.progress.Root, "phase", c.progress.Phase, "accounts", c.progress.Accounts, "nodes", c.progress.Nodes)
+	} else {
+		if scheme := rawdb.ReadStateScheme(db); scheme != rawdb.HashScheme {
+			return fmt.Errorf("state scheme is %q, not %q", scheme, rawdb.HashScheme)
+		}
+		header, err := convertibleHeader(db)
+		if err != nil {
+			return err
+		}
+		c.progress.Root = header.Root
+		log.Info("Converting state to path scheme", "number", header.Number, "root", header.Root)
+	}
+	if c.progress.Phase == conversionCopying {
+		start := time.Now()
+		if err := c.copy(); err != nil {
+			return err
+		}
+		log.Info("Copied state into path scheme", "accounts", c.progress.Accounts, "nodes", c.progress.Nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+
+		if err := c.finalize(); err != nil {
+			return err
+		}
+	}
+	return c.clean()
+}
+
+// convertibleHeader returns the header of the most recent canonical block whose
+// state is available. On clean shutdowns the head state is always persisted.
+func convertibleHeader(db ethdb.Database) (*types.Header, error) {
+	head := rawdb.ReadHeadBlock(db)
+	if head == nil {
+		return nil, errors.New("failed to load head block")
+	}
+	header := head.Header()
+	for !rawdb.HasLegacyTrieNode(db, header.Root) {
+		if header.Number.Uint64() == 0 {
+			return nil, errors.New("no state available to convert")
+		}
+		header = rawdb.ReadHeader(db, header.ParentHash, header.Number.Uint64()-1)
+		if header == nil {
+			return nil, errors.New("missing parent header")
+		}
+	}
+	if header.Hash() != head.Hash() {
+		log.Warn("Head state missing, converting older state", "head", head.Number(), "number", header.Number)
+	}
+	return header, nil
+}
+
+// copy copies the trie nodes of the state into the path-based layout, except
+// for the account trie root.
+func (c *stateConverter) copy() error {
+	snapconfig := snapshot.Config{
+		CacheSize:  256,
+		Recovery:   false,
+		NoBuild:    true,
+		AsyncBuild: false,
+	}
+	root := c.progress.Root
+	if snaptree, err := snapshot.New(snapconfig, c.db, c.triedb, root); err == nil && snaptree.Snapshot(root) != nil {
+		c.snaptree = snaptree
+		defer snaptree.Release()
+	} else {
+		log.Info("Snapshot unavailable, converting storage from tries")
+	}
+	tr, err := trie.New(trie.StateTrieID(root), c.triedb)
+	if err != nil {
+		return err
+	}
+	var start []byte
+	if c.progress.Accounts > 0 {
+		start = c.progress.Account.Bytes()
+	}
+	it, err := tr.NodeIterator(start)
+	if err != nil {
+		return err
+	}
+	logged := time.Now()
+	for it.Next(true) {
+		// Embedded nodes are stored in their parents, the root is written last
+		if it.Hash() != (common.Hash{}) && len(it.Path()) > 0 {
+			rawdb.WriteAccountTrieNode(c.batch, it.Path(), it.NodeBlob())
+			c.progress.Nodes++
+		}
+		if !it.Leaf() {
+			continue
+		}
+		// The account at the checkpoint was converted entirely already
+		if start != nil && bytes.Equal(it.LeafKey(), start) {
+			continue
+		}
+		var (
+			hash    = common.BytesToHash(it.LeafKey())
+			account types.StateAccount
+		)
+		if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+			return err
+		}
+		if account.Root != types.EmptyRootHash {
+			if err := c.copyStorage(hash, account.Root); err != nil {
+				return err
+			}
+		}
+		// Contract code may be stored under its bare hash by old databases, which
+		// are deleted along with the legacy trie nodes.
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != types.EmptyCodeHash && !rawdb.HasCodeWithPrefix(c.db, codeHash) {
+			code := rawdb.ReadCode(c.db, codeHash)
+			if len(code) == 0 {
+				return fmt.Errorf("missing code %x of account %x", codeHash, hash)
+			}
+			rawdb.WriteCode(c.batch, codeHash, code)
+		}
+		c.progress.Account = hash
+		c.progress.Accounts++
+
+		if c.batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := c.checkpoint(); err != nil {
+				return err
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Converting state", "at", hash, "accounts", c.progress.Accounts, "nodes", c.progress.Nodes)
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return c.checkpoint()
+}
+
+// copyStorage copies the storage trie nodes of the given account into the
+// path-based layout.
+func (c *stateConverter) copyStorage(account common.Hash, root common.Hash) error {
+	write := func(path []byte, hash common.Hash, blob []byte) {
+		rawdb.WriteStorageTrieNode(c.batch, account, path, blob)
+		c.progress.Nodes++
+	}
+	// Regenerate the trie from the snapshot, which avoids random disk reads
+	if c.snaptree != nil {
+		nodes := c.progress.Nodes
+		have, err := c.regenerateStorage(account, write)
+		if err != nil {
+			return err
+		}
+		if have == root {
+			return nil
+		}
+		// The written nodes are overwritten by the trie iteration below. The
+		// snapshot may not be fully generated yet, so it's not an error.
+		log.Debug("Snapshot storage unavailable, converting from trie", "account", account, "root", root)
+		c.progress.Nodes = nodes
+	}
+	tr, err := trie.New(trie.StorageTrieID(c.progress.Root, account, root), c.triedb)
+	if err != nil {
+		return err
+	}
+	it, err := tr.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	for it.Next(true) {
+		if it.Hash() == (common.Hash{}) {
+			continue
+		}
+		write(it.Path(), it.Hash(), it.NodeBlob())
+		if err := c.flush(); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// regenerateStorage regenerates the storage trie of the given account from the
+// snapshot, returning its root. An empty root is returned if the snapshot can't
+// be iterated.
+func (c *stateConverter) regenerateStorage(account common.Hash, write trie.OnTrieNode) (common.Hash, error) {
+	it, err := c.snaptree.StorageIterator(c.progress.Root, account, common.Hash{})
+	if err != nil {
+		return common.Hash{}, nil
+	}
+	defer it.Release()
+
+	st := trie.NewStackTrie(write)
+	for it.Next() {
+		st.Update(it.Hash().Bytes(), common.CopyBytes(it.Slot()))
+		if err := c.flush(); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	if it.Error() != nil {
+		return common.Hash{}, nil
+	}
+	return st.Hash(), nil
+}
+
+// flush writes out the batch if it grew too large, without checkpointing. It's
+// used within a single account, which is converted again if interrupted.
+func (c *stateConverter) flush() error {
+	if c.batch.ValueSize() < ethdb.IdealBatchSize {
+		return nil
+	}
+	if err := c.batch.Write(); err != nil {
+		return err
+	}
+	c.batch.Reset()
+	return nil
+}
+
+// checkpoint writes out the batch along with the conversion progress.
+func (c *stateConverter) checkpoint() error {
+	blob, err := rlp.EncodeToBytes(&c.progress)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteStateConversion(c.batch, blob)
+	if err := c.batch.Write(); err != nil {
+		return err
+	}
+	c.batch.Reset()
+	return nil
+}
+
+// finalize verifies the converted state and switches the database over to the
+// path-based scheme by writing the account trie root and the metadata.
+func (c *stateConverter) finalize() error {
+	root := c.progress.Root
+	blob := rawdb.ReadLegacyTrieNode(c.db, root)
+	if len(blob) == 0 {
+		return fmt.Errorf("missing state root %x", root)
+	}
+	rawdb.WriteAccountTrieNode(c.batch, nil, blob)
+	rawdb.WritePersistentStateID(c.batch, 0)
+	rawdb.WriteStateID(c.batch, root, 0)
+	rawdb.DeleteTrieJournal(c.batch)
+	if err := c.batch.Write(); err != nil {
+		return err
+	}
+	c.batch.Reset()
+
+	if err := verifyPathState(c.db, root); err != nil {
+		// Revert to the hash-based scheme and start the conversion over
+		rawdb.DeleteAccountTrieNode(c.batch, nil)
+		rawdb.DeleteStateConversion(c.batch)
+		if werr := c.batch.Write(); werr != nil {
+			return werr
+		}
+		return fmt.Errorf("converted state verification failed: %v", err)
+	}
+	// Persist the layer journal of the single disk layer
+	pdb := triedb.NewDatabase(c.db, &triedb.Config{PathDB: pathdb.Defaults})
+	if err := pdb.Journal(root); err != nil {
+		pdb.Close()
+		return err
+	}
+	if err := pdb.Close(); err != nil {
+		return err
+	}
+	c.progress.Phase = conversionCleaning
+	return c.checkpoint()
+}
+
+// verifyPathState iterates the entire state through the path-based database,
+// which checks each trie node against its hash and thus against the state root.
+func verifyPathState(db ethdb.Database, root common.Hash) error {
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		accounts uint64
+		slots    uint64
+	)
+	tdb := triedb.NewDatabase(db, &triedb.Config{PathDB: pathdb.ReadOnly})
+	defer tdb.Close()
+
+	tr, err := trie.New(trie.StateTrieID(root), tdb)
+	if err != nil {
+		return err
+	}
+	it, err := tr.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	for it.Next(true) {
+		if !it.Leaf() {
+			continue
+		}
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+			return err
+		}
+		hash := common.BytesToHash(it.LeafKey())
+		if account.Root != types.EmptyRootHash {
+			storage, err := trie.New(trie.StorageTrieID(root, hash, account.Root), tdb)
+			if err != nil {
+				return err
+			}
+			storageIt, err := storage.NodeIterator(nil)
+			if err != nil {
+				return err
+			}
+			for storageIt.Next(true) {
+				if storageIt.Leaf() {
+					slots++
+				}
+			}
+			if err := storageIt.Error(); err != nil {
+				return fmt.Errorf("storage of %x: %v", hash, err)
+			}
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != types.EmptyCodeHash && !rawdb.HasCodeWithPrefix(db, codeHash) {
+			return fmt.Errorf("missing code %x of account %x", codeHash, hash)
+		}
+		accounts++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying converted state", "at", hash, "accounts", accounts, "slots", slots)
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	log.Info("Verified converted state", "root", root, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// clean deletes all legacy trie nodes from the database and finishes the
+// conversion.
+func (c *stateConverter) clean() error {
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		count  int
+		size   common.StorageSize
+		iter   = c.db.NewIterator(nil, nil)
+	)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != common.HashLength || crypto.Keccak256Hash(iter.Value()) != common.BytesToHash(key) {
+			continue
+		}
+		count++
+		size += common.StorageSize(len(key) + len(iter.Value()))
+		c.batch.Delete(key)
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Deleting legacy trie nodes", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		// Recreate the iterator after every batch commit in order
+		// to allow the underlying compactor to delete the entries.
+		if c.batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := c.batch.Write(); err != nil {
+				iter.Release()
+				return err
+			}
+			c.batch.Reset()
+
+			iter.Release()
+			iter = c.db.NewIterator(nil, key)
+		}
+	}
+	err := iter.Error()
+	iter.Release()
+	if err != nil {
+		return err
+	}
+	rawdb.DeleteStateConversion(c.batch)
+	if err := c.batch.Write(); err != nil {
+		return err
+	}
+	c.batch.Reset()
+	log.Info("Deleted legacy trie nodes", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	if count >= rangeCompactionThreshold {
+		cstart := time.Now()
+		if err := c.db.Compact(nil, nil); err != nil {
+			log.Error("Database compaction failed", "error", err)
+			return err
+		}
+		log.Info("Database compaction finished", "elapsed", common.PrettyDuration(time.Since(cstart)))
+	}
+	log.Info("State converted to path scheme", "root", c.progress.Root)
+	return nil
+}
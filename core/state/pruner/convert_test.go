@@ -0,0 +1,135 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+	"github.com/ethereum/go-ethereum/params/vars"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+func TestConvertToPathScheme(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		signer   = types.LatestSigner(params.TestChainConfig)
+		gspec    = &genesisT.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: genesisT.GenesisAlloc{
+				sender: {Balance: big.NewInt(vars.Ether)},
+				// sstore(number, number); sstore(0, number)
+				contract: {Balance: common.Big0, Code: common.FromHex("0x4343554360005500")},
+			},
+		}
+		engine = ethash.NewFaker()
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 60, func(i int, b *core.BlockGen) {
+		for j, to := range []common.Address{contract, common.BigToAddress(big.NewInt(int64(0x1000 + i)))} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), to, big.NewInt(int64(j)), 100000, b.BaseFee(), nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{
+		TrieCleanLimit: 16,
+		TrieDirtyLimit: 16,
+		SnapshotLimit:  16,
+		SnapshotWait:   true,
+		StateScheme:    rawdb.HashScheme,
+	}, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[:50]); err != nil {
+		t.Fatalf("Failed to import chain: %v", err)
+	}
+	chain.Stop()
+	root := blocks[49].Root()
+
+	// Fake a checkpoint halfway through the accounts without any nodes having
+	// been converted, which must be caught by the verification.
+	var accounts []common.Hash
+	tr, _ := trie.New(trie.StateTrieID(root), triedb.NewDatabase(db, triedb.HashDefaults))
+	it, _ := tr.NodeIterator(nil)
+	for it.Next(true) {
+		if it.Leaf() {
+			accounts = append(accounts, common.BytesToHash(it.LeafKey()))
+		}
+	}
+	blob, _ := rlp.EncodeToBytes(&conversionProgress{Root: root, Account: accounts[len(accounts)/2], Accounts: uint64(len(accounts) / 2)})
+	rawdb.WriteStateConversion(db, blob)
+	if err := ConvertToPathScheme(db); err == nil {
+		t.Fatal("Incomplete conversion passed verification")
+	}
+	if scheme := rawdb.ReadStateScheme(db); scheme != rawdb.HashScheme {
+		t.Fatalf("Failed conversion not reverted, scheme %q", scheme)
+	}
+	if len(rawdb.ReadStateConversion(db)) != 0 {
+		t.Fatal("Failed conversion progress not reset")
+	}
+	// Starting over converts the state entirely.
+	if err := ConvertToPathScheme(db); err != nil {
+		t.Fatalf("Failed to convert state: %v", err)
+	}
+	if scheme := rawdb.ReadStateScheme(db); scheme != rawdb.PathScheme {
+		t.Fatalf("State scheme mismatch: have %q, want %q", scheme, rawdb.PathScheme)
+	}
+	if rawdb.HasLegacyTrieNode(db, root) || len(rawdb.ReadStateConversion(db)) != 0 {
+		t.Fatal("Legacy state not cleaned up")
+	}
+	verifyState(t, triedb.NewDatabase(db, &triedb.Config{PathDB: pathdb.ReadOnly}), root)
+
+	// The chain continues on top of the converted state.
+	chain, err = core.NewBlockChain(db, &core.CacheConfig{
+		TrieCleanLimit: 16,
+		TrieDirtyLimit: 16,
+		SnapshotLimit:  16,
+		SnapshotWait:   true,
+		StateScheme:    rawdb.PathScheme,
+	}, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create chain on converted state: %v", err)
+	}
+	defer chain.Stop()
+
+	if head := chain.CurrentBlock().Number.Uint64(); head != 50 {
+		t.Fatalf("Head mismatch: have %d, want 50", head)
+	}
+	if _, err := chain.InsertChain(blocks[50:]); err != nil {
+		t.Fatalf("Failed to import chain on converted state: %v", err)
+	}
+}
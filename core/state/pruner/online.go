@@ -0,0 +1,486 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// errPruningAborted is returned if the online pruning is stopped before finishing.
+var errPruningAborted = errors.New("pruning aborted")
+
+// Phases of the online pruning.
+const (
+	PhaseMarking    = "marking"    // Live state is being marked from the snapshot
+	PhaseWaiting    = "waiting"    // Waiting for the states from before the marking to become unreachable
+	PhaseSweeping   = "sweeping"   // Unmarked trie nodes are being deleted
+	PhaseCompacting = "compacting" // Database is being compacted after the deletion
+	PhaseDone       = "done"       // Pruning finished successfully
+	PhaseFailed     = "failed"     // Pruning failed or was aborted
+)
+
+// ChainReader defines the access to the running chain required by the online pruner.
+type ChainReader interface {
+	// CurrentBlock retrieves the header of the current head block.
+	CurrentBlock() *types.Header
+
+	// Snapshots returns the snapshot tree of the chain.
+	Snapshots() *snapshot.Tree
+
+	// TrieDB returns the trie database the chain commits its states into.
+	TrieDB() *triedb.Database
+}
+
+// OnlineConfig includes the configurations for online pruning.
+type OnlineConfig struct {
+	BloomSize  uint64        // The Megabytes of memory allocated to bloom-filter
+	BatchDelay time.Duration // Pause between deletion batches to throttle the disk load
+	Retention  uint64        // Number of recent blocks the chain can still reorg to
+}
+
+// Progress reports the state of an online pruning run.
+type Progress struct {
+	Phase    string             `json:"phase"`
+	Started  time.Time          `json:"started"`
+	Block    uint64             `json:"block"`    // Head block when the marking started
+	Position common.Hash        `json:"position"` // Last account marked or key swept
+	Accounts uint64             `json:"accounts"` // Number of accounts marked
+	Restarts uint64             `json:"restarts"` // Number of times the marking moved to a newer state
+	Tracked  uint64             `json:"tracked"`  // Number of nodes written by the chain during the run
+	Deleted  uint64             `json:"deleted"`  // Number of trie nodes deleted
+	Size     common.StorageSize `json:"size"`     // Size of the deleted trie nodes
+	Error    string             `json:"error,omitempty"`
+}
+
+// OnlinePruner deletes the stale state of a hash-based database while the chain
+// keeps importing blocks. The workflow is:
+//
+//   - track the trie nodes flushed to disk by the chain from now on
+//   - mark the trie nodes of the head state by regenerating them from the
+//     snapshot; if the snapshot moves on in the meantime the marking continues
+//     from the same position in the then current head state
+//   - wait until no state from before the marking can be reorged to
+//   - delete the trie nodes which are neither marked nor tracked in throttled
+//     batches
+//
+// Any trie node reachable from a state after the marking started is either part
+// of the marked state or has been created later, thus tracked. Contract codes
+// stored under their bare hash by old nodes are indistinguishable from trie
+// nodes, they are marked along with the accounts referencing them. Codes stored
+// since are prefixed and left alone.
+type OnlinePruner struct {
+	config OnlineConfig
+	db     ethdb.Database
+	chain  ChainReader
+	bloom  *stateBloom
+
+	lock sync.Mutex // Serializes the node tracking with the deletions
+
+	progress     Progress
+	progressLock sync.RWMutex
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewOnlinePruner creates an online pruner for the given chain. The chain must
+// use the hash-based scheme and have the snapshot enabled.
+func NewOnlinePruner(db ethdb.Database, chain ChainReader, config OnlineConfig) (*OnlinePruner, error) {
+	if scheme := chain.TrieDB().Scheme(); scheme != rawdb.HashScheme {
+		return nil, fmt.Errorf("online pruning is not supported by %s scheme", scheme)
+	}
+	if chain.Snapshots() == nil {
+		return nil, errors.New("snapshot is not available")
+	}
+	// Sanitize the bloom filter size if it's too small.
+	if config.BloomSize < 256 {
+		log.Warn("Sanitizing bloomfilter size", "provided(MB)", config.BloomSize, "updated(MB)", 256)
+		config.BloomSize = 256
+	}
+	bloom, err := newStateBloomWithSize(config.BloomSize)
+	if err != nil {
+		return nil, err
+	}
+	return &OnlinePruner{
+		config: config,
+		db:     db,
+		chain:  chain,
+		bloom:  bloom,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start launches the pruning in the background.
+func (p *OnlinePruner) Start() {
+	p.progressLock.Lock()
+	p.progress.Phase, p.progress.Started = PhaseMarking, time.Now()
+	p.progressLock.Unlock()
+
+	go p.run()
+}
+
+// Stop aborts the pruning and waits for it to terminate.
+func (p *OnlinePruner) Stop() {
+	select {
+	case <-p.quit:
+	default:
+		close(p.quit)
+	}
+	<-p.done
+}
+
+// Done returns a channel which is closed when the pruning terminates.
+func (p *OnlinePruner) Done() <-chan struct{} {
+	return p.done
+}
+
+// Progress returns the current progress of the pruning.
+func (p *OnlinePruner) Progress() Progress {
+	p.progressLock.RLock()
+	defer p.progressLock.RUnlock()
+
+	return p.progress
+}
+
+// updateProgress applies the given modification on the progress.
+func (p *OnlinePruner) updateProgress(update func(progress *Progress)) {
+	p.progressLock.Lock()
+	defer p.progressLock.Unlock()
+
+	update(&p.progress)
+}
+
+// run executes the pruning phases one after the other.
+func (p *OnlinePruner) run() {
+	defer close(p.done)
+
+	start := time.Now()
+	if err := p.chain.TrieDB().SetNodeTracker(p.track); err != nil {
+		p.fail(err)
+		return
+	}
+	defer p.chain.TrieDB().SetNodeTracker(nil)
+
+	first, err := p.mark()
+	if err == nil {
+		p.updateProgress(func(progress *Progress) { progress.Phase = PhaseWaiting })
+		err = p.wait(first)
+	}
+	if err == nil {
+		p.updateProgress(func(progress *Progress) { progress.Phase = PhaseSweeping })
+		err = p.sweep()
+	}
+	if err != nil {
+		p.fail(err)
+		return
+	}
+	p.updateProgress(func(progress *Progress) { progress.Phase = PhaseDone })
+
+	progress := p.Progress()
+	log.Info("Online state pruning successful", "nodes", progress.Deleted, "pruned", progress.Size, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// fail records the error terminating the pruning.
+func (p *OnlinePruner) fail(err error) {
+	log.Error("Online state pruning failed", "err", err)
+	p.updateProgress(func(progress *Progress) {
+		progress.Phase, progress.Error = PhaseFailed, err.Error()
+	})
+}
+
+// track marks a trie node flushed to disk by the chain as live.
+func (p *OnlinePruner) track(hash common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.bloom.Put(hash.Bytes(), nil)
+
+	p.progressLock.Lock()
+	p.progress.Tracked++
+	p.progressLock.Unlock()
+}
+
+// markNode marks a regenerated trie node as live.
+func (p *OnlinePruner) markNode(path []byte, hash common.Hash, blob []byte) {
+	p.bloom.Put(hash.Bytes(), nil)
+}
+
+// mark marks the trie nodes of the head state as live, returning the number of
+// the block the marking started at.
+func (p *OnlinePruner) mark() (uint64, error) {
+	// The genesis state is retained the same way as by the offline pruning.
+	if err := extractGenesis(p.db, p.bloom); err != nil {
+		return 0, err
+	}
+	var (
+		head  = p.chain.CurrentBlock()
+		first = head.Number.Uint64()
+		start common.Hash
+	)
+	p.updateProgress(func(progress *Progress) { progress.Block = first })
+	log.Info("Marking live state for pruning", "number", first, "root", head.Root)
+
+	for {
+		resume, last, err := p.markFrom(head.Root, start)
+		if err == nil {
+			return first, nil
+		}
+		if !errors.Is(err, snapshot.ErrSnapshotStale) {
+			return 0, err
+		}
+		// The snapshot layers moved on, continue with the current head state.
+		// The nodes spanning the switch point can't be regenerated from either
+		// part, mark them along the paths of the accounts around it.
+		head = p.chain.CurrentBlock()
+		for _, key := range []common.Hash{last, resume} {
+			if err := p.markPath(head.Root, key); err != nil {
+				return 0, err
+			}
+		}
+		start = resume
+		p.updateProgress(func(progress *Progress) { progress.Restarts++ })
+		log.Debug("Continuing state marking on new head", "number", head.Number, "root", head.Root, "position", resume)
+	}
+}
+
+// markFrom regenerates the trie nodes of the given state from the snapshot,
+// starting at the given account. If the snapshot becomes stale midway, the
+// account to resume from and the last account marked are returned.
+func (p *OnlinePruner) markFrom(root common.Hash, start common.Hash) (common.Hash, common.Hash, error) {
+	snaptree := p.chain.Snapshots()
+	acctIt, err := snaptree.AccountIterator(root, start)
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	defer acctIt.Release()
+
+	var (
+		accTrie = trie.NewStackTrie(p.markNode)
+		logged  = time.Now()
+		last    = start
+	)
+	for acctIt.Next() {
+		select {
+		case <-p.quit:
+			return common.Hash{}, common.Hash{}, errPruningAborted
+		default:
+		}
+		hash := acctIt.Hash()
+		account, err := types.FullAccount(acctIt.Account())
+		if err != nil {
+			return common.Hash{}, common.Hash{}, err
+		}
+		if !bytes.Equal(account.CodeHash, types.EmptyCodeHash.Bytes()) {
+			p.bloom.Put(account.CodeHash, nil)
+		}
+		if account.Root != types.EmptyRootHash {
+			storageRoot, err := p.markStorage(root, hash)
+			if err != nil {
+				return hash, last, err
+			}
+			if storageRoot != account.Root {
+				return common.Hash{}, common.Hash{}, fmt.Errorf("invalid storage root of %x, want %x, have %x", hash, account.Root, storageRoot)
+			}
+		}
+		blob, err := types.FullAccountRLP(acctIt.Account())
+		if err != nil {
+			return common.Hash{}, common.Hash{}, err
+		}
+		accTrie.Update(hash.Bytes(), blob)
+		last = hash
+
+		p.updateProgress(func(progress *Progress) {
+			progress.Accounts++
+			progress.Position = hash
+		})
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Marking live state", "at", hash, "accounts", p.Progress().Accounts)
+			logged = time.Now()
+		}
+	}
+	if err := acctIt.Error(); err != nil {
+		return last, last, err
+	}
+	// The regenerated root only matches if the whole state was marked at once.
+	if got := accTrie.Hash(); start == (common.Hash{}) && got != root {
+		return common.Hash{}, common.Hash{}, fmt.Errorf("state root hash mismatch: got %x, want %x", got, root)
+	}
+	return common.Hash{}, common.Hash{}, nil
+}
+
+// markStorage regenerates the storage trie nodes of the given account from the
+// snapshot, returning the storage root.
+func (p *OnlinePruner) markStorage(root common.Hash, account common.Hash) (common.Hash, error) {
+	storageIt, err := p.chain.Snapshots().StorageIterator(root, account, common.Hash{})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer storageIt.Release()
+
+	storageTrie := trie.NewStackTrie(p.markNode)
+	for storageIt.Next() {
+		storageTrie.Update(storageIt.Hash().Bytes(), common.CopyBytes(storageIt.Slot()))
+	}
+	if err := storageIt.Error(); err != nil {
+		return common.Hash{}, err
+	}
+	return storageTrie.Hash(), nil
+}
+
+// markPath marks the account trie nodes along the path of the given key.
+func (p *OnlinePruner) markPath(root common.Hash, key common.Hash) error {
+	tr, err := trie.New(trie.StateTrieID(root), p.chain.TrieDB())
+	if err != nil {
+		return err
+	}
+	return tr.Prove(key.Bytes(), p.bloom)
+}
+
+// wait blocks until the chain progressed far enough that none of the states from
+// before the marking can be reorged to.
+func (p *OnlinePruner) wait(first uint64) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for p.chain.CurrentBlock().Number.Uint64() < first+p.config.Retention {
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return errPruningAborted
+		}
+	}
+	return nil
+}
+
+// sweep deletes all trie nodes from the database which are neither marked nor
+// tracked. The deletions are checked and written while the tracking is blocked,
+// so that a node flushed by the chain in the meantime can't be lost.
+func (p *OnlinePruner) sweep() error {
+	type candidate struct {
+		key  []byte
+		size common.StorageSize
+	}
+	var (
+		start      = time.Now()
+		logged     = time.Now()
+		batch      = p.db.NewBatch()
+		candidates []candidate
+		next       []byte
+		count      uint64
+		size       common.StorageSize
+	)
+	flush := func() error {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		for _, c := range candidates {
+			if p.bloom.Contain(c.key) {
+				continue
+			}
+			batch.Delete(c.key)
+			count++
+			size += c.size
+		}
+		candidates = candidates[:0]
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+		return nil
+	}
+	for {
+		// Collect a batch of trie nodes, recreating the iterator after every
+		// batch in order to allow the underlying compactor to delete the entries.
+		iter := p.db.NewIterator(nil, next)
+		next = nil
+		for iter.Next() {
+			key := iter.Key()
+			if len(key) != common.HashLength {
+				continue
+			}
+			if len(candidates)*common.HashLength >= ethdb.IdealBatchSize {
+				next = common.CopyBytes(key)
+				break
+			}
+			candidates = append(candidates, candidate{
+				key:  common.CopyBytes(key),
+				size: common.StorageSize(len(key) + len(iter.Value())),
+			})
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		p.updateProgress(func(progress *Progress) {
+			progress.Deleted, progress.Size = count, size
+			progress.Position = common.BytesToHash(next)
+		})
+		if next == nil {
+			break
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state data", "nodes", count, "size", size, "at", common.BytesToHash(next), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		select {
+		case <-time.After(p.config.BatchDelay):
+		case <-p.quit:
+			return errPruningAborted
+		}
+	}
+	log.Info("Pruned state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Start compactions, will remove the deleted data from the disk immediately.
+	// Note for small pruning, the compaction is skipped.
+	if count >= rangeCompactionThreshold {
+		p.updateProgress(func(progress *Progress) { progress.Phase = PhaseCompacting })
+
+		cstart := time.Now()
+		for b := 0x00; b <= 0xf0; b += 0x10 {
+			var (
+				start = []byte{byte(b)}
+				end   = []byte{byte(b + 0x10)}
+			)
+			if b == 0xf0 {
+				end = nil
+			}
+			log.Info("Compacting database", "range", fmt.Sprintf("%#x-%#x", start, end), "elapsed", common.PrettyDuration(time.Since(cstart)))
+			if err := p.db.Compact(start, end); err != nil {
+				return err
+			}
+		}
+		log.Info("Database compaction finished", "elapsed", common.PrettyDuration(time.Since(cstart)))
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+	"github.com/ethereum/go-ethereum/params/vars"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// verifyState iterates over all the trie nodes of the given state.
+func verifyState(t *testing.T, db *triedb.Database, root common.Hash) {
+	t.Helper()
+
+	tr, err := trie.NewStateTrie(trie.StateTrieID(root), db)
+	if err != nil {
+		t.Fatalf("Failed to open state %x: %v", root, err)
+	}
+	it, err := tr.NodeIterator(nil)
+	if err != nil {
+		t.Fatalf("Failed to iterate state %x: %v", root, err)
+	}
+	for it.Next(true) {
+		if !it.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &acc); err != nil {
+			t.Fatalf("Invalid account: %v", err)
+		}
+		if acc.Root == types.EmptyRootHash {
+			continue
+		}
+		id := trie.StorageTrieID(root, common.BytesToHash(it.LeafKey()), acc.Root)
+		storage, err := trie.NewStateTrie(id, db)
+		if err != nil {
+			t.Fatalf("Failed to open storage %x: %v", acc.Root, err)
+		}
+		storageIt, err := storage.NodeIterator(nil)
+		if err != nil {
+			t.Fatalf("Failed to iterate storage %x: %v", acc.Root, err)
+		}
+		for storageIt.Next(true) {
+		}
+		if err := storageIt.Error(); err != nil {
+			t.Fatalf("Storage %x of state %x is incomplete: %v", acc.Root, root, err)
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("State %x is incomplete: %v", root, err)
+	}
+}
+
+func TestOnlinePruning(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		legacy   = crypto.CreateAddress(sender, 0)
+		signer   = types.LatestSigner(params.TestChainConfig)
+		gspec    = &genesisT.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: genesisT.GenesisAlloc{
+				sender: {Balance: big.NewInt(vars.Ether)},
+				// sstore(number, number); sstore(0, number)
+				contract: {Balance: common.Big0, Code: common.FromHex("0x4343554360005500")},
+			},
+		}
+		// sstore(1, number), deployed in the first block
+		code     = common.FromHex("0x4360015500")
+		codeHash = crypto.Keccak256Hash(code)
+		engine   = ethash.NewFaker()
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 300, func(i int, b *core.BlockGen) {
+		if i == 0 {
+			tx, _ := types.SignTx(types.NewContractCreation(b.TxNonce(sender), common.Big0, 100000, b.BaseFee(), common.FromHex("0x6443600155006000526005601bf3")), signer, key)
+			b.AddTx(tx)
+		}
+		for j, to := range []common.Address{contract, legacy, common.BigToAddress(big.NewInt(int64(0x1000 + i)))} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), to, big.NewInt(int64(j)), 100000, b.BaseFee(), nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{
+		TrieCleanLimit:    16,
+		TrieDirtyDisabled: true,
+		SnapshotLimit:     16,
+		SnapshotWait:      true,
+		StateScheme:       rawdb.HashScheme,
+	}, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[:100]); err != nil {
+		t.Fatalf("Failed to import chain: %v", err)
+	}
+	// Move the deployed contract code to its bare hash key, as stored by old nodes.
+	if !rawdb.HasCodeWithPrefix(db, codeHash) {
+		t.Fatalf("Contract code %x not deployed", codeHash)
+	}
+	rawdb.DeleteCode(db, codeHash)
+	if err := db.Put(codeHash.Bytes(), code); err != nil {
+		t.Fatalf("Failed to store legacy code: %v", err)
+	}
+	pruner, err := NewOnlinePruner(db, chain, OnlineConfig{BloomSize: 256, Retention: 32})
+	if err != nil {
+		t.Fatalf("Failed to create pruner: %v", err)
+	}
+	pruner.Start()
+
+	// Keep importing blocks while the pruning is running.
+	var (
+		next    = 100
+		timeout = time.After(time.Minute)
+	)
+	for running := true; running; {
+		select {
+		case <-pruner.Done():
+			running = false
+		case <-timeout:
+			t.Fatalf("Pruning timed out in phase %s", pruner.Progress().Phase)
+		case <-time.After(10 * time.Millisecond):
+			if next < len(blocks)-10 {
+				if _, err := chain.InsertChain(blocks[next : next+1]); err != nil {
+					t.Fatalf("Failed to import block %d: %v", next+1, err)
+				}
+				next++
+			}
+		}
+	}
+	progress := pruner.Progress()
+	if progress.Phase != PhaseDone {
+		t.Fatalf("Pruning failed in phase %s: %v", progress.Phase, progress.Error)
+	}
+	if progress.Deleted == 0 || progress.Tracked == 0 {
+		t.Fatalf("Unexpected progress: %+v", progress)
+	}
+	if _, err := chain.InsertChain(blocks[next:]); err != nil {
+		t.Fatalf("Failed to import chain after pruning: %v", err)
+	}
+	// The states from the marked one on must be intact, the stale ones gone.
+	verifier := triedb.NewDatabase(db, triedb.HashDefaults)
+	for number := progress.Block; number <= chain.CurrentBlock().Number.Uint64(); number++ {
+		verifyState(t, verifier, chain.GetHeaderByNumber(number).Root)
+	}
+	if !bytes.Equal(rawdb.ReadCode(db, codeHash), code) {
+		t.Fatalf("Legacy contract code %x is pruned", codeHash)
+	}
+	if rawdb.HasLegacyTrieNode(db, blocks[10].Root()) {
+		t.Fatalf("Stale state root %x is not pruned", blocks[10].Root())
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// The state export format is a sequence of RLP items. The first one is the
+// exportHeader, identifying the format version and the block whose state is
+// contained, along with its total difficulty. It's followed by the exportFrames, each of which holds an encoded
+// exportChunk along with its checksum. The chunks are numbered sequentially and
+// the last one is flagged, so that missing or truncated chunks are detected.
+//
+// The chunks contain the flat accounts and storage slots in the snapshot order,
+// and each contract code once, before the account referencing it.
+
+const (
+	// stateExportMagic identifies a state export file.
+	stateExportMagic = "gethstate"
+
+	// stateExportVersion is the version of the state export format.
+	stateExportVersion = 1
+
+	// stateExportChunkSize is the approximate size of the exported chunks.
+	stateExportChunkSize = 4 * 1024 * 1024
+)
+
+var errStateExportCorrupt = errors.New("corrupt state export")
+
+// exportHeader is the first item of a state export.
+type exportHeader struct {
+	Magic   string
+	Version uint64
+	Block   *types.Block // Block the state belongs to
+	TD      *big.Int     // Total difficulty of the block
+}
+
+// exportFrame is the envelope of a chunk, checksummed with keccak256.
+type exportFrame struct {
+	Chunk    []byte
+	Checksum common.Hash
+}
+
+// exportChunk is a batch of exported state items.
+type exportChunk struct {
+	Index uint64
+	Last  bool
+	Items []exportItem
+	Codes [][]byte
+}
+
+// exportItem is either an account in the slim snapshot encoding, or a storage
+// slot of the account preceding it if the slot hash is set.
+type exportItem struct {
+	Account common.Hash
+	Slot    []byte
+	Data    []byte
+}
+
+// stateExporter accumulates the state items into chunks and writes them out.
+type stateExporter struct {
+	w     io.Writer
+	chunk exportChunk
+	size  int
+
+	accounts, slots, codes uint64
+}
+
+// flush writes out the current chunk.
+func (e *stateExporter) flush(last bool) error {
+	e.chunk.Last = last
+	blob, err := rlp.EncodeToBytes(&e.chunk)
+	if err != nil {
+		return err
+	}
+	if err := rlp.Encode(e.w, &exportFrame{Chunk: blob, Checksum: crypto.Keccak256Hash(blob)}); err != nil {
+		return err
+	}
+	e.chunk = exportChunk{Index: e.chunk.Index + 1}
+	e.size = 0
+	return nil
+}
+
+// grow accounts for an added item, flushing the chunk if it grew large.
+func (e *stateExporter) grow(size int) error {
+	e.size += size
+	if e.size < stateExportChunkSize {
+		return nil
+	}
+	return e.flush(false)
+}
+
+// ExportState writes the state of the given block from the snapshot into the
+// writer in the portable state export format. The contract codes are read from
+// the given database.
+func ExportState(w io.Writer, t *Tree, db ethdb.KeyValueReader, block *types.Block, td *big.Int) error {
+	root := block.Root()
+	acctIt, err := t.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer acctIt.Release()
+
+	if err := rlp.Encode(w, &exportHeader{Magic: stateExportMagic, Version: stateExportVersion, Block: block, TD: td}); err != nil {
+		return err
+	}
+	var (
+		e      = &stateExporter{w: w}
+		seen   = make(map[common.Hash]struct{})
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for acctIt.Next() {
+		hash := acctIt.Hash()
+		account, err := types.FullAccount(acctIt.Account())
+		if err != nil {
+			return err
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != types.EmptyCodeHash {
+			if _, ok := seen[codeHash]; !ok {
+				code := rawdb.ReadCode(db, codeHash)
+				if len(code) == 0 {
+					return fmt.Errorf("missing code %x of account %x", codeHash, hash)
+				}
+				seen[codeHash] = struct{}{}
+				e.chunk.Codes = append(e.chunk.Codes, code)
+				e.codes++
+				if err := e.grow(len(code)); err != nil {
+					return err
+				}
+			}
+		}
+		e.chunk.Items = append(e.chunk.Items, exportItem{Account: hash, Data: common.CopyBytes(acctIt.Account())})
+		e.accounts++
+		if err := e.grow(common.HashLength + len(acctIt.Account())); err != nil {
+			return err
+		}
+		if account.Root != types.EmptyRootHash {
+			storageIt, err := t.StorageIterator(root, hash, common.Hash{})
+			if err != nil {
+				return err
+			}
+			for storageIt.Next() {
+				e.chunk.Items = append(e.chunk.Items, exportItem{Account: hash, Slot: storageIt.Hash().Bytes(), Data: common.CopyBytes(storageIt.Slot())})
+				e.slots++
+				if err := e.grow(2*common.HashLength + len(storageIt.Slot())); err != nil {
+					storageIt.Release()
+					return err
+				}
+			}
+			err = storageIt.Error()
+			storageIt.Release()
+			if err != nil {
+				return err
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state", "at", hash, "accounts", e.accounts, "slots", e.slots, "codes", e.codes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := acctIt.Error(); err != nil {
+		return err
+	}
+	if err := e.flush(true); err != nil {
+		return err
+	}
+	log.Info("Exported state", "root", root, "accounts", e.accounts, "slots", e.slots, "codes", e.codes, "chunks", e.chunk.Index, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// ReadStateExportHeader reads the header of a state export, returning the block
+// the state belongs to and its total difficulty.
+func ReadStateExportHeader(s *rlp.Stream) (*types.Block, *big.Int, error) {
+	var header exportHeader
+	if err := s.Decode(&header); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid header: %v", errStateExportCorrupt, err)
+	}
+	if header.Magic != stateExportMagic {
+		return nil, nil, fmt.Errorf("%w: invalid magic %q", errStateExportCorrupt, header.Magic)
+	}
+	if header.Version != stateExportVersion {
+		return nil, nil, fmt.Errorf("unsupported state export version %d", header.Version)
+	}
+	if header.Block == nil || header.TD == nil {
+		return nil, nil, fmt.Errorf("%w: missing block", errStateExportCorrupt)
+	}
+	return header.Block, header.TD, nil
+}
+
+// ImportState imports the state export following the export header in the
+// stream into the database, as the snapshot of the state of the given block.
+// The state trie is regenerated in the given scheme from the imported snapshot
+// and its root verified against the block, which is then written as the head of
+// the chain. The database must not contain a snapshot or a path-based state yet.
+func ImportState(s *rlp.Stream, db ethdb.Database, scheme string, block *types.Block, td *big.Int) error {
+	if rawdb.ReadSnapshotRoot(db) != (common.Hash{}) {
+		return errors.New("database already contains a snapshot")
+	}
+	if blob, _ := rawdb.ReadAccountTrieNode(db, nil); len(blob) != 0 {
+		return errors.New("database already contains a path-based state")
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		batch  = db.NewBatch()
+
+		lastAccount  common.Hash
+		lastSlot     []byte
+		accounts     uint64
+		slots, codes uint64
+	)
+	for index := uint64(0); ; index++ {
+		var frame exportFrame
+		if err := s.Decode(&frame); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("%w: truncated after %d chunks", errStateExportCorrupt, index)
+			}
+			return fmt.Errorf("%w: chunk %d: %v", errStateExportCorrupt, index, err)
+		}
+		if crypto.Keccak256Hash(frame.Chunk) != frame.Checksum {
+			return fmt.Errorf("%w: chunk %d checksum mismatch", errStateExportCorrupt, index)
+		}
+		var chunk exportChunk
+		if err := rlp.DecodeBytes(frame.Chunk, &chunk); err != nil {
+			return fmt.Errorf("%w: chunk %d: %v", errStateExportCorrupt, index, err)
+		}
+		if chunk.Index != index {
+			return fmt.Errorf("%w: chunk index mismatch: have %d, want %d", errStateExportCorrupt, chunk.Index, index)
+		}
+		for _, code := range chunk.Codes {
+			rawdb.WriteCode(batch, crypto.Keccak256Hash(code), code)
+		}
+		codes += uint64(len(chunk.Codes))
+
+		// The items must be ordered for the trie regeneration
+		for _, item := range chunk.Items {
+			if len(item.Slot) == 0 {
+				if accounts > 0 && bytes.Compare(item.Account[:], lastAccount[:]) <= 0 {
+					return fmt.Errorf("%w: account %x out of order", errStateExportCorrupt, item.Account)
+				}
+				rawdb.WriteAccountSnapshot(batch, item.Account, item.Data)
+				lastAccount, lastSlot = item.Account, nil
+				accounts++
+				continue
+			}
+			if accounts == 0 || item.Account != lastAccount {
+				return fmt.Errorf("%w: slot %x of unexpected account %x", errStateExportCorrupt, item.Slot, item.Account)
+			}
+			if len(item.Slot) != common.HashLength || (lastSlot != nil && bytes.Compare(item.Slot, lastSlot) <= 0) {
+				return fmt.Errorf("%w: slot %x out of order", errStateExportCorrupt, item.Slot)
+			}
+			rawdb.WriteStorageSnapshot(batch, item.Account, common.BytesToHash(item.Slot), item.Data)
+			lastSlot = item.Slot
+			slots++
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state", "chunks", index+1, "accounts", accounts, "slots", slots, "codes", codes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		if chunk.Last {
+			break
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	batch.Reset()
+	log.Info("Imported state snapshot", "accounts", accounts, "slots", slots, "codes", codes, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Regenerate the tries from the imported snapshot and verify the root
+	root, err := generateImportedTrie(db, scheme)
+	if err != nil {
+		return err
+	}
+	if root != block.Root() {
+		return fmt.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	if scheme == rawdb.PathScheme {
+		rawdb.WritePersistentStateID(batch, 0)
+		rawdb.WriteStateID(batch, root, 0)
+	}
+	// Mark the snapshot as complete and make the block the state belongs to the
+	// head of the chain
+	rawdb.WriteSnapshotRoot(batch, root)
+	journalProgress(batch, nil, nil)
+
+	hash, number := block.Hash(), block.NumberU64()
+	rawdb.WriteTd(batch, hash, number, td)
+	rawdb.WriteBlock(batch, block)
+	rawdb.WriteCanonicalHash(batch, hash, number)
+	rawdb.WriteHeadHeaderHash(batch, hash)
+	rawdb.WriteHeadBlockHash(batch, hash)
+	rawdb.WriteHeadFastBlockHash(batch, hash)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Imported state", "number", number, "hash", hash, "root", root, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// generateImportedTrie regenerates the whole state trie from the flat snapshot
+// in the database, returning its root.
+func generateImportedTrie(db ethdb.Database, scheme string) (common.Hash, error) {
+	dl := &diskLayer{
+		diskdb: db,
+		triedb: triedb.NewDatabase(db, triedb.HashDefaults),
+		cache:  fastcache.New(16 * 1024 * 1024),
+	}
+	defer dl.Release()
+
+	acctIt := dl.AccountIterator(common.Hash{})
+	defer acctIt.Release()
+
+	return generateTrieRoot(db, scheme, acctIt, common.Hash{}, stackTrieGenerate, func(dst ethdb.KeyValueWriter, accountHash, codeHash common.Hash, stat *generateStats) (common.Hash, error) {
+		storageIt, _ := dl.StorageIterator(accountHash, common.Hash{})
+		defer storageIt.Release()
+
+		return generateTrieRoot(dst, scheme, storageIt, accountHash, stackTrieGenerate, nil, stat, false)
+	}, newGenerateStats(), true)
+}
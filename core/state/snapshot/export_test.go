@@ -0,0 +1,106 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+// Tests that corrupted, truncated and mismatching state exports are rejected.
+// The import of valid exports is tested along with the chain opened on them.
+func TestStateExportCorrupt(t *testing.T) {
+	var (
+		helper = newHelper(rawdb.HashScheme)
+		code   = []byte{0x60, 0x00, 0x60, 0x00, 0x55}
+		keys   []string
+		vals   []string
+	)
+	// One of the storages is large enough to span multiple chunks
+	for i := 0; i < 70000; i++ {
+		keys = append(keys, fmt.Sprintf("key-%d", i))
+		vals = append(vals, fmt.Sprintf("val-%d", i))
+	}
+	rawdb.WriteCode(helper.diskdb, crypto.Keccak256Hash(code), code)
+	for i, acc := range []string{"acc-1", "acc-2", "acc-3"} {
+		account := &types.StateAccount{Balance: uint256.NewInt(uint64(i)), Root: types.EmptyRootHash, CodeHash: types.EmptyCodeHash.Bytes()}
+		if i != 1 {
+			n := 3
+			if i == 2 {
+				n = len(keys)
+			}
+			account.Root = helper.makeStorageTrie(hashData([]byte(acc)), keys[:n], vals[:n], true)
+			account.CodeHash = crypto.Keccak256(code)
+			helper.addSnapStorage(acc, keys[:n], vals[:n])
+		}
+		helper.addAccount(acc, account)
+	}
+	root, snap := helper.CommitAndGenerate()
+	select {
+	case <-snap.genPending:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Snapshot generation failed")
+	}
+	stop := make(chan *generatorStats)
+	snap.genAbort <- stop
+	<-stop
+
+	var (
+		tree  = &Tree{layers: map[common.Hash]snapshot{root: snap}}
+		block = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Root: root, Difficulty: big.NewInt(1)})
+		buf   bytes.Buffer
+	)
+	if err := ExportState(&buf, tree, helper.diskdb, block, big.NewInt(2)); err != nil {
+		t.Fatalf("Failed to export state: %v", err)
+	}
+	export := buf.Bytes()
+
+	importState := func(blob []byte, block *types.Block) error {
+		s := rlp.NewStream(bytes.NewReader(blob), 0)
+		if _, _, err := ReadStateExportHeader(s); err != nil {
+			return err
+		}
+		return ImportState(s, rawdb.NewMemoryDatabase(), rawdb.HashScheme, block, big.NewInt(2))
+	}
+	if err := importState(export, block); err != nil {
+		t.Fatalf("Failed to import state: %v", err)
+	}
+	corrupt := common.CopyBytes(export)
+	corrupt[len(corrupt)/2] ^= 0xff
+	if err := importState(corrupt, block); !errors.Is(err, errStateExportCorrupt) {
+		t.Fatalf("Corrupt export not detected: %v", err)
+	}
+	if err := importState(export[:len(export)-100], block); !errors.Is(err, errStateExportCorrupt) {
+		t.Fatalf("Truncated export not detected: %v", err)
+	}
+	wrong := types.CopyHeader(block.Header())
+	wrong.Root = common.Hash{0x01}
+	if err := importState(export, types.NewBlockWithHeader(wrong)); err == nil {
+		t.Fatal("State root mismatch not detected")
+	}
+}
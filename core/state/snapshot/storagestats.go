@@ -0,0 +1,505 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// errStorageStatsAborted is returned if the storage statistics collection was
+// interrupted.
+var errStorageStatsAborted = errors.New("storage statistics collection aborted")
+
+// StorageStat is the storage footprint of a single account. The size of a slot
+// is its hashed key plus its RLP encoded value, as stored in the snapshot.
+type StorageStat struct {
+	Account    common.Hash     `json:"account"`           // Hash of the account address
+	Address    *common.Address `json:"address,omitempty"` // Account address, if its preimage is known
+	Slots      uint64          `json:"slots"`             // Number of storage slots
+	Bytes      uint64          `json:"bytes"`             // Total size of the storage slots
+	SlotsDelta int64           `json:"slotsDelta"`        // Change of the slot count since the base state
+	BytesDelta int64           `json:"bytesDelta"`        // Change of the storage size since the base state
+}
+
+// StorageReport contains the ranked storage statistics of a state, optionally
+// compared against an older base state.
+type StorageReport struct {
+	Root       common.Hash    `json:"root"`
+	Base       *common.Hash   `json:"base,omitempty"`
+	Contracts  uint64         `json:"contracts"` // Number of accounts with storage
+	Slots      uint64         `json:"slots"`
+	Bytes      uint64         `json:"bytes"`
+	SlotsDelta int64          `json:"slotsDelta"`
+	BytesDelta int64          `json:"bytesDelta"`
+	BySlots    []*StorageStat `json:"bySlots"`            // Accounts with the most slots
+	ByBytes    []*StorageStat `json:"byBytes"`            // Accounts with the largest storage
+	ByGrowth   []*StorageStat `json:"byGrowth,omitempty"` // Accounts with the largest storage growth
+}
+
+// StorageStats collects the storage footprint of all the accounts in a state,
+// ranking the heaviest and fastest growing ones. The collection can be resumed
+// on a newer state if the traversed one becomes unavailable, in which case the
+// report is a composite of the states traversed.
+type StorageStats struct {
+	hasBase   bool
+	contracts uint64
+	slots     uint64
+	bytes     uint64
+	baseSlots uint64
+	baseBytes uint64
+	bySlots   *statRanking
+	byBytes   *statRanking
+	byGrowth  *statRanking
+
+	root     common.Hash // Last state traversed
+	base     common.Hash // Last base state traversed
+	started  bool        // Whether any account has been traversed yet
+	position common.Hash // Last account traversed
+	accounts uint64      // Number of accounts traversed
+	lock     sync.Mutex
+}
+
+// NewStorageStats creates a storage statistics collector keeping the given
+// number of accounts in each ranking.
+func NewStorageStats(top int) *StorageStats {
+	return &StorageStats{
+		bySlots: newStatRanking(top, func(a, b *StorageStat) bool {
+			if a.Slots != b.Slots {
+				return a.Slots < b.Slots
+			}
+			return bytes.Compare(a.Account[:], b.Account[:]) > 0
+		}),
+		byBytes: newStatRanking(top, func(a, b *StorageStat) bool {
+			if a.Bytes != b.Bytes {
+				return a.Bytes < b.Bytes
+			}
+			return bytes.Compare(a.Account[:], b.Account[:]) > 0
+		}),
+		byGrowth: newStatRanking(top, func(a, b *StorageStat) bool {
+			if a.BytesDelta != b.BytesDelta {
+				return a.BytesDelta < b.BytesDelta
+			}
+			return bytes.Compare(a.Account[:], b.Account[:]) > 0
+		}),
+	}
+}
+
+// Progress returns the last account traversed and the number of accounts
+// traversed so far.
+func (s *StorageStats) Progress() (common.Hash, uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.position, s.accounts
+}
+
+// Collect traverses the accounts of the given state, continuing after the last
+// account traversed by a previous call. If a base state is given, the growth
+// of each account is computed against it, accounts only present in the base
+// state are included with their storage shrunk to zero.
+//
+// The states are read from the snapshot if available, falling back to the tries
+// otherwise. Any of the two may be nil.
+func (s *StorageStats) Collect(snaps *Tree, db *triedb.Database, root common.Hash, base *common.Hash, abort <-chan struct{}) error {
+	src, err := newStatsSource(snaps, db, root)
+	if err != nil {
+		return err
+	}
+	var baseSrc statsSource
+	if base != nil {
+		if baseSrc, err = newStatsSource(snaps, db, *base); err != nil {
+			return err
+		}
+	}
+	s.lock.Lock()
+	if s.started && s.hasBase != (base != nil) {
+		s.lock.Unlock()
+		return errors.New("base state inconsistent with the resumed collection")
+	}
+	s.root, s.hasBase = root, base != nil
+	if base != nil {
+		s.base = *base
+	}
+	var seek common.Hash
+	if s.started {
+		next := increaseKey(common.CopyBytes(s.position[:]))
+		if next == nil {
+			s.lock.Unlock()
+			return nil // Last possible account already traversed
+		}
+		seek = common.BytesToHash(next)
+	}
+	s.lock.Unlock()
+
+	it, err := src.accounts(seek)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	var (
+		baseIt   statsIterator
+		baseNext bool
+	)
+	if baseSrc != nil {
+		if baseIt, err = baseSrc.accounts(seek); err != nil {
+			return err
+		}
+		defer baseIt.Release()
+		baseNext = baseIt.Next()
+	}
+	next := it.Next()
+	for next || baseNext {
+		select {
+		case <-abort:
+			return errStorageStatsAborted
+		default:
+		}
+		// Pick the next account in order from the two states
+		var inRoot, inBase bool
+		switch {
+		case !baseNext:
+			inRoot = true
+		case !next:
+			inBase = true
+		default:
+			switch bytes.Compare(it.Hash().Bytes(), baseIt.Hash().Bytes()) {
+			case -1:
+				inRoot = true
+			case 1:
+				inBase = true
+			default:
+				inRoot, inBase = true, true
+			}
+		}
+		var (
+			stat                StorageStat
+			baseSlots, baseSize uint64
+		)
+		if inRoot {
+			stat.Account = it.Hash()
+			if stat.Slots, stat.Bytes, err = src.storage(stat.Account, it.Root()); err != nil {
+				return err
+			}
+		}
+		if inBase {
+			stat.Account = baseIt.Hash()
+			if inRoot && it.Root() == baseIt.Root() {
+				baseSlots, baseSize = stat.Slots, stat.Bytes
+			} else if baseSlots, baseSize, err = baseSrc.storage(stat.Account, baseIt.Root()); err != nil {
+				return err
+			}
+		}
+		stat.SlotsDelta = int64(stat.Slots) - int64(baseSlots)
+		stat.BytesDelta = int64(stat.Bytes) - int64(baseSize)
+
+		s.lock.Lock()
+		s.add(&stat, baseSlots, baseSize)
+		s.started, s.position = true, stat.Account
+		s.accounts++
+		s.lock.Unlock()
+
+		if inRoot {
+			next = it.Next()
+		}
+		if inBase {
+			baseNext = baseIt.Next()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if baseIt != nil {
+		return baseIt.Error()
+	}
+	return nil
+}
+
+// add accumulates the statistics of an account into the totals and rankings.
+func (s *StorageStats) add(stat *StorageStat, baseSlots, baseBytes uint64) {
+	if stat.Slots > 0 {
+		s.contracts++
+		s.slots += stat.Slots
+		s.bytes += stat.Bytes
+		s.bySlots.add(stat)
+		s.byBytes.add(stat)
+	}
+	s.baseSlots += baseSlots
+	s.baseBytes += baseBytes
+	if s.hasBase && stat.BytesDelta != 0 {
+		s.byGrowth.add(stat)
+	}
+}
+
+// Report assembles the statistics collected so far. If a database is given,
+// the addresses of the ranked accounts are resolved from the known preimages.
+func (s *StorageStats) Report(db ethdb.KeyValueReader) *StorageReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	report := &StorageReport{
+		Root:      s.root,
+		Contracts: s.contracts,
+		Slots:     s.slots,
+		Bytes:     s.bytes,
+		BySlots:   s.bySlots.sorted(),
+		ByBytes:   s.byBytes.sorted(),
+	}
+	if s.hasBase {
+		base := s.base
+		report.Base = &base
+		report.SlotsDelta = int64(s.slots) - int64(s.baseSlots)
+		report.BytesDelta = int64(s.bytes) - int64(s.baseBytes)
+		report.ByGrowth = s.byGrowth.sorted()
+	}
+	if db != nil {
+		for _, list := range [][]*StorageStat{report.BySlots, report.ByBytes, report.ByGrowth} {
+			for _, stat := range list {
+				if preimage := rawdb.ReadPreimage(db, stat.Account); len(preimage) == common.AddressLength {
+					addr := common.BytesToAddress(preimage)
+					stat.Address = &addr
+				}
+			}
+		}
+	}
+	return report
+}
+
+// statRanking is a bounded min-heap retaining the highest ranked accounts.
+type statRanking struct {
+	limit int
+	less  func(a, b *StorageStat) bool // Whether a ranks below b
+	items []*StorageStat
+}
+
+func newStatRanking(limit int, less func(a, b *StorageStat) bool) *statRanking {
+	return &statRanking{limit: limit, less: less}
+}
+
+func (r *statRanking) Len() int           { return len(r.items) }
+func (r *statRanking) Less(i, j int) bool { return r.less(r.items[i], r.items[j]) }
+func (r *statRanking) Swap(i, j int)      { r.items[i], r.items[j] = r.items[j], r.items[i] }
+func (r *statRanking) Push(x any)         { r.items = append(r.items, x.(*StorageStat)) }
+func (r *statRanking) Pop() any {
+	item := r.items[len(r.items)-1]
+	r.items = r.items[:len(r.items)-1]
+	return item
+}
+
+// add inserts the account into the ranking if it ranks high enough.
+func (r *statRanking) add(stat *StorageStat) {
+	if r.limit <= 0 {
+		return
+	}
+	if len(r.items) < r.limit {
+		heap.Push(r, stat)
+		return
+	}
+	if r.less(r.items[0], stat) {
+		r.items[0] = stat
+		heap.Fix(r, 0)
+	}
+}
+
+// sorted returns a copy of the ranked accounts, highest first.
+func (r *statRanking) sorted() []*StorageStat {
+	list := make([]*StorageStat, 0, len(r.items))
+	for _, stat := range r.items {
+		cpy := *stat
+		list = append(list, &cpy)
+	}
+	sort.Slice(list, func(i, j int) bool { return r.less(list[j], list[i]) })
+	return list
+}
+
+// statsIterator iterates over the accounts of a state, exposing their storage
+// roots.
+type statsIterator interface {
+	Next() bool
+	Error() error
+	Hash() common.Hash
+	Root() common.Hash
+	Release()
+}
+
+// statsSource is a state the storage statistics can be collected from.
+type statsSource interface {
+	accounts(seek common.Hash) (statsIterator, error)
+	storage(account common.Hash, root common.Hash) (uint64, uint64, error)
+}
+
+// newStatsSource opens the given state from the snapshot if it's available,
+// or from the tries otherwise.
+func newStatsSource(snaps *Tree, db *triedb.Database, root common.Hash) (statsSource, error) {
+	if snaps != nil && snaps.Snapshot(root) != nil {
+		return &snapStatsSource{snaps: snaps, root: root}, nil
+	}
+	if db != nil {
+		if _, err := trie.NewStateTrie(trie.StateTrieID(root), db); err == nil {
+			return &trieStatsSource{db: db, root: root}, nil
+		}
+	}
+	return nil, fmt.Errorf("state %x is not available", root)
+}
+
+// snapStatsSource collects the storage statistics from the snapshot.
+type snapStatsSource struct {
+	snaps *Tree
+	root  common.Hash
+}
+
+// snapStatsIterator wraps a snapshot account iterator, decoding the storage
+// roots of the slim accounts.
+type snapStatsIterator struct {
+	AccountIterator
+	root common.Hash
+	err  error
+}
+
+func (it *snapStatsIterator) Next() bool {
+	if it.err != nil || !it.AccountIterator.Next() {
+		return false
+	}
+	account, err := types.FullAccount(it.Account())
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.root = account.Root
+	return true
+}
+
+func (it *snapStatsIterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.AccountIterator.Error()
+}
+
+func (it *snapStatsIterator) Root() common.Hash { return it.root }
+
+func (src *snapStatsSource) accounts(seek common.Hash) (statsIterator, error) {
+	it, err := src.snaps.AccountIterator(src.root, seek)
+	if err != nil {
+		return nil, err
+	}
+	return &snapStatsIterator{AccountIterator: it}, nil
+}
+
+func (src *snapStatsSource) storage(account common.Hash, root common.Hash) (uint64, uint64, error) {
+	if root == types.EmptyRootHash {
+		return 0, 0, nil
+	}
+	it, err := src.snaps.StorageIterator(src.root, account, common.Hash{})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer it.Release()
+
+	var slots, size uint64
+	for it.Next() {
+		slots++
+		size += uint64(common.HashLength + len(it.Slot()))
+	}
+	return slots, size, it.Error()
+}
+
+// trieStatsSource collects the storage statistics from the tries.
+type trieStatsSource struct {
+	db   *triedb.Database
+	root common.Hash
+}
+
+// trieStatsIterator wraps a state trie iterator, decoding the storage roots
+// of the accounts.
+type trieStatsIterator struct {
+	it   *trie.Iterator
+	root common.Hash
+	err  error
+}
+
+func (it *trieStatsIterator) Next() bool {
+	if it.err != nil || !it.it.Next() {
+		return false
+	}
+	var account types.StateAccount
+	if err := rlp.DecodeBytes(it.it.Value, &account); err != nil {
+		it.err = err
+		return false
+	}
+	it.root = account.Root
+	return true
+}
+
+func (it *trieStatsIterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.it.Err
+}
+
+func (it *trieStatsIterator) Hash() common.Hash { return common.BytesToHash(it.it.Key) }
+func (it *trieStatsIterator) Root() common.Hash { return it.root }
+func (it *trieStatsIterator) Release()          {}
+
+func (src *trieStatsSource) accounts(seek common.Hash) (statsIterator, error) {
+	tr, err := trie.NewStateTrie(trie.StateTrieID(src.root), src.db)
+	if err != nil {
+		return nil, err
+	}
+	nodeIt, err := tr.NodeIterator(seek[:])
+	if err != nil {
+		return nil, err
+	}
+	return &trieStatsIterator{it: trie.NewIterator(nodeIt)}, nil
+}
+
+func (src *trieStatsSource) storage(account common.Hash, root common.Hash) (uint64, uint64, error) {
+	if root == types.EmptyRootHash {
+		return 0, 0, nil
+	}
+	tr, err := trie.NewStateTrie(trie.StorageTrieID(src.root, account, root), src.db)
+	if err != nil {
+		return 0, 0, err
+	}
+	nodeIt, err := tr.NodeIterator(nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	var (
+		it          = trie.NewIterator(nodeIt)
+		slots, size uint64
+	)
+	for it.Next() {
+		slots++
+		size += uint64(common.HashLength + len(it.Value))
+	}
+	return slots, size, it.Err
+}
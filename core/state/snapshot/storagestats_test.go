@@ -0,0 +1,125 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+func TestStorageStats(t *testing.T) {
+	helper := newHelper(rawdb.HashScheme)
+	makeSlots := func(n int) ([]string, []string) {
+		var keys, vals []string
+		for i := 0; i < n; i++ {
+			keys = append(keys, fmt.Sprintf("key-%d", i))
+			vals = append(vals, fmt.Sprintf("val-%d", i))
+		}
+		return keys, vals
+	}
+	for acc, n := range map[string]int{"acc-1": 3, "acc-2": 0, "acc-3": 10, "acc-4": 5} {
+		account := &types.StateAccount{Balance: uint256.NewInt(1), Root: types.EmptyRootHash, CodeHash: types.EmptyCodeHash.Bytes()}
+		if n > 0 {
+			keys, vals := makeSlots(n)
+			account.Root = helper.makeStorageTrie(hashData([]byte(acc)), keys, vals, true)
+			helper.addSnapStorage(acc, keys, vals)
+		}
+		helper.addAccount(acc, account)
+	}
+	root, snap := helper.CommitAndGenerate()
+	select {
+	case <-snap.genPending:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Snapshot generation failed")
+	}
+	stop := make(chan *generatorStats)
+	snap.genAbort <- stop
+	<-stop
+
+	// Create a newer state deleting acc-1, growing acc-3 and creating acc-5
+	tree := &Tree{layers: map[common.Hash]snapshot{root: snap}}
+	var (
+		next     = common.Hash{0x01}
+		accounts = map[common.Hash][]byte{
+			hashData([]byte("acc-3")): types.SlimAccountRLP(types.StateAccount{Balance: uint256.NewInt(1), Root: common.Hash{0x03}, CodeHash: types.EmptyCodeHash.Bytes()}),
+			hashData([]byte("acc-5")): types.SlimAccountRLP(types.StateAccount{Balance: uint256.NewInt(1), Root: common.Hash{0x05}, CodeHash: types.EmptyCodeHash.Bytes()}),
+		}
+		storage = map[common.Hash]map[common.Hash][]byte{
+			hashData([]byte("acc-3")): {},
+			hashData([]byte("acc-5")): {},
+		}
+	)
+	for i := 10; i < 15; i++ {
+		storage[hashData([]byte("acc-3"))][hashData([]byte(fmt.Sprintf("key-%d", i)))] = []byte(fmt.Sprintf("val-%d", i))
+	}
+	for i := 0; i < 2; i++ {
+		storage[hashData([]byte("acc-5"))][hashData([]byte(fmt.Sprintf("key-%d", i)))] = []byte(fmt.Sprintf("val-%d", i))
+	}
+	if err := tree.Update(next, root, map[common.Hash]struct{}{hashData([]byte("acc-1")): {}}, accounts, storage); err != nil {
+		t.Fatalf("Failed to create diff layer: %v", err)
+	}
+	// The snapshot and the tries must yield the same statistics
+	fromSnap, fromTrie := NewStorageStats(10), NewStorageStats(10)
+	if err := fromSnap.Collect(tree, nil, root, nil, nil); err != nil {
+		t.Fatalf("Failed to collect from snapshot: %v", err)
+	}
+	if err := fromTrie.Collect(nil, helper.triedb, root, nil, nil); err != nil {
+		t.Fatalf("Failed to collect from tries: %v", err)
+	}
+	if have, want := fromTrie.Report(nil), fromSnap.Report(nil); !reflect.DeepEqual(have, want) {
+		t.Fatalf("Statistics mismatch: have %+v, want %+v", have, want)
+	}
+	if report := fromSnap.Report(nil); report.Contracts != 3 || report.Slots != 18 {
+		t.Fatalf("Totals mismatch: have %d contracts, %d slots, want 3, 18", report.Contracts, report.Slots)
+	}
+	// Collect the growth of the newer state, limiting the rankings
+	stats := NewStorageStats(2)
+	if err := stats.Collect(tree, nil, next, &root, nil); err != nil {
+		t.Fatalf("Failed to collect growth: %v", err)
+	}
+	// Resuming a finished collection must not count anything twice
+	if err := stats.Collect(tree, nil, next, &root, nil); err != nil {
+		t.Fatalf("Failed to resume collection: %v", err)
+	}
+	report := stats.Report(nil)
+	if report.Contracts != 3 || report.Slots != 22 || report.SlotsDelta != 4 {
+		t.Fatalf("Totals mismatch: have %d contracts, %d slots (%+d), want 3, 22 (+4)", report.Contracts, report.Slots, report.SlotsDelta)
+	}
+	ranked := func(list []*StorageStat) []common.Hash {
+		var hashes []common.Hash
+		for _, stat := range list {
+			hashes = append(hashes, stat.Account)
+		}
+		return hashes
+	}
+	if have, want := ranked(report.BySlots), []common.Hash{hashData([]byte("acc-3")), hashData([]byte("acc-4"))}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("Slot ranking mismatch: have %x, want %x", have, want)
+	}
+	if have, want := ranked(report.ByGrowth), []common.Hash{hashData([]byte("acc-3")), hashData([]byte("acc-5"))}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("Growth ranking mismatch: have %x, want %x", have, want)
+	}
+	if stat := report.ByGrowth[0]; stat.Slots != 15 || stat.SlotsDelta != 5 {
+		t.Fatalf("Growth mismatch: have %d slots (%+d), want 15 (+5)", stat.Slots, stat.SlotsDelta)
+	}
+}
@@ -194,7 +194,7 @@ func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
 		err   error
 		value common.Hash
 	)
-	if s.db.snap != nil {
+	if s.db.snap != nil && s.db.witness == nil {
 		start := time.Now()
 		enc, err = s.db.snap.Storage(s.addrHash, crypto.Keccak256Hash(key.Bytes()))
 		if metrics.EnabledExpensive {
@@ -209,7 +209,7 @@ func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
 		}
 	}
 	// If the snapshot is unavailable or reading from it fails, load from the database.
-	if s.db.snap == nil || err != nil {
+	if s.db.snap == nil || s.db.witness != nil || err != nil {
 		start := time.Now()
 		tr, err := s.getTrie()
 		if err != nil {
@@ -480,6 +480,9 @@ func (s *stateObject) Code() []byte {
 	if err != nil {
 		s.db.setError(fmt.Errorf("can't load code hash %x: %v", s.CodeHash(), err))
 	}
+	if s.db.witness != nil {
+		s.db.witness.AddCode(code)
+	}
 	s.code = code
 	return code
 }
@@ -494,6 +497,11 @@ func (s *stateObject) CodeSize() int {
 	if bytes.Equal(s.CodeHash(), types.EmptyCodeHash.Bytes()) {
 		return 0
 	}
+	// Stateless execution needs the full code to derive its size, load it
+	// into the witness.
+	if s.db.witness != nil {
+		return len(s.Code())
+	}
 	size, err := s.db.db.ContractCodeSize(s.address, common.BytesToHash(s.CodeHash()))
 	if err != nil {
 		s.db.setError(fmt.Errorf("can't load code size %x: %v", s.CodeHash(), err))
@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
@@ -69,6 +70,10 @@ type StateDB struct {
 	// It will be updated when the Commit is called.
 	originalRoot common.Hash
 
+	// witness collects the trie nodes and codes accessed during execution,
+	// nil if witness building is not enabled.
+	witness *stateless.Witness
+
 	// These maps hold the state changes (including the corresponding
 	// original value) that occurred in this **block**.
 	accounts       map[common.Hash][]byte                    // The mutated accounts in 'slim RLP' encoding
@@ -179,11 +184,35 @@ func (s *StateDB) StartPrefetcher(namespace string) {
 		s.prefetcher.close()
 		s.prefetcher = nil
 	}
-	if s.snap != nil {
+	// Prefetched tries replace the ones used for reading, dropping the accessed
+	// nodes, so don't prefetch if a witness is being built.
+	if s.snap != nil && s.witness == nil {
 		s.prefetcher = newTriePrefetcher(s.db, s.originalRoot, namespace)
 	}
 }
 
+// SetWitness enables collecting all the trie nodes and codes accessed during
+// execution into the given witness. Snapshots are bypassed while building
+// the witness, so that all state reads are resolved through the tries. It
+// must be called before the state is accessed.
+func (s *StateDB) SetWitness(witness *stateless.Witness) {
+	s.witness = witness
+}
+
+// Witness retrieves the witness being built, nil if witness building is not
+// enabled.
+func (s *StateDB) Witness() *stateless.Witness {
+	return s.witness
+}
+
+// witnessTrie adds the trie nodes accessed through the given trie to the
+// witness, if one is being built.
+func (s *StateDB) witnessTrie(tr Trie) {
+	if s.witness != nil && tr != nil {
+		s.witness.AddState(tr.Witness())
+	}
+}
+
 // StopPrefetcher terminates a running prefetcher and reports any leftover stats
 // from the gathered metrics.
 func (s *StateDB) StopPrefetcher() {
@@ -563,7 +592,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	}
 	// If no live objects are available, attempt to use snapshots
 	var data *types.StateAccount
-	if s.snap != nil {
+	if s.snap != nil && s.witness == nil {
 		start := time.Now()
 		acc, err := s.snap.Account(crypto.HashData(s.hasher, addr.Bytes()))
 		if metrics.EnabledExpensive {
@@ -627,6 +656,11 @@ func (s *StateDB) getOrNewStateObject(addr common.Address) *stateObject {
 func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = s.getDeletedStateObject(addr) // Note, prev might have been deleted, we need that!
 	newobj = newObject(s, addr, nil)
+	if prev != nil {
+		// The storage trie of the overwritten account is dropped, retain
+		// the nodes accessed through it.
+		s.witnessTrie(prev.trie)
+	}
 	if prev == nil {
 		s.journal.append(createObjectChange{account: &addr})
 	} else {
@@ -924,6 +958,12 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	if len(s.stateObjectsPending) > 0 {
 		s.stateObjectsPending = make(map[common.Address]struct{})
 	}
+	if s.witness != nil {
+		s.witnessTrie(s.trie)
+		for _, obj := range s.stateObjects {
+			s.witnessTrie(obj.trie)
+		}
+	}
 	// Track the amount of time wasted on hashing the account trie
 	if metrics.EnabledExpensive {
 		defer func(start time.Time) { s.AccountHashes += time.Since(start) }(time.Now())
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"math/rand"
 	"reflect"
 	"strings"
@@ -1189,3 +1190,70 @@ func TestDeleteStorage(t *testing.T) {
 		t.Fatalf("difference found:\nfast: %v\nslow: %v\n", fastRes, slowRes)
 	}
 }
+
+// Tests that historic states of the path-based scheme, whose trie nodes are
+// gone, are served from the state histories.
+func TestHistoricState(t *testing.T) {
+	var (
+		disk, _ = rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+		tdb     = triedb.NewDatabase(disk, &triedb.Config{PathDB: pathdb.Defaults})
+		db      = NewDatabaseWithNodeDB(disk, tdb)
+		addr    = common.Address{0x01}
+		other   = common.Address{0x02}
+		root    = types.EmptyRootHash
+		roots   []common.Hash
+	)
+	defer tdb.Close()
+
+	for i := 0; i < 8; i++ {
+		state, _ := New(root, db, nil)
+		state.SetBalance(addr, uint256.NewInt(uint64(i+1)))
+		state.SetState(addr, common.Hash{}, common.BigToHash(big.NewInt(int64(i+1))))
+		state.SetState(addr, common.Hash{byte(i + 1)}, common.Hash{0xff})
+		if i == 3 {
+			state.SetBalance(other, uint256.NewInt(100))
+		}
+		if i == 5 {
+			state.SelfDestruct(other)
+		}
+		root, _ = state.Commit(uint64(i), false)
+		// Flatten all layers, only the latest state is kept as trie nodes.
+		if err := tdb.Commit(root, false); err != nil {
+			t.Fatalf("failed to commit state %d: %v", i, err)
+		}
+		roots = append(roots, root)
+	}
+	for i, root := range roots {
+		state, err := New(root, db, nil)
+		if err != nil {
+			t.Fatalf("state %d not available: %v", i, err)
+		}
+		if historic := IsHistoricTrie(state.trie); historic != (i != len(roots)-1) {
+			t.Errorf("state %d historic mismatch: have %v", i, historic)
+		}
+		if have := state.GetBalance(addr); have.Uint64() != uint64(i+1) {
+			t.Errorf("state %d balance mismatch: have %v, want %d", i, have, i+1)
+		}
+		if have := state.GetState(addr, common.Hash{}); have.Big().Int64() != int64(i+1) {
+			t.Errorf("state %d slot mismatch: have %x, want %d", i, have, i+1)
+		}
+		for j := 1; j <= len(roots); j++ {
+			want := common.Hash{}
+			if j <= i+1 {
+				want = common.Hash{0xff}
+			}
+			if have := state.GetState(addr, common.Hash{byte(j)}); have != want {
+				t.Errorf("state %d slot %d mismatch: have %x, want %x", i, j, have, want)
+			}
+		}
+		if exist := state.Exist(other); exist != (i >= 3 && i < 5) {
+			t.Errorf("state %d existence mismatch: have %v", i, exist)
+		}
+		// Mutations are retained in memory for executing on top.
+		state.SetState(addr, common.Hash{}, common.Hash{0x01})
+		state.IntermediateRoot(false)
+		if have := state.GetState(addr, common.Hash{}); have != (common.Hash{0x01}) {
+			t.Errorf("state %d mutation lost: have %x", i, have)
+		}
+	}
+}
@@ -30,15 +30,13 @@ import (
 // of an arbitrary state with the goal of prefetching potentially useful state
 // data from disk before the main block processor start executing.
 type statePrefetcher struct {
-	config ctypes.ChainConfigurator // Chain configuration options
-	bc     *BlockChain              // Canonical block chain
-	engine consensus.Engine         // Consensus engine used for block rewards
+	bc     *BlockChain      // Canonical block chain
+	engine consensus.Engine // Consensus engine used for block rewards
 }
 
 // newStatePrefetcher initialises a new statePrefetcher.
-func newStatePrefetcher(config ctypes.ChainConfigurator, bc *BlockChain, engine consensus.Engine) *statePrefetcher {
+func newStatePrefetcher(bc *BlockChain, engine consensus.Engine) *statePrefetcher {
 	return &statePrefetcher{
-		config: config,
 		bc:     bc,
 		engine: engine,
 	}
@@ -49,14 +47,15 @@ func newStatePrefetcher(config ctypes.ChainConfigurator, bc *BlockChain, engine
 // only goal is to pre-cache transaction signatures and state trie nodes.
 func (p *statePrefetcher) Prefetch(block *types.Block, statedb *state.StateDB, cfg vm.Config, interrupt *atomic.Bool) {
 	var (
+		config       = p.bc.Config()
 		header       = block.Header()
 		gaspool      = new(GasPool).AddGas(block.GasLimit())
 		blockContext = NewEVMBlockContext(header, p.bc, nil)
-		evm          = vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
-		signer       = types.MakeSigner(p.config, header.Number, header.Time)
+		evm          = vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, cfg)
+		signer       = types.MakeSigner(config, header.Number, header.Time)
 	)
 	// Iterate over and process the individual transactions
-	byzantium := p.config.IsEnabled(p.config.GetEIP161abcTransition, block.Number())
+	byzantium := config.IsEnabled(config.GetEIP161abcTransition, block.Number())
 	for i, tx := range block.Transactions() {
 		// If block precaching was interrupted, abort
 		if interrupt != nil && interrupt.Load() {
@@ -68,7 +67,7 @@ func (p *statePrefetcher) Prefetch(block *types.Block, statedb *state.StateDB, c
 			return // Also invalid block, bail out
 		}
 		statedb.SetTxContext(tx.Hash(), i)
-		if err := precacheTransaction(msg, p.config, gaspool, statedb, header, evm); err != nil {
+		if err := precacheTransaction(msg, config, gaspool, statedb, header, evm); err != nil {
 			return // Ugh, something went horribly wrong, bail out
 		}
 		// If we're pre-byzantium, pre-load trie nodes for the intermediate root
@@ -36,15 +36,20 @@ import (
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
-	config ctypes.ChainConfigurator // Chain configuration options
-	bc     *BlockChain              // Canonical block chain
-	engine consensus.Engine         // Consensus engine used for block rewards
+	bc     processorChain   // Canonical block chain, also providing the chain configuration
+	engine consensus.Engine // Consensus engine used for block rewards
+}
+
+// processorChain is the chain access needed by the state processor, satisfied
+// by both the canonical chain and the ancestors carried in a block witness.
+type processorChain interface {
+	ChainContext
+	consensus.ChainHeaderReader
 }
 
 // NewStateProcessor initialises a new StateProcessor.
-func NewStateProcessor(config ctypes.ChainConfigurator, bc *BlockChain, engine consensus.Engine) *StateProcessor {
+func NewStateProcessor(bc *BlockChain, engine consensus.Engine) *StateProcessor {
 	return &StateProcessor{
-		config: config,
 		bc:     bc,
 		engine: engine,
 	}
@@ -66,18 +71,24 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		blockNumber = block.Number()
 		allLogs     []*types.Log
 		gp          = new(GasPool).AddGas(block.GasLimit())
+		config      = p.bc.Config()
 	)
 	// Mutate the block and state according to any hard-fork specs
-	isDAOSupport := p.config.IsEnabled(p.config.GetEthashEIP779Transition, block.Number())
+	isDAOSupport := config.IsEnabled(config.GetEthashEIP779Transition, block.Number())
 	if isDAOSupport {
-		if daoNumber := p.config.GetEthashEIP779Transition(); daoNumber != nil && *daoNumber == block.NumberU64() {
+		if daoNumber := config.GetEthashEIP779Transition(); daoNumber != nil && *daoNumber == block.NumberU64() {
 			mutations.ApplyDAOHardFork(statedb)
 		}
 	}
+	// If a witness is being built, record the ancestors accessed by BLOCKHASH
+	var chain ChainContext = p.bc
+	if witness := statedb.Witness(); witness != nil {
+		chain = &witnessChain{ChainContext: p.bc, witness: witness}
+	}
 	var (
-		context = NewEVMBlockContext(header, p.bc, nil)
-		vmenv   = vm.NewEVM(context, vm.TxContext{}, statedb, p.config, cfg)
-		signer  = types.MakeSigner(p.config, header.Number, header.Time)
+		context = NewEVMBlockContext(header, chain, nil)
+		vmenv   = vm.NewEVM(context, vm.TxContext{}, statedb, config, cfg)
+		signer  = types.MakeSigner(config, header.Number, header.Time)
 	)
 	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
 		ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
@@ -89,7 +100,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
 		statedb.SetTxContext(tx.Hash(), i)
-		receipt, err := applyTransaction(msg, p.config, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv)
+		receipt, err := applyTransaction(msg, config, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv)
 		if err != nil {
 			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
@@ -99,7 +110,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	// Fail if Shanghai not enabled and len(withdrawals) is non-zero.
 	withdrawals := block.Withdrawals()
 	blockTime := block.Time()
-	if len(withdrawals) > 0 && !(p.config.IsEnabledByTime(p.config.GetEIP4895TransitionTime, &blockTime) || p.config.IsEnabled(p.config.GetEIP4895Transition, blockNumber)) {
+	if len(withdrawals) > 0 && !(config.IsEnabledByTime(config.GetEIP4895TransitionTime, &blockTime) || config.IsEnabled(config.GetEIP4895Transition, blockNumber)) {
 		return nil, nil, 0, fmt.Errorf("withdrawals before shanghai")
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
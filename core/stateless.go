@@ -0,0 +1,159 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// ExecutionWitness re-executes a block on top of its parent state and returns
+// the witness required to execute it statelessly. The parent state must be
+// available.
+func (bc *BlockChain) ExecutionWitness(block *types.Block) (*stateless.Witness, error) {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	// Snapshots are deliberately not used, all reads must hit the tries
+	statedb, err := state.New(parent.Root, bc.stateCache, nil)
+	if err != nil {
+		return nil, err
+	}
+	witness := stateless.NewWitness(parent)
+	statedb.SetWitness(witness)
+
+	receipts, _, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
+		return nil, err
+	}
+	return witness, nil
+}
+
+// ExecuteStateless runs a stateless execution of a block based on a witness,
+// verifies everything it can locally and returns the state root and receipt
+// root, which the caller needs to check against the block header.
+func ExecuteStateless(config ctypes.ChainConfigurator, engine consensus.Engine, vmconfig vm.Config, block *types.Block, witness *stateless.Witness) (common.Hash, common.Hash, error) {
+	if parent := witness.Headers[0]; parent.Hash() != block.ParentHash() || parent.Number.Uint64()+1 != block.NumberU64() {
+		return common.Hash{}, common.Hash{}, fmt.Errorf("witness parent mismatch: have %d [%x], want %d [%x]", parent.Number, parent.Hash(), block.NumberU64()-1, block.ParentHash())
+	}
+	// Create and populate the state database to serve as the stateless backend
+	db := state.NewDatabaseWithConfig(witness.MakeHashDB(), triedb.HashDefaults)
+	statedb, err := state.New(witness.Root(), db, nil)
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	processor := &StateProcessor{
+		bc:     &statelessChain{config: config, engine: engine, witness: witness},
+		engine: engine,
+	}
+	receipts, _, usedGas, err := processor.Process(block, statedb, vmconfig)
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	if err := statedb.Error(); err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	if block.GasUsed() != usedGas {
+		return common.Hash{}, common.Hash{}, fmt.Errorf("invalid gas used (remote: %d local: %d)", block.GasUsed(), usedGas)
+	}
+	if rbloom := types.CreateBloom(receipts); rbloom != block.Bloom() {
+		return common.Hash{}, common.Hash{}, fmt.Errorf("invalid bloom (remote: %x  local: %x)", block.Bloom(), rbloom)
+	}
+	receiptRoot := types.DeriveSha(receipts, trie.NewStackTrie(nil))
+	stateRoot := statedb.IntermediateRoot(config.IsEnabled(config.GetEIP161dTransition, block.Number()))
+
+	// Any trie node missing from the witness surfaces as a state error
+	if err := statedb.Error(); err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	return stateRoot, receiptRoot, nil
+}
+
+// witnessChain wraps a chain context, recording the ancestor headers accessed
+// during execution into the witness.
+type witnessChain struct {
+	ChainContext
+	witness *stateless.Witness
+}
+
+// GetHeader retrieves a header from the chain and records it in the witness.
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header := c.ChainContext.GetHeader(hash, number)
+	if header != nil {
+		c.witness.AddHeader(header)
+	}
+	return header
+}
+
+// statelessChain serves the ancestor headers of a stateless execution from
+// the block witness.
+type statelessChain struct {
+	config  ctypes.ChainConfigurator
+	engine  consensus.Engine
+	witness *stateless.Witness
+}
+
+// Config retrieves the chain configuration.
+func (c *statelessChain) Config() ctypes.ChainConfigurator { return c.config }
+
+// Engine retrieves the consensus engine.
+func (c *statelessChain) Engine() consensus.Engine { return c.engine }
+
+// CurrentHeader returns the parent of the executed block.
+func (c *statelessChain) CurrentHeader() *types.Header { return c.witness.Headers[0] }
+
+// GetHeader retrieves an ancestor header from the witness.
+func (c *statelessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c.witness.Header(hash, number)
+}
+
+// GetHeaderByNumber retrieves an ancestor header from the witness by number.
+func (c *statelessChain) GetHeaderByNumber(number uint64) *types.Header {
+	for _, header := range c.witness.Headers {
+		if header.Number.Uint64() == number {
+			return header
+		}
+	}
+	return nil
+}
+
+// GetHeaderByHash retrieves an ancestor header from the witness by hash.
+func (c *statelessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, header := range c.witness.Headers {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+
+// GetTd is not available in stateless mode.
+func (c *statelessChain) GetTd(hash common.Hash, number uint64) *big.Int { return nil }
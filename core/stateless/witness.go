@@ -0,0 +1,119 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package stateless implements the block witness, the minimal set of state
+// required to execute a block without access to the full state database.
+package stateless
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Witness encompasses the state required to apply a set of transactions and
+// derive a post state/receipt root.
+type Witness struct {
+	Headers []*types.Header     // Past headers in reverse order (0=parent, 1=parent's-parent, etc)
+	Codes   map[string]struct{} // Set of bytecodes ran or accessed
+	State   map[string]struct{} // Set of MPT state trie nodes (account and storage together)
+
+	lock sync.Mutex // Lock to allow concurrent state insertions
+}
+
+// NewWitness creates an empty witness ready for recording the execution of a
+// block on top of the given parent.
+func NewWitness(parent *types.Header) *Witness {
+	return &Witness{
+		Headers: []*types.Header{parent},
+		Codes:   make(map[string]struct{}),
+		State:   make(map[string]struct{}),
+	}
+}
+
+// AddHeader records an ancestor header accessed during execution (i.e. via
+// BLOCKHASH). Headers are expected to be added in descending order starting
+// from the grandparent, anything not extending the tracked segment is ignored.
+func (w *Witness) AddHeader(header *types.Header) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	tail := w.Headers[len(w.Headers)-1]
+	if header.Number.Uint64()+1 != tail.Number.Uint64() || header.Hash() != tail.ParentHash {
+		return
+	}
+	w.Headers = append(w.Headers, header)
+}
+
+// AddCode records a bytecode used during execution.
+func (w *Witness) AddCode(code []byte) {
+	if len(code) == 0 {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.Codes[string(code)] = struct{}{}
+}
+
+// AddState inserts a batch of MPT trie nodes into the witness.
+func (w *Witness) AddState(nodes map[string]struct{}) {
+	if len(nodes) == 0 {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for node := range nodes {
+		w.State[node] = struct{}{}
+	}
+}
+
+// Root returns the pre-state root the witness is built upon.
+func (w *Witness) Root() common.Hash {
+	return w.Headers[0].Root
+}
+
+// Header retrieves an ancestor header contained in the witness, nil if it's
+// not available.
+func (w *Witness) Header(hash common.Hash, number uint64) *types.Header {
+	for _, header := range w.Headers {
+		if header.Number.Uint64() == number && header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+
+// MakeHashDB imports the witness content into a fresh in-memory database
+// using the hash-based node scheme, ready to be used as the backing store of
+// a stateless block execution.
+func (w *Witness) MakeHashDB() ethdb.Database {
+	db := rawdb.NewMemoryDatabase()
+	for code := range w.Codes {
+		blob := []byte(code)
+		rawdb.WriteCode(db, crypto.Keccak256Hash(blob), blob)
+	}
+	for node := range w.State {
+		blob := []byte(node)
+		rawdb.WriteLegacyTrieNode(db, crypto.Keccak256Hash(blob), blob)
+	}
+	return db
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+	"github.com/ethereum/go-ethereum/params/vars"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that blocks executed statelessly from their witness produce the same
+// state and receipts as the regular processor.
+func TestStatelessExecution(t *testing.T) {
+	t.Run("Byzantium/hash", func(t *testing.T) { testStatelessExecution(t, params.TestChainConfig, rawdb.HashScheme) })
+	t.Run("Byzantium/path", func(t *testing.T) { testStatelessExecution(t, params.TestChainConfig, rawdb.PathScheme) })
+	t.Run("Classic/hash", func(t *testing.T) { testStatelessExecution(t, params.ClassicChainConfig, rawdb.HashScheme) })
+}
+
+func testStatelessExecution(t *testing.T, config ctypes.ChainConfigurator, scheme string) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		bomb     = common.HexToAddress("0xb0b0")
+		signer   = types.HomesteadSigner{}
+		gasPrice = big.NewInt(2 * vars.InitialBaseFee)
+
+		// sstore(number, blockhash(number-5))
+		// sstore(0, extcodesize(bomb))
+		// sstore(2, sload(1))
+		code  = append(append(common.FromHex("0x600543034043557f"), common.LeftPadBytes(bomb.Bytes(), 32)...), common.FromHex("0x3b6000556001546002555b00")...)
+		gspec = &genesisT.Genesis{
+			Config: config,
+			Alloc: genesisT.GenesisAlloc{
+				sender:   {Balance: big.NewInt(vars.Ether)},
+				contract: {Balance: common.Big0, Code: code, Storage: map[common.Hash]common.Hash{{0x01}: {0x01}}},
+				bomb:     {Balance: common.Big1, Code: common.FromHex("0x33ff")}, // selfdestruct(caller)
+			},
+		}
+		engine = ethash.NewFaker()
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 16, func(i int, b *BlockGen) {
+		for j, to := range []common.Address{contract, common.BigToAddress(big.NewInt(int64(0x1000 + i)))} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), to, big.NewInt(int64(j)), 100000, gasPrice, nil), signer, key)
+			b.AddTx(tx)
+		}
+		if i == 10 {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), bomb, common.Big0, 100000, gasPrice, nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), DefaultCacheConfigWithScheme(scheme), gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("Failed to import chain: %v", err)
+	}
+	for _, block := range blocks {
+		witness, err := chain.ExecutionWitness(block)
+		if err != nil {
+			t.Fatalf("Block %d: failed to build witness: %v", block.NumberU64(), err)
+		}
+		if n := block.NumberU64(); n >= 5 && len(witness.Headers) != 4 {
+			t.Fatalf("Block %d: witness headers mismatch: have %d, want 4", n, len(witness.Headers))
+		}
+		// Ship the witness through its encoding, then execute from it alone
+		blob, err := rlp.EncodeToBytes(witness)
+		if err != nil {
+			t.Fatalf("Block %d: failed to encode witness: %v", block.NumberU64(), err)
+		}
+		witness = new(stateless.Witness)
+		if err := rlp.DecodeBytes(blob, witness); err != nil {
+			t.Fatalf("Block %d: failed to decode witness: %v", block.NumberU64(), err)
+		}
+		stateRoot, receiptRoot, err := ExecuteStateless(config, engine, vm.Config{}, block, witness)
+		if err != nil {
+			t.Fatalf("Block %d: stateless execution failed: %v", block.NumberU64(), err)
+		}
+		if stateRoot != block.Root() {
+			t.Fatalf("Block %d: state root mismatch: have %x, want %x", block.NumberU64(), stateRoot, block.Root())
+		}
+		if receiptRoot != block.ReceiptHash() {
+			t.Fatalf("Block %d: receipt root mismatch: have %x, want %x", block.NumberU64(), receiptRoot, block.ReceiptHash())
+		}
+		// An incomplete witness must not be accepted
+		for node := range witness.State {
+			delete(witness.State, node)
+			break
+		}
+		if stateRoot, _, err := ExecuteStateless(config, engine, vm.Config{}, block, witness); err == nil && stateRoot == block.Root() {
+			t.Fatalf("Block %d: incomplete witness accepted", block.NumberU64())
+		}
+	}
+}
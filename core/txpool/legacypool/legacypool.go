@@ -200,13 +200,12 @@ func (config *Config) sanitize() Config {
 // current state) and future transactions. Transactions move between those
 // two states over time as they are received and processed.
 type LegacyPool struct {
-	config      Config
-	chainconfig ctypes.ChainConfigurator
-	chain       BlockChain
-	gasTip      atomic.Pointer[uint256.Int]
-	txFeed      event.Feed
-	signer      types.Signer
-	mu          sync.RWMutex
+	config Config
+	chain  BlockChain
+	gasTip atomic.Pointer[uint256.Int]
+	txFeed event.Feed
+	signer types.Signer
+	mu     sync.RWMutex
 
 	currentHead   atomic.Pointer[types.Header] // Current head of the blockchain
 	currentState  *state.StateDB               // Current state in the blockchain head
@@ -247,7 +246,6 @@ func New(config Config, chain BlockChain) *LegacyPool {
 	pool := &LegacyPool{
 		config:          config,
 		chain:           chain,
-		chainconfig:     chain.Config(),
 		signer:          types.LatestSigner(chain.Config()),
 		pending:         make(map[common.Address]*list),
 		queue:           make(map[common.Address]*list),
@@ -605,7 +603,7 @@ func (pool *LegacyPool) local() map[common.Address]types.Transactions {
 // and does not require the pool mutex to be held.
 func (pool *LegacyPool) validateTxBasics(tx *types.Transaction, local bool) error {
 	opts := &txpool.ValidationOptions{
-		Config: pool.chainconfig,
+		Config: pool.chain.Config(),
 		Accept: 0 |
 			1<<types.LegacyTxType |
 			1<<types.AccessListTxType |
@@ -1297,9 +1295,10 @@ func (pool *LegacyPool) runReorg(done chan struct{}, reset *txpoolResetRequest,
 	if reset != nil {
 		pool.demoteUnexecutables()
 		if reset.newHead != nil {
-			isLondon := pool.chainconfig.IsEnabled(pool.chainconfig.GetEIP1559Transition, new(big.Int).Add(reset.newHead.Number, big.NewInt(1)))
+			config := pool.chain.Config()
+			isLondon := config.IsEnabled(config.GetEIP1559Transition, new(big.Int).Add(reset.newHead.Number, big.NewInt(1)))
 			if isLondon {
-				pendingBaseFee := eip1559.CalcBaseFee(pool.chainconfig, reset.newHead)
+				pendingBaseFee := eip1559.CalcBaseFee(config, reset.newHead)
 				pool.priced.SetBaseFee(pendingBaseFee)
 			} else {
 				pool.priced.Reheap()
@@ -473,7 +473,7 @@ func TestChainFork(t *testing.T) {
 		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
 		statedb.AddBalance(addr, uint256.NewInt(100000000000000))
 
-		pool.chain = newTestBlockChain(pool.chainconfig, 1000000, statedb, new(event.Feed))
+		pool.chain = newTestBlockChain(pool.chain.Config(), 1000000, statedb, new(event.Feed))
 		<-pool.requestReset(nil, nil)
 	}
 	resetState()
@@ -502,7 +502,7 @@ func TestDoubleNonce(t *testing.T) {
 		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
 		statedb.AddBalance(addr, uint256.NewInt(100000000000000))
 
-		pool.chain = newTestBlockChain(pool.chainconfig, 1000000, statedb, new(event.Feed))
+		pool.chain = newTestBlockChain(pool.chain.Config(), 1000000, statedb, new(event.Feed))
 		<-pool.requestReset(nil, nil)
 	}
 	resetState()
@@ -23,9 +23,15 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/forkcheck"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -143,6 +149,24 @@ func (api *AdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// ArtificialFinalityStatus is the ECBP1100 (MESS) artificial finality state of
+// the node, along with the safety conditions it depends on.
+type ArtificialFinalityStatus struct {
+	Active     bool                               `json:"active"`     // Whether artificial finality is enforced at the current head
+	Enabled    bool                               `json:"enabled"`    // Whether the safety conditions allowed artificial finality
+	Transition *hexutil.Uint64                    `json:"transition"` // Configured activation block, nil if unset
+	Peers      int                                `json:"peers"`      // Number of connected peers
+	Subnets    int                                `json:"subnets"`    // Number of distinct /16 subnets among the peers
+	Clients    int                                `json:"clients"`    // Number of distinct client implementations among the peers
+	HeadAge    uint64                             `json:"headAge"`    // Age of the local head block in seconds
+	Safe       bool                               `json:"safe"`       // Whether all safety conditions are met
+	Unmet      []string                           `json:"unmet"`      // Safety conditions not met
+	Thresholds ethconfig.ArtificialFinalitySafety `json:"thresholds"` // Configured safety conditions
+}
+
+// Ecbp1100 sets the ECBP1100 (MESS) activation block, returning whether artificial
+// finality is active at the current head. The safety conditions it depends on
+// are reported by Ecbp1100Status.
 func (api *AdminAPI) Ecbp1100(blockNr rpc.BlockNumber) (bool, error) {
 	i := uint64(blockNr.Int64())
 	err := api.eth.blockchain.Config().SetECBP1100Transition(&i)
@@ -152,6 +176,31 @@ func (api *AdminAPI) Ecbp1100(blockNr rpc.BlockNumber) (bool, error) {
 			api.eth.blockchain.CurrentBlock().Number), err
 }
 
+// Ecbp1100Status reports the artificial finality state of the node, along with
+// the safety conditions it depends on.
+func (api *AdminAPI) Ecbp1100Status() (*ArtificialFinalityStatus, error) {
+	var (
+		chain   = api.eth.blockchain
+		config  = chain.Config()
+		handler = api.eth.handler
+		safety  = handler.artificialFinalitySafety()
+		unmet   = safety.unmet(handler.afSafety)
+		status  = &ArtificialFinalityStatus{
+			Enabled:    chain.IsArtificialFinalityEnabled(),
+			Transition: (*hexutil.Uint64)(config.GetECBP1100Transition()),
+			Peers:      safety.peers,
+			Subnets:    safety.subnets,
+			Clients:    safety.clients,
+			HeadAge:    uint64(max(safety.headAge, 0) / time.Second),
+			Safe:       len(unmet) == 0,
+			Unmet:      unmet,
+			Thresholds: handler.afSafety,
+		}
+	)
+	status.Active = status.Enabled && config.IsEnabled(config.GetECBP1100Transition, chain.CurrentBlock().Number)
+	return status, nil
+}
+
 // MaxPeers sets the maximum peer limit for the protocol manager and the p2p server.
 func (api *AdminAPI) MaxPeers(n int) (bool, error) {
 	api.eth.handler.maxPeers = n
@@ -166,3 +215,50 @@ func (api *AdminAPI) MaxPeers(n int) (bool, error) {
 	}
 	return true, nil
 }
+
+// ReloadChainConfig applies the chain configuration read from the given file, or
+// the one configured at startup if omitted, to the running node. Only changes to
+// transitions beyond the current head are accepted.
+func (api *AdminAPI) ReloadChainConfig(file *string) (bool, error) {
+	var path string
+	if file != nil {
+		path = *file
+	}
+	if err := api.eth.ReloadChainConfig(path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ForkCheck reports the readiness of the node for the upcoming transitions of
+// its chain configuration.
+func (api *AdminAPI) ForkCheck() *forkcheck.Report {
+	var (
+		chain   = api.eth.BlockChain()
+		genesis = chain.Genesis().Hash()
+		stored  = rawdb.ReadChainConfig(api.eth.ChainDb(), genesis)
+	)
+	report := forkcheck.New(chain, stored, forkcheck.BuiltinConfig(genesis, chain.Config().GetChainID()))
+	report.AddPeers(api.eth.handler.peers.forkIDs(), *api.eth.handler.forkFilter.Load())
+	return report
+}
+
+// PruneState starts deleting the stale state from the database in the background
+// while the node keeps running. It's only supported by the hash-based scheme with
+// the snapshot enabled. The optional bloom filter size is given in megabytes.
+func (api *AdminAPI) PruneState(bloomSize *uint64) (bool, error) {
+	size := uint64(2048)
+	if bloomSize != nil {
+		size = *bloomSize
+	}
+	if err := api.eth.PruneState(size); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PruneStatus reports the progress of the last online state pruning, nil if none
+// has been started since the node is running.
+func (api *AdminAPI) PruneStatus() *pruner.Progress {
+	return api.eth.PruneProgress()
+}
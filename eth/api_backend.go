@@ -19,6 +19,7 @@ package eth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
@@ -231,6 +232,17 @@ func (b *EthAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (type
 }
 
 func (b *EthAPIBackend) GetLogs(ctx context.Context, hash common.Hash, number uint64) ([][]*types.Log, error) {
+	if b.eth.blockchain.ReceiptsPruned(hash, number) {
+		receipts := b.eth.blockchain.GetReceiptsByHash(hash)
+		if receipts == nil {
+			return nil, fmt.Errorf("failed to regenerate receipts of block %d", number)
+		}
+		logs := make([][]*types.Log, len(receipts))
+		for i, receipt := range receipts {
+			logs[i] = receipt.Logs
+		}
+		return logs, nil
+	}
 	return rawdb.ReadLogs(b.eth.chainDb, hash, number), nil
 }
 
@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -440,3 +441,55 @@ func (api *DebugAPI) GetTrieFlushInterval() (string, error) {
 	}
 	return api.eth.blockchain.GetTrieFlushInterval().String(), nil
 }
+
+// ExecutionWitness re-executes the given block on top of its parent state and
+// returns the witness (ancestor headers, codes and trie nodes accessed) needed
+// to verify it without holding any state.
+func (api *DebugAPI) ExecutionWitness(blockNr rpc.BlockNumber) (*stateless.Witness, error) {
+	var block *types.Block
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		return nil, errors.New("witness generation is not supported for the pending block")
+	case rpc.LatestBlockNumber:
+		head := api.eth.blockchain.CurrentBlock()
+		block = api.eth.blockchain.GetBlock(head.Hash(), head.Number.Uint64())
+	default:
+		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	return api.eth.blockchain.ExecutionWitness(block)
+}
+
+// StorageStats starts collecting the storage statistics of the state at the
+// given block in the background, ranking the accounts by storage slot count
+// and size. If a base block is given, the storage growth since its state is
+// ranked too. The progress and the final report are available through
+// StorageStatsStatus.
+func (api *DebugAPI) StorageStats(number rpc.BlockNumber, base *rpc.BlockNumber, top *int) (bool, error) {
+	limit := 20
+	if top != nil {
+		limit = *top
+	}
+	if err := api.eth.StartStorageStats(number, base, limit); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StorageStatsStatus reports the progress of the last storage statistics
+// collection, including its report once finished. It returns nil if none has
+// been started since the node is running.
+func (api *DebugAPI) StorageStatsStatus() *StorageStatsStatus {
+	return api.eth.StorageStatsStatus()
+}
+
+// StopStorageStats aborts the running storage statistics collection. It returns
+// false if none is running.
+func (api *DebugAPI) StopStorageStats() bool {
+	return api.eth.StopStorageStats()
+}
@@ -18,11 +18,16 @@
 package eth
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"os"
 	"runtime"
+	"slices"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
@@ -33,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/lyra2"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/txpool"
@@ -45,6 +51,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+	"github.com/ethereum/go-ethereum/eth/rpccache"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -56,6 +63,8 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/confp"
+	"github.com/ethereum/go-ethereum/params/confp/generic"
 	"github.com/ethereum/go-ethereum/params/types/coregeth"
 	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/types/goethereum"
@@ -79,6 +88,7 @@ type Ethereum struct {
 	handler            *handler
 	ethDialCandidates  enode.Iterator
 	snapDialCandidates enode.Iterator
+	dnsTree            *dnsTreePublisher // Publisher of the network node tree, nil if disabled
 	merger             *consensus.Merger
 
 	// DB interfaces
@@ -94,6 +104,12 @@ type Ethereum struct {
 
 	APIBackend *EthAPIBackend
 
+	rpcCache   *rpccache.Cache // Optional cache of immutable historical RPC results
+	rpcCacheDb ethdb.Database  // Optional persistent store backing rpcCache
+
+	pruner       *pruner.OnlinePruner // Online state pruning of the last run, if any
+	storageStats *storageStatsTask    // Storage statistics collection of the last run, if any
+
 	miner     *miner.Miner
 	gasPrice  *big.Int
 	etherbase common.Address
@@ -134,7 +150,15 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	log.Info("Allocated trie memory caches", "clean", common.StorageSize(config.TrieCleanCache)*1024*1024, "dirty", common.StorageSize(config.TrieDirtyCache)*1024*1024)
 
 	// Assemble the Ethereum object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	dbNamespace := "eth/db/chaindata/"
+	if config.Instance != "" {
+		dbNamespace = "eth/" + config.Instance + "/db/chaindata/"
+	}
+	freezer := config.DatabaseFreezer
+	if freezer != "" {
+		freezer = config.ResolvePath(freezer)
+	}
+	chainDb, err := stack.OpenDatabaseWithFreezer(config.ResolvePath("chaindata"), config.DatabaseCache, config.DatabaseHandles, freezer, dbNamespace, false)
 	if err != nil {
 		return nil, err
 	}
@@ -144,13 +168,14 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	}
 	// Try to recover offline state pruning only in hash-based.
 	if scheme == rawdb.HashScheme {
-		if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb); err != nil {
+		if err := pruner.RecoverPruning(stack.ResolvePath(config.ResolvePath("")), chainDb); err != nil {
 			log.Error("Failed to recover state", "error", err)
 		}
 	}
 	// Transfer mining-related config to the ethash config.
 	ethashConfig := config.Ethash
 	ethashConfig.NotifyFull = config.Miner.NotifyFull
+	ethashConfig.CacheDir = config.ResolvePath(ethashConfig.CacheDir)
 
 	if config.Genesis != nil && config.Genesis.Config != nil {
 		ethashConfig.ECIP1099Block = config.Genesis.GetEthashECIP1099Transition()
@@ -178,11 +203,21 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if networkID == 0 {
 		networkID = chainConfig.GetChainID().Uint64()
 	}
+	// Additional network instances get their own event mux and P2P server, so
+	// that neither sync events nor peers leak between the hosted networks.
+	eventMux, p2pServer := stack.EventMux(), stack.Server()
+	if config.Instance != "" {
+		if config.InstanceP2P == nil {
+			return nil, fmt.Errorf("missing P2P configuration for network instance %q", config.Instance)
+		}
+		eventMux = new(event.TypeMux)
+		p2pServer = stack.RegisterServer(config.Instance, *config.InstanceP2P)
+	}
 	eth := &Ethereum{
 		config:            config,
 		merger:            consensus.NewMerger(chainDb),
 		chainDb:           chainDb,
-		eventMux:          stack.EventMux(),
+		eventMux:          eventMux,
 		accountManager:    stack.AccountManager(),
 		engine:            engine,
 		closeBloomHandler: make(chan struct{}),
@@ -191,7 +226,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		etherbase:         config.Miner.Etherbase,
 		bloomRequests:     make(chan chan *bloombits.Retrieval),
 		bloomIndexer:      core.NewBloomIndexer(chainDb, vars.BloomBitsBlocks, vars.BloomConfirms),
-		p2pServer:         stack.Server(),
+		p2pServer:         p2pServer,
 		shutdownTracker:   shutdowncheck.NewShutdownTracker(chainDb),
 	}
 	bcVersion := rawdb.ReadDatabaseVersion(chainDb)
@@ -226,6 +261,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
 			StateHistory:        config.StateHistory,
+			ReceiptHistory:      config.ReceiptHistory,
 			StateScheme:         scheme,
 		}
 	)
@@ -242,17 +278,21 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		return nil, err
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
-	// Handle artificial finality config override cases.
-	if n := config.OverrideECBP1100; n != nil {
-		if err := eth.blockchain.Config().SetECBP1100Transition(n); err != nil {
+	// Apply the chain configuration file, scheduling any future transitions
+	// it changes over the stored configuration.
+	if config.ChainConfigFile != "" {
+		chainConfig, err := readChainConfig(config.ChainConfigFile)
+		if err != nil {
 			return nil, err
 		}
-	}
-	if n := config.OverrideECBP1100Deactivate; n != nil {
-		if err := eth.blockchain.Config().SetECBP1100DeactivateTransition(n); err != nil {
-			return nil, err
+		if err := eth.blockchain.SetChainConfig(chainConfig); err != nil {
+			return nil, fmt.Errorf("failed to apply chain configuration file: %v", err)
 		}
 	}
+	// Handle artificial finality config override cases.
+	if err := applyECBP1100Overrides(eth.blockchain.Config(), config); err != nil {
+		return nil, err
+	}
 
 	if config.ECBP1100NoDisable != nil {
 		if *config.ECBP1100NoDisable {
@@ -260,15 +300,19 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		}
 	}
 
-	if config.BlobPool.Datadir != "" {
-		config.BlobPool.Datadir = stack.ResolvePath(config.BlobPool.Datadir)
+	// Resolve the pool paths on copies, the config may be reused for additional
+	// networks which need to nest them in their own directory.
+	blobConfig := config.BlobPool
+	if blobConfig.Datadir != "" {
+		blobConfig.Datadir = stack.ResolvePath(config.ResolvePath(blobConfig.Datadir))
 	}
-	blobPool := blobpool.New(config.BlobPool, eth.blockchain)
+	blobPool := blobpool.New(blobConfig, eth.blockchain)
 
-	if config.TxPool.Journal != "" {
-		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
+	txPoolConfig := config.TxPool
+	if txPoolConfig.Journal != "" {
+		txPoolConfig.Journal = stack.ResolvePath(config.ResolvePath(txPoolConfig.Journal))
 	}
-	legacyPool := legacypool.New(config.TxPool, eth.blockchain)
+	legacyPool := legacypool.New(txPoolConfig, eth.blockchain)
 
 	eth.txPool, err = txpool.New(config.TxPool.PriceLimit, eth.blockchain, []txpool.SubPool{legacyPool, blobPool})
 	if err != nil {
@@ -284,6 +328,13 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			checkpoint = p.TrustedCheckpoint
 		}
 	}
+	syncCheckpoints := config.SyncCheckpoints
+	if list := config.CheckpointList; list != nil {
+		if err := list.Verify(eth.blockchain.Genesis().Hash(), config.CheckpointSigners, config.CheckpointThreshold); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint list: %v", err)
+		}
+		syncCheckpoints = append(slices.Clone(syncCheckpoints), list.Checkpoints...)
+	}
 	if eth.handler, err = newHandler(&handlerConfig{
 		Database:       chainDb,
 		Chain:          eth.blockchain,
@@ -291,15 +342,20 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		Merger:         eth.merger,
 		Network:        networkID,
 		Sync:           config.SyncMode,
+		HeaderFirst:    config.HeaderFirstSync,
 		BloomCache:     uint64(cacheLimit),
 		EventMux:       eth.eventMux,
 		Checkpoint:     checkpoint,
 		RequiredBlocks: config.RequiredBlocks,
+		Checkpoints:    syncCheckpoints,
+
+		ArtificialFinality: config.ECBP1100Safety,
+		TxPolicies:         NewTxPolicies(config.TxPolicy),
 	}); err != nil {
 		return nil, err
 	}
 
-	eth.miner = miner.New(eth, &config.Miner, eth.blockchain.Config(), eth.EventMux(), eth.engine, eth.isLocalBlock)
+	eth.miner = miner.New(eth, &config.Miner, eth.EventMux(), eth.engine, eth.isLocalBlock)
 	eth.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
 
 	eth.APIBackend = &EthAPIBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, eth, nil}
@@ -322,13 +378,41 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.DNSTree.Domain != "" {
+		if eth.dnsTree, err = newDNSTreePublisher(config.DNSTree, eth.blockchain); err != nil {
+			return nil, err
+		}
+	}
 
 	// Start the RPC service
 	eth.netRPCService = ethapi.NewNetAPI(eth.p2pServer, networkID)
 
+	// Set up the historical RPC result cache if requested. The node hosts a single
+	// cache, so it is only available to the primary network.
+	if config.Instance == "" && (config.RPCResultCache > 0 || config.RPCResultCacheDisk) {
+		cacheConfig := rpccache.Config{
+			Size:  config.RPCResultCache,
+			Depth: config.RPCResultCacheDepth,
+		}
+		if config.RPCResultCacheDisk {
+			if eth.rpcCacheDb, err = stack.OpenDatabase("rpccache", 16, 16, "eth/db/rpccache/", false); err != nil {
+				return nil, err
+			}
+			cacheConfig.Disk = eth.rpcCacheDb
+		}
+		eth.rpcCache = rpccache.New(eth.blockchain, cacheConfig)
+		stack.SetRPCResultCache(eth.rpcCache)
+		log.Info("Enabled RPC result cache", "size", common.StorageSize(config.RPCResultCache)*1024*1024, "depth", config.RPCResultCacheDepth, "disk", config.RPCResultCacheDisk)
+	}
+
 	// Register the backend on the node
-	stack.RegisterAPIs(eth.APIs())
-	stack.RegisterProtocols(eth.Protocols())
+	stack.RegisterAPIs(config.Namespaced(eth.APIs()))
+	if config.Instance != "" {
+		p2pServer.Protocols = append(p2pServer.Protocols, eth.Protocols()...)
+		log.Info("Hosting additional network", "instance", config.Instance, "network", networkID, "listen", p2pServer.ListenAddr)
+	} else {
+		stack.RegisterProtocols(eth.Protocols())
+	}
 	stack.RegisterLifecycle(eth)
 
 	// Successful startup; push a marker and check previous unclean shutdowns.
@@ -532,6 +616,140 @@ func (s *Ethereum) StopMining() {
 	s.miner.Stop()
 }
 
+// SetChainConfig applies a chain configuration scheduling new or changed future
+// transitions to the running node, refreshing the fork ID advertised to and
+// expected from peers. The ECBP1100 transitions overridden in the node
+// configuration take precedence over the given ones.
+func (s *Ethereum) SetChainConfig(config ctypes.ChainConfigurator) error {
+	config, err := confp.CloneChainConfigurator(config)
+	if err != nil {
+		return err
+	}
+	if err := applyECBP1100Overrides(config, s.config); err != nil {
+		return err
+	}
+	// Ethash keeps the epoch length it was created with for its caches and
+	// datasets, it can't follow a rescheduled ECIP-1099 transition
+	if have, want := s.blockchain.Config().GetEthashECIP1099Transition(), config.GetEthashECIP1099Transition(); (have == nil) != (want == nil) || (have != nil && *have != *want) {
+		return fmt.Errorf("ECIP-1099 transition can't be changed while running: have %v, want %v", printTransition(have), printTransition(want))
+	}
+	if err := s.blockchain.SetChainConfig(config); err != nil {
+		return err
+	}
+	s.handler.updateForkFilter()
+	if ln := s.p2pServer.LocalNode(); ln != nil {
+		eth.UpdateENR(s.blockchain, ln)
+	}
+	head := s.blockchain.CurrentHeader()
+	log.Info("Updated chain configuration", "forkid", forkid.NewID(s.blockchain.Config(), s.blockchain.Genesis(), head.Number.Uint64(), head.Time))
+	return nil
+}
+
+// ReloadChainConfig reads the chain configuration from the given file, or the
+// configured one if empty, and applies it to the running node.
+func (s *Ethereum) ReloadChainConfig(file string) error {
+	if file == "" {
+		file = s.config.ChainConfigFile
+	}
+	if file == "" {
+		return errors.New("no chain configuration file specified")
+	}
+	config, err := readChainConfig(file)
+	if err != nil {
+		return err
+	}
+	return s.SetChainConfig(config)
+}
+
+// printTransition formats an optional transition block for logging.
+func printTransition(n *uint64) string {
+	if n == nil {
+		return "unset"
+	}
+	return strconv.FormatUint(*n, 10)
+}
+
+// applyECBP1100Overrides sets the ECBP1100 transitions overridden in the node
+// configuration on the given chain configuration.
+func applyECBP1100Overrides(chainConfig ctypes.ChainConfigurator, config *ethconfig.Config) error {
+	if n := config.OverrideECBP1100; n != nil {
+		if err := chainConfig.SetECBP1100Transition(n); err != nil {
+			return err
+		}
+	}
+	if n := config.OverrideECBP1100Deactivate; n != nil {
+		if err := chainConfig.SetECBP1100DeactivateTransition(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readChainConfig loads a chain configuration from a file containing either a
+// genesis specification or a bare chain configuration.
+func readChainConfig(file string) (ctypes.ChainConfigurator, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var genesis struct {
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("invalid chain configuration file: %v", err)
+	}
+	if len(genesis.Config) > 0 {
+		data = genesis.Config
+	}
+	config, err := generic.UnmarshalChainConfigurator(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chain configuration: %v", err)
+	}
+	return config, nil
+}
+
+// PruneState starts deleting the stale state from the database in the background
+// while the node keeps running, using a bloom filter of the given size in MB.
+func (s *Ethereum) PruneState(bloomSize uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pruner != nil {
+		select {
+		case <-s.pruner.Done():
+		default:
+			return errors.New("state pruning is already running")
+		}
+	}
+	if !s.Synced() {
+		return errors.New("state pruning is not available while syncing")
+	}
+	p, err := pruner.NewOnlinePruner(s.chainDb, s.blockchain, pruner.OnlineConfig{
+		BloomSize:  bloomSize,
+		BatchDelay: 100 * time.Millisecond,
+		Retention:  core.TriesInMemory,
+	})
+	if err != nil {
+		return err
+	}
+	s.pruner = p
+	s.pruner.Start()
+	return nil
+}
+
+// PruneProgress returns the progress of the last online state pruning, nil if
+// none has been started.
+func (s *Ethereum) PruneProgress() *pruner.Progress {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.pruner == nil {
+		return nil
+	}
+	progress := s.pruner.Progress()
+	return &progress
+}
+
 func (s *Ethereum) IsMining() bool      { return s.miner.Mining() }
 func (s *Ethereum) Miner() *miner.Miner { return s.miner }
 
@@ -557,6 +775,13 @@ func (s *Ethereum) SyncMode() downloader.SyncMode {
 	return mode
 }
 
+// AddTxPolicy appends a transaction propagation policy to the ones already in
+// effect. It may be called at any time, allowing embedders to plug in their own
+// policies alongside the built-in ones.
+func (s *Ethereum) AddTxPolicy(policy TxPolicy) {
+	s.handler.txPolicies.add(policy)
+}
+
 // Protocols returns all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
@@ -571,6 +796,9 @@ func (s *Ethereum) Protocols() []p2p.Protocol {
 // Ethereum protocol implementation.
 func (s *Ethereum) Start() error {
 	eth.StartENRUpdater(s.blockchain, s.p2pServer.LocalNode())
+	if disc := s.p2pServer.DiscoveryV5(); disc != nil {
+		eth.StartTopicDiscovery(s.blockchain, disc, s.p2pServer.AddPreferredDialSource)
+	}
 
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers(vars.BloomBitsBlocks)
@@ -586,6 +814,12 @@ func (s *Ethereum) Start() error {
 		}
 		maxPeers -= s.config.LightPeers
 	}
+	// Start publishing the nodes of the network if requested
+	if s.dnsTree != nil {
+		if err := s.dnsTree.start(s.p2pServer); err != nil {
+			return fmt.Errorf("failed to start DNS tree publisher: %v", err)
+		}
+	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
 	return nil
@@ -597,13 +831,30 @@ func (s *Ethereum) Stop() error {
 	// Stop all the peer-related stuff first.
 	s.ethDialCandidates.Close()
 	s.snapDialCandidates.Close()
+	if s.dnsTree != nil {
+		s.dnsTree.stop()
+	}
 	s.handler.Stop()
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
+	if s.rpcCache != nil {
+		s.rpcCache.Close()
+	}
+	if s.rpcCacheDb != nil {
+		s.rpcCacheDb.Close()
+	}
 	s.txPool.Close()
 	s.miner.Close()
+	s.lock.RLock()
+	if s.pruner != nil {
+		s.pruner.Stop()
+	}
+	if s.storageStats != nil {
+		s.storageStats.stop()
+	}
+	s.lock.RUnlock()
 	s.blockchain.Stop()
 	s.engine.Close()
 
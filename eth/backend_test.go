@@ -2,7 +2,18 @@ package eth
 
 import (
 	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/confp"
 )
 
 func TestMakeExtraDataDefault(t *testing.T) {
@@ -10,3 +21,173 @@ func TestMakeExtraDataDefault(t *testing.T) {
 		t.Error("missing extra data default client identifier")
 	}
 }
+
+// Tests that an additional network hosted by the same node runs side by side with
+// the primary one, without sharing its chain, pools or APIs.
+func TestExtraNetworkInstance(t *testing.T) {
+	stack, err := node.New(&node.Config{
+		DataDir: t.TempDir(),
+		P2P:     p2p.Config{ListenAddr: "127.0.0.1:0", NoDiscovery: true, MaxPeers: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stack.Close()
+
+	config := ethconfig.Defaults
+	config.Genesis = params.DefaultMessNetGenesisBlock()
+	config.Ethash.PowMode = ethash.ModeFake
+	primary, err := New(stack, &config)
+	if err != nil {
+		t.Fatal("can't create primary network:", err)
+	}
+	// Derive the additional network from the config used by the primary one, the
+	// way the command line does.
+	extraConfig := config
+	extraConfig.Instance = "mordor"
+	extraConfig.Genesis = params.DefaultMordorGenesisBlock()
+	extraConfig.NetworkId = *extraConfig.Genesis.GetNetworkID()
+	extraConfig.InstanceP2P = &p2p.Config{ListenAddr: "127.0.0.1:0", NoDiscovery: true, MaxPeers: 10}
+	extra, err := New(stack, &extraConfig)
+	if err != nil {
+		t.Fatal("can't create extra network:", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if primary.BlockChain().Genesis().Hash() == extra.BlockChain().Genesis().Hash() {
+		t.Fatal("networks share the genesis block")
+	}
+	// Each network must keep its own pool data
+	for _, path := range []string{
+		stack.ResolvePath(ethconfig.Defaults.TxPool.Journal),
+		stack.ResolvePath(extraConfig.ResolvePath(ethconfig.Defaults.TxPool.Journal)),
+		stack.ResolvePath(ethconfig.Defaults.BlobPool.Datadir),
+		stack.ResolvePath(extraConfig.ResolvePath(ethconfig.Defaults.BlobPool.Datadir)),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("missing pool data: %v", err)
+		}
+	}
+	// The APIs of both networks must be served, under their own namespaces
+	client := stack.Attach()
+	defer client.Close()
+
+	var primaryID, extraID hexutil.Big
+	if err := client.Call(&primaryID, "eth_chainId"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&extraID, "mordor.eth_chainId"); err != nil {
+		t.Fatal(err)
+	}
+	if primaryID.ToInt().Cmp(config.Genesis.Config.GetChainID()) != 0 {
+		t.Errorf("primary chain ID mismatch: have %v", primaryID.ToInt())
+	}
+	if extraID.ToInt().Cmp(extraConfig.Genesis.Config.GetChainID()) != 0 {
+		t.Errorf("extra chain ID mismatch: have %v", extraID.ToInt())
+	}
+}
+
+// Tests that reloading the chain configuration keeps the ECBP1100 transitions
+// overridden in the node configuration.
+func TestReloadChainConfigOverrides(t *testing.T) {
+	stack, err := node.New(&node.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stack.Close()
+
+	// The overrides are applied to the stored configuration, don't let them
+	// reach the shared one
+	genesis := params.DefaultMessNetGenesisBlock()
+	if genesis.Config, err = confp.CloneChainConfigurator(genesis.Config); err != nil {
+		t.Fatal(err)
+	}
+	deactivate := uint64(5000)
+	config := ethconfig.Defaults
+	config.Genesis = genesis
+	config.Ethash.PowMode = ethash.ModeFake
+	config.OverrideECBP1100Deactivate = &deactivate
+	backend, err := New(stack, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := backend.BlockChain().Config()
+
+	// Reload a configuration file scheduling the overridden transition elsewhere
+	scheduled := 2 * deactivate
+	updated, err := confp.CloneChainConfigurator(params.MessNetConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := updated.SetECBP1100DeactivateTransition(&scheduled); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "config.json")
+	data, err := json.Marshal(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.ReloadChainConfig(file); err != nil {
+		t.Fatal("can't reload chain configuration:", err)
+	}
+	if backend.BlockChain().Config() == previous {
+		t.Error("chain configuration not replaced")
+	}
+	if have := backend.BlockChain().Config().GetECBP1100DeactivateTransition(); have == nil || *have != deactivate {
+		t.Errorf("ECBP1100 override lost: have %v, want %d", have, deactivate)
+	}
+	// Reloads rescheduling ECIP-1099 are rejected, ethash can't follow them
+	ecip1099 := uint64(100)
+	if err := updated.SetEthashECIP1099Transition(&ecip1099); err != nil {
+		t.Fatal(err)
+	}
+	current := backend.BlockChain().Config()
+	if err := backend.SetChainConfig(updated); err == nil {
+		t.Error("ECIP-1099 transition changed while running")
+	}
+	if backend.BlockChain().Config() != current {
+		t.Error("chain configuration replaced by rejected reload")
+	}
+}
+
+// Tests that an unset artificial finality safety configuration falls back to the
+// default conditions, while an explicitly zero one disables all of them.
+func TestArtificialFinalitySafetyDefaults(t *testing.T) {
+	tests := []struct {
+		safety *ethconfig.ArtificialFinalitySafety
+		want   ethconfig.ArtificialFinalitySafety
+		safe   bool
+	}{
+		{nil, ethconfig.DefaultArtificialFinalitySafety, false},
+		{new(ethconfig.ArtificialFinalitySafety), ethconfig.ArtificialFinalitySafety{}, true},
+	}
+	for i, tt := range tests {
+		stack, err := node.New(&node.Config{DataDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := ethconfig.Defaults
+		config.Genesis = params.DefaultMessNetGenesisBlock()
+		config.Ethash.PowMode = ethash.ModeFake
+		config.ECBP1100Safety = tt.safety
+		backend, err := New(stack, &config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := backend.handler.afSafety; have != tt.want {
+			t.Errorf("test %d: safety conditions mismatch: have %+v, want %+v", i, have, tt.want)
+		}
+		status, err := NewAdminAPI(backend).Ecbp1100Status()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Safe != tt.safe {
+			t.Errorf("test %d: safety mismatch without peers: have %v, want %v", i, status.Safe, tt.safe)
+		}
+		stack.Close()
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	dnsTreeFirstUpdate = 5 * time.Minute // Delay of the first tree update, giving the crawler time
+	dnsTreeChecks      = 16              // Number of nodes checked for liveness concurrently
+	dnsTreeMixTimeout  = time.Second     // Timeout of the discovery source mix of the crawler
+)
+
+// dnsTreeNode is a node of the network found by the crawler.
+type dnsTreeNode struct {
+	node    *enode.Node
+	checked time.Time // Last time the node answered an ENR request, zero if never
+}
+
+// dnsTreeResolver requests the current record of a node, checking it is alive.
+type dnsTreeResolver func(n *enode.Node) (*enode.Node, error)
+
+// dnsTreePublisher crawls the network of the node through node discovery and
+// publishes the live nodes on the same fork as a signed EIP-1459 node tree. The
+// tree is served by a built-in authoritative DNS server or written into a zone
+// file, so networks can run DNS discovery without a DNS hosting provider.
+type dnsTreePublisher struct {
+	config ethconfig.DNSTreeConfig
+	key    *ecdsa.PrivateKey
+	filter func(*enode.Node) bool
+
+	server *dnsdisc.Server
+	mix    *enode.FairMix
+
+	nodes map[enode.ID]*dnsTreeNode
+	lock  sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newDNSTreePublisher creates a node tree publisher for the network of the chain.
+func newDNSTreePublisher(config ethconfig.DNSTreeConfig, chain *core.BlockChain) (*dnsTreePublisher, error) {
+	if config.KeyFile == "" {
+		return nil, errors.New("no DNS tree signing key configured")
+	}
+	key, err := crypto.LoadECDSA(config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS tree signing key: %v", err)
+	}
+	for _, link := range config.Links {
+		if _, _, err := dnsdisc.ParseURL(link); err != nil {
+			return nil, fmt.Errorf("invalid DNS tree link %q: %v", link, err)
+		}
+	}
+	if config.Listen == "" && config.ZoneFile == "" {
+		return nil, errors.New("DNS tree needs a DNS server address or a zone file")
+	}
+	if config.Interval <= 0 {
+		config.Interval = ethconfig.Defaults.DNSTree.Interval
+	}
+	return &dnsTreePublisher{
+		config: config,
+		key:    key,
+		filter: eth.NewNodeFilter(chain),
+		nodes:  make(map[enode.ID]*dnsTreeNode),
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// start launches the DNS server, the crawler and the update loop. It must be
+// called after the p2p server was started.
+func (p *dnsTreePublisher) start(srv *p2p.Server) error {
+	var (
+		sources []enode.Iterator
+		resolve dnsTreeResolver
+	)
+	if disc := srv.DiscoveryV5(); disc != nil {
+		sources = append(sources, disc.RandomNodes())
+		resolve = disc.RequestENR
+	}
+	if disc := srv.DiscoveryV4(); disc != nil {
+		sources = append(sources, disc.RandomNodes())
+		resolve = disc.RequestENR
+	}
+	if len(sources) == 0 {
+		return errors.New("node discovery is disabled")
+	}
+	if p.config.Listen != "" {
+		p.server = dnsdisc.NewServer(p.config.Domain, p.config.TTL)
+		if err := p.server.ListenAndServe(p.config.Listen); err != nil {
+			return err
+		}
+		log.Info("Started DNS tree server", "domain", p.config.Domain, "addr", p.server.Addr())
+	}
+	p.mix = enode.NewFairMix(dnsTreeMixTimeout)
+	for _, source := range sources {
+		p.mix.AddSource(source)
+	}
+	p.wg.Add(2)
+	go p.crawl(enode.Filter(p.mix, p.filter))
+	go p.loop(srv.Self, resolve)
+	return nil
+}
+
+// stop terminates the publisher.
+func (p *dnsTreePublisher) stop() {
+	close(p.quit)
+	if p.mix != nil {
+		p.mix.Close()
+	}
+	p.wg.Wait()
+	if p.server != nil {
+		p.server.Close()
+	}
+}
+
+// crawl collects the nodes on our fork found through discovery.
+func (p *dnsTreePublisher) crawl(it enode.Iterator) {
+	defer p.wg.Done()
+
+	for it.Next() {
+		n := it.Node()
+
+		p.lock.Lock()
+		if old, ok := p.nodes[n.ID()]; !ok || old.node.Seq() < n.Seq() {
+			p.nodes[n.ID()] = &dnsTreeNode{node: n}
+		}
+		p.lock.Unlock()
+	}
+}
+
+// loop updates the published tree periodically.
+func (p *dnsTreePublisher) loop(self func() *enode.Node, resolve dnsTreeResolver) {
+	defer p.wg.Done()
+
+	timer := time.NewTimer(min(p.config.Interval, dnsTreeFirstUpdate))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if err := p.update(self(), resolve); err != nil {
+				log.Error("Failed to update DNS node tree", "err", err)
+			}
+			timer.Reset(p.config.Interval)
+
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// update checks the liveness of the crawled nodes and publishes the live ones.
+func (p *dnsTreePublisher) update(self *enode.Node, resolve dnsTreeResolver) error {
+	nodes := p.check(resolve)
+	if self.IPAddr().IsValid() && !self.IPAddr().IsUnspecified() && self.TCP() != 0 && p.filter(self) {
+		nodes = append(nodes, self)
+	}
+	select {
+	case <-p.quit:
+		return nil
+	default:
+	}
+	tree, err := dnsdisc.MakeTree(uint(time.Now().Unix()), nodes, p.config.Links)
+	if err != nil {
+		return err
+	}
+	url, err := tree.Sign(p.key, p.config.Domain)
+	if err != nil {
+		return err
+	}
+	if p.server != nil {
+		p.server.SetTree(tree)
+	}
+	if p.config.ZoneFile != "" {
+		if err := writeZoneFile(p.config.ZoneFile, tree, p.config.Domain, p.config.TTL); err != nil {
+			return err
+		}
+	}
+	log.Info("Updated DNS node tree", "url", url, "seq", tree.Seq(), "nodes", len(nodes))
+	return nil
+}
+
+// check requests the current record of the nodes not checked during the last
+// interval, dropping the ones that are unreachable or moved to another fork. It
+// returns the nodes known to be live.
+func (p *dnsTreePublisher) check(resolve dnsTreeResolver) []*enode.Node {
+	type result struct {
+		id   enode.ID
+		node *enode.Node
+	}
+	var (
+		now     = time.Now()
+		pending []*enode.Node
+	)
+	p.lock.Lock()
+	for _, entry := range p.nodes {
+		if now.Sub(entry.checked) >= p.config.Interval {
+			pending = append(pending, entry.node)
+		}
+	}
+	p.lock.Unlock()
+
+	var (
+		results = make(chan result, len(pending))
+		slots   = make(chan struct{}, dnsTreeChecks)
+	)
+	for _, n := range pending {
+		select {
+		case slots <- struct{}{}:
+		case <-p.quit:
+			return nil
+		}
+		go func(n *enode.Node) {
+			defer func() { <-slots }()
+			rn, err := resolve(n)
+			if err != nil || !p.filter(rn) {
+				rn = nil
+			}
+			results <- result{n.ID(), rn}
+		}(n)
+	}
+	for i := 0; i < cap(slots); i++ {
+		slots <- struct{}{}
+	}
+	close(results)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for res := range results {
+		if res.node == nil {
+			delete(p.nodes, res.id)
+			continue
+		}
+		p.nodes[res.id] = &dnsTreeNode{node: res.node, checked: now}
+	}
+	var live []*enode.Node
+	for _, entry := range p.nodes {
+		if !entry.checked.IsZero() {
+			live = append(live, entry.node)
+		}
+	}
+	return live
+}
+
+// writeZoneFile replaces the zone file with the records of the tree.
+func writeZoneFile(path string, tree *dnsdisc.Tree, domain string, ttl uint32) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	// The zone file is meant to be read by the DNS server, not only by us
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := tree.WriteZone(f, domain, ttl); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
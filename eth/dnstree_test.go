@@ -0,0 +1,286 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base32"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/params/confp"
+)
+
+// newDNSTreeTestPublisher creates a node tree publisher for the chain of the
+// test handler, without starting it.
+func newDNSTreeTestPublisher(h *testHandler, config ethconfig.DNSTreeConfig) *dnsTreePublisher {
+	return &dnsTreePublisher{
+		config: config,
+		filter: eth.NewNodeFilter(h.chain),
+		nodes:  make(map[enode.ID]*dnsTreeNode),
+		quit:   make(chan struct{}),
+	}
+}
+
+// newDNSTreeTestNode creates a node record with the given sequence number,
+// advertising the fork identifier in its `eth` entry if not nil.
+func newDNSTreeTestNode(t *testing.T, key *ecdsa.PrivateKey, seq uint64, id *forkid.ID) *enode.Node {
+	t.Helper()
+
+	var r enr.Record
+	r.SetSeq(seq)
+	r.Set(enr.IPv4(net.IP{127, 0, 0, 1}))
+	r.Set(enr.TCP(30303))
+	if id != nil {
+		r.Set(enr.WithEntry("eth", []forkid.ID{*id}))
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// Tests that the crawler only collects the nodes on the local fork, keeping the
+// latest record of each.
+func TestDNSTreeCrawl(t *testing.T) {
+	h := newTestHandler()
+	defer h.close()
+
+	var (
+		p       = newDNSTreeTestPublisher(h, ethconfig.Defaults.DNSTree)
+		own     = forkid.NewIDWithChain(h.chain)
+		foreign = forkid.ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}
+		key, _  = crypto.GenerateKey()
+		older   = newDNSTreeTestNode(t, key, 1, &own)
+		newer   = newDNSTreeTestNode(t, key, 2, &own)
+	)
+	foreignKey, _ := crypto.GenerateKey()
+	plainKey, _ := crypto.GenerateKey()
+
+	it := enode.IterNodes([]*enode.Node{
+		newer,
+		newDNSTreeTestNode(t, foreignKey, 1, &foreign),
+		newDNSTreeTestNode(t, plainKey, 1, nil),
+		older,
+	})
+	p.wg.Add(1)
+	p.crawl(enode.Filter(it, p.filter))
+
+	if len(p.nodes) != 1 {
+		t.Fatalf("crawled node count mismatch: have %d, want 1", len(p.nodes))
+	}
+	entry := p.nodes[newer.ID()]
+	if entry == nil {
+		t.Fatal("node on the local fork not crawled")
+	}
+	if entry.node.Seq() != newer.Seq() {
+		t.Errorf("record replaced by an older one: have seq %d, want %d", entry.node.Seq(), newer.Seq())
+	}
+	if !entry.checked.IsZero() {
+		t.Error("crawled node marked live before checking it")
+	}
+}
+
+// Tests that the liveness check drops the unreachable nodes and those moved to
+// another fork, and skips the nodes checked during the last interval.
+func TestDNSTreeCheck(t *testing.T) {
+	h := newTestHandler()
+	defer h.close()
+
+	var (
+		p       = newDNSTreeTestPublisher(h, ethconfig.DNSTreeConfig{Interval: time.Hour})
+		own     = forkid.NewIDWithChain(h.chain)
+		foreign = forkid.ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}
+		keys    = make([]*ecdsa.PrivateKey, 4)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	var (
+		live    = newDNSTreeTestNode(t, keys[0], 1, &own)
+		gone    = newDNSTreeTestNode(t, keys[1], 1, &own)
+		moved   = newDNSTreeTestNode(t, keys[2], 1, &own)
+		checked = newDNSTreeTestNode(t, keys[3], 1, &own)
+		updated = newDNSTreeTestNode(t, keys[0], 2, &own)
+	)
+	for _, n := range []*enode.Node{live, gone, moved} {
+		p.nodes[n.ID()] = &dnsTreeNode{node: n}
+	}
+	p.nodes[checked.ID()] = &dnsTreeNode{node: checked, checked: time.Now()}
+
+	var (
+		requested = make(map[enode.ID]int)
+		lock      sync.Mutex
+	)
+	resolve := func(n *enode.Node) (*enode.Node, error) {
+		lock.Lock()
+		requested[n.ID()]++
+		lock.Unlock()
+
+		switch n.ID() {
+		case live.ID():
+			return updated, nil
+		case moved.ID():
+			return newDNSTreeTestNode(t, keys[2], 2, &foreign), nil
+		default:
+			return nil, errors.New("timeout")
+		}
+	}
+	nodes := p.check(resolve)
+
+	if requested[checked.ID()] != 0 {
+		t.Error("recently checked node requested again")
+	}
+	for _, n := range []*enode.Node{live, gone, moved} {
+		if requested[n.ID()] != 1 {
+			t.Errorf("node %v requested %d times, want once", n.ID(), requested[n.ID()])
+		}
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("live node count mismatch: have %d, want 2", len(nodes))
+	}
+	for _, n := range nodes {
+		if n.ID() != live.ID() && n.ID() != checked.ID() {
+			t.Errorf("unexpected live node %v", n.ID())
+		}
+	}
+	if entry := p.nodes[live.ID()]; entry == nil || entry.checked.IsZero() || entry.node.Seq() != updated.Seq() {
+		t.Errorf("live node not updated: %+v", entry)
+	}
+	if p.nodes[gone.ID()] != nil || p.nodes[moved.ID()] != nil {
+		t.Error("unreachable or moved nodes kept")
+	}
+}
+
+// Tests that the fork filter of the publisher follows reloads of the chain
+// configuration.
+func TestDNSTreeForkFilter(t *testing.T) {
+	h := newTestHandler()
+	defer h.close()
+
+	p := newDNSTreeTestPublisher(h, ethconfig.Defaults.DNSTree)
+
+	// Schedule a fork the local chain doesn't know about yet
+	config, err := confp.CloneChainConfigurator(h.chain.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork := uint64(150)
+	if err := config.SetEIP3651TransitionTime(&fork); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		key, _ = crypto.GenerateKey()
+		id     = forkid.NewID(config, h.chain.Genesis(), 200, 200)
+		node   = newDNSTreeTestNode(t, key, 1, &id)
+	)
+	if p.filter(node) {
+		t.Fatal("node on an unknown fork accepted")
+	}
+	if err := h.chain.SetChainConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if !p.filter(node) {
+		t.Fatal("node on the rescheduled fork rejected")
+	}
+}
+
+// Tests that an update publishes the live nodes, including the local one, through
+// the DNS server and the zone file.
+func TestDNSTreeUpdate(t *testing.T) {
+	h := newTestHandler()
+	defer h.close()
+
+	const domain = "nodes.example.org"
+	var (
+		signer, _ = crypto.GenerateKey()
+		zone      = filepath.Join(t.TempDir(), "nodes.zone")
+		p         = newDNSTreeTestPublisher(h, ethconfig.DNSTreeConfig{
+			Domain:   domain,
+			ZoneFile: zone,
+			TTL:      60,
+			Interval: time.Hour,
+		})
+		own        = forkid.NewIDWithChain(h.chain)
+		key, _     = crypto.GenerateKey()
+		selfKey, _ = crypto.GenerateKey()
+	)
+	p.key = signer
+	p.server = dnsdisc.NewServer(domain, 60)
+	if err := p.server.ListenAndServe("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer p.server.Close()
+
+	var (
+		remote = newDNSTreeTestNode(t, key, 1, &own)
+		self   = newDNSTreeTestNode(t, selfKey, 1, &own)
+	)
+	p.nodes[remote.ID()] = &dnsTreeNode{node: remote}
+	resolve := func(n *enode.Node) (*enode.Node, error) { return n, nil }
+
+	if err := p.update(self, resolve); err != nil {
+		t.Fatal("update failed:", err)
+	}
+	// The tree must be served by the DNS server
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, p.server.Addr().String())
+		},
+	}
+	client := dnsdisc.NewClient(dnsdisc.Config{Resolver: resolver, RateLimit: 1000})
+	url := "enrtree://" + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(crypto.CompressPubkey(&signer.PublicKey)) + "@" + domain
+	tree, err := client.SyncTree(url)
+	if err != nil {
+		t.Fatal("can't sync published tree:", err)
+	}
+	published := make(map[enode.ID]bool)
+	for _, n := range tree.Nodes() {
+		published[n.ID()] = true
+	}
+	if len(published) != 2 || !published[remote.ID()] || !published[self.ID()] {
+		t.Errorf("published nodes mismatch: have %v", published)
+	}
+	// And written into the zone file
+	data, err := os.ReadFile(zone)
+	if err != nil {
+		t.Fatal("zone file not written:", err)
+	}
+	if !strings.Contains(string(data), "enrtree-root:v1") {
+		t.Errorf("zone file misses the tree root:\n%s", data)
+	}
+}
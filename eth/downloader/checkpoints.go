@@ -0,0 +1,89 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+)
+
+var errCheckpointMismatch = errors.New("chain does not include sync checkpoint")
+
+// SetSyncCheckpoints configures the blocks that any chain synced from the network
+// must include. Peers serving a chain conflicting with them are rejected. It must
+// be called before syncing starts.
+func (d *Downloader) SetSyncCheckpoints(checkpoints []ctypes.SyncCheckpoint) error {
+	sorted, err := ctypes.SortSyncCheckpoints(checkpoints)
+	if err != nil {
+		return err
+	}
+	d.syncCheckpoints = sorted
+	return nil
+}
+
+// checkSyncCheckpoints ensures that the chain of the peer includes all the sync
+// checkpoints between the common ancestor and its head. Checkpoints below the
+// ancestor are already part of the local chain, and ones above the head of the
+// peer cannot be checked yet.
+func (d *Downloader) checkSyncCheckpoints(p *peerConnection, origin, head uint64) error {
+	for _, checkpoint := range d.syncCheckpoints {
+		if checkpoint.Number <= origin {
+			continue
+		}
+		if checkpoint.Number > head {
+			break
+		}
+		headers, hashes, err := d.fetchHeadersByNumber(p, checkpoint.Number, 1, 0, false)
+		if err != nil {
+			return fmt.Errorf("%w: checkpoint header request failed: %v", errBadPeer, err)
+		}
+		if len(headers) != 1 {
+			return fmt.Errorf("%w: checkpoint #%d not delivered", errBadPeer, checkpoint.Number)
+		}
+		if hashes[0] != checkpoint.Hash {
+			p.log.Warn("Peer chain conflicts with sync checkpoint", "number", checkpoint.Number, "have", hashes[0], "want", checkpoint.Hash)
+			return fmt.Errorf("%w: #%d have %x, want %x", errCheckpointMismatch, checkpoint.Number, hashes[0], checkpoint.Hash)
+		}
+	}
+	return nil
+}
+
+// verifySyncCheckpoints ensures that a batch of headers does not conflict with
+// any of the sync checkpoints.
+func (d *Downloader) verifySyncCheckpoints(headers []*types.Header, hashes []common.Hash) error {
+	if len(d.syncCheckpoints) == 0 {
+		return nil
+	}
+	for i, header := range headers {
+		number := header.Number.Uint64()
+		n := sort.Search(len(d.syncCheckpoints), func(n int) bool {
+			return d.syncCheckpoints[n].Number >= number
+		})
+		if n == len(d.syncCheckpoints) || d.syncCheckpoints[n].Number != number {
+			continue
+		}
+		if want := d.syncCheckpoints[n].Hash; hashes[i] != want {
+			return fmt.Errorf("%w: #%d have %x, want %x", errCheckpointMismatch, number, hashes[i], want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+)
+
+// Tests that chains including the sync checkpoints are synced, and chains
+// conflicting with them are rejected.
+func TestSyncCheckpoints68Full(t *testing.T) { testSyncCheckpoints(t, eth.ETH68, FullSync) }
+func TestSyncCheckpoints68Snap(t *testing.T) { testSyncCheckpoints(t, eth.ETH68, SnapSync) }
+
+func testSyncCheckpoints(t *testing.T, protocol uint, mode SyncMode) {
+	chain := testChainBase.shorten(blockCacheMaxItems - 15)
+	fork := testChainForkLightA.shorten(len(testChainBase.blocks) + 80)
+	checkpoint := ctypes.SyncCheckpoint{
+		Number: uint64(len(testChainBase.blocks) + 50),
+		Hash:   fork.blocks[len(testChainBase.blocks)+50].Hash(),
+	}
+	// Sync a chain conflicting with a checkpoint
+	tester := newTester(t)
+	defer tester.terminate()
+
+	if err := tester.downloader.SetSyncCheckpoints([]ctypes.SyncCheckpoint{checkpoint}); err != nil {
+		t.Fatalf("failed to set checkpoints: %v", err)
+	}
+	tester.newPeer("fork", protocol, fork.blocks[1:])
+
+	bad := testChainForkLightB.shorten(len(testChainBase.blocks) + 81)
+	tester.newPeer("bad", protocol, bad.blocks[1:])
+	if err := tester.sync("bad", nil, mode); !errors.Is(err, errCheckpointMismatch) {
+		t.Fatalf("conflicting chain synced: %v", err)
+	}
+	// Checkpoints above the peer's head cannot be checked yet, so sync them
+	tester.newPeer("short", protocol, chain.blocks[1:])
+	if err := tester.sync("short", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	// Sync the chain including the checkpoint
+	if err := tester.sync("fork", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, len(fork.blocks))
+}
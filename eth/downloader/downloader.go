@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/vars"
 	"github.com/ethereum/go-ethereum/triedb"
 )
@@ -96,8 +97,9 @@ type badBlockFn func(invalid *types.Header, origin *types.Header)
 // headerTask is a set of downloaded headers to queue along with their precomputed
 // hashes to avoid constant rehashing.
 type headerTask struct {
-	headers []*types.Header
-	hashes  []common.Hash
+	headers  []*types.Header
+	hashes   []common.Hash
+	verified bool // Whether the headers were already fully verified by a header-first sync
 }
 
 type Downloader struct {
@@ -137,6 +139,12 @@ type Downloader struct {
 	// Skeleton sync
 	skeleton *skeleton // Header skeleton to backfill the chain with (eth2 mode)
 
+	// Header-first sync
+	headerFirst atomic.Bool  // Whether snap sync verifies all headers before retrieving content (PoW mode)
+	powSkeleton *powSkeleton // Progress of the fully verified header chain
+
+	syncCheckpoints []ctypes.SyncCheckpoint // Blocks any synced chain must include, sorted by number
+
 	// State sync
 	pivotHeader *types.Header // Pivot block header to dynamically push the syncing state root
 	pivotLock   sync.RWMutex  // Lock protecting pivot header reads from updates
@@ -248,6 +256,7 @@ func New(checkpoint uint64, stateDb ethdb.Database, mux *event.TypeMux, chain Bl
 	}
 	// Create the post-merge skeleton syncer and start the process
 	dl.skeleton = newSkeleton(stateDb, dl.peers, dropPeer, newBeaconBackfiller(dl, success))
+	dl.powSkeleton = newPoWSkeleton(stateDb, chain)
 
 	go dl.stateFetcher()
 	return dl
@@ -338,10 +347,25 @@ func (d *Downloader) UnregisterPeer(id string) error {
 	return nil
 }
 
+// PeerRoundtrip retrieves the smoothed request round trip time measured for a
+// sync peer. The flag is false if the peer is unknown or was never measured.
+func (d *Downloader) PeerRoundtrip(id string) (time.Duration, bool) {
+	if p := d.peers.Peer(id); p != nil {
+		return p.rates.Roundtrip()
+	}
+	return 0, false
+}
+
 // LegacySync tries to sync up our local block chain with a remote peer, both
 // adding various sanity checks as well as wrapping it with various log entries.
 func (d *Downloader) LegacySync(id string, head common.Hash, td, ttd *big.Int, mode SyncMode) error {
-	err := d.synchronise(id, head, td, ttd, mode, false, nil)
+	var err error
+	if mode == SnapSync && d.headerFirst.Load() {
+		err = d.syncHeadersFirst(id, head, td, ttd)
+	}
+	if err == nil {
+		err = d.synchronise(id, head, td, ttd, mode, false, nil)
+	}
 
 	switch err {
 	case nil, errBusy, errCanceled:
@@ -349,7 +373,8 @@ func (d *Downloader) LegacySync(id string, head common.Hash, td, ttd *big.Int, m
 	}
 	if errors.Is(err, errInvalidChain) || errors.Is(err, errBadPeer) || errors.Is(err, errTimeout) ||
 		errors.Is(err, errStallingPeer) || errors.Is(err, errUnsyncedPeer) || errors.Is(err, errEmptyHeaderSet) ||
-		errors.Is(err, errPeersUnavailable) || errors.Is(err, errTooOld) || errors.Is(err, errInvalidAncestor) {
+		errors.Is(err, errPeersUnavailable) || errors.Is(err, errTooOld) || errors.Is(err, errInvalidAncestor) ||
+		errors.Is(err, errCheckpointMismatch) {
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
@@ -547,6 +572,9 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td, ttd *
 		if err != nil {
 			return err
 		}
+		if err = d.checkSyncCheckpoints(p, origin, latest.Number.Uint64()); err != nil {
+			return err
+		}
 	} else {
 		// In beacon mode, use the skeleton chain for the ancestor lookup
 		origin, err = d.findBeaconAncestor()
@@ -643,6 +671,15 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td, ttd *
 	if !beaconMode {
 		// In legacy mode, headers are retrieved from the network
 		headerFetcher = func() error { return d.fetchHeaders(p, origin+1, latest.Number.Uint64()) }
+
+		// In header-first mode, headers verified in the header phase are taken
+		// from the local chain, up until the pivot which is always retrieved
+		if mode == SnapSync && d.headerFirst.Load() && pivot.Number.Uint64() > 0 {
+			verified := min(d.powSkeleton.Head().Number, latest.Number.Uint64(), pivot.Number.Uint64()-1)
+			headerFetcher = func() error {
+				return d.fetchVerifiedHeaders(p, origin+1, verified, latest.Number.Uint64())
+			}
+		}
 	} else {
 		// In beacon mode, headers are served by the skeleton syncer
 		headerFetcher = func() error { return d.fetchBeaconHeaders(origin + 1) }
@@ -1455,7 +1492,7 @@ func (d *Downloader) processHeaders(origin uint64, td, ttd *big.Int, beaconMode
 				return nil
 			}
 			// Otherwise split the chunk of headers into batches and process them
-			headers, hashes := task.headers, task.hashes
+			headers, hashes, verified := task.headers, task.hashes, task.verified
 
 			gotHeaders = true
 			for len(headers) > 0 {
@@ -1474,6 +1511,15 @@ func (d *Downloader) processHeaders(origin uint64, td, ttd *big.Int, beaconMode
 				chunkHeaders := headers[:limit]
 				chunkHashes := hashes[:limit]
 
+				// Reject chains conflicting with the sync checkpoints
+				if !beaconMode {
+					if err := d.verifySyncCheckpoints(chunkHeaders, chunkHashes); err != nil {
+						rollbackErr = err
+						log.Warn("Sync checkpoint violated", "err", err)
+						return fmt.Errorf("%w: %v", errInvalidChain, err)
+					}
+				}
+
 				// In case of header only syncing, validate the chunk immediately
 				if mode == SnapSync || mode == LightSync {
 					// If we're importing pure headers, verify based on their recentness
@@ -1489,6 +1535,13 @@ func (d *Downloader) processHeaders(origin uint64, td, ttd *big.Int, beaconMode
 					if chunkHeaders[len(chunkHeaders)-1].Number.Uint64()+uint64(fsHeaderForceVerify) > pivot {
 						frequency = 1
 					}
+					// Header-first sync verifies every seal, but only once
+					if d.headerFirst.Load() && !beaconMode {
+						frequency = 1
+						if verified {
+							frequency = 0
+						}
+					}
 					// Although the received headers might be all valid, a legacy
 					// PoW/PoA sync must not accept post-merge headers. Make sure
 					// that any transition is rejected at this point.
@@ -1532,6 +1585,9 @@ func (d *Downloader) processHeaders(origin uint64, td, ttd *big.Int, beaconMode
 							return fmt.Errorf("%w: %v", errInvalidChain, err)
 						}
 						// All verifications passed, track all headers within the allowed limits
+						if d.headerFirst.Load() && !beaconMode {
+							d.powSkeleton.Advance(chunkHeaders[len(chunkHeaders)-1])
+						}
 						if mode == SnapSync {
 							head := chunkHeaders[len(chunkHeaders)-1].Number.Uint64()
 							if head-rollback > uint64(fsHeaderSafetyNet) {
@@ -0,0 +1,220 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// powSkeletonStatus is the database representation of the header-first sync
+// progress: the highest canonical header whose entire ancestry had every seal
+// verified, along with its total difficulty.
+type powSkeletonStatus struct {
+	Number uint64      // Block number of the verified head header
+	Hash   common.Hash // Block hash of the verified head header
+	Td     *big.Int    // Total difficulty of the verified head header
+}
+
+// powSkeleton tracks the progress of a header-first proof-of-work sync.
+//
+// Unlike the beacon skeleton, which needs a scratch space because headers are
+// retrieved backwards from an authoritative head, PoW headers are downloaded
+// forward and can be verified on the spot, so they are inserted straight into
+// the header chain. Snap sync however only verifies a sample of the seals, so
+// the header chain alone does not tell how far headers were fully verified. The
+// skeleton persists that boundary, allowing a restarted sync to keep its header
+// progress, and to discard any header above it that was not fully verified.
+type powSkeleton struct {
+	db    ethdb.Database // Database backing the header chain and the sync status
+	chain BlockChain     // Chain whose headers are being verified
+
+	lock sync.Mutex // Lock protecting the status updates
+}
+
+// newPoWSkeleton creates a header-first sync progress tracker.
+func newPoWSkeleton(db ethdb.Database, chain BlockChain) *powSkeleton {
+	return &powSkeleton{db: db, chain: chain}
+}
+
+// Head returns the highest header known to be fully verified.
+func (s *powSkeleton) Head() *powSkeletonStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.load()
+}
+
+// load retrieves the persisted progress, falling back to the local chain if the
+// stored header is not canonical anymore. Blocks with their content imported
+// are always considered verified, as the headers below them are final anyway.
+//
+// The progress is not cached, since the chain may be rewound underneath by the
+// snap sync rollback or by the user.
+func (s *powSkeleton) load() *powSkeletonStatus {
+	var status *powSkeletonStatus
+	if blob := rawdb.ReadPoWSkeletonSyncStatus(s.db); len(blob) > 0 {
+		status = new(powSkeletonStatus)
+		if err := json.Unmarshal(blob, status); err != nil {
+			log.Error("Failed to decode PoW skeleton status", "err", err)
+			status = nil
+		}
+	}
+	if status != nil && rawdb.ReadCanonicalHash(s.db, status.Number) != status.Hash {
+		// The header chain was rolled back or reorged below the verified head.
+		// If it was rolled back, its head is still on the verified chain.
+		if head := s.chain.CurrentHeader(); head.Number.Uint64() < status.Number {
+			log.Info("PoW skeleton rolled back", "number", status.Number, "hash", status.Hash, "head", head.Number)
+			status = s.statusOf(head)
+		} else {
+			log.Warn("PoW skeleton reorged, resetting", "number", status.Number, "hash", status.Hash)
+			status = nil
+		}
+	}
+	for _, head := range []*types.Header{s.chain.CurrentBlock(), s.chain.CurrentSnapBlock()} {
+		if status == nil || head.Number.Uint64() > status.Number {
+			status = s.statusOf(head)
+		}
+	}
+	return status
+}
+
+// statusOf creates the skeleton status of a local header.
+func (s *powSkeleton) statusOf(header *types.Header) *powSkeletonStatus {
+	hash := header.Hash()
+	return &powSkeletonStatus{
+		Number: header.Number.Uint64(),
+		Hash:   hash,
+		Td:     s.chain.GetTd(hash, header.Number.Uint64()),
+	}
+}
+
+// Advance moves the verified head forward to the given header, assuming that
+// it and all of its ancestors were fully verified. Headers that did not become
+// canonical are ignored.
+func (s *powSkeleton) Advance(header *types.Header) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	number, hash := header.Number.Uint64(), header.Hash()
+	if number <= s.load().Number || rawdb.ReadCanonicalHash(s.db, number) != hash {
+		return
+	}
+	td := s.chain.GetTd(hash, number)
+	if td == nil {
+		return
+	}
+	s.write(&powSkeletonStatus{Number: number, Hash: hash, Td: td})
+}
+
+// Prune drops any header above the verified head from the header chain, so that
+// headers imported without full verification are retrieved and verified again.
+func (s *powSkeleton) Prune() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	status := s.load()
+	head := s.chain.CurrentHeader().Number.Uint64()
+	if head <= status.Number {
+		return nil
+	}
+	log.Warn("Discarding partially verified headers", "verified", status.Number, "head", head)
+	if err := s.chain.SetHead(status.Number); err != nil {
+		return err
+	}
+	s.write(status)
+	return nil
+}
+
+// write persists the given status.
+func (s *powSkeleton) write(status *powSkeletonStatus) {
+	blob, err := json.Marshal(status)
+	if err != nil {
+		panic(err) // This can only fail during implementation
+	}
+	rawdb.WritePoWSkeletonSyncStatus(s.db, blob)
+}
+
+// SetHeaderFirst enables or disables header-first snap sync. When enabled, snap
+// sync on a proof-of-work chain first downloads the entire header chain of the
+// peer with every seal verified, and only then retrieves the block content and
+// state. Otherwise only a sample of the seals below the pivot are verified.
+func (d *Downloader) SetHeaderFirst(enabled bool) {
+	d.headerFirst.Store(enabled)
+}
+
+// syncHeadersFirst runs the header phase of a header-first snap sync, retrieving
+// and fully verifying the header chain of the peer. It reuses the light sync mode,
+// which imports headers only and verifies each one of them.
+func (d *Downloader) syncHeadersFirst(id string, head common.Hash, td, ttd *big.Int) error {
+	verified := d.powSkeleton.Head()
+	if verified.Td != nil && verified.Td.Cmp(td) >= 0 {
+		return nil
+	}
+	if err := d.powSkeleton.Prune(); err != nil {
+		return err
+	}
+	log.Info("Syncing verified headers ahead of chain content", "peer", id, "verified", verified.Number)
+	return d.synchronise(id, head, td, ttd, LightSync, false, nil)
+}
+
+// fetchVerifiedHeaders feeds the header processor with the local headers already
+// verified by the header phase of a header-first sync, up to the given number,
+// then keeps retrieving the remaining headers from the peer. The local headers
+// are only used if the peer agrees with them, otherwise they are all retrieved
+// from the network anew.
+func (d *Downloader) fetchVerifiedHeaders(p *peerConnection, from, to, head uint64) error {
+	if to >= from {
+		headers, hashes, err := d.fetchHeadersByNumber(p, to, 1, 0, false)
+		if err != nil || len(headers) != 1 {
+			p.log.Debug("Failed to retrieve verified header boundary", "number", to, "err", err)
+			to = from - 1
+		} else if hashes[0] != rawdb.ReadCanonicalHash(d.stateDB, to) {
+			p.log.Debug("Peer disagrees with verified headers", "number", to, "hash", hashes[0])
+			to = from - 1
+		}
+	}
+	for from <= to {
+		var (
+			headers []*types.Header
+			hashes  []common.Hash
+		)
+		for ; from <= to && len(headers) < MaxHeaderFetch; from++ {
+			hash := rawdb.ReadCanonicalHash(d.stateDB, from)
+			header := rawdb.ReadHeader(d.stateDB, hash, from)
+			if header == nil {
+				return fmt.Errorf("verified header #%d missing", from)
+			}
+			headers, hashes = append(headers, header), append(hashes, hash)
+		}
+		p.log.Trace("Scheduling verified headers", "count", len(headers), "from", headers[0].Number)
+		select {
+		case d.headerProcCh <- &headerTask{headers: headers, hashes: hashes, verified: true}:
+		case <-d.cancelCh:
+			return errCanceled
+		}
+	}
+	return d.fetchHeaders(p, from, head)
+}
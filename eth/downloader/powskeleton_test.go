@@ -0,0 +1,235 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+)
+
+// headerNumberRequest is a header retrieval by number sent to a download tester peer.
+type headerNumberRequest struct {
+	origin uint64
+	amount int
+	skip   int
+}
+
+// headerFirstTestPeer is a download tester peer recording the headers and block
+// content retrieved from it.
+type headerFirstTestPeer struct {
+	*downloadTesterPeer
+
+	lock    sync.Mutex
+	headers []headerNumberRequest
+	content bool
+}
+
+// newHeaderFirstTestPeer registers a recording peer serving the given blocks.
+func newHeaderFirstTestPeer(tester *downloadTester, id string, blocks []*types.Block) *headerFirstTestPeer {
+	peer := &headerFirstTestPeer{downloadTesterPeer: tester.newPeer(id, eth.ETH68, blocks)}
+	peer.register()
+	return peer
+}
+
+// register (re)registers the peer with the downloader, replacing the plain tester
+// peer and clearing the recorded requests.
+func (p *headerFirstTestPeer) register() {
+	p.lock.Lock()
+	p.headers, p.content = nil, false
+	p.lock.Unlock()
+
+	p.dl.dropPeer(p.id)
+	p.dl.lock.Lock()
+	p.dl.peers[p.id] = p.downloadTesterPeer
+	p.dl.lock.Unlock()
+
+	if err := p.dl.downloader.RegisterPeer(p.id, eth.ETH68, p); err != nil {
+		panic(err)
+	}
+	if err := p.dl.downloader.SnapSyncer.Register(p); err != nil {
+		panic(err)
+	}
+}
+
+func (p *headerFirstTestPeer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool, sink chan *eth.Response) (*eth.Request, error) {
+	p.lock.Lock()
+	p.headers = append(p.headers, headerNumberRequest{origin: origin, amount: amount, skip: skip})
+	p.lock.Unlock()
+	return p.downloadTesterPeer.RequestHeadersByNumber(origin, amount, skip, reverse, sink)
+}
+
+func (p *headerFirstTestPeer) RequestBodies(hashes []common.Hash, sink chan *eth.Response) (*eth.Request, error) {
+	p.lock.Lock()
+	p.content = true
+	p.lock.Unlock()
+	return p.downloadTesterPeer.RequestBodies(hashes, sink)
+}
+
+func (p *headerFirstTestPeer) RequestReceipts(hashes []common.Hash, sink chan *eth.Response) (*eth.Request, error) {
+	p.lock.Lock()
+	p.content = true
+	p.lock.Unlock()
+	return p.downloadTesterPeer.RequestReceipts(hashes, sink)
+}
+
+// Tests that a header-first snap sync retrieves the entire chain and tracks the
+// fully verified headers.
+func TestHeaderFirstSync68(t *testing.T) {
+	tester := newTester(t)
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(blockCacheMaxItems - 15)
+	peer := tester.newPeer("peer", eth.ETH68, chain.blocks[1:])
+
+	tester.downloader.SetHeaderFirst(true)
+	head := peer.chain.CurrentBlock()
+	td := peer.chain.GetTd(head.Hash(), head.Number.Uint64())
+	if err := tester.downloader.LegacySync("peer", head.Hash(), td, nil, SnapSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, len(chain.blocks))
+
+	if verified := tester.downloader.powSkeleton.Head(); verified.Hash != head.Hash() || verified.Td.Cmp(td) != 0 {
+		t.Errorf("verified head mismatch: have #%d [%x], want #%d [%x]", verified.Number, verified.Hash, head.Number, head.Hash())
+	}
+}
+
+// Tests that a header failing verification aborts a header-first sync before any
+// block content is retrieved, even if the sampled seal checks of a plain snap sync
+// would have missed it.
+func TestHeaderFirstInvalidHeader68(t *testing.T) {
+	const invalid = 301 // Not a multiple of the header check frequency
+
+	tester := newTesterWithEngine(t, ethash.NewFakeFailer(invalid), nil)
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(800)
+	peer := newHeaderFirstTestPeer(tester, "peer", chain.blocks[1:])
+
+	tester.downloader.SetHeaderFirst(true)
+	head := peer.chain.CurrentBlock()
+	td := peer.chain.GetTd(head.Hash(), head.Number.Uint64())
+	if err := tester.downloader.LegacySync("peer", head.Hash(), td, nil, SnapSync); !errors.Is(err, errInvalidChain) {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, errInvalidChain)
+	}
+	if peer.content {
+		t.Error("block content retrieved despite an invalid header")
+	}
+	if number := tester.chain.CurrentHeader().Number.Uint64(); number >= invalid {
+		t.Errorf("header chain extended past the invalid header: have %d, want < %d", number, invalid)
+	}
+	if number := tester.downloader.powSkeleton.Head().Number; number >= invalid {
+		t.Errorf("verified head past the invalid header: have %d, want < %d", number, invalid)
+	}
+}
+
+// Tests that a header-first sync interrupted during its header phase resumes from
+// the verified headers instead of retrieving them again, discarding the headers
+// imported without full verification.
+func TestHeaderFirstResume68(t *testing.T) {
+	tester := newTester(t)
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(800)
+	peer := newHeaderFirstTestPeer(tester, "peer", chain.blocks[1:])
+
+	// Leave the header chain the way an interrupted header phase does, with the
+	// verified head persisted and partially verified headers above it
+	headers := make([]*types.Header, 0, len(chain.blocks)-1)
+	for _, block := range chain.blocks[1:] {
+		headers = append(headers, block.Header())
+	}
+	const verified = 400
+	if _, err := tester.chain.InsertHeaderChain(headers[:verified], 1); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	tester.downloader.powSkeleton.Advance(headers[verified-1])
+	if _, err := tester.chain.InsertHeaderChain(headers[verified:600], fsHeaderCheckFrequency); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	tester.downloader.SetHeaderFirst(true)
+	head := peer.chain.CurrentBlock()
+	td := peer.chain.GetTd(head.Hash(), head.Number.Uint64())
+	if err := tester.downloader.LegacySync("peer", head.Hash(), td, nil, SnapSync); err != nil {
+		t.Fatalf("failed to resume sync: %v", err)
+	}
+	assertOwnChain(t, tester, len(chain.blocks))
+
+	var resumed bool
+	for _, req := range peer.headers {
+		if req.skip == 0 && req.amount > 1 && req.origin <= verified {
+			t.Errorf("verified headers retrieved again: %d headers from #%d, verified up to #%d", req.amount, req.origin, verified)
+		}
+		if req.origin == verified+1 {
+			resumed = true
+		}
+	}
+	if !resumed {
+		t.Errorf("partially verified headers not retrieved again from #%d", verified+1)
+	}
+}
+
+// Tests that headers inserted without being fully verified are discarded before
+// a header-first sync, and that the verified head follows the canonical chain.
+func TestPoWSkeletonPrune(t *testing.T) {
+	tester := newTester(t)
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(200)
+	headers := make([]*types.Header, 0, len(chain.blocks)-1)
+	for _, block := range chain.blocks[1:] {
+		headers = append(headers, block.Header())
+	}
+	skeleton := tester.downloader.powSkeleton
+
+	// Insert some headers with full verification, the rest sampled
+	if _, err := tester.chain.InsertHeaderChain(headers[:100], 1); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	skeleton.Advance(headers[99])
+	if _, err := tester.chain.InsertHeaderChain(headers[100:], fsHeaderCheckFrequency); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	// Advancing backwards or to unknown headers must be a noop
+	fork := types.CopyHeader(headers[149])
+	fork.Extra = []byte("fork")
+
+	skeleton.Advance(headers[49])
+	skeleton.Advance(fork)
+	if number := skeleton.Head().Number; number != 100 {
+		t.Fatalf("verified head mismatch: have %d, want %d", number, 100)
+	}
+	if err := skeleton.Prune(); err != nil {
+		t.Fatalf("failed to prune headers: %v", err)
+	}
+	if number := tester.chain.CurrentHeader().Number.Uint64(); number != 100 {
+		t.Errorf("header chain head mismatch: have %d, want %d", number, 100)
+	}
+	// Rolling the chain back below the verified head must rewind it too
+	if err := tester.chain.SetHead(60); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if verified := skeleton.Head(); verified.Number != 60 || verified.Hash != headers[59].Hash() {
+		t.Errorf("verified head mismatch after rollback: have #%d [%x], want #%d [%x]", verified.Number, verified.Hash, 60, headers[59].Hash())
+	}
+}
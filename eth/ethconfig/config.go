@@ -34,13 +34,16 @@ import (
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/rpccache"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/types/genesisT"
 	"github.com/ethereum/go-ethereum/params/vars"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 // FullNodeGPO contains default gasprice oracle settings for full node.
@@ -65,26 +68,31 @@ var Defaults = Config{
 		DatasetsOnDisk:   2,
 		DatasetsLockMmap: false,
 	},
-	NetworkId:          0, // enable auto configuration of networkID == chainID
-	ProtocolVersions:   vars.DefaultProtocolVersions,
-	TxLookupLimit:      2350000,
-	TransactionHistory: 2350000,
-	StateHistory:       vars.FullImmutabilityThreshold,
-	LightPeers:         100,
-	UltraLightFraction: 75,
-	DatabaseCache:      512,
-	TrieCleanCache:     154,
-	TrieDirtyCache:     256,
-	TrieTimeout:        60 * time.Minute,
-	SnapshotCache:      102,
-	FilterLogCacheSize: 32,
-	Miner:              miner.DefaultConfig,
-	TxPool:             legacypool.DefaultConfig,
-	BlobPool:           blobpool.DefaultConfig,
-	RPCGasCap:          50000000,
-	RPCEVMTimeout:      5 * time.Second,
-	GPO:                FullNodeGPO,
-	RPCTxFeeCap:        1, // 1 ether
+	NetworkId:           0, // enable auto configuration of networkID == chainID
+	ProtocolVersions:    vars.DefaultProtocolVersions,
+	TxLookupLimit:       2350000,
+	TransactionHistory:  2350000,
+	StateHistory:        vars.FullImmutabilityThreshold,
+	LightPeers:          100,
+	UltraLightFraction:  75,
+	DatabaseCache:       512,
+	TrieCleanCache:      154,
+	TrieDirtyCache:      256,
+	TrieTimeout:         60 * time.Minute,
+	SnapshotCache:       102,
+	FilterLogCacheSize:  32,
+	Miner:               miner.DefaultConfig,
+	TxPool:              legacypool.DefaultConfig,
+	BlobPool:            blobpool.DefaultConfig,
+	RPCGasCap:           50000000,
+	RPCEVMTimeout:       5 * time.Second,
+	GPO:                 FullNodeGPO,
+	RPCTxFeeCap:         1, // 1 ether
+	RPCResultCacheDepth: rpccache.DefaultDepth,
+	DNSTree: DNSTreeConfig{
+		Interval: 30 * time.Minute,
+		TTL:      30 * 60,
+	},
 }
 
 func init() {
@@ -110,6 +118,93 @@ func init() {
 
 //go:generate go run github.com/fjl/gencodec -type Config -formats toml -out gen_config.go
 
+// DefaultArtificialFinalitySafety are the artificial finality safety conditions
+// used if none are configured.
+var DefaultArtificialFinalitySafety = ArtificialFinalitySafety{
+	MinPeers:      5,
+	MinSubnets:    3,
+	MinClients:    2,
+	StaleInterval: time.Second * time.Duration(30*vars.DurationLimit.Uint64()),
+}
+
+// ArtificialFinalitySafety holds the conditions a synced node must meet for
+// artificial finality (ECBP-1100) to be enabled. Failing any of them disables
+// it again. A zero field disables the respective condition.
+type ArtificialFinalitySafety struct {
+	// MinPeers is the minimum number of connected peers. A minimum number of
+	// peer connections mitigates the risk of lower-powered eclipse attacks.
+	MinPeers int `json:"minPeers"`
+
+	// MinSubnets is the minimum number of distinct /16 subnets the connected
+	// peers must be spread across, so that an attacker controlling a single
+	// network range cannot satisfy MinPeers on its own.
+	MinSubnets int `json:"minSubnets"`
+
+	// MinClients is the minimum number of distinct client implementations
+	// among the connected peers.
+	MinClients int `json:"minClients"`
+
+	// StaleInterval is the maximum age of the local head. If the head grows
+	// older, we could be on an attacker's chain getting starved, so artificial
+	// finality is disabled to prevent being forever stuck on a dead chain.
+	StaleInterval time.Duration `json:"staleInterval"`
+}
+
+// Unmet returns the conditions not satisfied by a node with the given number of
+// peers, distinct subnets and client implementations among them, and age of its
+// head block.
+func (s ArtificialFinalitySafety) Unmet(peers, subnets, clients int, headAge time.Duration) []string {
+	var unmet []string
+	if peers < s.MinPeers {
+		unmet = append(unmet, "low peers")
+	}
+	if subnets < s.MinSubnets {
+		unmet = append(unmet, "low subnet diversity")
+	}
+	if clients < s.MinClients {
+		unmet = append(unmet, "low client diversity")
+	}
+	if s.StaleInterval > 0 && headAge > s.StaleInterval {
+		unmet = append(unmet, "stale head")
+	}
+	return unmet
+}
+
+// TxPolicyConfig holds the built-in transaction propagation policies of the
+// node. The zero value propagates transactions to all peers as soon as they
+// enter the pool.
+type TxPolicyConfig struct {
+	// TrustedOnly restricts transaction propagation to trusted peers.
+	TrustedOnly bool
+
+	// LocalDelay postpones the propagation of local transactions, giving the
+	// node a head start before they are visible on the network.
+	LocalDelay time.Duration
+
+	// InvalidLimit is the number of invalid transactions a peer may deliver
+	// before its transaction announcements and broadcasts are ignored. Zero
+	// disables the limit.
+	InvalidLimit int
+
+	// PeerBandwidth is the number of bytes per second of transactions sent to
+	// each peer in full. Transactions exceeding the budget are announced only.
+	// Zero disables the budget.
+	PeerBandwidth uint64
+}
+
+// DNSTreeConfig holds the settings of the node list publisher, which crawls the
+// network of the node and publishes its members as a signed EIP-1459 node tree.
+// The publisher is disabled if no domain is set.
+type DNSTreeConfig struct {
+	Domain   string        // Domain the tree is published under
+	KeyFile  string        // File holding the private key signing the tree
+	Links    []string      // enrtree:// URLs of other trees linked from the tree
+	Listen   string        // Address of the built-in DNS server, disabled if empty
+	ZoneFile string        // Zone file written on every update, disabled if empty
+	Interval time.Duration // Time between tree updates
+	TTL      uint32        // TTL of the published records, in seconds
+}
+
 // Config contains configuration options for ETH and LES protocols.
 type Config struct {
 	// The genesis block, which is inserted if the database is empty.
@@ -121,6 +216,10 @@ type Config struct {
 	ProtocolVersions []uint // Protocol versions are the supported versions of the eth protocol (first is primary).
 	SyncMode         downloader.SyncMode
 
+	// HeaderFirstSync makes snap sync on proof-of-work chains download and fully
+	// verify the entire header chain before retrieving any block content.
+	HeaderFirstSync bool `toml:",omitempty"`
+
 	// This can be set to list of enrtree:// URLs which will be queried for
 	// for nodes to connect to.
 	EthDiscoveryURLs  []string
@@ -133,6 +232,7 @@ type Config struct {
 	TxLookupLimit      uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	TransactionHistory uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	StateHistory       uint64 `toml:",omitempty"` // The maximum number of blocks from head whose state histories are reserved.
+	ReceiptHistory     uint64 `toml:",omitempty"` // The maximum number of blocks from head whose receipts are stored, older ones are regenerated on demand.
 
 	// State scheme represents the scheme used to store ethereum states and trie
 	// nodes on top. It can be 'hash', 'path', or none which means use the scheme
@@ -209,12 +309,32 @@ type Config struct {
 	// send-transaction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// RPCResultCache is the memory allowance (MB) of the cache for RPC results
+	// about blocks buried below RPCResultCacheDepth. Zero disables the cache.
+	RPCResultCache int `toml:",omitempty"`
+
+	// RPCResultCacheDepth is the number of blocks a result must be buried below
+	// the head before it is considered immutable and cached.
+	RPCResultCacheDepth uint64 `toml:",omitempty"`
+
+	// RPCResultCacheDisk enables persisting cached RPC results to disk.
+	RPCResultCacheDisk bool `toml:",omitempty"`
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *ctypes.TrustedCheckpoint `toml:",omitempty"`
 
 	// CheckpointOracle is the configuration for checkpoint oracle.
 	CheckpointOracle *ctypes.CheckpointOracleConfig `toml:",omitempty"`
 
+	// SyncCheckpoints are blocks the chain synced from the network must include.
+	SyncCheckpoints []ctypes.SyncCheckpoint `toml:",omitempty"`
+
+	// CheckpointList is a list of additional sync checkpoints, only accepted if
+	// signed by at least CheckpointThreshold of the CheckpointSigners.
+	CheckpointList      *ctypes.SignedCheckpoints `toml:",omitempty"`
+	CheckpointSigners   []common.Address          `toml:",omitempty"`
+	CheckpointThreshold int                       `toml:",omitempty"`
+
 	// Manual configuration field for ECBP1100 activation number. Used for modifying genesis config via CLI flag.
 	OverrideECBP1100 *uint64 `toml:",omitempty"`
 	// Manual configuration field for ECBP1100's disablement block number. Used for modifying genesis config via CLI flag.
@@ -224,6 +344,16 @@ type Config struct {
 	// When this value is *true, ECBP100 will not (ever) be disabled; when *false, it will never be enabled.
 	ECBP1100NoDisable *bool `toml:",omitempty"`
 
+	// ECBP1100Safety are the conditions under which a synced node enables
+	// artificial finality. If nil, DefaultArtificialFinalitySafety applies.
+	ECBP1100Safety *ArtificialFinalitySafety `toml:",omitempty"`
+
+	// TxPolicy are the transaction propagation policies of the node.
+	TxPolicy TxPolicyConfig
+
+	// DNSTree configures publishing the nodes of the network as a DNS node tree.
+	DNSTree DNSTreeConfig
+
 	// OverrideShanghai (TODO: remove after the fork)
 	OverrideShanghai *uint64 `toml:",omitempty"`
 
@@ -232,6 +362,42 @@ type Config struct {
 
 	// OverrideVerkle (TODO: remove after the fork)
 	OverrideVerkle *uint64 `toml:",omitempty"`
+
+	// ChainConfigFile is a genesis or chain configuration file re-applied to the
+	// running node on demand, to schedule future transitions without a restart.
+	ChainConfigFile string `toml:",omitempty"`
+
+	// Instance, if set, hosts the backend as an additional network of a node which
+	// already runs another one. Its data is kept in a datadir subdirectory of this
+	// name, its RPC namespaces are prefixed with "<Instance>." and it connects to
+	// its network through a dedicated P2P server configured by InstanceP2P.
+	Instance string `toml:",omitempty"`
+
+	// InstanceP2P is the configuration of the dedicated P2P server of an instance.
+	InstanceP2P *p2p.Config `toml:"-"`
+}
+
+// ResolvePath returns the location of a relative datadir path for the backend,
+// which is nested within the instance subdirectory for additional networks.
+func (c *Config) ResolvePath(path string) string {
+	if c.Instance == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.Instance, path)
+}
+
+// Namespaced prefixes the namespaces of the given APIs with the instance name, so
+// that the APIs of several backends can be served by the same RPC endpoints.
+func (c *Config) Namespaced(apis []rpc.API) []rpc.API {
+	if c.Instance == "" {
+		return apis
+	}
+	namespaced := make([]rpc.API, len(apis))
+	for i, api := range apis {
+		api.Namespace = c.Instance + "." + api.Namespace
+		namespaced[i] = api
+	}
+	return namespaced
 }
 
 // CreateConsensusEngine creates a consensus engine for the given chain configuration.
@@ -12,6 +12,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/miner"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/types/genesisT"
 )
@@ -23,6 +24,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NetworkId                  uint64
 		ProtocolVersions           []uint
 		SyncMode                   downloader.SyncMode
+		HeaderFirstSync            bool `toml:",omitempty"`
 		EthDiscoveryURLs           []string
 		SnapDiscoveryURLs          []string
 		NoPruning                  bool
@@ -30,6 +32,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TxLookupLimit              uint64                 `toml:",omitempty"`
 		TransactionHistory         uint64                 `toml:",omitempty"`
 		StateHistory               uint64                 `toml:",omitempty"`
+		ReceiptHistory             uint64                 `toml:",omitempty"`
 		StateScheme                string                 `toml:",omitempty"`
 		RequiredBlocks             map[uint64]common.Hash `toml:"-"`
 		LightServ                  int                    `toml:",omitempty"`
@@ -65,20 +68,34 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		RPCGasCap                  uint64
 		RPCEVMTimeout              time.Duration
 		RPCTxFeeCap                float64
+		RPCResultCache             int                            `toml:",omitempty"`
+		RPCResultCacheDepth        uint64                         `toml:",omitempty"`
+		RPCResultCacheDisk         bool                           `toml:",omitempty"`
 		Checkpoint                 *ctypes.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle           *ctypes.CheckpointOracleConfig `toml:",omitempty"`
+		SyncCheckpoints            []ctypes.SyncCheckpoint        `toml:",omitempty"`
+		CheckpointList             *ctypes.SignedCheckpoints      `toml:",omitempty"`
+		CheckpointSigners          []common.Address               `toml:",omitempty"`
+		CheckpointThreshold        int                            `toml:",omitempty"`
 		OverrideECBP1100           *uint64                        `toml:",omitempty"`
 		OverrideECBP1100Deactivate *uint64                        `toml:",omitempty"`
 		ECBP1100NoDisable          *bool                          `toml:",omitempty"`
-		OverrideShanghai           *uint64                        `toml:",omitempty"`
-		OverrideCancun             *uint64                        `toml:",omitempty"`
-		OverrideVerkle             *uint64                        `toml:",omitempty"`
+		ECBP1100Safety             *ArtificialFinalitySafety      `toml:",omitempty"`
+		TxPolicy                   TxPolicyConfig
+		DNSTree                    DNSTreeConfig
+		OverrideShanghai           *uint64     `toml:",omitempty"`
+		OverrideCancun             *uint64     `toml:",omitempty"`
+		OverrideVerkle             *uint64     `toml:",omitempty"`
+		ChainConfigFile            string      `toml:",omitempty"`
+		Instance                   string      `toml:",omitempty"`
+		InstanceP2P                *p2p.Config `toml:"-"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.ProtocolVersions = c.ProtocolVersions
 	enc.SyncMode = c.SyncMode
+	enc.HeaderFirstSync = c.HeaderFirstSync
 	enc.EthDiscoveryURLs = c.EthDiscoveryURLs
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
 	enc.NoPruning = c.NoPruning
@@ -86,6 +103,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxLookupLimit = c.TxLookupLimit
 	enc.TransactionHistory = c.TransactionHistory
 	enc.StateHistory = c.StateHistory
+	enc.ReceiptHistory = c.ReceiptHistory
 	enc.StateScheme = c.StateScheme
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
@@ -121,14 +139,27 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.RPCResultCache = c.RPCResultCache
+	enc.RPCResultCacheDepth = c.RPCResultCacheDepth
+	enc.RPCResultCacheDisk = c.RPCResultCacheDisk
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
+	enc.SyncCheckpoints = c.SyncCheckpoints
+	enc.CheckpointList = c.CheckpointList
+	enc.CheckpointSigners = c.CheckpointSigners
+	enc.CheckpointThreshold = c.CheckpointThreshold
 	enc.OverrideECBP1100 = c.OverrideECBP1100
 	enc.OverrideECBP1100Deactivate = c.OverrideECBP1100Deactivate
 	enc.ECBP1100NoDisable = c.ECBP1100NoDisable
+	enc.ECBP1100Safety = c.ECBP1100Safety
+	enc.TxPolicy = c.TxPolicy
+	enc.DNSTree = c.DNSTree
 	enc.OverrideShanghai = c.OverrideShanghai
 	enc.OverrideCancun = c.OverrideCancun
 	enc.OverrideVerkle = c.OverrideVerkle
+	enc.ChainConfigFile = c.ChainConfigFile
+	enc.Instance = c.Instance
+	enc.InstanceP2P = c.InstanceP2P
 	return &enc, nil
 }
 
@@ -139,6 +170,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NetworkId                  *uint64
 		ProtocolVersions           []uint
 		SyncMode                   *downloader.SyncMode
+		HeaderFirstSync            *bool `toml:",omitempty"`
 		EthDiscoveryURLs           []string
 		SnapDiscoveryURLs          []string
 		NoPruning                  *bool
@@ -146,6 +178,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TxLookupLimit              *uint64                `toml:",omitempty"`
 		TransactionHistory         *uint64                `toml:",omitempty"`
 		StateHistory               *uint64                `toml:",omitempty"`
+		ReceiptHistory             *uint64                `toml:",omitempty"`
 		StateScheme                *string                `toml:",omitempty"`
 		RequiredBlocks             map[uint64]common.Hash `toml:"-"`
 		LightServ                  *int                   `toml:",omitempty"`
@@ -181,14 +214,27 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		RPCGasCap                  *uint64
 		RPCEVMTimeout              *time.Duration
 		RPCTxFeeCap                *float64
+		RPCResultCache             *int                           `toml:",omitempty"`
+		RPCResultCacheDepth        *uint64                        `toml:",omitempty"`
+		RPCResultCacheDisk         *bool                          `toml:",omitempty"`
 		Checkpoint                 *ctypes.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle           *ctypes.CheckpointOracleConfig `toml:",omitempty"`
+		SyncCheckpoints            []ctypes.SyncCheckpoint        `toml:",omitempty"`
+		CheckpointList             *ctypes.SignedCheckpoints      `toml:",omitempty"`
+		CheckpointSigners          []common.Address               `toml:",omitempty"`
+		CheckpointThreshold        *int                           `toml:",omitempty"`
 		OverrideECBP1100           *uint64                        `toml:",omitempty"`
 		OverrideECBP1100Deactivate *uint64                        `toml:",omitempty"`
 		ECBP1100NoDisable          *bool                          `toml:",omitempty"`
-		OverrideShanghai           *uint64                        `toml:",omitempty"`
-		OverrideCancun             *uint64                        `toml:",omitempty"`
-		OverrideVerkle             *uint64                        `toml:",omitempty"`
+		ECBP1100Safety             *ArtificialFinalitySafety      `toml:",omitempty"`
+		TxPolicy                   *TxPolicyConfig
+		DNSTree                    *DNSTreeConfig
+		OverrideShanghai           *uint64     `toml:",omitempty"`
+		OverrideCancun             *uint64     `toml:",omitempty"`
+		OverrideVerkle             *uint64     `toml:",omitempty"`
+		ChainConfigFile            *string     `toml:",omitempty"`
+		Instance                   *string     `toml:",omitempty"`
+		InstanceP2P                *p2p.Config `toml:"-"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -206,6 +252,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.HeaderFirstSync != nil {
+		c.HeaderFirstSync = *dec.HeaderFirstSync
+	}
 	if dec.EthDiscoveryURLs != nil {
 		c.EthDiscoveryURLs = dec.EthDiscoveryURLs
 	}
@@ -227,6 +276,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.StateHistory != nil {
 		c.StateHistory = *dec.StateHistory
 	}
+	if dec.ReceiptHistory != nil {
+		c.ReceiptHistory = *dec.ReceiptHistory
+	}
 	if dec.StateScheme != nil {
 		c.StateScheme = *dec.StateScheme
 	}
@@ -332,12 +384,33 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.RPCResultCache != nil {
+		c.RPCResultCache = *dec.RPCResultCache
+	}
+	if dec.RPCResultCacheDepth != nil {
+		c.RPCResultCacheDepth = *dec.RPCResultCacheDepth
+	}
+	if dec.RPCResultCacheDisk != nil {
+		c.RPCResultCacheDisk = *dec.RPCResultCacheDisk
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
 	if dec.CheckpointOracle != nil {
 		c.CheckpointOracle = dec.CheckpointOracle
 	}
+	if dec.SyncCheckpoints != nil {
+		c.SyncCheckpoints = dec.SyncCheckpoints
+	}
+	if dec.CheckpointList != nil {
+		c.CheckpointList = dec.CheckpointList
+	}
+	if dec.CheckpointSigners != nil {
+		c.CheckpointSigners = dec.CheckpointSigners
+	}
+	if dec.CheckpointThreshold != nil {
+		c.CheckpointThreshold = *dec.CheckpointThreshold
+	}
 	if dec.OverrideECBP1100 != nil {
 		c.OverrideECBP1100 = dec.OverrideECBP1100
 	}
@@ -347,6 +420,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.ECBP1100NoDisable != nil {
 		c.ECBP1100NoDisable = dec.ECBP1100NoDisable
 	}
+	if dec.ECBP1100Safety != nil {
+		c.ECBP1100Safety = dec.ECBP1100Safety
+	}
+	if dec.TxPolicy != nil {
+		c.TxPolicy = *dec.TxPolicy
+	}
+	if dec.DNSTree != nil {
+		c.DNSTree = *dec.DNSTree
+	}
 	if dec.OverrideShanghai != nil {
 		c.OverrideShanghai = dec.OverrideShanghai
 	}
@@ -356,5 +438,14 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.OverrideVerkle != nil {
 		c.OverrideVerkle = dec.OverrideVerkle
 	}
+	if dec.ChainConfigFile != nil {
+		c.ChainConfigFile = *dec.ChainConfigFile
+	}
+	if dec.Instance != nil {
+		c.Instance = *dec.Instance
+	}
+	if dec.InstanceP2P != nil {
+		c.InstanceP2P = dec.InstanceP2P
+	}
 	return nil
 }
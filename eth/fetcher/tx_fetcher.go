@@ -192,6 +192,7 @@ type TxFetcher struct {
 	addTxs   func([]*types.Transaction) []error // Insert a batch of transactions into local txpool
 	fetchTxs func(string, []common.Hash) error  // Retrieves a set of txs from a remote peer
 	dropPeer func(string)                       // Drops a peer in case of announcement violation
+	rejected func(string, []error)              // Notified of the transactions of a peer rejected by the pool (optional)
 
 	step  chan struct{} // Notification channel when the fetcher loop iterates
 	clock mclock.Clock  // Time wrapper to simulate in tests
@@ -232,6 +233,13 @@ func NewTxFetcherForTests(
 	}
 }
 
+// SetRejectHook registers a callback notified of the transactions of a peer the
+// pool rejected for reasons other than their price or being already known. It
+// must be called before the fetcher is started.
+func (f *TxFetcher) SetRejectHook(hook func(peer string, errs []error)) {
+	f.rejected = hook
+}
+
 // Notify announces the fetcher of the potential availability of a new batch of
 // transactions in the network.
 func (f *TxFetcher) Notify(peer string, types []byte, sizes []uint32, hashes []common.Hash) error {
@@ -314,8 +322,9 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 	// Push all the transactions into the pool, tracking underpriced ones to avoid
 	// re-requesting them and dropping the peer in case of malicious transfers.
 	var (
-		added = make([]common.Hash, 0, len(txs))
-		metas = make([]txMetadata, 0, len(txs))
+		added   = make([]common.Hash, 0, len(txs))
+		metas   = make([]txMetadata, 0, len(txs))
+		rejects []error
 	)
 	// proceed in batches
 	for i := 0; i < len(txs); i += 128 {
@@ -349,6 +358,9 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 
 			default:
 				otherreject++
+				if f.rejected != nil {
+					rejects = append(rejects, err)
+				}
 			}
 			added = append(added, batch[j].Hash())
 			metas = append(metas, txMetadata{
@@ -366,6 +378,9 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 			log.Debug("Peer delivering stale transactions", "peer", peer, "rejected", otherreject)
 		}
 	}
+	if len(rejects) > 0 {
+		f.rejected(peer, rejects)
+	}
 	select {
 	case f.cleanup <- &txDelivery{origin: peer, hashes: added, metas: metas, direct: direct}:
 		return nil
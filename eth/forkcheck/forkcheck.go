@@ -0,0 +1,236 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package forkcheck assembles reports about the readiness of a node for the
+// upcoming transitions of its chain configuration.
+package forkcheck
+
+import (
+	"cmp"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/confp"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+)
+
+// blockTimeWindow is the number of recent blocks the average block time used to
+// estimate activation times is measured over.
+const blockTimeWindow = 1000
+
+// Chain is the chain data needed to assemble a readiness report.
+type Chain interface {
+	Config() ctypes.ChainConfigurator
+	Genesis() *types.Block
+	CurrentHeader() *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// ForkID is the JSON representation of an EIP-2124 fork identifier.
+type ForkID struct {
+	Hash hexutil.Bytes `json:"hash"`
+	Next uint64        `json:"next"`
+}
+
+func newForkID(id forkid.ID) *ForkID {
+	return &ForkID{Hash: id.Hash[:], Next: id.Next}
+}
+
+// Transition is a point in the chain at which one or more transitions of the
+// chain configuration activate.
+type Transition struct {
+	Block     *uint64    `json:"block,omitempty"`    // Activation block of block based transitions
+	Time      *uint64    `json:"time,omitempty"`     // Activation timestamp of time based transitions
+	Names     []string   `json:"names"`              // Configuration transitions activating together
+	Remaining uint64     `json:"remaining"`          // Blocks (or seconds if time based) until activation
+	Estimate  *time.Time `json:"estimate,omitempty"` // Estimated activation time, if known
+}
+
+// Peers summarises the fork IDs advertised by the connected peers.
+type Peers struct {
+	Total      int `json:"total"`      // Number of connected peers
+	Compatible int `json:"compatible"` // Peers whose fork ID passes the local fork filter
+	Ready      int `json:"ready"`      // Peers announcing the same next fork as the local node
+}
+
+// Report describes the readiness of a node for its upcoming transitions.
+type Report struct {
+	Head       uint64        `json:"head"`
+	HeadTime   uint64        `json:"headTime"`
+	BlockTime  float64       `json:"blockTime"` // Recent average block time in seconds
+	ForkID     *ForkID       `json:"forkId"`
+	NextForkID *ForkID       `json:"nextForkId,omitempty"`
+	Upcoming   []*Transition `json:"upcoming"`
+	Peers      *Peers        `json:"peers,omitempty"`
+
+	// ConfigMismatch describes how the stored chain configuration differs
+	// from the one built into the binary, if at all.
+	ConfigMismatch string `json:"configMismatch,omitempty"`
+
+	local forkid.ID
+}
+
+// New assembles a readiness report for the configuration of the given chain.
+// If stored and builtin are both known, they are compared for equivalence.
+func New(chain Chain, stored, builtin ctypes.ChainConfigurator) *Report {
+	var (
+		config  = chain.Config()
+		genesis = chain.Genesis()
+		head    = chain.CurrentHeader()
+		number  = head.Number.Uint64()
+	)
+	report := &Report{
+		Head:     number,
+		HeadTime: head.Time,
+		local:    forkid.NewID(config, genesis, number, head.Time),
+		Upcoming: []*Transition{},
+	}
+	report.ForkID = newForkID(report.local)
+
+	// Compute the fork ID the node will advertise once the next fork passes.
+	if next := report.local.Next; next != 0 {
+		if slices.Contains(confp.BlockForks(config), next) {
+			report.NextForkID = newForkID(forkid.NewID(config, genesis, next, head.Time))
+		} else {
+			report.NextForkID = newForkID(forkid.NewID(config, genesis, number, next))
+		}
+	}
+	// Measure the recent block time to estimate activation times.
+	if number > 0 {
+		window := min(number, blockTimeWindow)
+		if past := chain.GetHeaderByNumber(number - window); past != nil {
+			report.BlockTime = float64(head.Time-past.Time) / float64(window)
+		}
+	}
+	report.Upcoming = upcoming(config, number, head.Time, report.BlockTime)
+
+	if stored != nil && builtin != nil {
+		if err := confp.Equivalent(builtin, stored); err != nil {
+			report.ConfigMismatch = err.Error()
+		}
+	}
+	return report
+}
+
+// upcoming collects the transitions of the configuration not yet activated at
+// the given head, grouped by activation point and ordered by activation.
+func upcoming(config ctypes.ChainConfigurator, number, now uint64, blockTime float64) []*Transition {
+	var (
+		byBlock = make(map[uint64]*Transition)
+		byTime  = make(map[uint64]*Transition)
+	)
+	fns, names := confp.Transitions(config)
+	for i, fn := range fns {
+		v := fn()
+		if v == nil || *v == math.MaxUint64 {
+			continue
+		}
+		name := strings.TrimPrefix(names[i], "Get")
+		if strings.HasSuffix(name, "TransitionTime") {
+			if *v <= now {
+				continue
+			}
+			t, ok := byTime[*v]
+			if !ok {
+				at := time.Unix(int64(*v), 0).UTC()
+				t = &Transition{Time: v, Remaining: *v - now, Estimate: &at}
+				byTime[*v] = t
+			}
+			t.Names = append(t.Names, strings.TrimSuffix(name, "TransitionTime"))
+			continue
+		}
+		if *v <= number {
+			continue
+		}
+		t, ok := byBlock[*v]
+		if !ok {
+			t = &Transition{Block: v, Remaining: *v - number}
+			if blockTime > 0 {
+				at := time.Unix(int64(now), 0).Add(time.Duration(float64(t.Remaining) * blockTime * float64(time.Second))).UTC()
+				t.Estimate = &at
+			}
+			byBlock[*v] = t
+		}
+		t.Names = append(t.Names, strings.TrimSuffix(name, "Transition"))
+	}
+	transitions := make([]*Transition, 0, len(byBlock)+len(byTime))
+	for _, t := range byBlock {
+		transitions = append(transitions, t)
+	}
+	for _, t := range byTime {
+		transitions = append(transitions, t)
+	}
+	slices.SortFunc(transitions, func(a, b *Transition) int {
+		// Block based transitions precede time based ones, mirroring fork IDs.
+		switch {
+		case a.Block != nil && b.Block != nil:
+			return cmp.Compare(*a.Block, *b.Block)
+		case a.Block != nil:
+			return -1
+		case b.Block != nil:
+			return 1
+		}
+		return cmp.Compare(*a.Time, *b.Time)
+	})
+	for _, t := range transitions {
+		slices.Sort(t.Names)
+	}
+	return transitions
+}
+
+// AddPeers tallies the fork IDs advertised by the connected peers against the
+// local fork filter and the next fork of the local node.
+func (r *Report) AddPeers(ids []forkid.ID, filter forkid.Filter) {
+	r.Peers = &Peers{Total: len(ids)}
+	for _, id := range ids {
+		if filter(id) == nil {
+			r.Peers.Compatible++
+		}
+		if id == r.local {
+			r.Peers.Ready++
+		}
+	}
+}
+
+// BuiltinConfig returns the chain configuration built into the binary for the
+// network with the given genesis hash and chain ID, or nil if none is known.
+func BuiltinConfig(genesis common.Hash, chainID *big.Int) ctypes.ChainConfigurator {
+	switch genesis {
+	case params.MainnetGenesisHash:
+		// Ethereum and Ethereum Classic share the genesis block.
+		if chainID != nil && chainID.Cmp(params.ClassicChainConfig.GetChainID()) == 0 {
+			return params.ClassicChainConfig
+		}
+		return params.MainnetChainConfig
+	case params.MordorGenesisHash:
+		return params.MordorChainConfig
+	case params.SepoliaGenesisHash:
+		return params.SepoliaChainConfig
+	case params.HoleskyGenesisHash:
+		return params.HoleskyChainConfig
+	case params.MintMeGenesisHash:
+		return params.MintMeChainConfig
+	}
+	return nil
+}
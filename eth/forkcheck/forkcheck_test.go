@@ -0,0 +1,113 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkcheck
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/coregeth"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+)
+
+func TestReport(t *testing.T) {
+	config := &coregeth.CoreGethChainConfig{
+		NetworkID:    61,
+		ChainID:      params.ClassicChainConfig.GetChainID(),
+		Ethash:       new(ctypes.EthashConfig),
+		EIP2FBlock:   big.NewInt(0),
+		EIP7FBlock:   big.NewInt(0),
+		EIP150Block:  big.NewInt(5),
+		EIP155Block:  big.NewInt(20),
+		EIP160FBlock: big.NewInt(20),
+		EIP140FBlock: big.NewInt(30),
+	}
+	gspec := &genesisT.Genesis{Config: config, Timestamp: 1000}
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 10, nil)
+
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	report := New(chain, config, params.ClassicChainConfig)
+
+	if report.Head != 10 {
+		t.Errorf("head mismatch: have %d, want 10", report.Head)
+	}
+	if report.BlockTime != 10 {
+		t.Errorf("block time mismatch: have %v, want 10", report.BlockTime)
+	}
+	if report.ForkID.Next != 20 {
+		t.Errorf("next fork mismatch: have %d, want 20", report.ForkID.Next)
+	}
+	if report.NextForkID == nil || report.NextForkID.Next != 30 {
+		t.Errorf("next fork ID mismatch: have %+v, want next 30", report.NextForkID)
+	}
+	if len(report.Upcoming) != 2 {
+		t.Fatalf("upcoming transitions mismatch: have %d, want 2", len(report.Upcoming))
+	}
+	if first := report.Upcoming[0]; *first.Block != 20 || first.Remaining != 10 || len(first.Names) != 2 {
+		t.Errorf("first transition mismatch: block %d, remaining %d, names %v", *first.Block, first.Remaining, first.Names)
+	}
+	if first := report.Upcoming[0]; first.Estimate == nil || first.Estimate.Unix() != int64(report.HeadTime)+100 {
+		t.Errorf("first transition estimate mismatch: have %v", first.Estimate)
+	}
+	if report.ConfigMismatch == "" {
+		t.Errorf("configuration mismatch with built-in config not reported")
+	}
+	if New(chain, config, config).ConfigMismatch != "" {
+		t.Errorf("configuration mismatch reported for identical configs")
+	}
+	// Tally peers against a filter accepting everything but a single fork ID.
+	local := forkid.NewID(config, chain.Genesis(), 10, report.HeadTime)
+	stale := forkid.ID{Hash: local.Hash, Next: 25}
+	bad := forkid.ID{Hash: [4]byte{0xde, 0xad}}
+	filter := func(id forkid.ID) error {
+		if id == bad {
+			return errors.New("incompatible")
+		}
+		return nil
+	}
+	report.AddPeers([]forkid.ID{local, local, stale, bad}, filter)
+	if have, want := *report.Peers, (Peers{Total: 4, Compatible: 3, Ready: 2}); have != want {
+		t.Errorf("peer stats mismatch: have %+v, want %+v", have, want)
+	}
+}
+
+func TestBuiltinConfig(t *testing.T) {
+	if BuiltinConfig(params.MainnetGenesisHash, params.ClassicChainConfig.GetChainID()) != params.ClassicChainConfig {
+		t.Errorf("classic configuration not detected")
+	}
+	if BuiltinConfig(params.MainnetGenesisHash, params.MainnetChainConfig.GetChainID()) != params.MainnetChainConfig {
+		t.Errorf("mainnet configuration not detected")
+	}
+	if BuiltinConfig(params.MordorGenesisHash, nil) != params.MordorChainConfig {
+		t.Errorf("mordor configuration not detected")
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"errors"
 	"math"
 	"math/big"
+	"slices"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
@@ -41,6 +44,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/reputation"
 	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/vars"
 	"github.com/ethereum/go-ethereum/triedb/pathdb"
@@ -81,6 +85,9 @@ type txPool interface {
 	// can decide whether to receive notifications only for newly seen transactions
 	// or also for reorged out ones.
 	SubscribeTransactions(ch chan<- core.NewTxsEvent, reorgs bool) event.Subscription
+
+	// Locals retrieves the accounts currently considered local by the pool.
+	Locals() []common.Address
 }
 
 // handlerConfig is the collection of initialization parameters to create a full
@@ -92,15 +99,20 @@ type handlerConfig struct {
 	Merger         *consensus.Merger         // The manager for eth1/2 transition
 	Network        uint64                    // Network identifier to advertise
 	Sync           downloader.SyncMode       // Whether to snap or full sync
+	HeaderFirst    bool                      // Whether to verify all headers before snap syncing content
 	BloomCache     uint64                    // Megabytes to alloc for snap sync bloom
 	EventMux       *event.TypeMux            // Legacy event mux, deprecate for `feed`
 	Checkpoint     *ctypes.TrustedCheckpoint // Hard coded checkpoint for sync challenges
 	RequiredBlocks map[uint64]common.Hash    // Hard coded map of required block hashes for sync challenges
+	Checkpoints    []ctypes.SyncCheckpoint   // Blocks any chain synced from the network must include
+
+	ArtificialFinality *ethconfig.ArtificialFinalitySafety // Artificial finality safety conditions, defaults if nil
+	TxPolicies         []TxPolicy                          // Transaction propagation policies, in order
 }
 
 type handler struct {
 	networkID  uint64
-	forkFilter forkid.Filter // Fork ID filter, constant across the lifetime of the node
+	forkFilter atomic.Pointer[forkid.Filter] // Fork ID filter, rebuilt on chain configuration updates
 
 	snapSync atomic.Bool // Flag whether snap sync is enabled (gets disabled if we already have blocks)
 	synced   atomic.Bool // Flag whether we're considered synchronised (enables transaction processing)
@@ -125,6 +137,8 @@ type handler struct {
 	minedBlockSub *event.TypeMuxSubscription
 
 	requiredBlocks map[uint64]common.Hash
+	afSafety       ethconfig.ArtificialFinalitySafety
+	txPolicies     txPolicies
 
 	// channels for fetcher, syncer, txsyncLoop
 	quitSync chan struct{}
@@ -144,7 +158,6 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	}
 	h := &handler{
 		networkID:      config.Network,
-		forkFilter:     forkid.NewFilter(config.Chain),
 		eventMux:       config.EventMux,
 		database:       config.Database,
 		txpool:         config.TxPool,
@@ -152,10 +165,18 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		peers:          newPeerSet(),
 		merger:         config.Merger,
 		requiredBlocks: config.RequiredBlocks,
+		afSafety:       ethconfig.DefaultArtificialFinalitySafety,
 		quitSync:       make(chan struct{}),
 		handlerDoneCh:  make(chan struct{}),
 		handlerStartCh: make(chan struct{}),
 	}
+	if config.ArtificialFinality != nil {
+		h.afSafety = *config.ArtificialFinality
+	}
+	for _, policy := range config.TxPolicies {
+		h.txPolicies.add(policy)
+	}
+	h.updateForkFilter()
 	if config.Sync == downloader.FullSync {
 		// The database seems empty as the current block is the genesis. Yet the snap
 		// block is ahead, so snap sync was enabled for this node at a certain point.
@@ -194,7 +215,14 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		return nil, errors.New("snap sync not supported with snapshots disabled")
 	}
 	// Construct the downloader (long sync)
-	h.downloader = downloader.New(h.checkpointNumber, config.Database, h.eventMux, h.chain, nil, h.removePeer, h.enableSyncedFeatures)
+	h.downloader = downloader.New(h.checkpointNumber, config.Database, h.eventMux, h.chain, nil, h.punishPeer(reputation.SyncFailure), h.enableSyncedFeatures)
+	h.downloader.SetHeaderFirst(config.HeaderFirst)
+	if err := h.downloader.SetSyncCheckpoints(config.Checkpoints); err != nil {
+		return nil, err
+	}
+	if len(config.Checkpoints) > 0 {
+		log.Info("Enforcing sync checkpoints", "count", len(config.Checkpoints))
+	}
 	if ttd := h.chain.Config().GetEthashTerminalTotalDifficulty(); ttd != nil {
 		if h.chain.Config().GetEthashTerminalTotalDifficultyPassed() {
 			log.Info("Chain post-merge, sync via beacon client")
@@ -295,7 +323,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return n, err
 	}
-	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer)
+	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.punishPeer(reputation.InvalidData))
 
 	fetchTx := func(peer string, hashes []common.Hash) error {
 		p := h.peers.peer(peer)
@@ -307,7 +335,12 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	addTxs := func(txs []*types.Transaction) []error {
 		return h.txpool.Add(txs, false, false)
 	}
-	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, addTxs, fetchTx, h.removePeer)
+	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, addTxs, fetchTx, h.punishPeer(reputation.InvalidData))
+	h.txFetcher.SetRejectHook(func(peer string, errs []error) {
+		if p := h.peers.peer(peer); p != nil {
+			h.txPolicies.rejected(p.Peer, errs)
+		}
+	})
 	h.chainSync = newChainSyncer(h)
 	return h, nil
 }
@@ -348,6 +381,13 @@ func (h *handler) decHandlers() {
 	h.handlerDoneCh <- struct{}{}
 }
 
+// updateForkFilter rebuilds the fork ID filter used to validate the handshakes of
+// remote peers from the current chain configuration.
+func (h *handler) updateForkFilter() {
+	filter := forkid.NewFilter(h.chain)
+	h.forkFilter.Store(&filter)
+}
+
 // runEthPeer registers an eth peer into the joint eth/snap peerset, adds it to
 // various subsystems and starts handling messages.
 func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
@@ -373,7 +413,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 		td      = h.chain.GetTd(hash, number)
 	)
 	forkID := forkid.NewID(h.chain.Config(), genesis, number, head.Time)
-	if err := peer.Handshake(h.networkID, td, hash, genesis.Hash(), forkID, h.forkFilter); err != nil {
+	if err := peer.Handshake(h.networkID, td, hash, genesis.Hash(), forkID, *h.forkFilter.Load()); err != nil {
 		peer.Log().Debug("Ethereum handshake failed", "err", err)
 		return err
 	}
@@ -466,6 +506,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 					return
 				}
 				if headers[0].Hash() != h.checkpointHash {
+					peer.Report(reputation.InvalidData)
 					res.Done <- errors.New("checkpoint hash mismatch")
 					return
 				}
@@ -473,6 +514,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 
 			case <-timeout.C:
 				peer.Log().Warn("Checkpoint challenge timed out, dropping", "addr", peer.RemoteAddr(), "type", peer.Name())
+				peer.Report(reputation.Timeout)
 				h.removePeer(peer.ID())
 
 			case <-dead:
@@ -511,6 +553,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 				}
 				if headers[0].Number.Uint64() != number || headers[0].Hash() != hash {
 					peer.Log().Info("Required block mismatch, dropping peer", "number", number, "hash", headers[0].Hash(), "want", hash)
+					peer.Report(reputation.InvalidData)
 					res.Done <- errors.New("required block mismatch")
 					return
 				}
@@ -518,6 +561,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 				res.Done <- nil
 			case <-timeout.C:
 				peer.Log().Warn("Required block challenge timed out, dropping", "addr", peer.RemoteAddr(), "type", peer.Name())
+				peer.Report(reputation.Timeout)
 				h.removePeer(peer.ID())
 			}
 		}(number, hash, req)
@@ -558,6 +602,17 @@ func (h *handler) removePeer(id string) {
 	}
 }
 
+// punishPeer returns a peer drop callback recording the given misbehaviour in
+// the reputation of the peer before disconnecting it.
+func (h *handler) punishPeer(ev reputation.Event) func(id string) {
+	return func(id string) {
+		if peer := h.peers.peer(id); peer != nil {
+			peer.Report(ev)
+		}
+		h.removePeer(id)
+	}
+}
+
 // unregisterPeer removes a peer from the downloader, fetchers and main peer set.
 func (h *handler) unregisterPeer(id string) {
 	// Create a custom logger to avoid printing the entire id
@@ -581,6 +636,10 @@ func (h *handler) unregisterPeer(id string) {
 	if peer.snapExt != nil {
 		h.downloader.SnapSyncer.Unregister(id)
 	}
+	// Remember how responsive the peer was during the session
+	if rtt, ok := h.downloader.PeerRoundtrip(id); ok {
+		peer.ReportLatency(rtt)
+	}
 	h.downloader.UnregisterPeer(id)
 	h.txFetcher.Drop(id)
 
@@ -692,8 +751,8 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		annCount    int // Number of transactions announced across all peers (duplicates included)
 		annPeers    int // Number of peers announced about transactions
 
-		txset = make(map[*ethPeer][]common.Hash) // Set peer->hash to transfer directly
-		annos = make(map[*ethPeer][]common.Hash) // Set peer->hash to announce
+		txset = make(map[*eth.Peer][]common.Hash) // Set peer->hash to transfer directly
+		annos = make(map[*eth.Peer][]common.Hash) // Set peer->hash to announce
 	)
 	// Broadcast transactions to a batch of peers not knowing about it
 	for _, tx := range txs {
@@ -708,12 +767,22 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		default:
 			numDirect = int(math.Sqrt(float64(len(peers))))
 		}
-		// Send the tx unconditionally to a subset of our peers
+		// Let the propagation policies narrow down the recipients
+		direct, announce := make([]*eth.Peer, 0, numDirect), make([]*eth.Peer, 0, len(peers)-numDirect)
 		for _, peer := range peers[:numDirect] {
+			direct = append(direct, peer.Peer)
+		}
+		for _, peer := range peers[numDirect:] {
+			announce = append(announce, peer.Peer)
+		}
+		direct, announce = h.txPolicies.relay(tx, direct, announce)
+
+		// Send the tx unconditionally to a subset of our peers
+		for _, peer := range direct {
 			txset[peer] = append(txset[peer], tx.Hash())
 		}
 		// For the remaining peers, send announcement only
-		for _, peer := range peers[numDirect:] {
+		for _, peer := range announce {
 			annos[peer] = append(annos[peer], tx.Hash())
 		}
 	}
@@ -743,19 +812,110 @@ func (h *handler) minedBroadcastLoop() {
 	}
 }
 
+// delayedTx is a transaction whose propagation was postponed by a policy.
+type delayedTx struct {
+	tx      *types.Transaction
+	release time.Time
+}
+
 // txBroadcastLoop announces new transactions to connected peers.
 func (h *handler) txBroadcastLoop() {
 	defer h.wg.Done()
+
+	var (
+		delayed []*delayedTx // Postponed transactions, ordered by release time
+		timer   = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	<-timer.C // discard the initial tick
+
 	for {
 		select {
 		case event := <-h.txsCh:
-			h.BroadcastTransactions(event.Txs)
+			if h.txPolicies.empty() {
+				h.BroadcastTransactions(event.Txs)
+				continue
+			}
+			var (
+				now   = time.Now()
+				ready = make(types.Transactions, 0, len(event.Txs))
+				local = h.localTxChecker()
+			)
+			for _, tx := range event.Txs {
+				delay := h.txPolicies.delay(tx, func() bool { return local(tx) })
+				if delay <= 0 {
+					ready = append(ready, tx)
+					continue
+				}
+				item := &delayedTx{tx: tx, release: now.Add(delay)}
+				i := sort.Search(len(delayed), func(i int) bool {
+					return delayed[i].release.After(item.release)
+				})
+				delayed = slices.Insert(delayed, i, item)
+				if i == 0 {
+					timer.Reset(delay)
+				}
+			}
+			if len(ready) > 0 {
+				h.BroadcastTransactions(ready)
+			}
+		case <-timer.C:
+			var (
+				now   = time.Now()
+				ready types.Transactions
+			)
+			for len(delayed) > 0 && !delayed[0].release.After(now) {
+				ready = append(ready, delayed[0].tx)
+				delayed = delayed[1:]
+			}
+			if len(delayed) > 0 {
+				timer.Reset(delayed[0].release.Sub(now))
+			}
+			h.BroadcastTransactions(ready)
+
 		case <-h.txsSub.Err():
 			return
 		}
 	}
 }
 
+// localTxChecker returns a function reporting whether a transaction was sent by
+// one of the accounts the pool considers local. The local accounts are only
+// retrieved on the first check, as most policies never ask for them.
+func (h *handler) localTxChecker() func(tx *types.Transaction) bool {
+	var check func(tx *types.Transaction) bool
+	return func(tx *types.Transaction) bool {
+		if check == nil {
+			check = h.newLocalTxChecker()
+		}
+		return check(tx)
+	}
+}
+
+// newLocalTxChecker creates the checker of localTxChecker from the current local
+// accounts of the pool.
+func (h *handler) newLocalTxChecker() func(tx *types.Transaction) bool {
+	locals := h.txpool.Locals()
+	if len(locals) == 0 {
+		return func(*types.Transaction) bool { return false }
+	}
+	var (
+		signer  = types.LatestSigner(h.chain.Config())
+		senders = make(map[common.Address]struct{}, len(locals))
+	)
+	for _, addr := range locals {
+		senders[addr] = struct{}{}
+	}
+	return func(tx *types.Transaction) bool {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return false
+		}
+		_, ok := senders[from]
+		return ok
+	}
+}
+
 // enableSyncedFeatures enables the post-sync functionalities when the initial
 // sync is finished.
 func (h *handler) enableSyncedFeatures() {
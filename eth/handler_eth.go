@@ -27,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/reputation"
 )
 
 // ethHandler implements the eth.Backend interface to handle the various network
@@ -68,6 +69,9 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 		return h.handleBlockBroadcast(peer, packet.Block, packet.TD)
 
 	case *eth.NewPooledTransactionHashesPacket:
+		if !h.txPolicies.accept(peer) {
+			return nil
+		}
 		return h.txFetcher.Notify(peer.ID(), packet.Types, packet.Sizes, packet.Hashes)
 
 	case *eth.TransactionsPacket:
@@ -76,9 +80,14 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 				return errors.New("disallowed broadcast blob transaction")
 			}
 		}
+		if !h.txPolicies.accept(peer) {
+			return nil
+		}
+		h.reportUsefulTxs(peer, *packet)
 		return h.txFetcher.Enqueue(peer.ID(), *packet, false)
 
 	case *eth.PooledTransactionsResponse:
+		h.reportUsefulTxs(peer, *packet)
 		return h.txFetcher.Enqueue(peer.ID(), *packet, true)
 
 	default:
@@ -86,6 +95,17 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 	}
 }
 
+// reportUsefulTxs credits the peer if it delivered any transaction not already
+// known to the local pool.
+func (h *ethHandler) reportUsefulTxs(peer *eth.Peer, txs []*types.Transaction) {
+	for _, tx := range txs {
+		if !h.txpool.Has(tx.Hash()) {
+			peer.Report(reputation.UsefulTxs)
+			return
+		}
+	}
+}
+
 // handleBlockAnnounces is invoked from a peer's message handler when it transmits a
 // batch of block announcements for the local node to process.
 func (h *ethHandler) handleBlockAnnounces(peer *eth.Peer, hashes []common.Hash, numbers []uint64) error {
@@ -121,6 +141,14 @@ func (h *ethHandler) handleBlockBroadcast(peer *eth.Peer, block *types.Block, td
 	if h.merger.PoSFinalized() {
 		return errors.New("disallowed block broadcast")
 	}
+	// Credit the peer if the block is new and heavier than our current head,
+	// propagating light side chains doesn't earn reputation
+	if !h.chain.HasBlock(block.Hash(), block.NumberU64()) {
+		head := h.chain.CurrentBlock()
+		if localTD := h.chain.GetTd(head.Hash(), head.Number.Uint64()); localTD != nil && td.Cmp(localTD) > 0 {
+			peer.Report(reputation.UsefulBlock)
+		}
+	}
 	// Schedule the block for import
 	h.blockFetcher.Enqueue(peer.ID(), block)
 
@@ -50,7 +50,9 @@ var (
 // Its goal is to get around setting up a valid statedb for the balance and nonce
 // checks.
 type testTxPool struct {
-	pool map[common.Hash]*types.Transaction // Hash map of collected transactions
+	pool   map[common.Hash]*types.Transaction // Hash map of collected transactions
+	locals []common.Address                   // Accounts reported as local
+	reject error                              // Error rejecting all added transactions, if set
 
 	txFeed event.Feed   // Notification feed to allow waiting for inclusion
 	lock   sync.RWMutex // Protects the transaction pool
@@ -86,6 +88,13 @@ func (p *testTxPool) Add(txs []*types.Transaction, local bool, sync bool) []erro
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	if p.reject != nil {
+		errs := make([]error, len(txs))
+		for i := range errs {
+			errs[i] = p.reject
+		}
+		return errs
+	}
 	for _, tx := range txs {
 		p.pool[tx.Hash()] = tx
 	}
@@ -129,6 +138,14 @@ func (p *testTxPool) SubscribeTransactions(ch chan<- core.NewTxsEvent, reorgs bo
 	return p.txFeed.Subscribe(ch)
 }
 
+// Locals retrieves the accounts considered local by the pool.
+func (p *testTxPool) Locals() []common.Address {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.locals
+}
+
 // testHandler is a live implementation of the Ethereum protocol handler, just
 // preinitialized with some sane testing defaults and the transaction pool mocked
 // out.
@@ -20,12 +20,15 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
 
 var (
@@ -192,6 +195,18 @@ func (ps *peerSet) peer(id string) *ethPeer {
 	return ps.peers[id]
 }
 
+// all retrieves all the registered `eth` peers.
+func (ps *peerSet) all() []*ethPeer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*ethPeer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		list = append(list, p)
+	}
+	return list
+}
+
 // peersWithoutBlock retrieves a list of peers that do not have a given block in
 // their set of known hashes so it might be propagated to them.
 func (ps *peerSet) peersWithoutBlock(hash common.Hash) []*ethPeer {
@@ -240,6 +255,44 @@ func (ps *peerSet) snapLen() int {
 	return ps.snapPeers
 }
 
+// forkIDs retrieves the fork IDs advertised by the `eth` peers at handshake.
+func (ps *peerSet) forkIDs() []forkid.ID {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	ids := make([]forkid.ID, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		ids = append(ids, p.ForkID())
+	}
+	return ids
+}
+
+// diversity counts the distinct /16 subnets and client implementations among
+// the `eth` peers. Peers without a known IP address don't count as a subnet.
+func (ps *peerSet) diversity() (subnets int, clients int) {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	var (
+		nets  = netutil.DistinctNetSet{Subnet: 16, Limit: 1}
+		names = make(map[string]struct{})
+	)
+	for _, p := range ps.peers {
+		if ip := p.Node().IPAddr(); ip.IsValid() {
+			nets.AddAddr(ip)
+		}
+		names[clientName(p.Fullname())] = struct{}{}
+	}
+	return nets.Len(), len(names)
+}
+
+// clientName extracts the client implementation from the node name advertised
+// by a peer, e.g. "coregeth" from "CoreGeth/v1.12.20-stable/linux-amd64/go1.21".
+func clientName(fullname string) string {
+	name, _, _ := strings.Cut(fullname, "/")
+	return strings.ToLower(name)
+}
+
 // peerWithHighestTD retrieves the known peer with the currently highest total
 // difficulty, but below the given PoS switchover threshold.
 func (ps *peerSet) peerWithHighestTD() *eth.Peer {
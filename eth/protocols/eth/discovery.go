@@ -17,9 +17,16 @@
 package eth
 
 import (
+	"math"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -57,6 +64,12 @@ func StartENRUpdater(chain *core.BlockChain, ln *enode.LocalNode) {
 	}()
 }
 
+// UpdateENR sets the `eth` ENR entry of the local node from the current state of
+// the chain, without waiting for the next chain head event.
+func UpdateENR(chain *core.BlockChain, ln *enode.LocalNode) {
+	ln.Set(currentENREntry(chain))
+}
+
 // currentENREntry constructs an `eth` ENR entry based on the current state of the chain.
 func currentENREntry(chain *core.BlockChain) *enrEntry {
 	head := chain.CurrentHeader()
@@ -64,3 +77,85 @@ func currentENREntry(chain *core.BlockChain) *enrEntry {
 		ForkID: forkid.NewID(chain.Config(), chain.Genesis(), head.Number.Uint64(), head.Time),
 	}
 }
+
+// NewNodeFilter returns a function reporting whether a node advertises a fork
+// identifier compatible with the local chain in its `eth` ENR entry. The fork
+// filter follows replacements of the chain configuration.
+func NewNodeFilter(chain *core.BlockChain) func(*enode.Node) bool {
+	var (
+		config ctypes.ChainConfigurator
+		filter forkid.Filter
+		lock   sync.Mutex
+	)
+	return func(n *enode.Node) bool {
+		var entry enrEntry
+		if err := n.Load(&entry); err != nil {
+			return false
+		}
+		lock.Lock()
+		if current := chain.Config(); current != config {
+			config, filter = current, forkid.NewFilter(chain)
+		}
+		check := filter
+		lock.Unlock()
+
+		return check(entry.ForkID) == nil
+	}
+}
+
+// NetworkTopic returns the discovery topic advertised by the nodes of the network
+// with the given genesis, following the fork schedule with the given identifier
+// at its last fork.
+func NetworkTopic(genesis common.Hash, id forkid.ID) discover.Topic {
+	return discover.NewTopic([]byte("eth"), genesis.Bytes(), id.Hash[:])
+}
+
+// StartTopicDiscovery advertises the network topic of the chain on discovery v5
+// and passes an iterator of the nodes found under it to addSource, so they can
+// be dialed in preference to nodes of other networks. The topic doesn't depend
+// on the head, so syncing nodes find the synced ones. If the fork schedule is
+// changed by reloading the chain configuration, the topic of the new schedule
+// replaces the old one with the next head.
+func StartTopicDiscovery(chain *core.BlockChain, disc *discover.UDPv5, addSource func(enode.Iterator)) {
+	var (
+		topic discover.Topic
+		nodes enode.Iterator
+	)
+	update := func() {
+		next := chainTopic(chain)
+		if nodes != nil {
+			if next == topic {
+				return
+			}
+			disc.StopAdvertising(topic)
+			nodes.Close()
+		}
+		topic = next
+		disc.AdvertiseTopic(topic)
+		nodes = disc.TopicNodes(topic)
+		addSource(nodes)
+		log.Debug("Advertising network discovery topic", "topic", topic)
+	}
+	update()
+
+	var newHead = make(chan core.ChainHeadEvent, 10)
+	sub := chain.SubscribeChainHeadEvent(newHead)
+
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-newHead:
+				update()
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+}
+
+// chainTopic returns the network topic of the chain, derived from its genesis and
+// the fork identifier after all scheduled forks.
+func chainTopic(chain *core.BlockChain) discover.Topic {
+	return NetworkTopic(chain.Genesis().Hash(), forkid.NewID(chain.Config(), chain.Genesis(), math.MaxUint64, math.MaxUint64))
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+)
+
+// startTopicTestDiscovery starts a discovery v5 instance on the loopback address.
+// Revalidation is sped up, for the records advertising the topic to replace the
+// ones learned before.
+func startTopicTestDiscovery(t *testing.T, bootnodes ...*enode.Node) *discover.UDPv5 {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := enode.OpenDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := socket.LocalAddr().(*net.UDPAddr)
+	ln := enode.NewLocalNode(db, key)
+	ln.SetStaticIP(addr.IP)
+	ln.SetFallbackUDP(addr.Port)
+
+	disc, err := discover.ListenV5(socket, ln, discover.Config{
+		PrivateKey:   key,
+		Bootnodes:    bootnodes,
+		PingInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(disc.Close)
+	return disc
+}
+
+// waitTableInit waits until the table of the node is initialized. Until then the
+// node ignores the nodes contacting it, so probe nodes are started until one of
+// them is added.
+func waitTableInit(t *testing.T, disc *discover.UDPv5) {
+	t.Helper()
+
+	for deadline := time.Now().Add(20 * time.Second); time.Now().Before(deadline); {
+		probe := startTopicTestDiscovery(t, disc.Self())
+		for i := 0; i < 10; i++ {
+			time.Sleep(50 * time.Millisecond)
+			if slices.ContainsFunc(disc.AllNodes(), func(n *enode.Node) bool { return n.ID() == probe.Self().ID() }) {
+				probe.Close()
+				return
+			}
+		}
+		probe.Close()
+	}
+	t.Fatal("node table not initialized")
+}
+
+// findTopicNode waits for the iterator to return the given node.
+func findTopicNode(t *testing.T, it enode.Iterator, want enode.ID) {
+	t.Helper()
+
+	timeout := time.AfterFunc(20*time.Second, it.Close)
+	defer timeout.Stop()
+
+	for it.Next() {
+		if it.Node().ID() == want {
+			return
+		}
+	}
+	t.Fatalf("node %v not found under the network topic", want)
+}
+
+// Tests that nodes of the same network at different heads, on either side of a
+// fork, advertise the same topic and find each other under it.
+func TestTopicDiscoveryHeads(t *testing.T) {
+	config := *params.TestChainConfig
+	config.MuirGlacierBlock = big.NewInt(2)
+	gspec := &genesisT.Genesis{Config: &config}
+
+	synced, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synced.Stop()
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 4, nil)
+	if _, err := synced.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	syncing, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syncing.Stop()
+
+	if forkid.NewIDWithChain(synced) == forkid.NewIDWithChain(syncing) {
+		t.Fatal("nodes on the same side of the fork")
+	}
+	if chainTopic(synced) != chainTopic(syncing) {
+		t.Fatal("network topic depends on the head")
+	}
+	// The syncing node starts from the synced one, both must find each other
+	sources := make(chan enode.Iterator, 2)
+	addSource := func(it enode.Iterator) { sources <- it }
+
+	syncedDisc := startTopicTestDiscovery(t)
+	StartTopicDiscovery(synced, syncedDisc, addSource)
+	syncedNodes := <-sources
+	defer syncedNodes.Close()
+
+	waitTableInit(t, syncedDisc)
+
+	syncingDisc := startTopicTestDiscovery(t, syncedDisc.Self())
+	StartTopicDiscovery(syncing, syncingDisc, addSource)
+	syncingNodes := <-sources
+	defer syncingNodes.Close()
+
+	findTopicNode(t, syncingNodes, syncedDisc.Self().ID())
+	findTopicNode(t, syncedNodes, syncingDisc.Self().ID())
+}
@@ -255,7 +255,13 @@ func ServiceGetReceiptsQuery(chain *core.BlockChain, query GetReceiptsRequest) [
 			lookups >= 2*maxReceiptsServe {
 			break
 		}
-		// Retrieve the requested block's receipts
+		// Retrieve the requested block's receipts, pruned ones are not
+		// regenerated for remote peers as it would require re-executing
+		// the block. The response must stay aligned with the request, so
+		// it ends there.
+		if header := chain.GetHeaderByHash(hash); header != nil && chain.ReceiptsPruned(hash, header.Number.Uint64()) {
+			break
+		}
 		results := chain.GetReceiptsByHash(hash)
 		if results == nil {
 			if header := chain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
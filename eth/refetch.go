@@ -0,0 +1,145 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// refetchTimeout is the time a peer is given to answer a single request.
+const refetchTimeout = 10 * time.Second
+
+var (
+	// errNoRefetchPeer is returned if there are no peers to refetch from.
+	errNoRefetchPeer = errors.New("no peers to refetch from")
+
+	// errRefetchTimeout is returned if a peer didn't answer a request in time.
+	errRefetchTimeout = errors.New("request timed out")
+)
+
+// RefetchBlock retrieves the header, body and receipts of the block with the
+// given hash from the connected peers, trying them in turn until one delivers.
+// The data is validated against the hash and the roots of the header, so it can
+// be used to repair corrupted chain data.
+func (s *Ethereum) RefetchBlock(ctx context.Context, hash common.Hash) (*types.Header, *types.Body, types.Receipts, error) {
+	peers := s.handler.peers.all()
+	if len(peers) == 0 {
+		return nil, nil, nil, errNoRefetchPeer
+	}
+	var err error
+	for _, peer := range peers {
+		var (
+			header   *types.Header
+			body     *types.Body
+			receipts types.Receipts
+		)
+		if header, body, receipts, err = refetchBlock(ctx, peer.Peer, hash); err == nil {
+			return header, body, receipts, nil
+		}
+		peer.Log().Debug("Failed to refetch block", "hash", hash, "err", err)
+		if ctx.Err() != nil {
+			return nil, nil, nil, ctx.Err()
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("no peer delivered block %x, last error: %w", hash, err)
+}
+
+// refetchBlock retrieves and validates the data of a block from a single peer.
+func refetchBlock(ctx context.Context, peer *eth.Peer, hash common.Hash) (*types.Header, *types.Body, types.Receipts, error) {
+	res, err := refetchRequest(ctx, func(sink chan *eth.Response) (*eth.Request, error) {
+		return peer.RequestHeadersByHash(hash, 1, 0, false, sink)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	headers := *res.(*eth.BlockHeadersRequest)
+	if len(headers) != 1 || headers[0].Hash() != hash {
+		return nil, nil, nil, errors.New("invalid header")
+	}
+	header := headers[0]
+
+	res, err = refetchRequest(ctx, func(sink chan *eth.Response) (*eth.Request, error) {
+		return peer.RequestBodies([]common.Hash{hash}, sink)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bodies := *res.(*eth.BlockBodiesResponse)
+	if len(bodies) != 1 {
+		return nil, nil, nil, errors.New("missing body")
+	}
+	body := &types.Body{
+		Transactions: bodies[0].Transactions,
+		Uncles:       bodies[0].Uncles,
+		Withdrawals:  bodies[0].Withdrawals,
+	}
+	hasher := trie.NewStackTrie(nil)
+	if types.DeriveSha(types.Transactions(body.Transactions), hasher) != header.TxHash {
+		return nil, nil, nil, errors.New("transaction root mismatch")
+	}
+	if types.CalcUncleHash(body.Uncles) != header.UncleHash {
+		return nil, nil, nil, errors.New("uncle hash mismatch")
+	}
+	if header.WithdrawalsHash != nil {
+		if body.Withdrawals == nil || types.DeriveSha(types.Withdrawals(body.Withdrawals), hasher) != *header.WithdrawalsHash {
+			return nil, nil, nil, errors.New("withdrawal root mismatch")
+		}
+	}
+	res, err = refetchRequest(ctx, func(sink chan *eth.Response) (*eth.Request, error) {
+		return peer.RequestReceipts([]common.Hash{hash}, sink)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	receipts := *res.(*eth.ReceiptsResponse)
+	if len(receipts) != 1 || types.DeriveSha(types.Receipts(receipts[0]), hasher) != header.ReceiptHash {
+		return nil, nil, nil, errors.New("receipt root mismatch")
+	}
+	return header, body, receipts[0], nil
+}
+
+// refetchRequest sends a request to a peer and waits for the response, which
+// is accepted as is, it's up to the caller to validate it.
+func refetchRequest(ctx context.Context, send func(sink chan *eth.Response) (*eth.Request, error)) (interface{}, error) {
+	sink := make(chan *eth.Response)
+	req, err := send(sink)
+	if err != nil {
+		return nil, err
+	}
+	defer req.Close()
+
+	timeout := time.NewTimer(refetchTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout.C:
+		return nil, errRefetchTimeout
+	case res := <-sink:
+		res.Done <- nil
+		return res.Res, nil
+	}
+}
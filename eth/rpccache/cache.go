@@ -0,0 +1,486 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rpccache implements a JSON-RPC result cache for queries about chain
+// data which is buried deep enough below the head to be considered immutable.
+package rpccache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	missMeter       = metrics.NewRegisteredMeter("rpc/cache/miss", nil)
+	storeMeter      = metrics.NewRegisteredMeter("rpc/cache/store", nil)
+	invalidateMeter = metrics.NewRegisteredMeter("rpc/cache/invalidate", nil)
+	sizeGauge       = metrics.NewRegisteredGauge("rpc/cache/size", nil)
+)
+
+// DefaultDepth is the default number of blocks a result must be buried below
+// the chain head before it is considered immutable and eligible for caching.
+const DefaultDepth = 1024
+
+// Database key layout of the optional persistent result store.
+var (
+	resultPrefix = []byte("r") // resultPrefix + key -> number (uint64 big endian) + block hash + result
+	indexPrefix  = []byte("n") // indexPrefix + number (uint64 big endian) + key -> nil
+)
+
+// entryOverhead approximates the memory used by an entry besides its result.
+const entryOverhead = 2*common.HashLength + 8
+
+// Config contains the settings of the result cache.
+type Config struct {
+	Size  int                 // Memory allowance in megabytes
+	Depth uint64              // Confirmations after which a result is cached
+	Disk  ethdb.KeyValueStore // Optional store to retain results evicted from memory
+}
+
+// Chain defines the blockchain methods needed to decide whether a result is
+// immutable and to detect reorganisations invalidating cached results.
+type Chain interface {
+	CurrentHeader() *types.Header
+	GetHeader(hash common.Hash, number uint64) *types.Header
+	GetHeaderByHash(hash common.Hash) *types.Header
+	GetCanonicalHash(number uint64) common.Hash
+	GetTransactionLookup(hash common.Hash) (*rawdb.LegacyTxLookupEntry, *types.Transaction, error)
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// argKind describes how the block a call refers to is derived from its first
+// positional parameter.
+type argKind int
+
+const (
+	blockNumberArg argKind = iota
+	blockHashArg
+	blockNumberOrHashArg
+	txHashArg
+)
+
+// cacheableMethods lists the methods whose results are a pure function of the
+// referenced block or transaction and may thus be cached once it is final.
+var cacheableMethods = map[string]argKind{
+	"eth_getBlockByNumber":      blockNumberArg,
+	"eth_getBlockByHash":        blockHashArg,
+	"eth_getHeaderByNumber":     blockNumberArg,
+	"eth_getHeaderByHash":       blockHashArg,
+	"eth_getBlockReceipts":      blockNumberOrHashArg,
+	"eth_getTransactionByHash":  txHashArg,
+	"eth_getTransactionReceipt": txHashArg,
+	"debug_traceBlockByNumber":  blockNumberArg,
+	"debug_traceBlockByHash":    blockHashArg,
+	"debug_traceTransaction":    txHashArg,
+	"trace_block":               blockNumberArg,
+	"trace_transaction":         txHashArg,
+}
+
+// entry is a cached result along with the canonical block it was derived from.
+type entry struct {
+	number uint64
+	hash   common.Hash
+	result json.RawMessage
+}
+
+func (e *entry) size() uint64 {
+	return uint64(len(e.result) + entryOverhead)
+}
+
+// Cache is a rpc.ResultCache retaining the results of historical queries which
+// refer to blocks at least Depth blocks below the current head. Results are kept
+// in a size bounded LRU and optionally persisted to disk. Entries are validated
+// against the canonical chain on retrieval and purged on deep reorgs.
+type Cache struct {
+	chain Chain
+	depth uint64
+	disk  ethdb.KeyValueStore
+
+	lock  sync.Mutex
+	mem   lru.BasicLRU[common.Hash, *entry]
+	size  uint64
+	limit uint64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+var _ rpc.ResultCache = (*Cache)(nil)
+
+// New creates a result cache tracking the given chain and starts the goroutine
+// watching for reorganisations.
+func New(chain Chain, config Config) *Cache {
+	if config.Depth == 0 {
+		config.Depth = DefaultDepth
+	}
+	c := &Cache{
+		chain: chain,
+		depth: config.Depth,
+		disk:  config.Disk,
+		mem:   lru.NewBasicLRU[common.Hash, *entry](1 << 30), // bounded by size, not count
+		limit: uint64(config.Size) * 1024 * 1024,
+		quit:  make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.loop()
+	return c
+}
+
+// Close stops the reorg tracking of the cache.
+func (c *Cache) Close() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// Get implements rpc.ResultCache, returning a cached result if one exists and
+// the block it was derived from is still canonical.
+func (c *Cache) Get(method string, params json.RawMessage) (json.RawMessage, bool) {
+	if _, ok := cacheableMethods[method]; !ok {
+		return nil, false
+	}
+	key, err := cacheKey(method, params)
+	if err != nil {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.mem.Get(key)
+	if !ok && c.disk != nil {
+		if e = readEntry(c.disk, key); e != nil {
+			c.addMem(key, e)
+			ok = true
+		}
+	}
+	if !ok {
+		missMeter.Mark(1)
+		return nil, false
+	}
+	if c.chain.GetCanonicalHash(e.number) != e.hash {
+		c.remove(key, e)
+		invalidateMeter.Mark(1)
+		missMeter.Mark(1)
+		return nil, false
+	}
+	return e.result, true
+}
+
+// Put implements rpc.ResultCache, storing the result if it refers to a canonical
+// block buried at least the configured depth below the head.
+func (c *Cache) Put(method string, params json.RawMessage, result json.RawMessage) {
+	kind, ok := cacheableMethods[method]
+	if !ok || len(result) == 0 || bytes.Equal(result, []byte("null")) {
+		return
+	}
+	number, hash, ok := c.resolve(kind, params)
+	if !ok {
+		return
+	}
+	if head := c.chain.CurrentHeader().Number.Uint64(); number+c.depth > head {
+		return
+	}
+	key, err := cacheKey(method, params)
+	if err != nil {
+		return
+	}
+	e := &entry{number: number, hash: hash, result: common.CopyBytes(result)}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.addMem(key, e)
+	if c.disk != nil {
+		writeEntry(c.disk, key, e)
+	}
+	storeMeter.Mark(1)
+}
+
+// addMem inserts an entry into the memory cache, evicting the least recently
+// used entries until the size allowance is satisfied. The caller must hold the lock.
+func (c *Cache) addMem(key common.Hash, e *entry) {
+	if old, ok := c.mem.Peek(key); ok {
+		c.size -= old.size()
+	}
+	c.mem.Add(key, e)
+	c.size += e.size()
+	for c.size > c.limit {
+		_, evicted, ok := c.mem.RemoveOldest()
+		if !ok {
+			break
+		}
+		c.size -= evicted.size()
+	}
+	sizeGauge.Update(int64(c.size))
+}
+
+// remove deletes an entry from memory and disk. The caller must hold the lock.
+func (c *Cache) remove(key common.Hash, e *entry) {
+	if c.mem.Remove(key) {
+		c.size -= e.size()
+		sizeGauge.Update(int64(c.size))
+	}
+	if c.disk != nil {
+		deleteEntry(c.disk, key, e.number)
+	}
+}
+
+// resolve determines the canonical block a call refers to based on its first
+// positional parameter.
+func (c *Cache) resolve(kind argKind, params json.RawMessage) (uint64, common.Hash, bool) {
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) == 0 {
+		return 0, common.Hash{}, false
+	}
+	var (
+		number uint64
+		want   common.Hash // block hash the call explicitly refers to, if any
+	)
+	switch kind {
+	case blockNumberArg:
+		var n rpc.BlockNumber
+		if err := json.Unmarshal(args[0], &n); err != nil || n < 0 {
+			return 0, common.Hash{}, false
+		}
+		number = uint64(n)
+
+	case blockHashArg, blockNumberOrHashArg:
+		var ref rpc.BlockNumberOrHash
+		if err := json.Unmarshal(args[0], &ref); err != nil {
+			return 0, common.Hash{}, false
+		}
+		if hash, ok := ref.Hash(); ok {
+			header := c.chain.GetHeaderByHash(hash)
+			if header == nil {
+				return 0, common.Hash{}, false
+			}
+			number, want = header.Number.Uint64(), hash
+		} else if n, ok := ref.Number(); ok && kind == blockNumberOrHashArg && n >= 0 {
+			number = uint64(n)
+		} else {
+			return 0, common.Hash{}, false
+		}
+
+	case txHashArg:
+		var hash common.Hash
+		if err := json.Unmarshal(args[0], &hash); err != nil {
+			return 0, common.Hash{}, false
+		}
+		lookup, _, err := c.chain.GetTransactionLookup(hash)
+		if err != nil || lookup == nil {
+			return 0, common.Hash{}, false
+		}
+		number, want = lookup.BlockIndex, lookup.BlockHash
+	}
+	hash := c.chain.GetCanonicalHash(number)
+	if hash == (common.Hash{}) || (want != (common.Hash{}) && want != hash) {
+		return 0, common.Hash{}, false
+	}
+	return number, hash, true
+}
+
+// loop watches the chain head and purges cached results invalidated by reorgs
+// deeper than the cache depth.
+func (c *Cache) loop() {
+	defer c.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := c.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	last := c.chain.CurrentHeader()
+	for {
+		select {
+		case ev := <-heads:
+			head := ev.Block.Header()
+			if ancestor, ok := c.forkPoint(last); ok && ancestor+c.depth < last.Number.Uint64() {
+				log.Warn("Purging RPC result cache after deep reorg", "ancestor", ancestor, "oldhead", last.Number, "newhead", head.Number)
+				c.purge(ancestor)
+			}
+			last = head
+
+		case <-sub.Err():
+			return
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// forkPoint returns the number of the newest ancestor of the given previous
+// head which is still canonical, if the previous head was reorged out.
+func (c *Cache) forkPoint(prev *types.Header) (uint64, bool) {
+	header := prev
+	for header != nil && c.chain.GetCanonicalHash(header.Number.Uint64()) != header.Hash() {
+		if header.Number.Sign() == 0 {
+			return 0, true
+		}
+		header = c.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	if header == nil || header == prev {
+		return 0, false
+	}
+	return header.Number.Uint64(), true
+}
+
+// purge drops all cached results derived from blocks above the given number.
+func (c *Cache) purge(number uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var purged int
+	for _, key := range c.mem.Keys() {
+		if e, ok := c.mem.Peek(key); ok && e.number > number {
+			c.mem.Remove(key)
+			c.size -= e.size()
+			purged++
+		}
+	}
+	sizeGauge.Update(int64(c.size))
+
+	if c.disk != nil {
+		it := c.disk.NewIterator(indexPrefix, encodeNumber(number+1))
+		defer it.Release()
+
+		batch := c.disk.NewBatch()
+		for it.Next() {
+			if len(it.Key()) != len(indexPrefix)+8+common.HashLength {
+				continue
+			}
+			batch.Delete(it.Key())
+			batch.Delete(resultKey(common.BytesToHash(it.Key()[len(indexPrefix)+8:])))
+			purged++
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Error("Failed to purge RPC result cache", "err", err)
+					return
+				}
+				batch.Reset()
+			}
+		}
+		if err := batch.Write(); err != nil {
+			log.Error("Failed to purge RPC result cache", "err", err)
+		}
+	}
+	invalidateMeter.Mark(int64(purged))
+}
+
+// cacheKey derives the cache key of a call from its method name and canonical
+// form of its parameters.
+func cacheKey(method string, params json.RawMessage) (common.Hash, error) {
+	var args []interface{}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.UseNumber()
+	if err := dec.Decode(&args); err != nil {
+		return common.Hash{}, err
+	}
+	canon, err := json.Marshal(canonicalize(args))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte(method), canon), nil
+}
+
+// canonicalize normalizes a decoded JSON value so that semantically equivalent
+// parameters map to the same cache key. Object keys are sorted by the encoder,
+// hex strings are lowercased here.
+func canonicalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if strings.HasPrefix(v, "0x") || strings.HasPrefix(v, "0X") {
+			return strings.ToLower(v)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = canonicalize(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = canonicalize(v[k])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func encodeNumber(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return enc
+}
+
+func resultKey(key common.Hash) []byte {
+	return append(append([]byte{}, resultPrefix...), key.Bytes()...)
+}
+
+func indexKey(number uint64, key common.Hash) []byte {
+	return append(append(append([]byte{}, indexPrefix...), encodeNumber(number)...), key.Bytes()...)
+}
+
+// readEntry loads a persisted result from disk.
+func readEntry(db ethdb.KeyValueReader, key common.Hash) *entry {
+	blob, err := db.Get(resultKey(key))
+	if err != nil || len(blob) < 8+common.HashLength {
+		return nil
+	}
+	return &entry{
+		number: binary.BigEndian.Uint64(blob[:8]),
+		hash:   common.BytesToHash(blob[8 : 8+common.HashLength]),
+		result: blob[8+common.HashLength:],
+	}
+}
+
+// writeEntry persists a result along with its block number index.
+func writeEntry(db ethdb.KeyValueStore, key common.Hash, e *entry) {
+	blob := make([]byte, 0, 8+common.HashLength+len(e.result))
+	blob = append(blob, encodeNumber(e.number)...)
+	blob = append(blob, e.hash.Bytes()...)
+	blob = append(blob, e.result...)
+
+	batch := db.NewBatch()
+	batch.Put(resultKey(key), blob)
+	batch.Put(indexKey(e.number, key), nil)
+	if err := batch.Write(); err != nil {
+		log.Warn("Failed to persist RPC result", "err", err)
+	}
+}
+
+// deleteEntry removes a persisted result along with its block number index.
+func deleteEntry(db ethdb.KeyValueStore, key common.Hash, number uint64) {
+	batch := db.NewBatch()
+	batch.Delete(resultKey(key))
+	batch.Delete(indexKey(number, key))
+	if err := batch.Write(); err != nil {
+		log.Warn("Failed to delete RPC result", "err", err)
+	}
+}
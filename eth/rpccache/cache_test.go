@@ -0,0 +1,210 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpccache
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/types/genesisT"
+	"github.com/ethereum/go-ethereum/params/vars"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+)
+
+// testGenesis is the genesis of the test chains, funding the transaction sender.
+var testGenesis = &genesisT.Genesis{
+	Config: params.TestChainConfig,
+	Alloc:  genesisT.GenesisAlloc{testAddress: {Balance: big.NewInt(vars.Ether)}},
+}
+
+// generateBlocks creates n blocks each containing a single transaction. Blocks
+// above forkAt are mined by a different coinbase.
+func generateBlocks(n int, forkAt int) []*types.Block {
+	signer := types.LatestSigner(testGenesis.Config)
+	_, blocks, _ := core.GenerateChainWithGenesis(testGenesis, ethash.NewFaker(), n, func(i int, gen *core.BlockGen) {
+		if i >= forkAt {
+			gen.SetCoinbase(common.Address{0xff})
+		}
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(testAddress), common.Address{1}, big.NewInt(1), vars.TxGas, gen.BaseFee(), nil), signer, testKey)
+		gen.AddTx(tx)
+	})
+	return blocks
+}
+
+// newTestChain creates a blockchain of n blocks, each containing a single transaction.
+func newTestChain(t *testing.T, n int) (*core.BlockChain, []*types.Block) {
+	blocks := generateBlocks(n, n)
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, testGenesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	return chain, blocks
+}
+
+func args(values ...interface{}) json.RawMessage {
+	enc, _ := json.Marshal(values)
+	return enc
+}
+
+func TestCacheImmutableResults(t *testing.T) {
+	chain, blocks := newTestChain(t, 20)
+	defer chain.Stop()
+
+	cache := New(chain, Config{Size: 1, Depth: 4})
+	defer cache.Close()
+
+	result := json.RawMessage(`{"result":true}`)
+	tests := []struct {
+		method string
+		params json.RawMessage
+		cached bool
+	}{
+		{"eth_getBlockByNumber", args("0x5", false), true},
+		{"eth_getBlockByNumber", args("0x10", false), true},
+		{"eth_getBlockByNumber", args("0x11", false), false}, // too recent
+		{"eth_getBlockByNumber", args("latest", false), false},
+		{"eth_getBlockByNumber", args("0x100", false), false}, // unknown block
+		{"eth_getBlockByHash", args(blocks[2].Hash(), true), true},
+		{"eth_getBlockByHash", args(blocks[18].Hash(), true), false},
+		{"eth_getBlockReceipts", args(blocks[3].Hash()), true},
+		{"eth_getBlockReceipts", args("0x3"), true},
+		{"eth_getTransactionReceipt", args(blocks[4].Transactions()[0].Hash()), true},
+		{"debug_traceTransaction", args(blocks[19].Transactions()[0].Hash()), false},
+		{"eth_getBalance", args(testAddress, "0x1"), false}, // not a cacheable method
+	}
+	for i, tt := range tests {
+		cache.Put(tt.method, tt.params, result)
+		if _, ok := cache.Get(tt.method, tt.params); ok != tt.cached {
+			t.Errorf("test %d (%s %s): cached mismatch: have %v, want %v", i, tt.method, tt.params, ok, tt.cached)
+		}
+	}
+	// Parameters differing only in the case of hex strings must share the entry.
+	upper := json.RawMessage(fmt.Sprintf(`["0x%X",true]`, blocks[2].Hash().Bytes()))
+	if _, ok := cache.Get("eth_getBlockByHash", upper); !ok {
+		t.Errorf("canonicalized parameters not served from cache: %s", upper)
+	}
+	// Null results must not be cached.
+	cache.Put("eth_getBlockByNumber", args("0x6", true), json.RawMessage("null"))
+	if _, ok := cache.Get("eth_getBlockByNumber", args("0x6", true)); ok {
+		t.Errorf("null result cached")
+	}
+}
+
+func TestCacheMemoryLimit(t *testing.T) {
+	chain, _ := newTestChain(t, 8)
+	defer chain.Stop()
+
+	cache := New(chain, Config{Size: 1, Depth: 1})
+	defer cache.Close()
+
+	// Each result is a quarter of the allowance, so only the last three fit.
+	result := json.RawMessage(`"` + strings.Repeat("a", 256*1024) + `"`)
+	for i := 1; i <= 6; i++ {
+		cache.Put("eth_getBlockByNumber", args(hexutil.Uint64(i), false), result)
+	}
+	for i := 1; i <= 6; i++ {
+		_, ok := cache.Get("eth_getBlockByNumber", args(hexutil.Uint64(i), false))
+		if want := i > 3; ok != want {
+			t.Errorf("block %d: cached mismatch: have %v, want %v", i, ok, want)
+		}
+	}
+	if cache.size > cache.limit {
+		t.Errorf("cache exceeds allowance: have %d, limit %d", cache.size, cache.limit)
+	}
+}
+
+func TestCacheDiskSpill(t *testing.T) {
+	chain, _ := newTestChain(t, 8)
+	defer chain.Stop()
+
+	disk := rawdb.NewMemoryDatabase()
+	cache := New(chain, Config{Size: 0, Depth: 1, Disk: disk})
+	defer cache.Close()
+
+	// With no memory allowance every result must be served from disk.
+	result := json.RawMessage(`{"number":"0x2"}`)
+	cache.Put("eth_getBlockByNumber", args("0x2", false), result)
+	if cache.mem.Len() != 0 {
+		t.Fatalf("memory cache not empty: %d", cache.mem.Len())
+	}
+	have, ok := cache.Get("eth_getBlockByNumber", args("0x2", false))
+	if !ok || string(have) != string(result) {
+		t.Fatalf("result not served from disk: have %s", have)
+	}
+	// A fresh cache on the same database must serve persisted results.
+	reopened := New(chain, Config{Size: 1, Depth: 1, Disk: disk})
+	defer reopened.Close()
+
+	if _, ok := reopened.Get("eth_getBlockByNumber", args("0x2", false)); !ok {
+		t.Fatalf("persisted result not found")
+	}
+}
+
+func TestCacheReorgInvalidation(t *testing.T) {
+	chain, _ := newTestChain(t, 10)
+	defer chain.Stop()
+
+	disk := rawdb.NewMemoryDatabase()
+	cache := New(chain, Config{Size: 1, Depth: 2, Disk: disk})
+	defer cache.Close()
+
+	result := json.RawMessage(`{"result":true}`)
+	for i := 1; i <= 8; i++ {
+		cache.Put("eth_getBlockByNumber", args(hexutil.Uint64(i), false), result)
+	}
+	// Replace the chain above block 3 with a heavier fork.
+	if _, err := chain.InsertChain(generateBlocks(15, 3)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 8; i++ {
+		_, ok := cache.Get("eth_getBlockByNumber", args(hexutil.Uint64(i), false))
+		if want := i <= 3; ok != want {
+			t.Errorf("block %d: cached mismatch after reorg: have %v, want %v", i, ok, want)
+		}
+	}
+	// Purging must remove the persisted entries above the fork point.
+	cache.purge(3)
+	it := disk.NewIterator(indexPrefix, nil)
+	defer it.Release()
+
+	var entries int
+	for it.Next() {
+		entries++
+	}
+	if entries != 3 {
+		t.Errorf("wrong number of persisted entries after purge: have %d, want 3", entries)
+	}
+}
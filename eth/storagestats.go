@@ -0,0 +1,239 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// StorageStatsStatus is the progress of a storage statistics collection, along
+// with its report once finished.
+type StorageStatsStatus struct {
+	Started  time.Time               `json:"started"`
+	Block    uint64                  `json:"block"`    // Block whose state is being traversed
+	Position common.Hash             `json:"position"` // Last account traversed
+	Accounts uint64                  `json:"accounts"` // Number of accounts traversed
+	Restarts uint64                  `json:"restarts"` // Number of times the traversal moved to a newer state
+	Done     bool                    `json:"done"`
+	Error    string                  `json:"error,omitempty"`
+	Report   *snapshot.StorageReport `json:"report,omitempty"`
+}
+
+// storageStatsTask is a storage statistics collection running in the background.
+// If the requested state is the head, the traversal continues on the new head
+// state whenever the snapshot of the previous one becomes stale.
+type storageStatsTask struct {
+	chain  *core.BlockChain
+	db     ethdb.Database
+	number rpc.BlockNumber  // Requested state, rpc.LatestBlockNumber follows the head
+	base   *rpc.BlockNumber // Optional state to compute the growth against
+	stats  *snapshot.StorageStats
+
+	started  time.Time
+	block    uint64
+	restarts uint64
+	report   *snapshot.StorageReport
+	err      error
+	lock     sync.Mutex
+
+	abort chan struct{}
+	done  chan struct{}
+}
+
+// newStorageStatsTask creates a storage statistics collection of the state at
+// the given block, ranking the given number of accounts.
+func newStorageStatsTask(chain *core.BlockChain, db ethdb.Database, number rpc.BlockNumber, base *rpc.BlockNumber, top int) *storageStatsTask {
+	return &storageStatsTask{
+		chain:   chain,
+		db:      db,
+		number:  number,
+		base:    base,
+		stats:   snapshot.NewStorageStats(top),
+		started: time.Now(),
+		abort:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// header resolves the header of a requested state.
+func (t *storageStatsTask) header(number rpc.BlockNumber) (*types.Header, error) {
+	var header *types.Header
+	switch number {
+	case rpc.LatestBlockNumber:
+		header = t.chain.CurrentBlock()
+	case rpc.FinalizedBlockNumber:
+		header = t.chain.CurrentFinalBlock()
+	case rpc.SafeBlockNumber:
+		header = t.chain.CurrentSafeBlock()
+	case rpc.PendingBlockNumber:
+		return nil, errors.New("pending state is not supported")
+	default:
+		header = t.chain.GetHeaderByNumber(uint64(number))
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return header, nil
+}
+
+// run traverses the state until finished, failed or aborted.
+func (t *storageStatsTask) run() {
+	defer close(t.done)
+
+	var base *common.Hash
+	if t.base != nil {
+		header, err := t.header(*t.base)
+		if err != nil {
+			t.finish(err)
+			return
+		}
+		base = &header.Root
+	}
+	for {
+		header, err := t.header(t.number)
+		if err != nil {
+			t.finish(err)
+			return
+		}
+		t.lock.Lock()
+		t.block = header.Number.Uint64()
+		t.lock.Unlock()
+
+		err = t.stats.Collect(t.chain.Snapshots(), t.chain.TrieDB(), header.Root, base, t.abort)
+		if errors.Is(err, snapshot.ErrSnapshotStale) && t.number == rpc.LatestBlockNumber {
+			log.Debug("Storage statistics state went stale, moving to head", "number", header.Number)
+
+			t.lock.Lock()
+			t.restarts++
+			t.lock.Unlock()
+			continue
+		}
+		t.finish(err)
+		return
+	}
+}
+
+// finish records the outcome of the collection.
+func (t *storageStatsTask) finish(err error) {
+	var report *snapshot.StorageReport
+	if err == nil {
+		report = t.stats.Report(t.db)
+		log.Info("Collected storage statistics", "block", t.block, "contracts", report.Contracts, "slots", report.Slots, "elapsed", common.PrettyDuration(time.Since(t.started)))
+	} else {
+		log.Warn("Failed to collect storage statistics", "err", err)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.report, t.err = report, err
+}
+
+// stop aborts the collection and waits for it to terminate.
+func (t *storageStatsTask) stop() {
+	select {
+	case <-t.abort:
+	default:
+		close(t.abort)
+	}
+	<-t.done
+}
+
+// status reports the progress of the collection.
+func (t *storageStatsTask) status() *StorageStatsStatus {
+	position, accounts := t.stats.Progress()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	status := &StorageStatsStatus{
+		Started:  t.started,
+		Block:    t.block,
+		Position: position,
+		Accounts: accounts,
+		Restarts: t.restarts,
+		Report:   t.report,
+	}
+	select {
+	case <-t.done:
+		status.Done = true
+	default:
+	}
+	if t.err != nil {
+		status.Error = t.err.Error()
+	}
+	return status
+}
+
+// StartStorageStats starts collecting the storage statistics of the state at
+// the given block in the background, optionally computing the growth since
+// the state of an older block.
+func (s *Ethereum) StartStorageStats(number rpc.BlockNumber, base *rpc.BlockNumber, top int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.storageStats != nil {
+		select {
+		case <-s.storageStats.done:
+		default:
+			return errors.New("storage statistics collection is already running")
+		}
+	}
+	s.storageStats = newStorageStatsTask(s.blockchain, s.chainDb, number, base, top)
+	go s.storageStats.run()
+	return nil
+}
+
+// StopStorageStats aborts the running storage statistics collection, if any.
+func (s *Ethereum) StopStorageStats() bool {
+	s.lock.RLock()
+	task := s.storageStats
+	s.lock.RUnlock()
+
+	if task == nil {
+		return false
+	}
+	select {
+	case <-task.done:
+		return false
+	default:
+	}
+	task.stop()
+	return true
+}
+
+// StorageStatsStatus returns the progress of the last storage statistics
+// collection, nil if none has been started.
+func (s *Ethereum) StorageStatsStatus() *StorageStatsStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.storageStats == nil {
+		return nil
+	}
+	return s.storageStats.status()
+}
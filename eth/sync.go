@@ -19,15 +19,16 @@ package eth
 import (
 	"errors"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/ethereum/go-ethereum/params/vars"
 )
 
 const (
@@ -35,17 +36,35 @@ const (
 	defaultMinSyncPeers = 5                // Amount of peers desired to start syncing
 )
 
-var (
-	// minArtificialFinalityPeers defines the minimum number of peers our node must be connected
-	// to in order to enable artificial finality features.
-	// A minimum number of peer connections mitigates the risk of lower-powered eclipse attacks.
-	minArtificialFinalityPeers = defaultMinSyncPeers
+// afSafetyState is a snapshot of the conditions artificial finality depends on.
+type afSafetyState struct {
+	peers   int           // Number of connected peers
+	subnets int           // Number of distinct /16 subnets among the peers
+	clients int           // Number of distinct client implementations among the peers
+	headAge time.Duration // Age of the local head block
+}
 
-	// artificialFinalitySafetyInterval defines the interval at which the local head is checked for staleness.
-	// If the head is found to be stale across this interval, artificial finality features are disabled.
-	// This prevents an abandoned victim of an eclipse attack from being forever destitute.
-	artificialFinalitySafetyInterval = time.Second * time.Duration(30*vars.DurationLimit.Uint64())
-)
+// artificialFinalitySafety measures the current artificial finality safety state.
+func (h *handler) artificialFinalitySafety() afSafetyState {
+	subnets, clients := h.peers.diversity()
+	return afSafetyState{
+		peers:   h.peers.len(),
+		subnets: subnets,
+		clients: clients,
+		headAge: time.Since(time.Unix(int64(h.chain.CurrentHeader().Time), 0)),
+	}
+}
+
+// unmet returns the safety conditions not satisfied by the state.
+func (s afSafetyState) unmet(cfg ethconfig.ArtificialFinalitySafety) []string {
+	return cfg.Unmet(s.peers, s.subnets, s.clients, s.headAge)
+}
+
+// logCtx returns the state as logging context, along with the reason of the
+// artificial finality state change.
+func (s afSafetyState) logCtx(reason string) []interface{} {
+	return []interface{}{"reason", reason, "peers", s.peers, "subnets", s.subnets, "clients", s.clients, "age", common.PrettyAge(time.Now().Add(-s.headAge))}
+}
 
 // artificialFinalitySafetyLoop compares our local head across timer intervals.
 // If it changes, assuming the interval is sufficiently long,
@@ -56,7 +75,13 @@ var (
 func (h *handler) artificialFinalitySafetyLoop() {
 	defer h.wg.Done()
 
-	t := time.NewTicker(artificialFinalitySafetyInterval)
+	interval := h.afSafety.StaleInterval
+	if interval <= 0 {
+		// Staleness check disabled, nothing to do
+		<-h.quitSync
+		return
+	}
+	t := time.NewTicker(interval)
 	defer t.Stop()
 
 	for {
@@ -66,8 +91,8 @@ func (h *handler) artificialFinalitySafetyLoop() {
 				// Check if your chain has grown stale.
 				// If it has, disable artificial finality, we could be on an attacker's
 				// chain getting starved.
-				if time.Since(time.Unix(int64(h.chain.CurrentHeader().Time), 0)) > artificialFinalitySafetyInterval {
-					h.chain.EnableArtificialFinality(false, "reason", "stale safety interval", "interval", artificialFinalitySafetyInterval)
+				if time.Since(time.Unix(int64(h.chain.CurrentHeader().Time), 0)) > interval {
+					h.chain.EnableArtificialFinality(false, "reason", "stale safety interval", "interval", interval)
 				}
 			}
 		case <-h.quitSync:
@@ -78,11 +103,18 @@ func (h *handler) artificialFinalitySafetyLoop() {
 
 // syncTransactions starts sending all currently pending transactions to the given peer.
 func (h *handler) syncTransactions(p *eth.Peer) {
-	var hashes []common.Hash
-	for _, batch := range h.txpool.Pending(txpool.PendingFilter{OnlyPlainTxs: true}) {
-		for _, tx := range batch {
-			hashes = append(hashes, tx.Hash)
+	var (
+		hashes  []common.Hash
+		pending = h.txpool.Pending(txpool.PendingFilter{OnlyPlainTxs: true})
+	)
+	if h.txPolicies.empty() {
+		for _, batch := range pending {
+			for _, tx := range batch {
+				hashes = append(hashes, tx.Hash)
+			}
 		}
+	} else {
+		hashes = h.policyTransactions(p, pending)
 	}
 	if len(hashes) == 0 {
 		return
@@ -90,6 +122,30 @@ func (h *handler) syncTransactions(p *eth.Peer) {
 	p.AsyncSendPooledTransactionHashes(hashes)
 }
 
+// policyTransactions returns the hashes of the pending transactions the
+// propagation policies would have announced to the given peer.
+func (h *handler) policyTransactions(p *eth.Peer, pending map[common.Address][]*txpool.LazyTransaction) []common.Hash {
+	var (
+		hashes []common.Hash
+		local  = h.localTxChecker()
+		peers  = []*eth.Peer{p}
+	)
+	for _, batch := range pending {
+		for _, ltx := range batch {
+			if tx := ltx.Resolve(); tx != nil {
+				if delay := h.txPolicies.delay(tx, func() bool { return local(tx) }); delay > 0 && time.Since(tx.Time()) < delay {
+					continue
+				}
+				if _, announce := h.txPolicies.relay(tx, nil, peers); len(announce) == 0 {
+					continue
+				}
+			}
+			hashes = append(hashes, ltx.Hash)
+		}
+	}
+	return hashes
+}
+
 // chainSyncer coordinates blockchain sync components.
 type chainSyncer struct {
 	handler     *handler
@@ -203,9 +259,10 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 		minPeers = cs.handler.maxPeers
 	}
 	if cs.handler.chain.IsArtificialFinalityEnabled() {
-		if cs.handler.peers.len() < minArtificialFinalityPeers {
+		safety := cs.handler.artificialFinalitySafety()
+		if unmet := safety.unmet(cs.handler.afSafety); len(unmet) > 0 {
 			// If artificial finality state is forcefully set (overridden) this will just be a noop.
-			cs.handler.chain.EnableArtificialFinality(false, "reason", "low peers", "peers", cs.handler.peers.len())
+			cs.handler.chain.EnableArtificialFinality(false, safety.logCtx(strings.Join(unmet, ", "))...)
 		}
 	}
 	if cs.handler.peers.len() < minPeers {
@@ -230,14 +287,12 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 		}
 		// Enable artificial finality if parameters if should.
 		// - In full sync mode.
-		if op.mode == downloader.FullSync &&
-			// - Have enough peers.
-			cs.handler.peers.len() >= minArtificialFinalityPeers &&
-			// - Head is not stale.
-			!(time.Since(time.Unix(int64(cs.handler.chain.CurrentHeader().Time), 0)) > artificialFinalitySafetyInterval) &&
-			// - AF is disabled (so we should reenable).
-			!cs.handler.chain.IsArtificialFinalityEnabled() {
-			cs.handler.chain.EnableArtificialFinality(true, "reason", "synced", "peers", cs.handler.peers.len())
+		// - AF is disabled (so we should reenable).
+		if op.mode == downloader.FullSync && !cs.handler.chain.IsArtificialFinalityEnabled() {
+			// - Have enough diverse peers and the head is not stale.
+			if safety := cs.handler.artificialFinalitySafety(); len(safety.unmet(cs.handler.afSafety)) == 0 {
+				cs.handler.chain.EnableArtificialFinality(true, safety.logCtx("synced")...)
+			}
 		}
 		return nil // We're in sync.
 	}
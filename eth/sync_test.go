@@ -18,6 +18,7 @@ package eth
 
 import (
 	"math/big"
+	"reflect"
 	"testing"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -171,13 +173,6 @@ func TestArtificialFinalityFeatureEnablingDisabling(t *testing.T) {
 	one := uint64(1)
 	a.chain.Config().SetECBP1100Transition(&one)
 
-	oMinAFPeers := minArtificialFinalityPeers
-	defer func() {
-		// Clean up after, resetting global default to original value.
-		minArtificialFinalityPeers = oMinAFPeers
-	}()
-	minArtificialFinalityPeers = 1
-
 	// Create a full protocol manager, check that fast sync gets disabled
 	b := newTestHandlerWithBlocksWithOpts(0, downloader.FullSync, genFunc)
 	if b.handler.snapSync.Load() {
@@ -185,6 +180,11 @@ func TestArtificialFinalityFeatureEnablingDisabling(t *testing.T) {
 	}
 	defer b.close()
 	b.chain.Config().SetECBP1100Transition(&one)
+
+	// Relax the safety conditions to the single test peer, which has no IP address.
+	b.handler.afSafety.MinPeers = 1
+	b.handler.afSafety.MinSubnets = 0
+	b.handler.afSafety.MinClients = 1
 	// b.chainSync.forced = true
 
 	// Sync up the two handlers
@@ -224,7 +224,7 @@ func TestArtificialFinalityFeatureEnablingDisabling(t *testing.T) {
 	}
 
 	// Set the value back to default (more than 1).
-	minArtificialFinalityPeers = oMinAFPeers
+	b.handler.afSafety = ethconfig.DefaultArtificialFinalitySafety
 
 	// Next sync op will unset AF because manager only has 1 peer.
 	b.handler.chainSync.forced = true
@@ -250,18 +250,16 @@ func TestArtificialFinalityFeatureEnablingDisabling_NoDisable(t *testing.T) {
 	one := uint64(1)
 	a.chain.Config().SetECBP1100Transition(&one)
 
-	oMinAFPeers := minArtificialFinalityPeers
-	defer func() {
-		// Clean up after, resetting global default to original value.
-		minArtificialFinalityPeers = oMinAFPeers
-	}()
-	minArtificialFinalityPeers = 1
-
 	// Create a full protocol manager, check that fast sync gets disabled
 	b := newTestHandlerWithBlocksWithOpts(0, downloader.FullSync, genFunc)
 	defer b.close()
 	b.chain.Config().SetECBP1100Transition(&one)
 
+	// Relax the safety conditions to the single test peer, which has no IP address.
+	b.handler.afSafety.MinPeers = 1
+	b.handler.afSafety.MinSubnets = 0
+	b.handler.afSafety.MinClients = 1
+
 	// NOTE1: Set the nodisable switch to the on position.
 	// This prevents the de-activation of AF features once they have been enabled.
 	// In geth code, this is set in the backend during blockchain construction.
@@ -296,7 +294,7 @@ func TestArtificialFinalityFeatureEnablingDisabling_NoDisable(t *testing.T) {
 
 	// Revert safety condition overrides to default values.
 	// Set the value back to default (more than 1).
-	minArtificialFinalityPeers = oMinAFPeers
+	b.handler.afSafety = ethconfig.DefaultArtificialFinalitySafety
 
 	if next != nil {
 		t.Fatal("non-nil next sync op")
@@ -317,7 +315,7 @@ func TestArtificialFinalityFeatureEnablingDisabling_NoDisable(t *testing.T) {
 	if !b.chain.IsArtificialFinalityEnabled() {
 		t.Error(`AF not enabled;
 The AF disable mechanism triggered by the minimum peers floor should have been short-circuited,
-preventing AF disablement on this sync op (with the minArtificialFinalityPeers value set to > 1 (defaultMinSyncPeers = 5)),
+preventing AF disablement on this sync op (with the MinPeers safety value set to > 1 (defaultMinSyncPeers = 5)),
 and the number of peers 'a' is connected with being only 1.)`)
 	}
 }
@@ -335,18 +333,16 @@ func TestArtificialFinalityFeatureEnablingDisabling_StaleHead(t *testing.T) {
 	one := uint64(1)
 	a.chain.Config().SetECBP1100Transition(&one)
 
-	oMinAFPeers := minArtificialFinalityPeers
-	defer func() {
-		// Clean up after, resetting global default to original value.
-		minArtificialFinalityPeers = oMinAFPeers
-	}()
-	minArtificialFinalityPeers = 1
-
 	// Create a full protocol manager, check that fast sync gets disabled
 	b := newTestHandlerWithBlocksWithOpts(0, downloader.FullSync, nil)
 	defer b.close()
 	b.chain.Config().SetECBP1100Transition(&one)
 
+	// Relax the safety conditions to the single test peer, which has no IP address.
+	b.handler.afSafety.MinPeers = 1
+	b.handler.afSafety.MinSubnets = 0
+	b.handler.afSafety.MinClients = 1
+
 	// Sync up the two handlers
 	emptyPipe, fullPipe := p2p.MsgPipe()
 	defer emptyPipe.Close()
@@ -376,7 +372,7 @@ func TestArtificialFinalityFeatureEnablingDisabling_StaleHead(t *testing.T) {
 
 	// Revert safety condition overrides to default values.
 	// Set the value back to default (more than 1).
-	minArtificialFinalityPeers = oMinAFPeers
+	b.handler.afSafety = ethconfig.DefaultArtificialFinalitySafety
 
 	if next != nil {
 		t.Fatal("non-nil next sync op")
@@ -387,7 +383,7 @@ func TestArtificialFinalityFeatureEnablingDisabling_StaleHead(t *testing.T) {
 	// Unit test the timestamp. We want to be sure that the blockchain's current header is actually very old
 	// (since we expect its age to act as a condition preventing the enabling of AF features).
 	d := uint64(time.Now().Unix()) - b.chain.CurrentHeader().Time
-	if time.Second*time.Duration(d) < artificialFinalitySafetyInterval {
+	if time.Second*time.Duration(d) < ethconfig.DefaultArtificialFinalitySafety.StaleInterval {
 		t.Errorf("Expected blockchain current head to be very old, it is not.")
 	}
 	if b.chain.IsArtificialFinalityEnabled() {
@@ -396,7 +392,44 @@ func TestArtificialFinalityFeatureEnablingDisabling_StaleHead(t *testing.T) {
 }
 
 func TestArtificialFinalitySafetyLoopTimeComparison(t *testing.T) {
-	if !(time.Since(time.Unix(int64(params.DefaultMessNetGenesisBlock().Timestamp), 0)) > artificialFinalitySafetyInterval) {
+	if !(time.Since(time.Unix(int64(params.DefaultMessNetGenesisBlock().Timestamp), 0)) > ethconfig.DefaultArtificialFinalitySafety.StaleInterval) {
 		t.Fatal("bad unit logic!")
 	}
 }
+
+func TestArtificialFinalitySafetyConditions(t *testing.T) {
+	cfg := ethconfig.DefaultArtificialFinalitySafety
+	tests := []struct {
+		state afSafetyState
+		unmet []string
+	}{
+		{afSafetyState{peers: 5, subnets: 3, clients: 2}, nil},
+		{afSafetyState{peers: 4, subnets: 3, clients: 2}, []string{"low peers"}},
+		{afSafetyState{peers: 25, subnets: 2, clients: 2}, []string{"low subnet diversity"}},
+		{afSafetyState{peers: 5, subnets: 3, clients: 1}, []string{"low client diversity"}},
+		{afSafetyState{peers: 5, subnets: 3, clients: 2, headAge: cfg.StaleInterval + time.Second}, []string{"stale head"}},
+		{afSafetyState{headAge: cfg.StaleInterval + time.Second}, []string{"low peers", "low subnet diversity", "low client diversity", "stale head"}},
+	}
+	for i, tt := range tests {
+		if unmet := tt.state.unmet(cfg); !reflect.DeepEqual(unmet, tt.unmet) {
+			t.Errorf("test %d: unmet conditions mismatch: have %v, want %v", i, unmet, tt.unmet)
+		}
+	}
+	// Zero thresholds disable the conditions
+	if unmet := (afSafetyState{headAge: cfg.StaleInterval + time.Second}).unmet(ethconfig.ArtificialFinalitySafety{}); len(unmet) != 0 {
+		t.Errorf("disabled conditions unmet: %v", unmet)
+	}
+}
+
+func TestClientName(t *testing.T) {
+	tests := map[string]string{
+		"CoreGeth/v1.12.20-stable-c2fb4412/linux-amd64/go1.21.10": "coregeth",
+		"Geth/v1.13.15-stable/linux-amd64/go1.22.1":               "geth",
+		"besu/v24.1.2/linux-x86_64/openjdk-java-17":               "besu",
+	}
+	for fullname, want := range tests {
+		if name := clientName(fullname); name != want {
+			t.Errorf("client name mismatch for %q: have %q, want %q", fullname, name, want)
+		}
+	}
+}
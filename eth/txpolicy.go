@@ -0,0 +1,384 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// TxPolicy controls how transactions are propagated to, and accepted from, the
+// connected peers. Policies are chained, each one seeing the decisions of the
+// ones before it. Implementations must be safe for concurrent use.
+type TxPolicy interface {
+	// Name identifies the policy in logs and metrics.
+	Name() string
+
+	// Delay returns for how long the propagation of a new transaction should
+	// be postponed. The local callback reports whether the transaction was
+	// submitted to this node directly; it is costly, so policies only call it
+	// when their decision depends on it.
+	Delay(tx *types.Transaction, local func() bool) time.Duration
+
+	// Relay narrows down the peers a transaction is sent to in full and the
+	// peers it is only announced to. Peers may be moved from the direct set to
+	// the announced one, or removed altogether.
+	Relay(tx *types.Transaction, direct, announce []*eth.Peer) ([]*eth.Peer, []*eth.Peer)
+
+	// Accept reports whether the transaction announcements and broadcasts of a
+	// peer should be processed.
+	Accept(peer *eth.Peer) bool
+
+	// Rejected notifies the policy that the pool rejected transactions delivered
+	// by a peer, for reasons other than their price or being already known.
+	Rejected(peer *eth.Peer, errs []error)
+}
+
+// NewTxPolicies creates the built-in transaction propagation policies enabled
+// in the configuration.
+func NewTxPolicies(config ethconfig.TxPolicyConfig) []TxPolicy {
+	var policies []TxPolicy
+	if config.TrustedOnly {
+		policies = append(policies, TrustedTxPolicy{})
+	}
+	if config.LocalDelay > 0 {
+		policies = append(policies, NewLocalDelayTxPolicy(config.LocalDelay))
+	}
+	if config.InvalidLimit > 0 {
+		policies = append(policies, NewInvalidTxPolicy(config.InvalidLimit))
+	}
+	if config.PeerBandwidth > 0 {
+		policies = append(policies, NewBandwidthTxPolicy(config.PeerBandwidth))
+	}
+	return policies
+}
+
+// nopTxPolicy propagates and accepts all transactions. It is embedded by the
+// built-in policies to implement the methods they do not care about.
+type nopTxPolicy struct{}
+
+func (nopTxPolicy) Delay(*types.Transaction, func() bool) time.Duration { return 0 }
+func (nopTxPolicy) Accept(*eth.Peer) bool                               { return true }
+func (nopTxPolicy) Rejected(*eth.Peer, []error)                         {}
+
+func (nopTxPolicy) Relay(tx *types.Transaction, direct, announce []*eth.Peer) ([]*eth.Peer, []*eth.Peer) {
+	return direct, announce
+}
+
+// TrustedTxPolicy propagates transactions to trusted peers only.
+type TrustedTxPolicy struct{ nopTxPolicy }
+
+// Name implements TxPolicy.
+func (TrustedTxPolicy) Name() string { return "trusted" }
+
+// Relay implements TxPolicy, dropping all untrusted peers.
+func (TrustedTxPolicy) Relay(tx *types.Transaction, direct, announce []*eth.Peer) ([]*eth.Peer, []*eth.Peer) {
+	return trustedPeers(direct), trustedPeers(announce)
+}
+
+// trustedPeers filters the trusted peers out of a peer list.
+func trustedPeers(peers []*eth.Peer) []*eth.Peer {
+	var trusted []*eth.Peer
+	for _, peer := range peers {
+		if peer.Trusted() {
+			trusted = append(trusted, peer)
+		}
+	}
+	return trusted
+}
+
+// LocalDelayTxPolicy postpones the propagation of local transactions.
+type LocalDelayTxPolicy struct {
+	nopTxPolicy
+	delay time.Duration
+}
+
+// NewLocalDelayTxPolicy creates a policy delaying local transactions by the
+// given duration.
+func NewLocalDelayTxPolicy(delay time.Duration) *LocalDelayTxPolicy {
+	return &LocalDelayTxPolicy{delay: delay}
+}
+
+// Name implements TxPolicy.
+func (p *LocalDelayTxPolicy) Name() string { return "localdelay" }
+
+// Delay implements TxPolicy.
+func (p *LocalDelayTxPolicy) Delay(tx *types.Transaction, local func() bool) time.Duration {
+	if local() {
+		return p.delay
+	}
+	return 0
+}
+
+// invalidTxErrors are the pool rejections caused by a transaction being invalid
+// by itself, as opposed to not fitting the current state of the pool.
+var invalidTxErrors = []error{
+	txpool.ErrInvalidSender,
+	txpool.ErrNegativeValue,
+	txpool.ErrOversizedData,
+	txpool.ErrGasLimit,
+	core.ErrIntrinsicGas,
+	core.ErrTxTypeNotSupported,
+	core.ErrTipAboveFeeCap,
+	core.ErrTipVeryHigh,
+	core.ErrFeeCapVeryHigh,
+	core.ErrMaxInitCodeSizeExceeded,
+	core.ErrGasUintOverflow,
+}
+
+// isInvalidTxError reports whether a pool rejection means the transaction was
+// invalid by itself.
+func isInvalidTxError(err error) bool {
+	for _, invalid := range invalidTxErrors {
+		if errors.Is(err, invalid) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidTxPeers is the number of peers whose invalid transactions are tracked.
+const invalidTxPeers = 1024
+
+// InvalidTxPolicy ignores the transaction announcements and broadcasts of peers
+// that delivered too many invalid transactions.
+type InvalidTxPolicy struct {
+	nopTxPolicy
+	limit int
+
+	invalid lru.BasicLRU[string, int] // Number of invalid transactions per peer
+	lock    sync.Mutex
+}
+
+// NewInvalidTxPolicy creates a policy ignoring peers after they delivered the
+// given number of invalid transactions.
+func NewInvalidTxPolicy(limit int) *InvalidTxPolicy {
+	return &InvalidTxPolicy{
+		limit:   limit,
+		invalid: lru.NewBasicLRU[string, int](invalidTxPeers),
+	}
+}
+
+// Name implements TxPolicy.
+func (p *InvalidTxPolicy) Name() string { return "invalid" }
+
+// Accept implements TxPolicy.
+func (p *InvalidTxPolicy) Accept(peer *eth.Peer) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	invalid, _ := p.invalid.Peek(peer.ID())
+	return invalid < p.limit
+}
+
+// Rejected implements TxPolicy, counting the invalid transactions of the peer.
+func (p *InvalidTxPolicy) Rejected(peer *eth.Peer, errs []error) {
+	var count int
+	for _, err := range errs {
+		if isInvalidTxError(err) {
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	invalid, _ := p.invalid.Get(peer.ID())
+	if invalid < p.limit && invalid+count >= p.limit {
+		peer.Log().Debug("Ignoring transactions of peer", "invalid", invalid+count)
+	}
+	p.invalid.Add(peer.ID(), invalid+count)
+}
+
+// bandwidthPeers is the number of peers whose bandwidth budget is tracked.
+const bandwidthPeers = 1024
+
+// txBudget is the token bucket tracking the transaction bandwidth of a peer.
+type txBudget struct {
+	tokens  float64   // Bytes that can be sent right away
+	updated time.Time // Time the tokens were last refilled
+}
+
+// BandwidthTxPolicy limits the bytes of transactions sent in full to each peer
+// per second. Transactions not fitting the budget of a peer are announced to it
+// instead, letting the peer decide whether to retrieve them.
+type BandwidthTxPolicy struct {
+	nopTxPolicy
+	rate float64          // Bytes per second allowed for each peer, also the burst size
+	now  func() time.Time // Wall clock, overridable in tests
+
+	budgets lru.BasicLRU[string, *txBudget]
+	lock    sync.Mutex
+}
+
+// NewBandwidthTxPolicy creates a policy limiting the transactions sent in full
+// to each peer to the given bytes per second.
+func NewBandwidthTxPolicy(rate uint64) *BandwidthTxPolicy {
+	return &BandwidthTxPolicy{
+		rate:    float64(rate),
+		now:     time.Now,
+		budgets: lru.NewBasicLRU[string, *txBudget](bandwidthPeers),
+	}
+}
+
+// Name implements TxPolicy.
+func (p *BandwidthTxPolicy) Name() string { return "bandwidth" }
+
+// Relay implements TxPolicy, demoting the peers out of budget to announcements.
+func (p *BandwidthTxPolicy) Relay(tx *types.Transaction, direct, announce []*eth.Peer) ([]*eth.Peer, []*eth.Peer) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var (
+		now  = p.now()
+		size = float64(tx.Size())
+		keep []*eth.Peer
+	)
+	for _, peer := range direct {
+		budget, ok := p.budgets.Get(peer.ID())
+		if !ok {
+			budget = &txBudget{tokens: p.rate, updated: now}
+			p.budgets.Add(peer.ID(), budget)
+		}
+		if elapsed := now.Sub(budget.updated); elapsed > 0 {
+			budget.tokens = min(p.rate, budget.tokens+elapsed.Seconds()*p.rate)
+			budget.updated = now
+		}
+		if budget.tokens >= size {
+			budget.tokens -= size
+			keep = append(keep, peer)
+		} else {
+			announce = append(announce, peer)
+		}
+	}
+	return keep, announce
+}
+
+// txPolicyMeters are the metrics of a single transaction propagation policy.
+type txPolicyMeters struct {
+	delayed  metrics.Meter // Transactions whose propagation was postponed
+	demoted  metrics.Meter // Peers moved from direct propagation to announcements
+	filtered metrics.Meter // Peers excluded from propagation altogether
+	ignored  metrics.Meter // Transaction packets of peers ignored
+	rejected metrics.Meter // Transactions rejected by the pool reported to the policy
+}
+
+func newTxPolicyMeters(name string) *txPolicyMeters {
+	prefix := "eth/txpolicy/" + name + "/"
+	return &txPolicyMeters{
+		delayed:  metrics.GetOrRegisterMeter(prefix+"delayed", nil),
+		demoted:  metrics.GetOrRegisterMeter(prefix+"demoted", nil),
+		filtered: metrics.GetOrRegisterMeter(prefix+"filtered", nil),
+		ignored:  metrics.GetOrRegisterMeter(prefix+"ignored", nil),
+		rejected: metrics.GetOrRegisterMeter(prefix+"rejected", nil),
+	}
+}
+
+// txPolicies is the chain of transaction propagation policies of the handler,
+// applied in the order they were added.
+type txPolicies struct {
+	policies []TxPolicy
+	meters   []*txPolicyMeters
+	lock     sync.RWMutex
+}
+
+// add appends a policy to the chain.
+func (ps *txPolicies) add(policy TxPolicy) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	ps.policies = append(ps.policies, policy)
+	ps.meters = append(ps.meters, newTxPolicyMeters(policy.Name()))
+}
+
+// empty reports whether there are no policies to apply.
+func (ps *txPolicies) empty() bool {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.policies) == 0
+}
+
+// delay returns the longest propagation delay required by any of the policies.
+func (ps *txPolicies) delay(tx *types.Transaction, local func() bool) time.Duration {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	var delay time.Duration
+	for i, policy := range ps.policies {
+		if d := policy.Delay(tx, local); d > 0 {
+			ps.meters[i].delayed.Mark(1)
+			delay = max(delay, d)
+		}
+	}
+	return delay
+}
+
+// relay runs the peers a transaction is propagated to through all the policies.
+func (ps *txPolicies) relay(tx *types.Transaction, direct, announce []*eth.Peer) ([]*eth.Peer, []*eth.Peer) {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	for i, policy := range ps.policies {
+		total, announced := len(direct)+len(announce), len(announce)
+
+		direct, announce = policy.Relay(tx, direct, announce)
+		if filtered := total - len(direct) - len(announce); filtered > 0 {
+			ps.meters[i].filtered.Mark(int64(filtered))
+		}
+		if demoted := len(announce) - announced; demoted > 0 {
+			ps.meters[i].demoted.Mark(int64(demoted))
+		}
+	}
+	return direct, announce
+}
+
+// accept reports whether all the policies accept the transactions of a peer.
+func (ps *txPolicies) accept(peer *eth.Peer) bool {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	for i, policy := range ps.policies {
+		if !policy.Accept(peer) {
+			ps.meters[i].ignored.Mark(1)
+			return false
+		}
+	}
+	return true
+}
+
+// rejected notifies all the policies of the pool rejecting transactions of a peer.
+func (ps *txPolicies) rejected(peer *eth.Peer, errs []error) {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	for i, policy := range ps.policies {
+		ps.meters[i].rejected.Mark(int64(len(errs)))
+		policy.Rejected(peer, errs)
+	}
+}
@@ -0,0 +1,483 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// newTxPolicyPeer creates an unconnected peer to run through the policies.
+func newTxPolicyPeer(t *testing.T, n byte) *eth.Peer {
+	peer := eth.NewPeer(eth.ETH68, p2p.NewPeer(enode.ID{n}, fmt.Sprintf("peer-%d", n), nil), nil, nil)
+	t.Cleanup(peer.Close)
+	return peer
+}
+
+// newTxPolicyTx creates an unsigned transaction with the given payload size.
+func newTxPolicyTx(nonce uint64, size int) *types.Transaction {
+	return types.NewTransaction(nonce, common.Address{}, common.Big0, 100000, common.Big1, make([]byte, size))
+}
+
+// Tests that the built-in policies are only enabled when configured.
+func TestNewTxPolicies(t *testing.T) {
+	if policies := NewTxPolicies(ethconfig.TxPolicyConfig{}); len(policies) != 0 {
+		t.Fatalf("default config policies mismatch: have %d, want 0", len(policies))
+	}
+	policies := NewTxPolicies(ethconfig.TxPolicyConfig{
+		TrustedOnly:   true,
+		LocalDelay:    time.Second,
+		InvalidLimit:  1,
+		PeerBandwidth: 1024,
+	})
+	want := []string{"trusted", "localdelay", "invalid", "bandwidth"}
+	if len(policies) != len(want) {
+		t.Fatalf("policy count mismatch: have %d, want %d", len(policies), len(want))
+	}
+	for i, policy := range policies {
+		if policy.Name() != want[i] {
+			t.Errorf("policy %d: name mismatch: have %s, want %s", i, policy.Name(), want[i])
+		}
+	}
+}
+
+// Tests that untrusted peers are dropped by the trusted-only policy.
+func TestTrustedTxPolicy(t *testing.T) {
+	var (
+		policy = TrustedTxPolicy{}
+		peers  = []*eth.Peer{newTxPolicyPeer(t, 1), newTxPolicyPeer(t, 2)}
+	)
+	direct, announce := policy.Relay(newTxPolicyTx(0, 0), peers[:1], peers[1:])
+	if len(direct) != 0 || len(announce) != 0 {
+		t.Fatalf("untrusted peers relayed: direct %d, announce %d", len(direct), len(announce))
+	}
+}
+
+// Tests that only local transactions are delayed, and that the chain of policies
+// picks the longest delay.
+func TestLocalDelayTxPolicy(t *testing.T) {
+	var chain txPolicies
+	chain.add(NewLocalDelayTxPolicy(time.Second))
+	chain.add(NewLocalDelayTxPolicy(3 * time.Second))
+
+	tx := newTxPolicyTx(0, 0)
+	if delay := chain.delay(tx, func() bool { return false }); delay != 0 {
+		t.Errorf("remote delay mismatch: have %v, want 0", delay)
+	}
+	if delay := chain.delay(tx, func() bool { return true }); delay != 3*time.Second {
+		t.Errorf("local delay mismatch: have %v, want %v", delay, 3*time.Second)
+	}
+}
+
+// Tests that the local accounts are not looked up for policies never delaying.
+func TestTxPolicyLocalLookup(t *testing.T) {
+	var chain txPolicies
+	chain.add(TrustedTxPolicy{})
+	chain.add(NewInvalidTxPolicy(1))
+
+	local := func() bool {
+		t.Fatal("local accounts looked up")
+		return false
+	}
+	if delay := chain.delay(newTxPolicyTx(0, 0), local); delay != 0 {
+		t.Errorf("delay mismatch: have %v, want 0", delay)
+	}
+}
+
+// Tests that peers are ignored once they delivered enough invalid transactions,
+// and that transactions rejected for other reasons are not held against them.
+func TestInvalidTxPolicy(t *testing.T) {
+	var (
+		policy = NewInvalidTxPolicy(3)
+		peer   = newTxPolicyPeer(t, 1)
+		other  = newTxPolicyPeer(t, 2)
+	)
+	policy.Rejected(peer, []error{txpool.ErrUnderpriced, core.ErrNonceTooLow, txpool.ErrAlreadyKnown})
+	if !policy.Accept(peer) {
+		t.Fatalf("peer ignored for valid transactions")
+	}
+	policy.Rejected(peer, []error{txpool.ErrInvalidSender, fmt.Errorf("%w: wrapped", core.ErrIntrinsicGas)})
+	if !policy.Accept(peer) {
+		t.Fatalf("peer ignored below the limit")
+	}
+	policy.Rejected(peer, []error{txpool.ErrOversizedData})
+	if policy.Accept(peer) {
+		t.Fatalf("peer accepted above the limit")
+	}
+	if !policy.Accept(other) {
+		t.Fatalf("unrelated peer ignored")
+	}
+}
+
+// Tests that transactions exceeding the bandwidth budget of a peer are demoted to
+// announcements, and that the budget refills over time.
+func TestBandwidthTxPolicy(t *testing.T) {
+	var (
+		now    = time.Unix(0, 0)
+		policy = NewBandwidthTxPolicy(1000)
+		peers  = []*eth.Peer{newTxPolicyPeer(t, 1), newTxPolicyPeer(t, 2)}
+		tx     = newTxPolicyTx(0, 300)
+		size   = float64(tx.Size())
+	)
+	policy.now = func() time.Time { return now }
+
+	var chain txPolicies
+	chain.add(policy)
+
+	// Send as many transactions as fit into the budget, all should go direct
+	fits := int(1000 / size)
+	for i := 0; i < fits; i++ {
+		direct, announce := chain.relay(tx, peers, nil)
+		if len(direct) != 2 || len(announce) != 0 {
+			t.Fatalf("tx %d: relay mismatch: direct %d, announce %d", i, len(direct), len(announce))
+		}
+	}
+	// The next one should be demoted for both peers
+	direct, announce := chain.relay(tx, peers, nil)
+	if len(direct) != 0 || len(announce) != 2 {
+		t.Fatalf("over budget relay mismatch: direct %d, announce %d", len(direct), len(announce))
+	}
+	// Wait for the budget to refill and ensure transactions are sent again
+	now = now.Add(time.Second)
+	direct, announce = chain.relay(tx, peers, nil)
+	if len(direct) != 2 || len(announce) != 0 {
+		t.Fatalf("refilled relay mismatch: direct %d, announce %d", len(direct), len(announce))
+	}
+}
+
+// testTxPolicy is a policy accepting peers on demand and recording the ones it
+// was consulted about.
+type testTxPolicy struct {
+	nopTxPolicy
+	accept   atomic.Bool
+	accepts  chan *eth.Peer
+	rejected chan []error
+}
+
+func newTestTxPolicy() *testTxPolicy {
+	return &testTxPolicy{
+		accepts:  make(chan *eth.Peer, 16),
+		rejected: make(chan []error, 16),
+	}
+}
+
+func (p *testTxPolicy) Name() string { return "test" }
+
+func (p *testTxPolicy) Accept(peer *eth.Peer) bool {
+	p.accepts <- peer
+	return p.accept.Load()
+}
+
+func (p *testTxPolicy) Rejected(peer *eth.Peer, errs []error) {
+	p.rejected <- errs
+}
+
+// startTxPolicyPeer connects a peer to the handler and runs the handshake. The
+// remote end of the connection is returned along with its message pipe, for the
+// test to send messages through and receive the ones of the handler.
+func startTxPolicyPeer(t *testing.T, h *testHandler, id byte, trusted bool) (*eth.Peer, *p2p.MsgPipeRW) {
+	t.Helper()
+
+	localPipe, remotePipe := p2p.MsgPipe()
+	t.Cleanup(func() {
+		localPipe.Close()
+		remotePipe.Close()
+	})
+	newPeer := p2p.NewPeerPipe
+	if trusted {
+		newPeer = p2p.NewTrustedPeerPipe
+	}
+	local := eth.NewPeer(eth.ETH68, newPeer(enode.ID{id}, "", nil, localPipe), localPipe, h.txpool)
+	remote := eth.NewPeer(eth.ETH68, p2p.NewPeerPipe(enode.ID{}, "", nil, remotePipe), remotePipe, h.txpool)
+	t.Cleanup(local.Close)
+	t.Cleanup(remote.Close)
+
+	go h.handler.runEthPeer(local, func(peer *eth.Peer) error {
+		return eth.Handle((*ethHandler)(h.handler), peer)
+	})
+	var (
+		genesis = h.chain.Genesis()
+		head    = h.chain.CurrentBlock()
+		td      = h.chain.GetTd(head.Hash(), head.Number.Uint64())
+	)
+	if err := remote.Handshake(1, td, head.Hash(), genesis.Hash(), forkid.NewIDWithChain(h.chain), forkid.NewFilter(h.chain)); err != nil {
+		t.Fatalf("failed to run protocol handshake: %v", err)
+	}
+	for h.handler.peers.peer(local.ID()) == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return remote, remotePipe
+}
+
+// receiveTxPolicyTxs collects the hashes of the transactions sent or announced to
+// the remote end of a connection.
+func receiveTxPolicyTxs(t *testing.T, remote *eth.Peer) chan common.Hash {
+	backend := new(testEthHandler)
+
+	anns := make(chan []common.Hash)
+	annSub := backend.txAnnounces.Subscribe(anns)
+	t.Cleanup(annSub.Unsubscribe)
+
+	bcasts := make(chan []*types.Transaction)
+	bcastSub := backend.txBroadcasts.Subscribe(bcasts)
+	t.Cleanup(bcastSub.Unsubscribe)
+
+	go eth.Handle(backend, remote)
+
+	hashes := make(chan common.Hash, 16)
+	go func() {
+		for {
+			select {
+			case batch := <-anns:
+				for _, hash := range batch {
+					hashes <- hash
+				}
+			case batch := <-bcasts:
+				for _, tx := range batch {
+					hashes <- tx.Hash()
+				}
+			case <-annSub.Err():
+				return
+			case <-bcastSub.Err():
+				return
+			}
+		}
+	}()
+	return hashes
+}
+
+// Tests that the broadcast loop holds back the transactions delayed by a policy
+// until their release time, while propagating the others right away.
+func TestTxPolicyDelayedBroadcast(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler()
+	defer h.close()
+
+	const delay = 500 * time.Millisecond
+	h.handler.txPolicies.add(NewLocalDelayTxPolicy(delay))
+
+	h.txpool.lock.Lock()
+	h.txpool.locals = []common.Address{testAddr}
+	h.txpool.lock.Unlock()
+
+	remote, _ := startTxPolicyPeer(t, h, 1, false)
+	hashes := receiveTxPolicyTxs(t, remote)
+
+	otherKey, _ := crypto.GenerateKey()
+	local, _ := types.SignTx(newTxPolicyTx(0, 0), types.HomesteadSigner{}, testKey)
+	other, _ := types.SignTx(newTxPolicyTx(0, 0), types.HomesteadSigner{}, otherKey)
+
+	start := time.Now()
+	h.txpool.Add([]*types.Transaction{local, other}, false, false)
+
+	select {
+	case hash := <-hashes:
+		if hash != other.Hash() {
+			t.Fatalf("propagated transaction mismatch: have %x, want the remote %x", hash, other.Hash())
+		}
+	case <-time.After(delay / 2):
+		t.Fatal("remote transaction not propagated right away")
+	}
+	select {
+	case hash := <-hashes:
+		if hash != local.Hash() {
+			t.Fatalf("propagated transaction mismatch: have %x, want the local %x", hash, local.Hash())
+		}
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("local transaction propagated early: after %v, want %v", elapsed, delay)
+		}
+	case <-time.After(4 * delay):
+		t.Fatal("delayed transaction never propagated")
+	}
+}
+
+// Tests that transactions are only relayed to trusted peers by the trusted-only
+// policy.
+func TestTxPolicyTrustedBroadcast(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler()
+	defer h.close()
+
+	h.handler.txPolicies.add(TrustedTxPolicy{})
+
+	trustedPeer, _ := startTxPolicyPeer(t, h, 1, true)
+	untrustedPeer, _ := startTxPolicyPeer(t, h, 2, false)
+	trusted := receiveTxPolicyTxs(t, trustedPeer)
+	untrusted := receiveTxPolicyTxs(t, untrustedPeer)
+
+	tx, _ := types.SignTx(newTxPolicyTx(0, 0), types.HomesteadSigner{}, testKey)
+	h.txpool.Add([]*types.Transaction{tx}, false, false)
+
+	select {
+	case hash := <-trusted:
+		if hash != tx.Hash() {
+			t.Fatalf("propagated transaction mismatch: have %x, want %x", hash, tx.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("transaction not propagated to the trusted peer")
+	}
+	select {
+	case hash := <-untrusted:
+		t.Fatalf("transaction %x propagated to an untrusted peer", hash)
+	case <-time.After(250 * time.Millisecond):
+	}
+}
+
+// Tests that the transactions broadcast by a peer are dropped while the policies
+// don't accept it.
+func TestTxPolicyAcceptTransactions(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler()
+	defer h.close()
+	h.handler.synced.Store(true) // mark synced to accept transactions
+
+	policy := newTestTxPolicy()
+	h.handler.txPolicies.add(policy)
+
+	txs := make(chan core.NewTxsEvent, 16)
+	sub := h.txpool.SubscribeTransactions(txs, false)
+	defer sub.Unsubscribe()
+
+	remote, _ := startTxPolicyPeer(t, h, 1, false)
+
+	dropped, _ := types.SignTx(newTxPolicyTx(0, 0), types.HomesteadSigner{}, testKey)
+	if err := remote.SendTransactions([]*types.Transaction{dropped}); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	<-policy.accepts
+	policy.accept.Store(true)
+
+	accepted, _ := types.SignTx(newTxPolicyTx(1, 0), types.HomesteadSigner{}, testKey)
+	if err := remote.SendTransactions([]*types.Transaction{accepted}); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	select {
+	case event := <-txs:
+		if len(event.Txs) != 1 || event.Txs[0].Hash() != accepted.Hash() {
+			t.Fatalf("added transactions mismatch: have %v, want %x", event.Txs, accepted.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("accepted transaction not added")
+	}
+	if h.txpool.Has(dropped.Hash()) {
+		t.Error("transaction of an ignored peer added")
+	}
+}
+
+// Tests that the transactions announced by a peer are not requested while the
+// policies don't accept it.
+func TestTxPolicyAcceptAnnouncements(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler()
+	defer h.close()
+	h.handler.synced.Store(true) // mark synced to accept transactions
+
+	policy := newTestTxPolicy()
+	h.handler.txPolicies.add(policy)
+
+	_, pipe := startTxPolicyPeer(t, h, 1, false)
+
+	requests := make(chan eth.GetPooledTransactionsRequest, 16)
+	go func() {
+		for {
+			msg, err := pipe.ReadMsg()
+			if err != nil {
+				return
+			}
+			if msg.Code == eth.GetPooledTransactionsMsg {
+				var req eth.GetPooledTransactionsPacket
+				if err := msg.Decode(&req); err != nil {
+					t.Errorf("failed to decode transaction request: %v", err)
+				}
+				requests <- req.GetPooledTransactionsRequest
+			}
+			msg.Discard()
+		}
+	}()
+	announce := func(hash common.Hash) {
+		packet := eth.NewPooledTransactionHashesPacket{Types: []byte{types.LegacyTxType}, Sizes: []uint32{100}, Hashes: []common.Hash{hash}}
+		if err := p2p.Send(pipe, eth.NewPooledTransactionHashesMsg, packet); err != nil {
+			t.Fatalf("failed to announce transaction: %v", err)
+		}
+	}
+	dropped, accepted := common.Hash{0x01}, common.Hash{0x02}
+
+	announce(dropped)
+	<-policy.accepts
+	policy.accept.Store(true)
+	announce(accepted)
+
+	select {
+	case req := <-requests:
+		if len(req) != 1 || req[0] != accepted {
+			t.Fatalf("requested transactions mismatch: have %x, want %x", req, accepted)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("accepted announcement not requested")
+	}
+}
+
+// Tests that the policies are notified of the transactions of a peer rejected by
+// the pool.
+func TestTxPolicyRejectHook(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler()
+	defer h.close()
+	h.handler.synced.Store(true) // mark synced to accept transactions
+
+	policy := newTestTxPolicy()
+	policy.accept.Store(true)
+	h.handler.txPolicies.add(policy)
+
+	h.txpool.lock.Lock()
+	h.txpool.reject = txpool.ErrInvalidSender
+	h.txpool.lock.Unlock()
+
+	remote, _ := startTxPolicyPeer(t, h, 1, false)
+
+	tx, _ := types.SignTx(newTxPolicyTx(0, 0), types.HomesteadSigner{}, testKey)
+	if err := remote.SendTransactions([]*types.Transaction{tx}); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	select {
+	case errs := <-policy.rejected:
+		if len(errs) != 1 || !errors.Is(errs[0], txpool.ErrInvalidSender) {
+			t.Fatalf("rejection errors mismatch: have %v, want %v", errs, txpool.ErrInvalidSender)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("policy not notified of the rejection")
+	}
+}
@@ -1147,12 +1147,18 @@ var allRPCMethods = []string{
 	"admin_addTrustedPeer",
 	"admin_datadir",
 	"admin_ecbp1100",
+	"admin_ecbp1100Status",
 	"admin_exportChain",
+	"admin_forkCheck",
 	"admin_importChain",
 	"admin_maxPeers",
 	"admin_nodeInfo",
 	"admin_peers",
 	"admin_peerEvents",
+	"admin_peerScores",
+	"admin_pruneState",
+	"admin_pruneStatus",
+	"admin_reloadChainConfig",
 	"admin_removePeer",
 	"admin_removeTrustedPeer",
 	"admin_startHTTP",
@@ -1171,6 +1177,7 @@ var allRPCMethods = []string{
 	"debug_dbGet",
 	"debug_discoveryV4Table",
 	"debug_dumpBlock",
+	"debug_executionWitness",
 	"debug_freeOSMemory",
 	"debug_gcStats",
 	"debug_getAccessibleState",
@@ -1201,7 +1208,10 @@ var allRPCMethods = []string{
 	"debug_startGoTrace",
 	"debug_stopCPUProfile",
 	"debug_stopGoTrace",
+	"debug_stopStorageStats",
 	"debug_storageRangeAt",
+	"debug_storageStats",
+	"debug_storageStatsStatus",
 	"debug_subscribe",
 	"debug_traceBadBlock",
 	"debug_traceBlock",
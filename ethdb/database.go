@@ -130,6 +130,10 @@ type AncientWriter interface {
 	// The second argument is a function that takes a raw entry and returns it
 	// in the newest format.
 	MigrateTable(string, func([]byte) ([]byte, error)) error
+
+	// RepairAncient replaces a corrupted item of the given kind with the given
+	// uncompressed item, which is served on all subsequent reads.
+	RepairAncient(kind string, number uint64, item []byte) error
 }
 
 // AncientWriteOp is given to the function argument of ModifyAncients.
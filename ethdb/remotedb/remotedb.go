@@ -114,6 +114,10 @@ func (db *Database) MigrateTable(s string, f func([]byte) ([]byte, error)) error
 	panic("not supported")
 }
 
+func (db *Database) RepairAncient(kind string, number uint64, item []byte) error {
+	panic("not supported")
+}
+
 func (db *Database) NewBatch() ethdb.Batch {
 	panic("not supported")
 }
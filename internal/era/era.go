@@ -154,6 +154,76 @@ func (e *Era) GetBlockByNumber(num uint64) (*types.Block, error) {
 	return types.NewBlockWithHeader(&header).WithBody(body.Transactions, body.Uncles), nil
 }
 
+// GetRawHeaderByNumber returns the RLP encoded header of the block with the
+// given number.
+func (e *Era) GetRawHeaderByNumber(num uint64) ([]byte, error) {
+	return e.readSnappyEntry(num, 0, TypeCompressedHeader)
+}
+
+// GetRawBodyByNumber returns the RLP encoded body of the block with the given
+// number.
+func (e *Era) GetRawBodyByNumber(num uint64) ([]byte, error) {
+	return e.readSnappyEntry(num, 1, TypeCompressedBody)
+}
+
+// GetRawReceiptsByNumber returns the RLP encoded receipts of the block with
+// the given number, in consensus encoding.
+func (e *Era) GetRawReceiptsByNumber(num uint64) ([]byte, error) {
+	return e.readSnappyEntry(num, 2, TypeCompressedReceipts)
+}
+
+// GetTotalDifficultyByNumber returns the total difficulty of the block with
+// the given number.
+func (e *Era) GetTotalDifficultyByNumber(num uint64) (*big.Int, error) {
+	off, err := e.entryOffset(num, 3)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := e.s.ReaderAt(TypeTotalDifficulty, off)
+	if err != nil {
+		return nil, err
+	}
+	rawTd, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(reverseOrder(rawTd)), nil
+}
+
+// readSnappyEntry reads and decompresses the entry at position idx of the
+// block tuple of the given block.
+func (e *Era) readSnappyEntry(num uint64, idx int, typ uint16) ([]byte, error) {
+	off, err := e.entryOffset(num, idx)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := newSnappyReader(e.s, typ, off)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// entryOffset returns the offset of the entry at position idx of the block
+// tuple of the given block.
+func (e *Era) entryOffset(num uint64, idx int) (int64, error) {
+	if e.m.start > num || e.m.start+e.m.count <= num {
+		return 0, errors.New("out-of-bounds")
+	}
+	off, err := e.readOffset(num)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < idx; i++ {
+		length, err := e.s.LengthAt(off)
+		if err != nil {
+			return 0, err
+		}
+		off += length
+	}
+	return off, nil
+}
+
 // Accumulator reads the accumulator entry in the Era1 file.
 func (e *Era) Accumulator() (common.Hash, error) {
 	entry, err := e.s.Find(TypeAccumulator)
@@ -169,6 +169,16 @@ web3._extend({
 			call: 'admin_sleepBlocks',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'pruneState',
+			call: 'admin_pruneState',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'pruneStatus',
+			call: 'admin_pruneStatus'
+		}),
 		new web3._extend.Method({
 			name: 'startHTTP',
 			call: 'admin_startHTTP',
@@ -211,6 +221,14 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerScores',
+			getter: 'admin_peerScores'
+		}),
+		new web3._extend.Property({
+			name: 'ecbp1100Status',
+			getter: 'admin_ecbp1100Status'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -512,6 +530,28 @@ web3._extend({
 			call: 'debug_getTrieFlushInterval',
 			params: 0
 		}),
+		new web3._extend.Method({
+			name: 'executionWitness',
+			call: 'debug_executionWitness',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'storageStats',
+			call: 'debug_storageStats',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'storageStatsStatus',
+			call: 'debug_storageStatsStatus',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'stopStorageStats',
+			call: 'debug_stopStorageStats',
+			params: 0
+		}),
 	],
 	properties: []
 });
@@ -386,7 +386,7 @@ func createMiner(t *testing.T) (*Miner, *event.TypeMux, func(skipMiner bool)) {
 	// Create event Mux
 	mux := new(event.TypeMux)
 	// Create Miner
-	miner := New(backend, &config, chainConfig, mux, engine, nil)
+	miner := New(backend, &config, mux, engine, nil)
 	cleanup := func(skipMiner bool) {
 		bc.Stop()
 		engine.Close()
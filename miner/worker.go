@@ -38,7 +38,6 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params/mutations"
-	"github.com/ethereum/go-ethereum/params/types/ctypes"
 	"github.com/ethereum/go-ethereum/params/vars"
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/holiman/uint256"
@@ -205,11 +204,10 @@ type intervalAdjust struct {
 // worker is the main object which takes care of submitting new work to consensus engine
 // and gathering the sealing result.
 type worker struct {
-	config      *Config
-	chainConfig ctypes.ChainConfigurator
-	engine      consensus.Engine
-	eth         Backend
-	chain       *core.BlockChain
+	config *Config
+	engine consensus.Engine
+	eth    Backend
+	chain  *core.BlockChain
 
 	// Feeds
 	pendingLogsFeed event.Feed
@@ -286,10 +284,9 @@ type worker struct {
 	resubmitHook func(time.Duration, time.Duration) // Method to call upon updating resubmitting interval.
 }
 
-func newWorker(config *Config, chainConfig ctypes.ChainConfigurator, engine consensus.Engine, eth Backend, mux *event.TypeMux, isLocalBlock func(header *types.Header) bool, init bool) *worker {
+func newWorker(config *Config, engine consensus.Engine, eth Backend, mux *event.TypeMux, isLocalBlock func(header *types.Header) bool, init bool) *worker {
 	worker := &worker{
 		config:             config,
-		chainConfig:        chainConfig,
 		engine:             engine,
 		eth:                eth,
 		chain:              eth.BlockChain(),
@@ -530,7 +527,7 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 		case <-timer.C:
 			// If sealing is running resubmit a new work cycle periodically to pull in
 			// higher priced transactions. Disable this overhead for pending blocks.
-			if w.isRunning() && (!w.chainConfig.GetConsensusEngineType().IsClique() || w.chainConfig.GetCliquePeriod() > 0) {
+			if w.isRunning() && (!w.chain.Config().GetConsensusEngineType().IsClique() || w.chain.Config().GetCliquePeriod() > 0) {
 				// Short circuit if no new transaction arrives.
 				if w.newTxs.Load() == 0 {
 					timer.Reset(recommit)
@@ -677,7 +674,7 @@ func (w *worker) mainLoop() {
 				// Special case, if the consensus engine is 0 period clique(dev mode),
 				// submit sealing work here since all empty submission will be rejected
 				// by clique. Of course the advance sealing(empty submission) is disabled.
-				if w.chainConfig.GetConsensusEngineType().IsClique() && w.chainConfig.GetCliquePeriod() == 0 {
+				if w.chain.Config().GetConsensusEngineType().IsClique() && w.chain.Config().GetCliquePeriod() == 0 {
 					w.commitWork(nil, true, time.Now().Unix())
 				}
 			}
@@ -832,7 +829,7 @@ func (w *worker) makeEnv(parent *types.Header, header *types.Header, coinbase co
 
 	// Note the passed coinbase may be different with header.Coinbase.
 	env := &environment{
-		signer:    types.MakeSigner(w.chainConfig, header.Number, header.Time),
+		signer:    types.MakeSigner(w.chain.Config(), header.Number, header.Time),
 		state:     state,
 		coinbase:  coinbase,
 		ancestors: mapset.NewSet[common.Hash](),
@@ -934,7 +931,7 @@ func (w *worker) applyTransaction(env *environment, tx *types.Transaction) (*typ
 		snap = env.state.Snapshot()
 		gp   = env.gasPool.Gas()
 	)
-	receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &env.coinbase, env.gasPool, env.state, env.header, tx, &env.header.GasUsed, *w.chain.GetVMConfig())
+	receipt, err := core.ApplyTransaction(w.chain.Config(), w.chain, &env.coinbase, env.gasPool, env.state, env.header, tx, &env.header.GasUsed, *w.chain.GetVMConfig())
 	if err != nil {
 		env.state.RevertToSnapshot(snap)
 		env.gasPool.SetGas(gp)
@@ -943,6 +940,7 @@ func (w *worker) applyTransaction(env *environment, tx *types.Transaction) (*typ
 }
 
 func (w *worker) commitTransactions(env *environment, plainTxs, blobTxs *transactionsByPriceAndNonce, interrupt *atomic.Int32) error {
+	config := w.chain.Config()
 	gasLimit := env.header.GasLimit
 	if env.gasPool == nil {
 		env.gasPool = new(core.GasPool).AddGas(gasLimit)
@@ -1015,8 +1013,8 @@ func (w *worker) commitTransactions(env *environment, plainTxs, blobTxs *transac
 
 		// Check whether the tx is replay protected. If we're not in the EIP155 hf
 		// phase, start ignoring the sender until we do.
-		if tx.Protected() && !w.chainConfig.IsEnabled(w.chainConfig.GetEIP155Transition, env.header.Number) {
-			log.Trace("Ignoring replay protected transaction", "hash", ltx.Hash, "eip155", w.chainConfig.GetEIP155Transition())
+		if tx.Protected() && !config.IsEnabled(config.GetEIP155Transition, env.header.Number) {
+			log.Trace("Ignoring replay protected transaction", "hash", ltx.Hash, "eip155", config.GetEIP155Transition())
 			txs.Pop()
 			continue
 		}
@@ -1081,6 +1079,9 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
+	// Use a single view of the chain configuration, it may be swapped meanwhile
+	config := w.chain.Config()
+
 	// Find the parent block for sealing task
 	parent := w.chain.CurrentBlock()
 	if genParams.parentHash != (common.Hash{}) {
@@ -1116,17 +1117,17 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 		header.MixDigest = genParams.random
 	}
 	// Set baseFee and GasLimit if we are on an EIP-1559 chain
-	if w.chainConfig.IsEnabled(w.chainConfig.GetEIP1559Transition, header.Number) {
-		header.BaseFee = eip1559.CalcBaseFee(w.chainConfig, parent)
-		if !w.chainConfig.IsEnabled(w.chainConfig.GetEIP1559Transition, parent.Number) {
-			parentGasLimit := parent.GasLimit * w.chainConfig.GetElasticityMultiplier()
+	if config.IsEnabled(config.GetEIP1559Transition, header.Number) {
+		header.BaseFee = eip1559.CalcBaseFee(config, parent)
+		if !config.IsEnabled(config.GetEIP1559Transition, parent.Number) {
+			parentGasLimit := parent.GasLimit * config.GetElasticityMultiplier()
 			header.GasLimit = core.CalcGasLimit(parentGasLimit, w.config.GasCeil)
 		}
 	}
 	// Apply EIP-4844.
-	if w.chainConfig.IsEnabledByTime(w.chainConfig.GetEIP4844TransitionTime, &header.Time) || w.chainConfig.IsEnabled(w.chainConfig.GetEIP4844Transition, header.Number) {
+	if config.IsEnabledByTime(config.GetEIP4844TransitionTime, &header.Time) || config.IsEnabled(config.GetEIP4844Transition, header.Number) {
 		var excessBlobGas uint64
-		if w.chainConfig.IsEnabledByTime(w.chainConfig.GetEIP4844TransitionTime, &parent.Time) || w.chainConfig.IsEnabled(w.chainConfig.GetEIP4844Transition, parent.Number) {
+		if config.IsEnabledByTime(config.GetEIP4844TransitionTime, &parent.Time) || config.IsEnabled(config.GetEIP4844Transition, parent.Number) {
 			excessBlobGas = eip4844.CalcExcessBlobGas(*parent.ExcessBlobGas, *parent.BlobGasUsed)
 		} else {
 			// For the first post-fork block, both parent.data_gas_used and parent.excess_data_gas are evaluated as 0
@@ -1136,7 +1137,7 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 		header.ExcessBlobGas = &excessBlobGas
 	}
 	// Apply EIP-4788.
-	if w.chainConfig.IsEnabledByTime(w.chainConfig.GetEIP4788TransitionTime, &header.Time) || w.chainConfig.IsEnabled(w.chainConfig.GetEIP4788Transition, header.Number) {
+	if config.IsEnabledByTime(config.GetEIP4788TransitionTime, &header.Time) || config.IsEnabled(config.GetEIP4788Transition, header.Number) {
 		header.ParentBeaconRoot = genParams.beaconRoot
 	}
 	// Run the consensus preparation with the default or customized consensus engine.
@@ -1145,13 +1146,13 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 		return nil, err
 	}
 	// If we are care about TheDAO hard-fork check whether to override the extra-data or not
-	if daoBlockUint64 := w.chainConfig.GetEthashEIP779Transition(); daoBlockUint64 != nil {
+	if daoBlockUint64 := config.GetEthashEIP779Transition(); daoBlockUint64 != nil {
 		daoBlock := new(big.Int).SetUint64(*daoBlockUint64)
 		// Check whether the block is among the fork extra-override range
 		limit := new(big.Int).Add(daoBlock, vars.DAOForkExtraRange)
 		if header.Number.Cmp(daoBlock) >= 0 && header.Number.Cmp(limit) < 0 {
 			// Depending whether we support or oppose the fork, override differently
-			if w.chainConfig.GetEthashEIP779Transition() != nil {
+			if config.GetEthashEIP779Transition() != nil {
 				header.Extra = common.CopyBytes(vars.DAOForkBlockExtra)
 			} else if bytes.Equal(header.Extra, vars.DAOForkBlockExtra) {
 				header.Extra = []byte{} // If miner opposes, don't let it use the reserved extra-data
@@ -1167,9 +1168,9 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 		return nil, err
 	}
 	// Mutate the block and state according to any hard-fork specs
-	isDAOSupport := w.chainConfig.IsEnabled(w.chainConfig.GetEthashEIP779Transition, header.Number)
+	isDAOSupport := config.IsEnabled(config.GetEthashEIP779Transition, header.Number)
 	if isDAOSupport {
-		if daoNumber := w.chainConfig.GetEthashEIP779Transition(); daoNumber != nil && *daoNumber == header.Number.Uint64() {
+		if daoNumber := config.GetEthashEIP779Transition(); daoNumber != nil && *daoNumber == header.Number.Uint64() {
 			mutations.ApplyDAOHardFork(env.state)
 		}
 	}
@@ -1193,7 +1194,7 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 	}
 	if header.ParentBeaconRoot != nil {
 		context := core.NewEVMBlockContext(header, w.chain, nil)
-		vmenv := vm.NewEVM(context, vm.TxContext{}, env.state, w.chainConfig, vm.Config{})
+		vmenv := vm.NewEVM(context, vm.TxContext{}, env.state, config, vm.Config{})
 		core.ProcessBeaconBlockRoot(*header.ParentBeaconRoot, vmenv, env.state)
 	}
 	return env, nil
@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/reputation"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -183,6 +184,7 @@ func (api *adminAPI) StartHTTP(host *string, port *int, cors *string, apis *stri
 		rpcEndpointConfig: rpcEndpointConfig{
 			batchItemLimit:         api.node.config.BatchRequestLimit,
 			batchResponseSizeLimit: api.node.config.BatchResponseMaxSize,
+			resultCache:            api.node.rpcResultCache,
 		},
 	}
 	if cors != nil {
@@ -261,6 +263,7 @@ func (api *adminAPI) StartWS(host *string, port *int, allowedOrigins *string, ap
 		rpcEndpointConfig: rpcEndpointConfig{
 			batchItemLimit:         api.node.config.BatchRequestLimit,
 			batchResponseSizeLimit: api.node.config.BatchResponseMaxSize,
+			resultCache:            api.node.rpcResultCache,
 		},
 	}
 	if apis != nil {
@@ -309,6 +312,16 @@ func (api *adminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerScores retrieves the reputation of all the peers remembered by the node,
+// ordered from the best to the worst score.
+func (api *adminAPI) PeerScores() ([]*reputation.PeerScore, error) {
+	server := api.node.Server()
+	if server == nil || server.Reputation() == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.Reputation().Scores(), nil
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *adminAPI) NodeInfo() (*p2p.NodeInfo, error) {
@@ -213,6 +213,18 @@ type Config struct {
 	EnablePersonal bool `toml:"-"`
 
 	DBEngine string `toml:",omitempty"`
+
+	// AncientCompression is the codec of newly created compressed ancient tables,
+	// either "snappy" (default) or "zstd".
+	AncientCompression string `toml:",omitempty"`
+
+	// AncientChecksum enables storing a checksum of each item of newly created
+	// ancient tables.
+	AncientChecksum bool `toml:",omitempty"`
+
+	// AncientEraDir is the directory of the era1 files holding the chain history
+	// offloaded from the ancient store.
+	AncientEraDir string `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -391,17 +403,25 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	if key, err := crypto.LoadECDSA(keyfile); err == nil {
 		return key
 	}
+	return persistentNodeKey(c.instanceDir())
+}
+
+// persistentNodeKey loads the node key stored in the given directory, generating
+// and storing a new one if none exists yet.
+func persistentNodeKey(dir string) *ecdsa.PrivateKey {
+	keyfile := filepath.Join(dir, datadirPrivateKey)
+	if key, err := crypto.LoadECDSA(keyfile); err == nil {
+		return key
+	}
 	// No persistent key found, generate and store a new one.
 	key, err := crypto.GenerateKey()
 	if err != nil {
 		log.Crit(fmt.Sprintf("Failed to generate node key: %v", err))
 	}
-	instanceDir := filepath.Join(c.DataDir, c.name())
-	if err := os.MkdirAll(instanceDir, 0700); err != nil {
+	if err := os.MkdirAll(dir, 0700); err != nil {
 		log.Error(fmt.Sprintf("Failed to persist node key: %v", err))
 		return key
 	}
-	keyfile = filepath.Join(instanceDir, datadirPrivateKey)
 	if err := crypto.SaveECDSA(keyfile, key); err != nil {
 		log.Error(fmt.Sprintf("Failed to persist node key: %v", err))
 	}
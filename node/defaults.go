@@ -67,6 +67,10 @@ var DefaultConfig = Config{
 		ListenAddr: ":30303",
 		MaxPeers:   50,
 		NAT:        nat.Any(),
+
+		OutboundSubnetLimit: 2,
+		InboundSubnetLimit:  3,
+		AnchorPeers:         2,
 	},
 	DBEngine: "", // Use whatever exists, will default to Pebble if non-existent and supported
 }
@@ -19,6 +19,7 @@ package node
 import (
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -70,6 +71,19 @@ func checkModuleAvailability(modules []string, apis []rpc.API) (bad, available [
 	return bad, available
 }
 
+// moduleAllowed reports whether the APIs of a namespace are enabled by the given
+// allow list. The namespaces of the additional networks hosted by the node, like
+// mordor.eth, are enabled by their base module as well.
+func moduleAllowed(allowList map[string]bool, namespace string) bool {
+	if allowList[namespace] {
+		return true
+	}
+	if i := strings.LastIndexByte(namespace, '.'); i >= 0 {
+		return allowList[namespace[i+1:]]
+	}
+	return false
+}
+
 // CheckTimeouts ensures that timeout values are meaningful
 func CheckTimeouts(timeouts *rpc.HTTPTimeouts) {
 	if timeouts.ReadTimeout < time.Second {
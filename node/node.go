@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
@@ -53,6 +54,7 @@ type Node struct {
 	dirLock       *flock.Flock  // prevents concurrent use of instance directory
 	stop          chan struct{} // Channel to wait for termination notifications
 	server        *p2p.Server   // Currently running P2P networking layer
+	subServers    []*p2p.Server // Additional P2P servers of networks hosted alongside the main one
 	startStopLock sync.Mutex    // Start/Stop are protected by an additional lock
 	state         int           // Tracks state of node lifecycle
 
@@ -66,6 +68,8 @@ type Node struct {
 	ipc           *ipcServer  // Stores information about the ipc http server
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
+	rpcResultCache rpc.ResultCache // Optional cache shared by the public RPC endpoints
+
 	databases map[*closeTrackingDB]struct{} // All open databases
 
 	inprocOpenRPC   *go_openrpc_reflect.Document
@@ -278,11 +282,23 @@ func (n *Node) openEndpoints() error {
 	if err := n.server.Start(); err != nil {
 		return convertFileLockError(err)
 	}
+	for i, srv := range n.subServers {
+		if err := srv.Start(); err != nil {
+			for _, started := range n.subServers[:i] {
+				started.Stop()
+			}
+			n.server.Stop()
+			return convertFileLockError(err)
+		}
+	}
 	// start RPC endpoints
 	err := n.startRPC()
 	if err != nil {
 		log.Error("Failed to open RPC endpoints", "error", err)
 		n.stopRPC()
+		for _, srv := range n.subServers {
+			srv.Stop()
+		}
 		n.server.Stop()
 		return err
 	}
@@ -314,6 +330,9 @@ func (n *Node) stopServices(running []Lifecycle) error {
 	}
 
 	// Stop p2p networking.
+	for _, srv := range n.subServers {
+		srv.Stop()
+	}
 	n.server.Stop()
 
 	if len(failure.Services) > 0 {
@@ -489,6 +508,7 @@ func (n *Node) startRPC() error {
 	rpcConfig := rpcEndpointConfig{
 		batchItemLimit:         n.config.BatchRequestLimit,
 		batchResponseSizeLimit: n.config.BatchResponseMaxSize,
+		resultCache:            n.rpcResultCache,
 	}
 
 	initHttp := func(server *httpServer, port int) error {
@@ -675,6 +695,19 @@ func (n *Node) RegisterAPIs(apis []rpc.API) {
 	n.rpcAPIs = append(n.rpcAPIs, apis...)
 }
 
+// SetRPCResultCache installs a result cache on the in-process, HTTP and WebSocket
+// RPC endpoints. The authenticated engine endpoints and IPC are not cached.
+func (n *Node) SetRPCResultCache(cache rpc.ResultCache) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.state != initializingState {
+		panic("can't set RPC result cache on running/stopped node")
+	}
+	n.rpcResultCache = cache
+	n.inprocHandler.SetResultCache(cache)
+}
+
 // getAPIs return two sets of APIs, both the ones that do not require
 // authentication, and the complete set
 func (n *Node) getAPIs() (unauthenticated, all []rpc.API) {
@@ -733,6 +766,41 @@ func (n *Node) Server() *p2p.Server {
 	return n.server
 }
 
+// RegisterServer creates an additional P2P server for a network hosted alongside
+// the node's main one. Unless set in the given config, the node key and discovery
+// database of the server are kept in the named subdirectory of the instance
+// directory. The server is started and stopped together with the node.
+func (n *Node) RegisterServer(name string, config p2p.Config) *p2p.Server {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.state != initializingState {
+		panic("can't register P2P server on running/stopped node")
+	}
+	if config.PrivateKey == nil {
+		if n.config.DataDir == "" {
+			key, err := crypto.GenerateKey()
+			if err != nil {
+				log.Crit(fmt.Sprintf("Failed to generate ephemeral node key: %v", err))
+			}
+			config.PrivateKey = key
+		} else {
+			config.PrivateKey = persistentNodeKey(n.config.ResolvePath(name))
+		}
+	}
+	if config.NodeDatabase == "" && n.config.DataDir != "" {
+		config.NodeDatabase = n.config.ResolvePath(filepath.Join(name, datadirNodeDatabase))
+	}
+	if config.Name == "" {
+		config.Name = n.config.NodeName()
+	}
+	config.Logger = n.log.New("network", name)
+
+	srv := &p2p.Server{Config: config}
+	n.subServers = append(n.subServers, srv)
+	return srv
+}
+
 // DataDir retrieves the current datadir used by the protocol stack.
 // Deprecated: No files should be stored in this directory, use InstanceDir instead.
 func (n *Node) DataDir() string {
@@ -847,6 +915,11 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient
 			Cache:             cache,
 			Handles:           handles,
 			ReadOnly:          readonly,
+			AncientOptions: rawdb.FreezerOptions{
+				Compression: n.config.AncientCompression,
+				Checksum:    n.config.AncientChecksum,
+				EraDir:      n.config.AncientEraDir,
+			},
 		})
 	}
 
@@ -918,7 +991,7 @@ func GetAPIsByWhitelist(apis []rpc.API, modules []string, exposeAll bool) (regis
 	}
 	// Register all the APIs exposed by the services
 	for _, api := range apis {
-		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+		if exposeAll || moduleAllowed(whitelist, api.Namespace) || (len(whitelist) == 0 && api.Public) {
 			// This is what the function DOES NOT do (relative to its sister function, RegisterAPIsFromWhitelist).
 			/*
 				if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
@@ -153,6 +153,35 @@ func TestRegisterProtocols(t *testing.T) {
 	}
 }
 
+// Tests that additional P2P servers are started and stopped along with the node
+// and persist their own node key in the datadir.
+func TestRegisterServer(t *testing.T) {
+	config := testNodeConfig()
+	config.DataDir = t.TempDir()
+	config.P2P.ListenAddr = "127.0.0.1:0"
+	config.P2P.NoDiscovery = true
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	srv := stack.RegisterServer("extra", p2p.Config{MaxPeers: 1, ListenAddr: "127.0.0.1:0", NoDiscovery: true})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if srv.Self() == nil {
+		t.Fatal("additional server not started")
+	}
+	if srv.Self().ID() == stack.Server().Self().ID() {
+		t.Fatal("additional server shares the node key of the main server")
+	}
+	if _, err := os.Stat(filepath.Join(config.DataDir, config.name(), "extra", datadirPrivateKey)); err != nil {
+		t.Fatalf("additional server node key not persisted: %v", err)
+	}
+}
+
 // TestRegisterProtocols_OpenRPC tests whether a running stack adequately responds to rpc_discover.
 func TestRegisterProtocols_OpenRPC_HTTP(t *testing.T) {
 	stack, err := New(testNodeConfig())
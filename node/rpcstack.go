@@ -58,6 +58,7 @@ type rpcEndpointConfig struct {
 	batchItemLimit         int
 	batchResponseSizeLimit int
 	httpBodyLimit          int
+	resultCache            rpc.ResultCache // optional cache of immutable call results
 }
 
 type rpcHandler struct {
@@ -309,6 +310,9 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 	if config.httpBodyLimit > 0 {
 		srv.SetHTTPBodyLimit(config.httpBodyLimit)
 	}
+	if config.resultCache != nil {
+		srv.SetResultCache(config.resultCache)
+	}
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
@@ -344,6 +348,9 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	if config.httpBodyLimit > 0 {
 		srv.SetHTTPBodyLimit(config.httpBodyLimit)
 	}
+	if config.resultCache != nil {
+		srv.SetResultCache(config.resultCache)
+	}
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
@@ -642,7 +649,7 @@ func RegisterApis(apis []rpc.API, modules []string, srv *rpc.Server) error {
 	}
 	// Register all the APIs exposed by the services
 	for _, api := range apis {
-		if allowList[api.Namespace] || len(allowList) == 0 {
+		if moduleAllowed(allowList, api.Namespace) || len(allowList) == 0 {
 			if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
 				return err
 			}
@@ -614,3 +614,37 @@ func (s *testService) Greet() string {
 func (s *testService) Sleep() {
 	time.Sleep(1500 * time.Millisecond)
 }
+
+// Tests that the namespaces of the additional networks hosted by the node are
+// enabled by their base module.
+func TestRegisterApisNamespaced(t *testing.T) {
+	apis := []rpc.API{
+		{Namespace: "eth", Service: new(testService)},
+		{Namespace: "net", Service: new(testService)},
+		{Namespace: "mordor.eth", Service: new(testService)},
+		{Namespace: "mordor.net", Service: new(testService)},
+	}
+	srv := rpc.NewServer()
+	defer srv.Stop()
+
+	if err := RegisterApis(apis, []string{"eth"}, srv); err != nil {
+		t.Fatalf("failed to register apis: %v", err)
+	}
+	client := rpc.DialInProc(srv)
+	defer client.Close()
+
+	modules, err := client.SupportedModules()
+	if err != nil {
+		t.Fatalf("failed to retrieve modules: %v", err)
+	}
+	for _, namespace := range []string{"eth", "mordor.eth"} {
+		if _, ok := modules[namespace]; !ok {
+			t.Errorf("enabled module %s not registered", namespace)
+		}
+	}
+	for _, namespace := range []string{"net", "mordor.net"} {
+		if _, ok := modules[namespace]; ok {
+			t.Errorf("disabled module %s registered", namespace)
+		}
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/p2p/reputation"
 )
 
 const (
@@ -77,6 +78,8 @@ var (
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNetRestrict      = errors.New("not contained in netrestrict list")
 	errNoPort           = errors.New("node does not provide TCP port")
+	errLowReputation    = errors.New("reputation below threshold")
+	errSubnetLimit      = errors.New("too many peers in subnet")
 )
 
 // dialer creates outbound connections and submits them into Server.
@@ -88,6 +91,12 @@ var (
 //   - dynamic dials are created from node discovery results. The dialer
 //     continuously reads candidate nodes from its input iterator and attempts
 //     to create peer connections to nodes arriving through the iterator.
+//
+// Dynamic dials are spread across network prefixes: at most subnetLimit dialed
+// peers may share a prefix. Anchor nodes, the long-lived peers of the previous
+// run, are dialed once on startup before any discovery results. Candidates of
+// the preferred iterator, such as nodes found advertising our network, are
+// dialed before the ones of the main iterator whenever both are available.
 type dialScheduler struct {
 	dialConfig
 	setupFunc   dialSetupFunc
@@ -95,6 +104,7 @@ type dialScheduler struct {
 	cancel      context.CancelFunc
 	ctx         context.Context
 	nodesIn     chan *enode.Node
+	preferredIn chan *enode.Node
 	doneCh      chan *dialTask
 	addStaticCh chan *enode.Node
 	remStaticCh chan *enode.Node
@@ -106,6 +116,8 @@ type dialScheduler struct {
 	dialing   map[enode.ID]*dialTask // active tasks
 	peers     map[enode.ID]struct{}  // all connected peers
 	dialPeers int                    // current number of dialed peers
+	subnets   *subnetCounter         // dialed peers and dynamic dials per subnet
+	anchors   []*enode.Node          // anchor nodes not dialed yet
 
 	// The static map tracks all static dial tasks. The subset of usable static dial tasks
 	// (i.e. those passing checkDial) is kept in staticPool. The scheduler prefers
@@ -126,10 +138,14 @@ type dialScheduler struct {
 type dialSetupFunc func(net.Conn, connFlag, *enode.Node) error
 
 type dialConfig struct {
-	self           enode.ID         // our own ID
-	maxDialPeers   int              // maximum number of dialed peers
-	maxActiveDials int              // maximum number of active dials
-	netRestrict    *netutil.Netlist // IP netrestrict list, disabled if nil
+	self           enode.ID          // our own ID
+	maxDialPeers   int               // maximum number of dialed peers
+	maxActiveDials int               // maximum number of active dials
+	netRestrict    *netutil.Netlist  // IP netrestrict list, disabled if nil
+	reputation     *reputation.Store // peer reputation filter, disabled if nil
+	subnetLimit    int               // maximum number of dialed peers per subnet, disabled if zero
+	anchors        []*enode.Node     // long-lived peers of the previous run
+	preferred      enode.Iterator    // dial candidates taking precedence, disabled if nil
 	resolver       nodeResolver
 	dialer         NodeDialer
 	log            log.Logger
@@ -165,8 +181,11 @@ func newDialScheduler(config dialConfig, it enode.Iterator, setupFunc dialSetupF
 		dialing:      make(map[enode.ID]*dialTask),
 		static:       make(map[enode.ID]*dialTask),
 		peers:        make(map[enode.ID]struct{}),
+		subnets:      newSubnetCounter(cfg.subnetLimit),
+		anchors:      cfg.anchors,
 		doneCh:       make(chan *dialTask),
 		nodesIn:      make(chan *enode.Node),
+		preferredIn:  make(chan *enode.Node),
 		addStaticCh:  make(chan *enode.Node),
 		remStaticCh:  make(chan *enode.Node),
 		addPeerCh:    make(chan *conn),
@@ -175,7 +194,11 @@ func newDialScheduler(config dialConfig, it enode.Iterator, setupFunc dialSetupF
 	d.lastStatsLog = d.clock.Now()
 	d.ctx, d.cancel = context.WithCancel(context.Background())
 	d.wg.Add(2)
-	go d.readNodes(it)
+	go d.readNodes(it, d.nodesIn)
+	if cfg.preferred != nil {
+		d.wg.Add(1)
+		go d.readNodes(cfg.preferred, d.preferredIn)
+	}
 	go d.loop(it)
 	return d
 }
@@ -221,7 +244,8 @@ func (d *dialScheduler) peerRemoved(c *conn) {
 // loop is the main loop of the dialer.
 func (d *dialScheduler) loop(it enode.Iterator) {
 	var (
-		nodesCh chan *enode.Node
+		nodesCh     chan *enode.Node
+		preferredCh chan *enode.Node
 	)
 
 loop:
@@ -229,31 +253,43 @@ loop:
 		// Launch new dials if slots are available.
 		slots := d.freeDialSlots()
 		slots -= d.startStaticDials(slots)
+		slots -= d.startAnchorDials(slots)
 		if slots > 0 {
-			nodesCh = d.nodesIn
+			nodesCh, preferredCh = d.nodesIn, d.preferredIn
 		} else {
-			nodesCh = nil
+			nodesCh, preferredCh = nil, nil
 		}
 		d.rearmHistoryTimer()
 		d.logStats()
 
+		// Take a preferred candidate if one is ready, before looking at the rest.
+		select {
+		case node := <-preferredCh:
+			d.dialCandidate(node)
+			continue loop
+		default:
+		}
+
 		select {
+		case node := <-preferredCh:
+			d.dialCandidate(node)
+
 		case node := <-nodesCh:
-			if err := d.checkDial(node); err != nil {
-				d.log.Trace("Discarding dial candidate", "id", node.ID(), "ip", node.IPAddr(), "reason", err)
-			} else {
-				d.startDial(newDialTask(node, dynDialedConn))
-			}
+			d.dialCandidate(node)
 
 		case task := <-d.doneCh:
 			id := task.dest().ID()
 			delete(d.dialing, id)
+			if task.flags&dynDialedConn != 0 {
+				d.subnets.remove(task.dest().IPAddr())
+			}
 			d.updateStaticPool(id)
 			d.doneSinceLastLog++
 
 		case c := <-d.addPeerCh:
 			if c.is(dynDialedConn) || c.is(staticDialedConn) {
 				d.dialPeers++
+				d.subnets.add(c.node.IPAddr())
 			}
 			id := c.node.ID()
 			d.peers[id] = struct{}{}
@@ -267,6 +303,7 @@ loop:
 		case c := <-d.remPeerCh:
 			if c.is(dynDialedConn) || c.is(staticDialedConn) {
 				d.dialPeers--
+				d.subnets.remove(c.node.IPAddr())
 			}
 			delete(d.peers, c.node.ID())
 			d.updateStaticPool(c.node.ID())
@@ -300,6 +337,9 @@ loop:
 
 		case <-d.ctx.Done():
 			it.Close()
+			if d.preferred != nil {
+				d.preferred.Close()
+			}
 			break loop
 		}
 	}
@@ -312,18 +352,28 @@ loop:
 }
 
 // readNodes runs in its own goroutine and delivers nodes from
-// the input iterator to the nodesIn channel.
-func (d *dialScheduler) readNodes(it enode.Iterator) {
+// an input iterator to the given channel.
+func (d *dialScheduler) readNodes(it enode.Iterator, ch chan<- *enode.Node) {
 	defer d.wg.Done()
 
 	for it.Next() {
 		select {
-		case d.nodesIn <- it.Node():
+		case ch <- it.Node():
 		case <-d.ctx.Done():
 		}
 	}
 }
 
+// dialCandidate starts a dynamic dial to a discovered node, unless it is not a
+// suitable dial target.
+func (d *dialScheduler) dialCandidate(node *enode.Node) {
+	if err := d.checkDial(node); err != nil {
+		d.log.Trace("Discarding dial candidate", "id", node.ID(), "ip", node.IPAddr(), "reason", err)
+		return
+	}
+	d.startDial(newDialTask(node, dynDialedConn))
+}
+
 // logStats prints dialer statistics to the log. The message is suppressed when enough
 // peers are connected because users should only see it while their client is starting up
 // or comes back online.
@@ -391,6 +441,14 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	if d.history.contains(string(n.ID().Bytes())) {
 		return errRecentlyDialed
 	}
+	if _, static := d.static[n.ID()]; !static {
+		if d.reputation != nil && !d.reputation.Allowed(n.ID()) {
+			return errLowReputation
+		}
+		if d.subnets.full(n.IPAddr()) {
+			return errSubnetLimit
+		}
+	}
 	return nil
 }
 
@@ -405,6 +463,22 @@ func (d *dialScheduler) startStaticDials(n int) (started int) {
 	return started
 }
 
+// startAnchorDials starts up to n dials to the remaining anchor nodes. Anchors
+// failing checkDial are dropped.
+func (d *dialScheduler) startAnchorDials(n int) (started int) {
+	for started < n && len(d.anchors) > 0 {
+		node := d.anchors[0]
+		d.anchors = d.anchors[1:]
+		if err := d.checkDial(node); err != nil {
+			d.log.Trace("Discarding anchor node", "id", node.ID(), "ip", node.IPAddr(), "reason", err)
+			continue
+		}
+		d.startDial(newDialTask(node, dynDialedConn))
+		started++
+	}
+	return started
+}
+
 // updateStaticPool attempts to move the given static dial back into staticPool.
 func (d *dialScheduler) updateStaticPool(id enode.ID) {
 	task, ok := d.static[id]
@@ -440,6 +514,9 @@ func (d *dialScheduler) startDial(task *dialTask) {
 	hkey := string(node.ID().Bytes())
 	d.history.add(hkey, d.clock.Now().Add(dialHistoryExpiration))
 	d.dialing[node.ID()] = task
+	if task.flags&dynDialedConn != 0 {
+		d.subnets.add(node.IPAddr())
+	}
 	go func() {
 		task.run(d)
 		d.doneCh <- task
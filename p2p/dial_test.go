@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/p2p/reputation"
 )
 
 // This test checks that dynamic dials are launched from discovery results.
@@ -152,6 +153,133 @@ func TestDialSchedNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that candidates with a bad reputation are not dialed.
+func TestDialSchedReputation(t *testing.T) {
+	t.Parallel()
+
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	nodes := []*enode.Node{
+		newNode(uintID(0x01), "127.0.0.1:30303"),
+		newNode(uintID(0x02), "127.0.0.2:30303"),
+		newNode(uintID(0x03), "127.0.0.3:30303"),
+	}
+	config := dialConfig{
+		reputation:     reputation.NewStore(db, reputation.DefaultThreshold),
+		maxActiveDials: 10,
+		maxDialPeers:   10,
+	}
+	defer config.reputation.Close()
+
+	config.reputation.Report(nodes[1].ID(), reputation.InvalidData)
+	config.reputation.Report(nodes[1].ID(), reputation.InvalidData)
+	config.reputation.Report(nodes[2].ID(), reputation.InvalidData)
+
+	runDialTest(t, config, []dialTestRound{
+		{
+			discovered:   nodes,
+			wantNewDials: []*enode.Node{nodes[0], nodes[2]},
+		},
+	})
+}
+
+// This test checks that dynamic dials are spread across subnets.
+func TestDialSchedSubnetLimit(t *testing.T) {
+	t.Parallel()
+
+	nodes := []*enode.Node{
+		newNode(uintID(0x01), "1.1.0.1:30303"),
+		newNode(uintID(0x02), "1.1.0.2:30303"),
+		newNode(uintID(0x03), "1.1.0.3:30303"),
+		newNode(uintID(0x04), "2.2.0.1:30303"),
+		newNode(uintID(0x05), "1.1.0.5:30303"),
+		newNode(uintID(0x06), "192.168.0.1:30303"),
+		newNode(uintID(0x07), "192.168.0.2:30303"),
+		newNode(uintID(0x08), "192.168.0.3:30303"),
+	}
+	config := dialConfig{
+		maxActiveDials: 10,
+		maxDialPeers:   10,
+		subnetLimit:    2,
+	}
+	runDialTest(t, config, []dialTestRound{
+		// Only two dials to 1.1.0.0/16 are allowed, LAN addresses aren't limited.
+		{
+			discovered: []*enode.Node{nodes[0], nodes[1], nodes[2], nodes[3], nodes[5], nodes[6], nodes[7]},
+			wantNewDials: []*enode.Node{
+				nodes[0], nodes[1], nodes[3], nodes[5], nodes[6], nodes[7],
+			},
+		},
+		// One dial connects, the other fails, freeing a slot in the subnet.
+		{
+			succeeded: []enode.ID{nodes[0].ID()},
+			failed:    []enode.ID{nodes[1].ID()},
+		},
+		{
+			discovered:   []*enode.Node{nodes[2], nodes[4]},
+			wantNewDials: []*enode.Node{nodes[2]},
+		},
+	})
+}
+
+// This test checks that anchor nodes are dialed before discovered nodes.
+func TestDialSchedAnchors(t *testing.T) {
+	t.Parallel()
+
+	anchors := []*enode.Node{
+		newNode(uintID(0x01), "127.0.0.1:30303"),
+		newNode(uintID(0x02), "127.0.0.2:30303"),
+		newNode(uintID(0x03), "127.0.0.3:30303"),
+	}
+	config := dialConfig{
+		maxActiveDials: 2,
+		maxDialPeers:   10,
+		anchors:        anchors,
+	}
+	runDialTest(t, config, []dialTestRound{
+		{
+			discovered:   []*enode.Node{newNode(uintID(0x04), "127.0.0.4:30303")},
+			wantNewDials: []*enode.Node{anchors[0], anchors[1]},
+		},
+		{
+			failed:       []enode.ID{anchors[0].ID(), anchors[1].ID()},
+			wantNewDials: []*enode.Node{anchors[2], newNode(uintID(0x04), "127.0.0.4:30303")},
+		},
+	})
+}
+
+// This test checks that candidates of the preferred iterator are dialed along
+// with the discovered ones, and subject to the same checks.
+func TestDialSchedPreferred(t *testing.T) {
+	t.Parallel()
+
+	preferred := []*enode.Node{
+		newNode(uintID(0x01), "127.0.0.1:30303"),
+		newNode(uintID(0x02), "127.0.0.2:30303"),
+		newNode(uintID(0x03), "127.0.0.3:30303"),
+		newNode(uintID(0x04), ""), // not dialed because it has no TCP port
+	}
+	it := newDialTestIterator()
+	it.addNodes(preferred)
+
+	config := dialConfig{
+		maxActiveDials: 2,
+		maxDialPeers:   10,
+		preferred:      it,
+	}
+	runDialTest(t, config, []dialTestRound{
+		{
+			wantNewDials: []*enode.Node{preferred[0], preferred[1]},
+		},
+		{
+			failed:       []enode.ID{preferred[0].ID(), preferred[1].ID()},
+			discovered:   []*enode.Node{newNode(uintID(0x05), "127.0.0.5:30303")},
+			wantNewDials: []*enode.Node{preferred[2], newNode(uintID(0x05), "127.0.0.5:30303")},
+		},
+	})
+}
+
 // This test checks that static dials work and obey the limits.
 func TestDialSchedStaticDial(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,260 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Topic discovery lets nodes find the members of a group, such as the nodes of
+// a specific network, without connecting to every node of the shared DHT. Nodes
+// advertise their topics in the "topics" entry of their record, and answer
+// topic queries, sent as TALKREQ, with the nodes of their table advertising the
+// requested topic.
+const (
+	topicProtocol       = "topic"
+	topicResponseLimit  = 1000             // bytes of records in a topic query response
+	topicQueryCacheSize = 1024             // number of nodes whose last query is remembered
+	topicQueryInterval  = 10 * time.Minute // minimum time between queries to the same node
+)
+
+var errTopicMismatch = errors.New("node does not advertise topic")
+
+// Topic identifies a group of nodes on the discovery network.
+type Topic [32]byte
+
+// NewTopic creates the topic identified by the hash of the given data.
+func NewTopic(data ...[]byte) Topic {
+	return Topic(crypto.Keccak256Hash(data...))
+}
+
+// String implements fmt.Stringer.
+func (t Topic) String() string {
+	return fmt.Sprintf("%x", t[:8])
+}
+
+// topicsEntry is the ENR entry listing the topics advertised by a node.
+type topicsEntry []Topic
+
+// ENRKey implements enr.Entry.
+func (topicsEntry) ENRKey() string { return "topics" }
+
+// NodeTopics returns the topics advertised in the record of a node.
+func NodeTopics(n *enode.Node) []Topic {
+	var topics topicsEntry
+	if n.Load(&topics) != nil {
+		return nil
+	}
+	return topics
+}
+
+// HasTopic reports whether a node advertises the given topic.
+func HasTopic(n *enode.Node, topic Topic) bool {
+	return slices.Contains(NodeTopics(n), topic)
+}
+
+// AdvertiseTopic adds a topic to the ones advertised by the local node.
+func (t *UDPv5) AdvertiseTopic(topic Topic) {
+	t.topicMu.Lock()
+	defer t.topicMu.Unlock()
+
+	if slices.Contains(t.topics, topic) {
+		return
+	}
+	t.topics = append(t.topics, topic)
+	t.localNode.Set(topicsEntry(slices.Clone(t.topics)))
+}
+
+// StopAdvertising removes a topic from the ones advertised by the local node.
+func (t *UDPv5) StopAdvertising(topic Topic) {
+	t.topicMu.Lock()
+	defer t.topicMu.Unlock()
+
+	i := slices.Index(t.topics, topic)
+	if i < 0 {
+		return
+	}
+	t.topics = slices.Delete(t.topics, i, i+1)
+	if len(t.topics) == 0 {
+		t.localNode.Delete(topicsEntry(nil))
+	} else {
+		t.localNode.Set(topicsEntry(slices.Clone(t.topics)))
+	}
+}
+
+// TopicNodes returns an iterator of the nodes advertising the given topic. It
+// runs random lookups, returning the nodes found advertising the topic, and
+// asks the nodes supporting topic queries for the ones they know about.
+func (t *UDPv5) TopicNodes(topic Topic) enode.Iterator {
+	return &topicIterator{
+		t:       t,
+		topic:   topic,
+		lookup:  newLookupIterator(t.closeCtx, t.newRandomLookup),
+		queried: lru.NewBasicLRU[enode.ID, mclock.AbsTime](topicQueryCacheSize),
+	}
+}
+
+// TopicQuery asks a node for the nodes of its table advertising the topic.
+func (t *UDPv5) TopicQuery(n *enode.Node, topic Topic) ([]*enode.Node, error) {
+	resp, err := t.TalkRequest(n, topicProtocol, topic[:])
+	if err != nil {
+		return nil, err
+	}
+	var records []*enr.Record
+	if err := rlp.DecodeBytes(resp, &records); err != nil {
+		return nil, err
+	}
+	nodes := make([]*enode.Node, 0, len(records))
+	for _, r := range records {
+		node, err := t.verifyTopicNode(n, r, topic)
+		if err != nil {
+			t.log.Debug("Invalid record in topic response", "id", n.ID(), "err", err)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// verifyTopicNode checks a record returned by a topic query.
+func (t *UDPv5) verifyTopicNode(src *enode.Node, r *enr.Record, topic Topic) (*enode.Node, error) {
+	node, err := enode.New(t.validSchemes, r)
+	if err != nil {
+		return nil, err
+	}
+	if node.ID() == t.Self().ID() {
+		return nil, errors.New("local node")
+	}
+	if err := netutil.CheckRelayAddr(src.IPAddr(), node.IPAddr()); err != nil {
+		return nil, err
+	}
+	if t.netrestrict != nil && !t.netrestrict.ContainsAddr(node.IPAddr()) {
+		return nil, errors.New("not contained in netrestrict list")
+	}
+	if node.UDP() <= 1024 {
+		return nil, errLowPort
+	}
+	if !HasTopic(node, topic) {
+		return nil, errTopicMismatch
+	}
+	return node, nil
+}
+
+// handleTopicQuery answers a topic query with the live nodes of the table that
+// advertise the requested topic.
+func (t *UDPv5) handleTopicQuery(id enode.ID, addr *net.UDPAddr, req []byte) []byte {
+	if len(req) != len(Topic{}) {
+		return nil
+	}
+	var (
+		topic   = Topic(req)
+		rip     = addr.AddrPort().Addr().Unmap()
+		records []*enr.Record
+		size    uint64
+	)
+	for dist := uint(1); dist <= 256; dist++ {
+		for _, n := range t.tab.appendLiveNodes(dist, nil) {
+			if n.ID() == id || !HasTopic(n, topic) || netutil.CheckRelayAddr(rip, n.IPAddr()) != nil {
+				continue
+			}
+			r := n.Record()
+			if size += r.Size(); size > topicResponseLimit {
+				return encodeTopicResponse(records)
+			}
+			records = append(records, r)
+		}
+	}
+	return encodeTopicResponse(records)
+}
+
+func encodeTopicResponse(records []*enr.Record) []byte {
+	if records == nil {
+		records = []*enr.Record{}
+	}
+	resp, err := rlp.EncodeToBytes(records)
+	if err != nil {
+		panic(err) // This can only fail during implementation
+	}
+	return resp
+}
+
+// topicIterator finds the nodes advertising a topic.
+type topicIterator struct {
+	t      *UDPv5
+	topic  Topic
+	lookup *lookupIterator
+
+	queried lru.BasicLRU[enode.ID, mclock.AbsTime] // Last query time of the nodes asked
+	buffer  []*enode.Node                          // Nodes found but not returned yet
+	cur     *enode.Node
+}
+
+// Next moves to the next node advertising the topic.
+func (it *topicIterator) Next() bool {
+	it.cur = nil
+	for len(it.buffer) == 0 {
+		if !it.lookup.Next() {
+			return false
+		}
+		n := it.lookup.Node()
+		topics := NodeTopics(n)
+		if slices.Contains(topics, it.topic) {
+			it.buffer = append(it.buffer, n)
+		}
+		// Nodes advertising any topic support topic queries, ask them for more
+		if len(topics) > 0 && it.shouldQuery(n.ID()) {
+			if nodes, err := it.t.TopicQuery(n, it.topic); err == nil {
+				it.buffer = append(it.buffer, nodes...)
+			}
+		}
+	}
+	it.cur, it.buffer = it.buffer[0], it.buffer[1:]
+	return true
+}
+
+// shouldQuery reports whether a node should be sent a topic query, marking it
+// as queried if so.
+func (it *topicIterator) shouldQuery(id enode.ID) bool {
+	now := it.t.clock.Now()
+	if last, ok := it.queried.Get(id); ok && now.Sub(last) < topicQueryInterval {
+		return false
+	}
+	it.queried.Add(id, now)
+	return true
+}
+
+// Node returns the current node.
+func (it *topicIterator) Node() *enode.Node {
+	return it.cur
+}
+
+// Close ends the iterator.
+func (it *topicIterator) Close() {
+	it.lookup.Close()
+}
@@ -77,6 +77,10 @@ type UDPv5 struct {
 	// talkreq handler registry
 	talk *talkSystem
 
+	// topics advertised by the local node
+	topicMu sync.Mutex
+	topics  []Topic
+
 	// channels into dispatch
 	packetInCh    chan ReadPacket
 	readNextCh    chan struct{}
@@ -175,6 +179,7 @@ func newUDPv5(conn UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
 		cancelCloseCtx: cancelCloseCtx,
 	}
 	t.talk = newTalkSystem(t)
+	t.talk.register(topicProtocol, t.handleTopicQuery)
 	tab, err := newTable(t, t.db, cfg)
 	if err != nil {
 		return nil, err
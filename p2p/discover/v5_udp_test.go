@@ -856,3 +856,93 @@ func (test *udpV5Test) close() {
 		test.t.Fatalf("%d unmatched UDP packets in queue", len(test.pipe.queue))
 	}
 }
+
+// This test checks that topic queries return the live table nodes advertising the
+// requested topic, and that the topic iterator finds them.
+func TestUDPv5_topicDiscovery(t *testing.T) {
+	t.Parallel()
+
+	var (
+		topic = NewTopic([]byte("test"))
+		other = NewTopic([]byte("other"))
+	)
+	server := startLocalhostV5(t, Config{})
+	defer server.Close()
+	server.AdvertiseTopic(other)
+
+	// Fill the table of the server with nodes of both topics.
+	var want []*enode.Node
+	for i := 0; i < 4; i++ {
+		advertised := other
+		if i%2 == 0 {
+			advertised = topic
+		}
+		db, _ := enode.OpenDB("")
+		ln := enode.NewLocalNode(db, newkey())
+		ln.SetStaticIP(net.IP{127, 0, 0, 1})
+		ln.Set(enr.UDP(30303 + i))
+		ln.Set(topicsEntry{advertised})
+		if advertised == topic {
+			want = append(want, ln.Node())
+		}
+		fillTable(server.tab, []*enode.Node{ln.Node()}, true)
+		db.Close()
+	}
+	client := startLocalhostV5(t, Config{Bootnodes: []*enode.Node{server.Self()}})
+	defer client.Close()
+
+	// Query the server directly.
+	nodes, err := client.TopicQuery(server.Self(), topic)
+	if err != nil {
+		t.Fatal("topic query failed:", err)
+	}
+	if err := checkNodesEqual(nodes, want); err != nil {
+		t.Fatalf("wrong topic query results: %v", err)
+	}
+	if nodes, err := client.TopicQuery(server.Self(), NewTopic([]byte("unknown"))); err != nil || len(nodes) != 0 {
+		t.Fatalf("unknown topic query results: %v, %v", nodes, err)
+	}
+
+	// Run the iterator, which should query the server as it advertises a topic.
+	it := client.TopicNodes(topic)
+	defer it.Close()
+
+	found := make(map[enode.ID]bool)
+	for len(found) < len(want) && it.Next() {
+		if !HasTopic(it.Node(), topic) {
+			t.Fatalf("iterator returned node without topic: %v", it.Node())
+		}
+		found[it.Node().ID()] = true
+	}
+	for _, n := range want {
+		if !found[n.ID()] {
+			t.Errorf("node %v not found by iterator", n.ID())
+		}
+	}
+}
+
+// This test checks that the advertised topics are reflected in the local record.
+func TestUDPv5_advertiseTopic(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	var (
+		a = NewTopic([]byte("a"))
+		b = NewTopic([]byte("b"))
+	)
+	test.udp.AdvertiseTopic(a)
+	test.udp.AdvertiseTopic(b)
+	test.udp.AdvertiseTopic(a)
+	if topics := NodeTopics(test.udp.Self()); !slices.Equal(topics, []Topic{a, b}) {
+		t.Fatalf("wrong advertised topics: %v", topics)
+	}
+	test.udp.StopAdvertising(a)
+	if topics := NodeTopics(test.udp.Self()); !slices.Equal(topics, []Topic{b}) {
+		t.Fatalf("wrong advertised topics after removal: %v", topics)
+	}
+	test.udp.StopAdvertising(b)
+	if topics := NodeTopics(test.udp.Self()); len(topics) != 0 {
+		t.Fatalf("topics advertised after removing all: %v", topics)
+	}
+}
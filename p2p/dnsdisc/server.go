@@ -0,0 +1,313 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	maxUDPResponseSize  = 512             // response size limit of queries without EDNS
+	maxEDNSResponseSize = 1232            // response size limit advertised to EDNS clients
+	maxTXTStringSize    = 255             // length limit of a single TXT character-string
+	tcpTimeout          = 5 * time.Second // idle timeout of DNS-over-TCP connections
+)
+
+// Server is an authoritative DNS server for the TXT records of a node tree. It
+// answers queries over UDP and TCP, making it possible to publish a tree without
+// relying on a DNS hosting provider. The zone of the tree needs to be delegated
+// to the server with NS records in the parent zone.
+type Server struct {
+	domain string
+	ttl    uint32
+	log    log.Logger
+
+	lock    sync.RWMutex
+	records map[string]string // TXT records, keyed by lowercase name
+
+	udp  net.PacketConn
+	tcp  net.Listener
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewServer creates a DNS server for the tree published under the given domain.
+// Answers are returned with the given TTL in seconds.
+func NewServer(domain string, ttl uint32) *Server {
+	return &Server{
+		domain:  canonicalName(domain),
+		ttl:     ttl,
+		log:     log.Root().New("domain", domain),
+		records: make(map[string]string),
+		quit:    make(chan struct{}),
+	}
+}
+
+// SetTree replaces the records served with the ones of the given tree.
+func (s *Server) SetTree(t *Tree) {
+	records := make(map[string]string)
+	for name, txt := range t.ToTXT(s.domain) {
+		records[canonicalName(name)] = txt
+	}
+	s.lock.Lock()
+	s.records = records
+	s.lock.Unlock()
+}
+
+// ListenAndServe starts serving DNS queries on the given UDP and TCP address.
+func (s *Server) ListenAndServe(addr string) error {
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	// Listen on the same port over TCP, which matters if the UDP one was random
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		udp.Close()
+		return err
+	}
+	s.udp, s.tcp = udp, tcp
+
+	s.wg.Add(2)
+	go s.serveUDP()
+	go s.serveTCP()
+	return nil
+}
+
+// Addr returns the UDP address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.udp.LocalAddr()
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	close(s.quit)
+	if s.udp != nil {
+		s.udp.Close()
+		s.tcp.Close()
+	}
+	s.wg.Wait()
+}
+
+func (s *Server) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.log.Debug("DNS server read failed", "err", err)
+			}
+			return
+		}
+		if resp := s.handle(buf[:n], false); resp != nil {
+			s.udp.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *Server) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.log.Debug("DNS server accept failed", "err", err)
+			}
+			return
+		}
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn answers the length-prefixed queries of a DNS-over-TCP connection.
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.quit:
+			conn.Close()
+		case <-done:
+		}
+	}()
+	for {
+		conn.SetDeadline(time.Now().Add(tcpTimeout))
+
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		resp := s.handle(req, true)
+		if resp == nil {
+			return
+		}
+		binary.BigEndian.PutUint16(size[:], uint16(len(resp)))
+		if _, err := conn.Write(append(size[:], resp...)); err != nil {
+			return
+		}
+	}
+}
+
+// handle answers a DNS query. It returns nil if the query can't be parsed.
+func (s *Server) handle(req []byte, stream bool) []byte {
+	var p dnsmessage.Parser
+	reqHeader, err := p.Start(req)
+	if err != nil || reqHeader.Response {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+	// Find the response size limit of the client
+	limit, edns := maxUDPResponseSize, false
+	if err := p.SkipAllQuestions(); err == nil {
+		if err := p.SkipAllAnswers(); err == nil {
+			if err := p.SkipAllAuthorities(); err == nil {
+				for {
+					h, err := p.AdditionalHeader()
+					if err != nil {
+						break
+					}
+					if h.Type == dnsmessage.TypeOPT {
+						edns, limit = true, max(limit, min(int(h.Class), maxEDNSResponseSize))
+						break
+					}
+					p.SkipAdditional()
+				}
+			}
+		}
+	}
+	if stream {
+		limit = 65535
+	}
+	header := dnsmessage.Header{
+		ID:            reqHeader.ID,
+		Response:      true,
+		OpCode:        reqHeader.OpCode,
+		Authoritative: true,
+		RCode:         dnsmessage.RCodeSuccess,
+	}
+	// Look up the requested record
+	var (
+		name      = canonicalName(q.Name.String())
+		txt       string
+		found     bool
+		answering bool
+	)
+	switch {
+	case reqHeader.OpCode != 0:
+		header.RCode = dnsmessage.RCodeNotImplemented
+	case q.Class != dnsmessage.ClassINET && q.Class != dnsmessage.ClassANY:
+		header.RCode = dnsmessage.RCodeRefused
+	case name != s.domain && !strings.HasSuffix(name, "."+s.domain):
+		header.RCode = dnsmessage.RCodeRefused
+	default:
+		s.lock.RLock()
+		txt, found = s.records[name]
+		s.lock.RUnlock()
+
+		if !found {
+			header.RCode = dnsmessage.RCodeNameError
+		}
+		answering = found && (q.Type == dnsmessage.TypeTXT || q.Type == dnsmessage.TypeALL)
+	}
+	resp, err := s.buildResponse(header, q, txt, answering, edns)
+	if err != nil {
+		s.log.Debug("Failed to build DNS response", "name", name, "err", err)
+		return nil
+	}
+	if len(resp) > limit {
+		// Signal the client to retry over TCP
+		header.Truncated = true
+		if resp, err = s.buildResponse(header, q, "", false, edns); err != nil {
+			return nil
+		}
+	}
+	return resp
+}
+
+// buildResponse assembles a DNS response, optionally answering with a TXT record.
+func (s *Server) buildResponse(header dnsmessage.Header, q dnsmessage.Question, txt string, answer, edns bool) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, header)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if answer {
+		h := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: s.ttl}
+		if err := b.TXTResource(h, dnsmessage.TXTResource{TXT: splitTXT(txt)}); err != nil {
+			return nil, err
+		}
+	}
+	if edns {
+		if err := b.StartAdditionals(); err != nil {
+			return nil, err
+		}
+		var h dnsmessage.ResourceHeader
+		if err := h.SetEDNS0(maxEDNSResponseSize, dnsmessage.RCodeSuccess, false); err != nil {
+			return nil, err
+		}
+		if err := b.OPTResource(h, dnsmessage.OPTResource{}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// splitTXT splits a TXT record value into character-strings of the maximum size.
+// Resolvers concatenate the strings of a record.
+func splitTXT(txt string) []string {
+	var parts []string
+	for len(txt) > maxTXTStringSize {
+		parts = append(parts, txt[:maxTXTStringSize])
+		txt = txt[maxTXTStringSize:]
+	}
+	return append(parts, txt)
+}
+
+// canonicalName returns the lowercase form of a domain name, without the trailing
+// dot.
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// bigTestNode creates a node whose record is large enough for its TXT entry to
+// exceed the size of a single character-string and of a plain UDP response.
+func bigTestNode(t *testing.T) *enode.Node {
+	key := testKeys(1)[0]
+	record := new(enr.Record)
+	record.Set(enr.WithEntry("junk", make([]byte, 150)))
+	if err := enode.SignV4(record, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// This test checks that a client can sync a tree served by the DNS server.
+func TestServerSyncTree(t *testing.T) {
+	const domain = "nodes.example.org"
+
+	nodes := append(testNodes(testKeys(20)), bigTestNode(t))
+	tree, url := makeTestTree(domain, nodes, nil)
+
+	srv := NewServer(domain, 60)
+	if err := srv.ListenAndServe("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.SetTree(tree)
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, srv.Addr().String())
+		},
+	}
+	c := NewClient(Config{Resolver: resolver, RateLimit: 1000, Logger: testlog.Logger(t, log.LvlTrace)})
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortByID(stree.Nodes()), sortByID(nodes)) {
+		t.Errorf("wrong nodes in synced tree: have %d, want %d", len(stree.Nodes()), len(nodes))
+	}
+	if stree.Seq() != tree.Seq() {
+		t.Errorf("synced tree has wrong seq: %d", stree.Seq())
+	}
+	// Names outside of the zone must be refused.
+	if _, err := resolver.LookupTXT(context.Background(), "example.com."); err == nil {
+		t.Error("query outside of zone answered")
+	}
+}
+
+// This test checks that answers too large for a UDP response are truncated, and
+// complete over TCP.
+func TestServerTruncation(t *testing.T) {
+	const domain = "a-long-domain-name-filling-up-the-response.another-long-label-in-the-domain-name.nodes.example.org"
+
+	tree, _ := makeTestTree(domain, []*enode.Node{bigTestNode(t)}, nil)
+	srv := NewServer(domain, 60)
+	srv.SetTree(tree)
+
+	var name string
+	for n, txt := range tree.ToTXT(domain) {
+		if strings.HasPrefix(txt, "enr:") {
+			name = n
+		}
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1, RecursionDesired: true})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(name + "."),
+		Type:  dnsmessage.TypeTXT,
+		Class: dnsmessage.ClassINET,
+	})
+	req, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Over UDP, the answer doesn't fit without EDNS.
+	var msg dnsmessage.Message
+	if err := msg.Unpack(srv.handle(req, false)); err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Truncated || len(msg.Answers) != 0 {
+		t.Fatalf("UDP response not truncated: truncated %v, answers %d", msg.Truncated, len(msg.Answers))
+	}
+	// Over TCP, the full record is returned.
+	if err := msg.Unpack(srv.handle(req, true)); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Truncated || len(msg.Answers) != 1 {
+		t.Fatalf("TCP response truncated: truncated %v, answers %d", msg.Truncated, len(msg.Answers))
+	}
+	txt := msg.Answers[0].Body.(*dnsmessage.TXTResource).TXT
+	if len(txt) < 2 {
+		t.Fatalf("long record not split into multiple strings: %d", len(txt))
+	}
+	if strings.Join(txt, "") != tree.ToTXT(domain)[name] {
+		t.Fatal("wrong record returned")
+	}
+}
+
+// This test checks the zone file output of a tree.
+func TestTreeWriteZone(t *testing.T) {
+	const domain = "nodes.example.org"
+
+	tree, _ := makeTestTree(domain, append(testNodes(testKeys(3)), bigTestNode(t)), nil)
+
+	var buf bytes.Buffer
+	if err := tree.WriteZone(&buf, domain, 300); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	records := tree.ToTXT(domain)
+	if len(lines) != len(records) {
+		t.Fatalf("wrong number of zone records: have %d, want %d", len(lines), len(records))
+	}
+	if !strings.HasPrefix(lines[0], domain+". 300 IN TXT \"enrtree-root:v1 ") {
+		t.Fatalf("root record not first: %s", lines[0])
+	}
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, " 300 IN TXT ")
+		if !ok {
+			t.Fatalf("malformed zone record: %s", line)
+		}
+		want, ok := records[strings.TrimSuffix(name, ".")]
+		if !ok {
+			t.Fatalf("unknown record name: %s", name)
+		}
+		var joined string
+		for _, part := range strings.Split(value, " ") {
+			part = strings.TrimPrefix(strings.TrimSuffix(part, `"`), `"`)
+			if len(part) > maxTXTStringSize {
+				t.Fatalf("zone string too long: %d", len(part))
+			}
+			joined += part
+		}
+		// The root record contains spaces, compare without them.
+		if joined != strings.ReplaceAll(want, " ", "") && !strings.Contains(line, `"`+want+`"`) {
+			t.Errorf("wrong zone record for %s: %s", name, value)
+		}
+	}
+}
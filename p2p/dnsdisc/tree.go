@@ -92,6 +92,41 @@ func (t *Tree) ToTXT(domain string) map[string]string {
 	return records
 }
 
+// zoneEscaper escapes the characters with a special meaning in zone file strings.
+var zoneEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// WriteZone writes the TXT records of the tree in zone file format, with the
+// given TTL in seconds. The output contains the records only and is meant to be
+// included into the zone of the domain, e.g. with the $INCLUDE directive.
+func (t *Tree) WriteZone(w io.Writer, domain string, ttl uint32) error {
+	records := t.ToTXT(domain)
+	names := make([]string, 0, len(records))
+	for name := range records {
+		names = append(names, name)
+	}
+	// Put the root first, followed by the other records in a stable order.
+	slices.SortFunc(names, func(a, b string) int {
+		switch {
+		case a == domain:
+			return -1
+		case b == domain:
+			return 1
+		default:
+			return strings.Compare(a, b)
+		}
+	})
+	for _, name := range names {
+		var quoted []string
+		for _, part := range splitTXT(records[name]) {
+			quoted = append(quoted, `"`+zoneEscaper.Replace(part)+`"`)
+		}
+		if _, err := fmt.Fprintf(w, "%s. %d IN TXT %s\n", name, ttl, strings.Join(quoted, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Links returns all links contained in the tree.
 func (t *Tree) Links() []string {
 	var links []string
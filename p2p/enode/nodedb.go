@@ -41,6 +41,8 @@ const (
 	dbVersionKey   = "version" // Version of the database to flush if changes
 	dbNodePrefix   = "n:"      // Identifier to prefix node entries with
 	dbLocalPrefix  = "local:"
+	dbPeerPrefix   = "peer:"   // Identifier to prefix peer reputation entries with
+	dbAnchorPrefix = "anchor:" // Identifier to prefix anchor peer entries with
 	dbDiscoverRoot = "v4"
 	dbDiscv5Root   = "v5"
 
@@ -194,6 +196,16 @@ func localItemKey(id ID, field string) []byte {
 	return key
 }
 
+// peerKey returns the database key for the reputation record of a peer.
+func peerKey(id ID) []byte {
+	return append([]byte(dbPeerPrefix), id[:]...)
+}
+
+// anchorKey returns the database key for an anchor peer.
+func anchorKey(id ID) []byte {
+	return append([]byte(dbAnchorPrefix), id[:]...)
+}
+
 // fetchInt64 retrieves an integer associated with a particular key.
 func (db *DB) fetchInt64(key []byte) int64 {
 	blob, err := db.lvl.Get(key, nil)
@@ -427,6 +439,89 @@ func (db *DB) UpdateFindFailsV5(id ID, ip netip.Addr, fails int) error {
 	return db.storeInt64(v5Key(id, ip, dbNodeFindFails), int64(fails))
 }
 
+// PeerReputation retrieves the encoded reputation record of a peer, or nil if
+// no reputation was recorded for it.
+func (db *DB) PeerReputation(id ID) []byte {
+	blob, err := db.lvl.Get(peerKey(id), nil)
+	if err != nil {
+		return nil
+	}
+	return blob
+}
+
+// UpdatePeerReputation stores the encoded reputation record of a peer.
+func (db *DB) UpdatePeerReputation(id ID, blob []byte) error {
+	return db.lvl.Put(peerKey(id), blob, nil)
+}
+
+// DeletePeerReputation deletes the reputation record of a peer.
+func (db *DB) DeletePeerReputation(id ID) error {
+	return db.lvl.Delete(peerKey(id), nil)
+}
+
+// IteratePeerReputations calls fn for every stored peer reputation record,
+// stopping early if fn returns false.
+func (db *DB) IteratePeerReputations(fn func(id ID, blob []byte) bool) {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbPeerPrefix)), nil)
+	defer it.Release()
+
+	for it.Next() {
+		var id ID
+		key := it.Key()[len(dbPeerPrefix):]
+		if len(key) != len(id) {
+			continue
+		}
+		copy(id[:], key)
+		if !fn(id, bytes.Clone(it.Value())) {
+			return
+		}
+	}
+}
+
+// Anchors retrieves the anchor peers, i.e. the long-lived peers stored to be
+// reconnected after a restart.
+func (db *DB) Anchors() []*Node {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbAnchorPrefix)), nil)
+	defer it.Release()
+
+	var nodes []*Node
+	for it.Next() {
+		var (
+			id ID
+			r  enr.Record
+		)
+		key := it.Key()[len(dbAnchorPrefix):]
+		if len(key) != len(id) {
+			continue
+		}
+		copy(id[:], key)
+		if err := rlp.DecodeBytes(it.Value(), &r); err != nil {
+			continue
+		}
+		nodes = append(nodes, newNodeWithID(&r, id))
+	}
+	return nodes
+}
+
+// UpdateAnchors replaces the stored anchor peers with the given nodes.
+func (db *DB) UpdateAnchors(nodes []*Node) error {
+	batch := new(leveldb.Batch)
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbAnchorPrefix)), nil)
+	for it.Next() {
+		batch.Delete(bytes.Clone(it.Key()))
+	}
+	it.Release()
+
+	for _, n := range nodes {
+		blob, err := rlp.EncodeToBytes(&n.r)
+		if err != nil {
+			return err
+		}
+		batch.Put(anchorKey(n.ID()), blob)
+	}
+	return db.lvl.Write(batch, nil)
+}
+
 // localSeq retrieves the local record sequence counter, defaulting to the current
 // timestamp if no previous exists. This ensures that wiping all data associated
 // with a node (apart from its key) will not generate already used sequence nums.
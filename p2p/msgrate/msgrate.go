@@ -131,6 +131,7 @@ type Tracker struct {
 	// each peer to target the same RTT. There's no need to make this number
 	// the real networking RTT, we just need a number to compare peers with.
 	roundtrip time.Duration
+	measured  bool // Whether the roundtrip was measured or is still the initial estimate
 
 	lock sync.RWMutex
 }
@@ -197,6 +198,17 @@ func (t *Tracker) Update(kind uint64, elapsed time.Duration, items int) {
 
 	t.capacity[kind] = (1-measurementImpact)*(t.capacity[kind]) + measurementImpact*measured
 	t.roundtrip = time.Duration((1-measurementImpact)*float64(t.roundtrip) + measurementImpact*float64(elapsed))
+	t.measured = true
+}
+
+// Roundtrip returns the peer's smoothed request round trip time. The flag is
+// false if no delivery was measured yet, the round trip being only the initial
+// estimate.
+func (t *Tracker) Roundtrip() (time.Duration, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.roundtrip, t.measured
 }
 
 // Trackers is a set of message rate trackers across a number of peers with the
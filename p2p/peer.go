@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/reputation"
 	"github.com/ethereum/go-ethereum/rlp"
 	"golang.org/x/exp/slices"
 )
@@ -118,6 +119,9 @@ type Peer struct {
 	// events receives message send / receive events if set
 	events   *event.Feed
 	testPipe *MsgPipeRW // for testing
+
+	// reputation tracks the peer's quality across sessions if set
+	reputation *reputation.Store
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -147,6 +151,13 @@ func NewPeerPipe(id enode.ID, name string, caps []Cap, pipe *MsgPipeRW) *Peer {
 	return p
 }
 
+// NewTrustedPeerPipe creates a trusted peer for testing purposes, see NewPeerPipe.
+func NewTrustedPeerPipe(id enode.ID, name string, caps []Cap, pipe *MsgPipeRW) *Peer {
+	p := NewPeerPipe(id, name, caps, pipe)
+	p.rw.set(trustedConn, true)
+	return p
+}
+
 // ID returns the node's public key.
 func (p *Peer) ID() enode.ID {
 	return p.rw.node.ID()
@@ -225,6 +236,28 @@ func (p *Peer) Inbound() bool {
 	return p.rw.is(inboundConn)
 }
 
+// Trusted returns true if the peer is a trusted peer.
+func (p *Peer) Trusted() bool {
+	return p.rw.is(trustedConn)
+}
+
+// Report records a behaviour event affecting the peer's reputation. It is a
+// no-op for peers whose reputation is not tracked.
+func (p *Peer) Report(ev reputation.Event) {
+	if p.reputation != nil {
+		p.log.Trace("Reporting peer behaviour", "event", ev)
+		p.reputation.Report(p.ID(), ev)
+	}
+}
+
+// ReportLatency records a response round trip measurement affecting the peer's
+// reputation. It is a no-op for peers whose reputation is not tracked.
+func (p *Peer) ReportLatency(rtt time.Duration) {
+	if p.reputation != nil {
+		p.reputation.ReportLatency(p.ID(), rtt)
+	}
+}
+
 func newPeer(log log.Logger, conn *conn, protocols []Protocol) *Peer {
 	protomap := matchProtocols(protocols, conn.caps, conn)
 	p := &Peer{
@@ -0,0 +1,472 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package reputation implements a persistent peer quality score, remembering
+// how well remote peers behaved across sessions.
+package reputation
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// MaxScore and MinScore are the bounds of a peer's reputation score. New
+	// peers start out neutral, with a score of zero.
+	MaxScore = 1000
+	MinScore = -1000
+
+	// DefaultThreshold is the score below which peers are neither dialed nor
+	// accepted, unless configured otherwise.
+	DefaultThreshold = -500
+
+	// scoreHalfLife is the time it takes for both the rewards and penalties of
+	// a peer to halve, allowing misbehaving peers to eventually be forgiven.
+	scoreHalfLife = 12 * time.Hour
+
+	// recordExpiration is the time after which the record of a peer that was
+	// not seen anymore is deleted.
+	recordExpiration = 30 * 24 * time.Hour
+
+	// flushInterval is the time between two writes of the changed records into
+	// the node database.
+	flushInterval = 5 * time.Minute
+
+	// targetLatency is the response round trip at which a peer is neither
+	// rewarded nor penalised for its latency.
+	targetLatency = 500 * time.Millisecond
+
+	// Bounds of the latency component of the score.
+	maxLatencyReward  = 50
+	maxLatencyPenalty = 200
+)
+
+// Event is a peer behaviour affecting its reputation.
+type Event int
+
+const (
+	// InvalidData is reported when a peer delivers invalid or inconsistent data.
+	InvalidData Event = iota
+
+	// Timeout is reported when a peer fails to answer a request in time.
+	Timeout
+
+	// SyncFailure is reported when a peer breaks a chain synchronisation, be it
+	// by feeding a bad chain or by stalling it.
+	SyncFailure
+
+	// UsefulBlock is reported when a peer propagates a previously unknown block.
+	UsefulBlock
+
+	// UsefulTxs is reported when a peer delivers previously unknown transactions.
+	UsefulTxs
+)
+
+// String implements fmt.Stringer.
+func (ev Event) String() string {
+	switch ev {
+	case InvalidData:
+		return "invalid data"
+	case Timeout:
+		return "timeout"
+	case SyncFailure:
+		return "sync failure"
+	case UsefulBlock:
+		return "useful block"
+	case UsefulTxs:
+		return "useful transactions"
+	default:
+		return "unknown"
+	}
+}
+
+// weight returns the score change caused by the event, positive for rewards
+// and negative for penalties.
+func (ev Event) weight() int64 {
+	switch ev {
+	case InvalidData:
+		return -400
+	case Timeout:
+		return -100
+	case SyncFailure:
+		return -200
+	case UsefulBlock:
+		return 10
+	case UsefulTxs:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// record is the reputation of a single peer.
+type record struct {
+	reward   float64   // Decaying credit earned by useful deliveries
+	penalty  float64   // Decaying debit caused by misbehaviour
+	latency  uint64    // Smoothed response round trip in milliseconds, zero if unmeasured
+	invalid  uint64    // Number of invalid data deliveries
+	timeouts uint64    // Number of timed out requests
+	failures uint64    // Number of failed synchronisations
+	blocks   uint64    // Number of useful block propagations
+	txs      uint64    // Number of useful transaction deliveries
+	updated  time.Time // Time of the last update
+}
+
+// storedRecord is the RLP encoding of a peer reputation in the node database.
+// The decaying fields are stored as IEEE 754 bits to avoid rounding them away.
+type storedRecord struct {
+	Reward   uint64
+	Penalty  uint64
+	Latency  uint64
+	Invalid  uint64
+	Timeouts uint64
+	Failures uint64
+	Blocks   uint64
+	Txs      uint64
+	Updated  uint64 // Unix time in seconds
+}
+
+// encodeRecord serializes a peer reputation for the node database.
+func encodeRecord(r *record) ([]byte, error) {
+	return rlp.EncodeToBytes(&storedRecord{
+		Reward:   math.Float64bits(r.reward),
+		Penalty:  math.Float64bits(r.penalty),
+		Latency:  r.latency,
+		Invalid:  r.invalid,
+		Timeouts: r.timeouts,
+		Failures: r.failures,
+		Blocks:   r.blocks,
+		Txs:      r.txs,
+		Updated:  uint64(r.updated.Unix()),
+	})
+}
+
+// decodeRecord deserializes a peer reputation from the node database.
+func decodeRecord(blob []byte) (*record, error) {
+	var stored storedRecord
+	if err := rlp.DecodeBytes(blob, &stored); err != nil {
+		return nil, err
+	}
+	return &record{
+		reward:   math.Float64frombits(stored.Reward),
+		penalty:  math.Float64frombits(stored.Penalty),
+		latency:  stored.Latency,
+		invalid:  stored.Invalid,
+		timeouts: stored.Timeouts,
+		failures: stored.Failures,
+		blocks:   stored.Blocks,
+		txs:      stored.Txs,
+		updated:  time.Unix(int64(stored.Updated), 0),
+	}, nil
+}
+
+// decay ages the rewards and penalties of the record to the given time.
+func (r *record) decay(now time.Time) {
+	elapsed := now.Sub(r.updated)
+	if elapsed <= 0 {
+		return
+	}
+	factor := math.Pow(0.5, float64(elapsed)/float64(scoreHalfLife))
+	r.reward *= factor
+	r.penalty *= factor
+	r.updated = now
+}
+
+// score calculates the reputation score of the record, assuming it was already
+// decayed to the current time.
+func (r *record) score() int64 {
+	score := int64(math.Round(r.reward - r.penalty))
+	if r.latency > 0 {
+		// Reward peers faster than the target proportionally to how much faster
+		// they are, and penalise slower ones similarly.
+		target := targetLatency.Milliseconds()
+		latency := (target - int64(r.latency)) * 100 / target
+		score += max(-maxLatencyPenalty, min(maxLatencyReward, latency))
+	}
+	return max(MinScore, min(MaxScore, score))
+}
+
+// PeerScore is the reputation of a peer, as reported through the admin API.
+type PeerScore struct {
+	ID       string `json:"id"`       // Unique node identifier
+	Score    int64  `json:"score"`    // Current reputation score
+	Latency  uint64 `json:"latency"`  // Smoothed response round trip in milliseconds
+	Invalid  uint64 `json:"invalid"`  // Number of invalid data deliveries
+	Timeouts uint64 `json:"timeouts"` // Number of timed out requests
+	Failures uint64 `json:"failures"` // Number of failed synchronisations
+	Blocks   uint64 `json:"blocks"`   // Number of useful block propagations
+	Txs      uint64 `json:"txs"`      // Number of useful transaction deliveries
+	Updated  uint64 `json:"updated"`  // Unix time of the last update
+}
+
+// Store tracks the reputation of remote peers. The records are kept in memory
+// and persisted into the node database periodically, when a peer disconnects
+// and when the store is closed, so that they survive restarts.
+type Store struct {
+	db        *enode.DB
+	threshold int64
+	now       func() time.Time // Wall clock, overridable in tests
+
+	records map[enode.ID]*record  // Reputation of all known peers
+	dirty   map[enode.ID]struct{} // Peers whose record changed since the last flush
+	lock    sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewStore creates a reputation store on top of the given node database, loading
+// the records persisted in it. Peers scoring below the threshold are considered
+// unwanted.
+func NewStore(db *enode.DB, threshold int64) *Store {
+	s := &Store{
+		db:        db,
+		threshold: threshold,
+		now:       time.Now,
+		records:   make(map[enode.ID]*record),
+		dirty:     make(map[enode.ID]struct{}),
+		quit:      make(chan struct{}),
+	}
+	var corrupt []enode.ID
+	db.IteratePeerReputations(func(id enode.ID, blob []byte) bool {
+		rec, err := decodeRecord(blob)
+		if err != nil {
+			log.Warn("Discarding corrupt peer reputation", "id", id, "err", err)
+			corrupt = append(corrupt, id)
+			return true
+		}
+		s.records[id] = rec
+		return true
+	})
+	for _, id := range corrupt {
+		db.DeletePeerReputation(id)
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+// loop persists the changed records periodically.
+func (s *Store) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Close stops the periodic persistence and writes out all changed records. The
+// node database must be closed only after the store.
+func (s *Store) Close() {
+	close(s.quit)
+	s.wg.Wait()
+	s.flush()
+}
+
+// flush writes all changed records into the node database, and drops the ones
+// of peers not seen for a long time.
+func (s *Store) flush() {
+	s.lock.Lock()
+	var (
+		now     = s.now()
+		updates = make(map[enode.ID]*record, len(s.dirty))
+		stale   []enode.ID
+	)
+	for id, rec := range s.records {
+		if now.Sub(rec.updated) > recordExpiration {
+			delete(s.records, id)
+			stale = append(stale, id)
+		}
+	}
+	for id := range s.dirty {
+		if rec, ok := s.records[id]; ok {
+			cpy := *rec
+			updates[id] = &cpy
+		}
+	}
+	clear(s.dirty)
+	s.lock.Unlock()
+
+	// Write outside of the lock, not to block the scoring on disk I/O
+	for id, rec := range updates {
+		s.store(id, rec)
+	}
+	for _, id := range stale {
+		s.db.DeletePeerReputation(id)
+	}
+}
+
+// Flush writes the record of a peer into the node database, if it changed since
+// the last flush. It is meant to be called when the peer disconnects.
+func (s *Store) Flush(id enode.ID) {
+	s.lock.Lock()
+	rec, ok := s.records[id]
+	if _, dirty := s.dirty[id]; !ok || !dirty {
+		s.lock.Unlock()
+		return
+	}
+	cpy := *rec
+	delete(s.dirty, id)
+	s.lock.Unlock()
+
+	s.store(id, &cpy)
+}
+
+// store writes the reputation record of a peer into the node database.
+func (s *Store) store(id enode.ID, rec *record) {
+	blob, err := encodeRecord(rec)
+	if err != nil {
+		log.Error("Failed to encode peer reputation", "id", id, "err", err)
+		return
+	}
+	if err := s.db.UpdatePeerReputation(id, blob); err != nil {
+		log.Warn("Failed to store peer reputation", "id", id, "err", err)
+	}
+}
+
+// record returns a copy of the reputation record of a peer, decayed to the
+// current time. The caller must hold the lock.
+func (s *Store) record(id enode.ID, now time.Time) record {
+	rec, ok := s.records[id]
+	if !ok {
+		return record{updated: now}
+	}
+	cpy := *rec
+	cpy.decay(now)
+	return cpy
+}
+
+// update returns the reputation record of a peer for modification, decayed to
+// the current time and created if unknown. The caller must hold the lock.
+func (s *Store) update(id enode.ID, now time.Time) *record {
+	rec, ok := s.records[id]
+	if !ok {
+		rec = &record{updated: now}
+		s.records[id] = rec
+	}
+	rec.decay(now)
+	s.dirty[id] = struct{}{}
+	return rec
+}
+
+// Report records a behaviour event of a peer.
+func (s *Store) Report(id enode.ID, ev Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rec := s.update(id, s.now())
+	switch ev {
+	case InvalidData:
+		rec.invalid++
+	case Timeout:
+		rec.timeouts++
+	case SyncFailure:
+		rec.failures++
+	case UsefulBlock:
+		rec.blocks++
+	case UsefulTxs:
+		rec.txs++
+	}
+	if weight := float64(ev.weight()); weight > 0 {
+		rec.reward = min(rec.reward+weight, MaxScore)
+	} else {
+		rec.penalty = min(rec.penalty-weight, -MinScore)
+	}
+}
+
+// ReportLatency records a response round trip measurement of a peer.
+func (s *Store) ReportLatency(id enode.ID, rtt time.Duration) {
+	if rtt <= 0 {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rec := s.update(id, s.now())
+	if ms := uint64(max(rtt.Milliseconds(), 1)); rec.latency == 0 {
+		rec.latency = ms
+	} else {
+		rec.latency = (rec.latency + ms) / 2
+	}
+}
+
+// Score returns the current reputation score of a peer.
+func (s *Store) Score(id enode.ID) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rec := s.record(id, s.now())
+	return rec.score()
+}
+
+// Allowed reports whether the reputation of a peer is good enough for it to be
+// dialed or accepted. A nil store allows all peers.
+func (s *Store) Allowed(id enode.ID) bool {
+	if s == nil {
+		return true
+	}
+	return s.Score(id) >= s.threshold
+}
+
+// Scores returns the reputation of all known peers, ordered from the best to
+// the worst score. Records of peers not seen for a long time are left out.
+func (s *Store) Scores() []*PeerScore {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		now    = s.now()
+		scores []*PeerScore
+	)
+	for id, rec := range s.records {
+		if now.Sub(rec.updated) > recordExpiration {
+			continue
+		}
+		decayed := s.record(id, now)
+		scores = append(scores, &PeerScore{
+			ID:       id.String(),
+			Score:    decayed.score(),
+			Latency:  rec.latency,
+			Invalid:  rec.invalid,
+			Timeouts: rec.timeouts,
+			Failures: rec.failures,
+			Blocks:   rec.blocks,
+			Txs:      rec.txs,
+			Updated:  uint64(rec.updated.Unix()),
+		})
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].ID < scores[j].ID
+	})
+	return scores
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package reputation
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func newTestStore(db *enode.DB) (*Store, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	store := NewStore(db, DefaultThreshold)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestScoring(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	store, _ := newTestStore(db)
+	defer store.Close()
+	var good, bad, fresh enode.ID
+	good[0], bad[0], fresh[0] = 1, 2, 3
+
+	for i := 0; i < 5; i++ {
+		store.Report(good, UsefulBlock)
+	}
+	store.Report(good, UsefulTxs)
+	if score := store.Score(good); score != 51 {
+		t.Errorf("good peer score mismatch: have %d, want %d", score, 51)
+	}
+	store.Report(bad, InvalidData)
+	if score := store.Score(bad); score != -400 {
+		t.Errorf("bad peer score mismatch: have %d, want %d", score, -400)
+	}
+	if !store.Allowed(bad) {
+		t.Errorf("peer banned after a single offence")
+	}
+	store.Report(bad, Timeout)
+	store.Report(bad, SyncFailure)
+	if store.Allowed(bad) {
+		t.Errorf("peer allowed with score %d", store.Score(bad))
+	}
+	if score := store.Score(fresh); score != 0 || !store.Allowed(fresh) {
+		t.Errorf("unknown peer not neutral: score %d", score)
+	}
+	// Penalties are bounded
+	for i := 0; i < 10; i++ {
+		store.Report(bad, InvalidData)
+	}
+	if score := store.Score(bad); score != MinScore {
+		t.Errorf("score not bounded: have %d, want %d", score, MinScore)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	store, _ := newTestStore(db)
+	defer store.Close()
+	var fast, slow enode.ID
+	fast[0], slow[0] = 1, 2
+
+	store.ReportLatency(fast, 100*time.Millisecond)
+	store.ReportLatency(slow, 2*time.Second)
+	if score := store.Score(fast); score != maxLatencyReward {
+		t.Errorf("fast peer score mismatch: have %d, want %d", score, maxLatencyReward)
+	}
+	if score := store.Score(slow); score != -maxLatencyPenalty {
+		t.Errorf("slow peer score mismatch: have %d, want %d", score, -maxLatencyPenalty)
+	}
+	// Measurements are smoothed across sessions
+	store.ReportLatency(slow, 500*time.Millisecond)
+	if score := store.Score(slow); score != -150 {
+		t.Errorf("smoothed score mismatch: have %d, want %d", score, -150)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	store, now := newTestStore(db)
+	defer store.Close()
+
+	var id enode.ID
+	store.Report(id, InvalidData)
+	store.Report(id, InvalidData)
+	if store.Allowed(id) {
+		t.Fatalf("peer allowed with score %d", store.Score(id))
+	}
+	*now = now.Add(scoreHalfLife)
+	if score := store.Score(id); score != -400 {
+		t.Errorf("decayed score mismatch: have %d, want %d", score, -400)
+	}
+	if !store.Allowed(id) {
+		t.Errorf("peer not forgiven after decay")
+	}
+	// Frequent updates must not stop the decay
+	for i := 0; i < 3600; i++ {
+		*now = now.Add(time.Second)
+		store.ReportLatency(id, targetLatency)
+	}
+	want := int64(-378) // -400 * 0.5^(1h/12h)
+	if score := store.Score(id); score != want {
+		t.Errorf("decayed score mismatch: have %d, want %d", score, want)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes")
+	db, err := enode.OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, now := newTestStore(db)
+
+	var good, bad, stale enode.ID
+	good[0], bad[0], stale[0] = 1, 2, 3
+	store.Report(stale, UsefulBlock)
+	store.flush()
+	if db.PeerReputation(stale) == nil {
+		t.Fatalf("record not flushed")
+	}
+	*now = now.Add(recordExpiration + time.Hour)
+	store.Report(good, UsefulBlock)
+	store.Report(bad, InvalidData)
+	if db.PeerReputation(good) != nil || db.PeerReputation(bad) != nil {
+		t.Fatalf("records written before being flushed")
+	}
+	// Disconnecting peers are flushed individually
+	store.Flush(bad)
+	if db.PeerReputation(bad) == nil || db.PeerReputation(good) != nil {
+		t.Fatalf("record of disconnected peer not flushed alone")
+	}
+	store.Close()
+	if db.PeerReputation(stale) != nil {
+		t.Errorf("stale record not deleted")
+	}
+	db.Close()
+
+	// Reopen the database and ensure the scores survived
+	db, err = enode.OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store = NewStore(db, DefaultThreshold)
+	defer store.Close()
+	store.now = func() time.Time { return *now }
+
+	scores := store.Scores()
+	if len(scores) != 2 {
+		t.Fatalf("score count mismatch: have %d, want %d", len(scores), 2)
+	}
+	if scores[0].ID != good.String() || scores[0].Score != 10 || scores[0].Blocks != 1 {
+		t.Errorf("good peer mismatch: %+v", scores[0])
+	}
+	if scores[1].ID != bad.String() || scores[1].Score != -400 || scores[1].Invalid != 1 {
+		t.Errorf("bad peer mismatch: %+v", scores[1])
+	}
+}
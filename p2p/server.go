@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -39,6 +40,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/p2p/reputation"
 	"golang.org/x/exp/slices"
 )
 
@@ -57,6 +59,9 @@ const (
 	// This time limits inbound connection attempts per source IP.
 	inboundThrottleTime = 30 * time.Second
 
+	// Minimum connection time for a dialed peer to qualify as an anchor peer.
+	anchorMinDuration = 10 * time.Minute
+
 	// Maximum time allowed for reading a complete message.
 	// This is effectively the amount of time a connection can be idle.
 	frameReadTimeout = 30 * time.Second
@@ -130,6 +135,25 @@ type Config struct {
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
 
+	// ReputationThreshold is the reputation score below which peers are neither
+	// dialed nor accepted, unless they are static or trusted.
+	// Zero defaults to reputation.DefaultThreshold.
+	ReputationThreshold int64 `toml:",omitempty"`
+
+	// OutboundSubnetLimit is the maximum number of dialed peers sharing a network
+	// prefix (/16 for IPv4, /32 for IPv6), and InboundSubnetLimit the same for
+	// inbound peers. Bounding them spreads the peer set across many networks,
+	// making eclipse attacks costlier. Local network addresses, static and
+	// trusted peers are exempt. Zero means no limit.
+	OutboundSubnetLimit int `toml:",omitempty"`
+	InboundSubnetLimit  int `toml:",omitempty"`
+
+	// AnchorPeers is the maximum number of long-lived dialed peers stored in the
+	// node database on shutdown and redialed first on the next startup, so that
+	// a restart doesn't hand the peer set over to whoever answers discovery.
+	// Zero disables anchor peers.
+	AnchorPeers int `toml:",omitempty"`
+
 	// Protocols should contain the protocols supported
 	// by the server. Matching protocols are launched for
 	// each peer.
@@ -189,12 +213,14 @@ type Server struct {
 	peerFeed     event.Feed
 	log          log.Logger
 
-	nodedb    *enode.DB
-	localnode *enode.LocalNode
-	discv4    *discover.UDPv4
-	discv5    *discover.UDPv5
-	discmix   *enode.FairMix
-	dialsched *dialScheduler
+	nodedb     *enode.DB
+	reputation *reputation.Store
+	localnode  *enode.LocalNode
+	discv4     *discover.UDPv4
+	discv5     *discover.UDPv5
+	discmix    *enode.FairMix
+	prefmix    *enode.FairMix // preferred dial candidates, e.g. topic discovery results
+	dialsched  *dialScheduler
 
 	// This is read by the NAT port mapping loop.
 	portMappingRegister chan *portMapping
@@ -389,6 +415,12 @@ func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
 }
 
+// Reputation returns the store tracking the reputation of remote peers, or nil
+// if the server is not running.
+func (srv *Server) Reputation() *reputation.Store {
+	return srv.reputation
+}
+
 // Self returns the local node's endpoint information.
 func (srv *Server) Self() *enode.Node {
 	srv.lock.Lock()
@@ -411,6 +443,21 @@ func (srv *Server) DiscoveryV5() *discover.UDPv5 {
 	return srv.discv5
 }
 
+// AddPreferredDialSource adds a source of dial candidates taking precedence over
+// the nodes found through general discovery. It is meant for sources yielding
+// nodes known to be relevant, such as the ones advertising a discovery topic, and
+// must be called while the server is running.
+func (srv *Server) AddPreferredDialSource(it enode.Iterator) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		it.Close()
+		return
+	}
+	srv.prefmix.AddSource(it)
+}
+
 // Stop terminates the server and all active peer connections.
 // It blocks until all active connections have been closed.
 func (srv *Server) Stop() {
@@ -529,6 +576,11 @@ func (srv *Server) setupLocalNode() error {
 		return err
 	}
 	srv.nodedb = db
+	threshold := srv.ReputationThreshold
+	if threshold == 0 {
+		threshold = reputation.DefaultThreshold
+	}
+	srv.reputation = reputation.NewStore(db, threshold)
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
 	// TODO: check conflicts
@@ -542,6 +594,7 @@ func (srv *Server) setupLocalNode() error {
 
 func (srv *Server) setupDiscovery() error {
 	srv.discmix = enode.NewFairMix(discmixTimeout)
+	srv.prefmix = enode.NewFairMix(discmixTimeout)
 
 	// Don't listen on UDP endpoint if DHT is disabled.
 	if srv.NoDiscovery {
@@ -612,6 +665,12 @@ func (srv *Server) setupDialScheduler() {
 		netRestrict:    srv.NetRestrict,
 		dialer:         srv.Dialer,
 		clock:          srv.clock,
+		reputation:     srv.reputation,
+		subnetLimit:    srv.OutboundSubnetLimit,
+		preferred:      srv.prefmix,
+	}
+	if srv.AnchorPeers > 0 {
+		config.anchors = srv.loadAnchors()
 	}
 	if srv.discv4 != nil {
 		config.resolver = srv.discv4
@@ -625,6 +684,48 @@ func (srv *Server) setupDialScheduler() {
 	}
 }
 
+// loadAnchors retrieves the anchor peers stored on the last shutdown. They are
+// removed from the database, so that anchors failing to connect are not redialed
+// should the node crash.
+func (srv *Server) loadAnchors() []*enode.Node {
+	anchors := srv.nodedb.Anchors()
+	if len(anchors) > srv.AnchorPeers {
+		anchors = anchors[:srv.AnchorPeers]
+	}
+	if err := srv.nodedb.UpdateAnchors(nil); err != nil {
+		srv.log.Warn("Failed to reset anchor peers", "err", err)
+	}
+	if len(anchors) > 0 {
+		srv.log.Info("Loaded anchor peers", "count", len(anchors))
+	}
+	return anchors
+}
+
+// storeAnchors persists the longest connected dialed peers as anchor peers.
+func (srv *Server) storeAnchors(peers map[enode.ID]*Peer) {
+	var candidates []*Peer
+	for _, p := range peers {
+		if p.rw.is(dynDialedConn) && time.Duration(mclock.Now()-p.created) >= anchorMinDuration {
+			candidates = append(candidates, p)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].created < candidates[j].created
+	})
+	if len(candidates) > srv.AnchorPeers {
+		candidates = candidates[:srv.AnchorPeers]
+	}
+	anchors := make([]*enode.Node, len(candidates))
+	for i, p := range candidates {
+		anchors[i] = p.Node()
+	}
+	if err := srv.nodedb.UpdateAnchors(anchors); err != nil {
+		srv.log.Warn("Failed to store anchor peers", "err", err)
+		return
+	}
+	srv.log.Debug("Stored anchor peers", "count", len(anchors))
+}
+
 func (srv *Server) maxInboundConns() int {
 	return srv.MaxPeers - srv.maxDialedConns()
 }
@@ -715,12 +816,15 @@ func (srv *Server) run() {
 	srv.log.Info("Started P2P networking", "self", srv.localnode.Node().URLv4())
 	defer srv.loopWG.Done()
 	defer srv.nodedb.Close()
+	defer srv.reputation.Close()
 	defer srv.discmix.Close()
+	defer srv.prefmix.Close()
 	defer srv.dialsched.stop()
 
 	var (
 		peers        = make(map[enode.ID]*Peer)
 		inboundCount = 0
+		inboundNets  = newSubnetCounter(srv.InboundSubnetLimit)
 		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
 	)
 	// Put trusted nodes into a map to speed up checks.
@@ -767,12 +871,12 @@ running:
 				c.flags |= trustedConn
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
-			c.cont <- srv.postHandshakeChecks(peers, inboundCount, c)
+			c.cont <- srv.postHandshakeChecks(peers, inboundCount, inboundNets, c)
 
 		case c := <-srv.checkpointAddPeer:
 			// At this point the connection is past the protocol handshake.
 			// Its capabilities are known and the remote identity is verified.
-			err := srv.addPeerChecks(peers, inboundCount, c)
+			err := srv.addPeerChecks(peers, inboundCount, inboundNets, c)
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := srv.launchPeer(c)
@@ -781,6 +885,7 @@ running:
 				srv.dialsched.peerAdded(c)
 				if p.Inbound() {
 					inboundCount++
+					inboundNets.add(c.node.IPAddr())
 					serveSuccessMeter.Mark(1)
 					activeInboundPeerGauge.Inc(1)
 				} else {
@@ -799,6 +904,7 @@ running:
 			srv.dialsched.peerRemoved(pd.rw)
 			if pd.Inbound() {
 				inboundCount--
+				inboundNets.remove(pd.Node().IPAddr())
 				activeInboundPeerGauge.Dec(1)
 			} else {
 				activeOutboundPeerGauge.Dec(1)
@@ -816,6 +922,10 @@ running:
 	if srv.discv5 != nil {
 		srv.discv5.Close()
 	}
+	// Remember the long-lived peers for the next run.
+	if srv.AnchorPeers > 0 {
+		srv.storeAnchors(peers)
+	}
 	// Disconnect all peers.
 	for _, p := range peers {
 		p.Disconnect(DiscQuitting)
@@ -830,12 +940,16 @@ running:
 	}
 }
 
-func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
+func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, inboundNets *subnetCounter, c *conn) error {
 	switch {
 	case !c.is(trustedConn) && len(peers) >= srv.MaxPeers:
 		return DiscTooManyPeers
 	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
 		return DiscTooManyPeers
+	case !c.is(trustedConn) && c.is(inboundConn) && !srv.reputation.Allowed(c.node.ID()):
+		return DiscUselessPeer
+	case !c.is(trustedConn) && c.is(inboundConn) && inboundNets.full(c.node.IPAddr()):
+		return DiscTooManyPeers
 	case peers[c.node.ID()] != nil:
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
@@ -845,14 +959,14 @@ func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount in
 	}
 }
 
-func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
+func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, inboundNets *subnetCounter, c *conn) error {
 	// Drop connections with no matching protocols.
 	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
 		return DiscUselessPeer
 	}
 	// Repeat the post-handshake checks because the
 	// peer set might have changed since those checks were performed.
-	return srv.postHandshakeChecks(peers, inboundCount, c)
+	return srv.postHandshakeChecks(peers, inboundCount, inboundNets, c)
 }
 
 // listenLoop runs in its own goroutine and accepts
@@ -1044,6 +1158,7 @@ func (srv *Server) checkpoint(c *conn, stage chan<- *conn) error {
 
 func (srv *Server) launchPeer(c *conn) *Peer {
 	p := newPeer(srv.log, c, srv.Protocols)
+	p.reputation = srv.reputation
 	if srv.EnableMsgEvents {
 		// If message events are enabled, pass the peerFeed
 		// to the peer.
@@ -1067,6 +1182,7 @@ func (srv *Server) runPeer(p *Peer) {
 
 	// Run the per-peer main loop.
 	remoteRequested, err := p.run()
+	srv.reputation.Flush(p.ID())
 
 	// Announce disconnect on the main loop to update the peer set.
 	// The main loop waits for existing peers to be sent on srv.delpeer
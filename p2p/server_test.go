@@ -23,17 +23,20 @@ import (
 	"io"
 	"math/rand"
 	"net"
+	"net/netip"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/testlog"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/reputation"
 	"github.com/ethereum/go-ethereum/p2p/rlpx"
 )
 
@@ -310,6 +313,130 @@ func TestServerAtCap(t *testing.T) {
 	}
 }
 
+// This test checks that inbound peers with a bad reputation are rejected,
+// unless they are trusted.
+func TestServerInboundReputation(t *testing.T) {
+	remote := newkey()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    10,
+			NoDial:      true,
+			NoDiscovery: true,
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	badID := randomID()
+	srv.Reputation().Report(badID, reputation.InvalidData)
+	srv.Reputation().Report(badID, reputation.InvalidData)
+
+	if err := srv.checkpoint(newconn(randomID()), srv.checkpointPostHandshake); err != nil {
+		t.Errorf("unexpected error for unknown peer: %v", err)
+	}
+	if err := srv.checkpoint(newconn(badID), srv.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Errorf("wrong error for peer with bad reputation: %v", err)
+	}
+	srv.AddTrustedPeer(newNode(badID, ""))
+	if err := srv.checkpoint(newconn(badID), srv.checkpointPostHandshake); err != nil {
+		t.Errorf("unexpected error for trusted peer with bad reputation: %v", err)
+	}
+}
+
+func TestServerInboundSubnetLimit(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    10,
+			NoDial:      true,
+			NoDiscovery: true,
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	nets := newSubnetCounter(2)
+	nets.add(netip.MustParseAddr("1.1.0.1"))
+	nets.add(netip.MustParseAddr("1.1.0.2"))
+	for i := 0; i < 3; i++ {
+		nets.add(netip.MustParseAddr("192.168.0.1"))
+	}
+	tests := []struct {
+		addr  string
+		flags connFlag
+		want  error
+	}{
+		{"1.1.0.3:30303", inboundConn, DiscTooManyPeers},
+		{"1.1.0.3:30303", inboundConn | trustedConn, nil},
+		{"1.1.0.3:30303", dynDialedConn, nil},
+		{"2.2.0.1:30303", inboundConn, nil},
+		{"192.168.0.2:30303", inboundConn, nil},
+	}
+	for i, tt := range tests {
+		c := &conn{flags: tt.flags, node: newNode(randomID(), tt.addr)}
+		if err := srv.postHandshakeChecks(nil, 0, nets, c); err != tt.want {
+			t.Errorf("test %d: wrong error for %s: have %v, want %v", i, tt.addr, err, tt.want)
+		}
+	}
+}
+
+func TestServerAnchors(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	srv := &Server{
+		Config: Config{AnchorPeers: 2},
+		nodedb: db,
+		log:    testlog.Logger(t, log.LvlTrace),
+	}
+	var (
+		now   = mclock.Now()
+		peers = make(map[enode.ID]*Peer)
+	)
+	addPeer := func(flags connFlag, age time.Duration) *enode.Node {
+		node := newNode(randomID(), "1.1.1.1:30303")
+		p := newPeer(srv.log, &conn{flags: flags, node: node}, nil)
+		p.created = now - mclock.AbsTime(age)
+		peers[node.ID()] = p
+		return node
+	}
+	oldest := addPeer(dynDialedConn, 3*time.Hour)
+	older := addPeer(dynDialedConn, 2*time.Hour)
+	addPeer(dynDialedConn, time.Hour)
+	addPeer(dynDialedConn, time.Minute)
+	addPeer(staticDialedConn, 4*time.Hour)
+	addPeer(inboundConn, 4*time.Hour)
+
+	srv.storeAnchors(peers)
+	anchors := srv.loadAnchors()
+	if len(anchors) != 2 {
+		t.Fatalf("wrong anchor count: have %d, want %d", len(anchors), 2)
+	}
+	ids := map[enode.ID]bool{anchors[0].ID(): true, anchors[1].ID(): true}
+	if !ids[oldest.ID()] || !ids[older.ID()] {
+		t.Errorf("wrong anchors: have %v, want %v and %v", anchors, oldest, older)
+	}
+	if anchors[0].IPAddr() != oldest.IPAddr() || anchors[0].TCP() != oldest.TCP() {
+		t.Errorf("anchor endpoint not stored: %v", anchors[0])
+	}
+	if left := db.Anchors(); len(left) != 0 {
+		t.Errorf("anchors not removed after loading: %v", left)
+	}
+}
+
 func TestServerPeerLimits(t *testing.T) {
 	srvkey := newkey()
 	clientkey := newkey()
@@ -0,0 +1,89 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net/netip"
+
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+const (
+	// Prefix lengths approximating the network operator (autonomous system) an
+	// address belongs to. Eclipse attackers usually control a handful of such
+	// ranges, so bucketing peers by them forces an attacker to acquire
+	// addresses in many distinct networks.
+	subnetBitsV4 = 16
+	subnetBitsV6 = 32
+)
+
+// subnetCounter counts connections per network prefix, enforcing an upper
+// bound on the connections sharing a prefix. Addresses on the local network are
+// neither counted nor limited, so that private networks keep working.
+type subnetCounter struct {
+	limit  int // Maximum connections per prefix, zero for no limit
+	counts map[netip.Prefix]int
+}
+
+func newSubnetCounter(limit int) *subnetCounter {
+	return &subnetCounter{limit: limit, counts: make(map[netip.Prefix]int)}
+}
+
+// prefix returns the network prefix of the address, or false if the address is
+// exempt from the limit.
+func (s *subnetCounter) prefix(ip netip.Addr) (netip.Prefix, bool) {
+	if s.limit <= 0 || !ip.IsValid() || netutil.AddrIsLAN(ip) {
+		return netip.Prefix{}, false
+	}
+	ip = ip.Unmap()
+	bits := subnetBitsV6
+	if ip.Is4() {
+		bits = subnetBitsV4
+	}
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return prefix, true
+}
+
+// full reports whether another connection to the address would exceed the
+// limit of its prefix.
+func (s *subnetCounter) full(ip netip.Addr) bool {
+	prefix, ok := s.prefix(ip)
+	return ok && s.counts[prefix] >= s.limit
+}
+
+// add counts a connection to the address.
+func (s *subnetCounter) add(ip netip.Addr) {
+	if prefix, ok := s.prefix(ip); ok {
+		s.counts[prefix]++
+	}
+}
+
+// remove forgets a connection to the address.
+func (s *subnetCounter) remove(ip netip.Addr) {
+	prefix, ok := s.prefix(ip)
+	if !ok {
+		return
+	}
+	if s.counts[prefix] <= 1 {
+		delete(s.counts, prefix)
+	} else {
+		s.counts[prefix]--
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+)
+
+// CheckpointSigners are the keys trusted to sign the sync checkpoint lists of the
+// networks by default. Lists distributed separately from the release are accepted
+// when signed by them too, unless other signers are configured.
+var CheckpointSigners = []common.Address{
+	common.HexToAddress("0xe3082674F67cF508d584Abdd0D077e539c731e6D"),
+}
+
+// The sync checkpoint lists bundled with the release, signed by the default
+// checkpoint signers. Adding a checkpoint to a list invalidates its signatures,
+// so the list must be signed again.
+var (
+	// ClassicCheckpoints are the sync checkpoints of the Classic main network.
+	ClassicCheckpoints = &ctypes.SignedCheckpoints{
+		Genesis: MainnetGenesisHash,
+		Checkpoints: []ctypes.SyncCheckpoint{
+			{Number: 1_920_000, Hash: common.HexToHash("0x94365e3a8c0b35089c1d1195081fe7489b528a84b22199c916180db8b28ade7f")},
+			{Number: 2_500_000, Hash: common.HexToHash("0xca12c63534f565899681965528d536c52cb05b7c48e269c2a6cb77ad864d878a")},
+		},
+		Signatures: []hexutil.Bytes{
+			hexutil.MustDecode("0xcc88caa6788ad2136593bab5d58116fd913c216216da4cefc2e9b28966b1f84416f14ff4eb14a52c0ff4208a789cda765168bb2cc15345d3b36dc8bf00ad83c900"),
+		},
+	}
+
+	// MordorCheckpoints are the sync checkpoints of the Mordor test network.
+	MordorCheckpoints = &ctypes.SignedCheckpoints{
+		Genesis: MordorGenesisHash,
+		Checkpoints: []ctypes.SyncCheckpoint{
+			{Number: 840_013, Hash: common.HexToHash("0x2ceada2b191879b71a5bcf2241dd9bc50d6d953f1640e62f9c2cee941dc61c9d")},
+			{Number: 840_014, Hash: common.HexToHash("0x8ec29dd692c8985b82410817bac232fc82805b746538d17bc924624fe74a0fcf")},
+		},
+		Signatures: []hexutil.Bytes{
+			hexutil.MustDecode("0xeb7936f562102f68120133c9c7ce182607b706e2540dad5fdba89a99c0cd563a29188d7dc982bb3e90164f00cc8a125d723a67c0349bb480916efd1837ce3d9c00"),
+		},
+	}
+
+	// MintMeCheckpoints are the sync checkpoints of the MintMe network. No block
+	// has been agreed upon as a checkpoint yet, the signed list only commits to
+	// the genesis of the network.
+	MintMeCheckpoints = &ctypes.SignedCheckpoints{
+		Genesis: MintMeGenesisHash,
+		Signatures: []hexutil.Bytes{
+			hexutil.MustDecode("0x1b1eb6e380ebad0ad0385543218c19d490bcd0ad1f03f1d56741777ca0de67435aa1f00b86894ff742783ba31651d5e8216580a4b56c6564262674be032ee1f200"),
+		},
+	}
+)
@@ -0,0 +1,50 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params/types/ctypes"
+)
+
+// Tests that the bundled checkpoint lists are ordered and signed by the default
+// checkpoint signers for their network.
+func TestCheckpointSignatures(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    *ctypes.SignedCheckpoints
+		genesis common.Hash
+	}{
+		{"classic", ClassicCheckpoints, MainnetGenesisHash},
+		{"mordor", MordorCheckpoints, MordorGenesisHash},
+		{"mintme", MintMeCheckpoints, MintMeGenesisHash},
+	}
+	for _, tt := range tests {
+		if err := tt.list.Verify(tt.genesis, CheckpointSigners, len(CheckpointSigners)); err != nil {
+			t.Errorf("%s: invalid checkpoint list: %v", tt.name, err)
+		}
+		sorted, err := ctypes.SortSyncCheckpoints(tt.list.Checkpoints)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if len(sorted) > 0 && !reflect.DeepEqual(sorted, tt.list.Checkpoints) {
+			t.Errorf("%s: checkpoints not in ascending order", tt.name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package ctypes
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	errNoCheckpointSigners    = errors.New("no checkpoint signers configured")
+	errCheckpointSignatures   = errors.New("not enough valid checkpoint signatures")
+	errCheckpointWrongGenesis = errors.New("checkpoint list of another network")
+)
+
+// SyncCheckpoint is a block known to be part of the canonical chain of a proof-of-work
+// network. Chain synchronisation rejects any chain that does not include it, so
+// that a node bootstrapping from scratch cannot be led onto an attacker's chain,
+// no matter how heavy, before it reaches the checkpoint.
+type SyncCheckpoint struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// String implements fmt.Stringer.
+func (c SyncCheckpoint) String() string {
+	return fmt.Sprintf("%d=%s", c.Number, c.Hash.Hex())
+}
+
+// SignedCheckpoints is a list of sync checkpoints of a network, along with the
+// signatures of the keys vouching for it.
+type SignedCheckpoints struct {
+	Genesis     common.Hash      `json:"genesis"`     // Genesis hash of the network the checkpoints belong to
+	Checkpoints []SyncCheckpoint `json:"checkpoints"` // Checkpoints, in ascending block number order
+	Signatures  []hexutil.Bytes  `json:"signatures"`  // Signatures of the SigHash, 65 bytes each
+}
+
+// SigHash returns the hash signed by the checkpoint signers, committing to both
+// the network and its checkpoints.
+func (l *SignedCheckpoints) SigHash() common.Hash {
+	blob, err := rlp.EncodeToBytes([]interface{}{l.Genesis, l.Checkpoints})
+	if err != nil {
+		panic(err) // This can only fail during implementation
+	}
+	return crypto.Keccak256Hash(blob)
+}
+
+// Sign signs the checkpoint list with the given key, appending the signature to
+// the ones already present.
+func (l *SignedCheckpoints) Sign(key *ecdsa.PrivateKey) error {
+	sig, err := crypto.Sign(l.SigHash().Bytes(), key)
+	if err != nil {
+		return err
+	}
+	l.Signatures = append(l.Signatures, sig)
+	return nil
+}
+
+// Verify checks that the checkpoint list belongs to the network with the given
+// genesis, and that it was signed by at least threshold distinct signers out of
+// the given ones. A zero threshold requires a single signature.
+func (l *SignedCheckpoints) Verify(genesis common.Hash, signers []common.Address, threshold int) error {
+	if l.Genesis != genesis {
+		return fmt.Errorf("%w: have %x, want %x", errCheckpointWrongGenesis, l.Genesis, genesis)
+	}
+	if len(signers) == 0 {
+		return errNoCheckpointSigners
+	}
+	threshold = max(threshold, 1)
+
+	allowed := make(map[common.Address]bool, len(signers))
+	for _, signer := range signers {
+		allowed[signer] = true
+	}
+	var (
+		hash   = l.SigHash()
+		signed = make(map[common.Address]bool)
+	)
+	for _, sig := range l.Signatures {
+		pubkey, err := crypto.SigToPub(hash.Bytes(), sig)
+		if err != nil {
+			continue
+		}
+		if signer := crypto.PubkeyToAddress(*pubkey); allowed[signer] {
+			signed[signer] = true
+		}
+	}
+	if len(signed) < threshold {
+		return fmt.Errorf("%w: have %d, want %d", errCheckpointSignatures, len(signed), threshold)
+	}
+	return nil
+}
+
+// SortSyncCheckpoints orders the checkpoints by block number and removes the
+// duplicates. It returns an error if two checkpoints conflict.
+func SortSyncCheckpoints(checkpoints []SyncCheckpoint) ([]SyncCheckpoint, error) {
+	sorted := append([]SyncCheckpoint(nil), checkpoints...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Number < sorted[j].Number
+	})
+	var unique []SyncCheckpoint
+	for _, checkpoint := range sorted {
+		if n := len(unique); n > 0 && unique[n-1].Number == checkpoint.Number {
+			if unique[n-1].Hash != checkpoint.Hash {
+				return nil, fmt.Errorf("conflicting checkpoints %v and %v", unique[n-1], checkpoint)
+			}
+			continue
+		}
+		unique = append(unique, checkpoint)
+	}
+	return unique, nil
+}
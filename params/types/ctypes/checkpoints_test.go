@@ -0,0 +1,85 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package ctypes
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSignedCheckpointsVerify(t *testing.T) {
+	var (
+		key1, _  = crypto.GenerateKey()
+		key2, _  = crypto.GenerateKey()
+		other, _ = crypto.GenerateKey()
+		signer1  = crypto.PubkeyToAddress(key1.PublicKey)
+		signer2  = crypto.PubkeyToAddress(key2.PublicKey)
+		genesis  = common.HexToHash("0x01")
+	)
+	list := &SignedCheckpoints{
+		Genesis:     genesis,
+		Checkpoints: []SyncCheckpoint{{Number: 100, Hash: common.HexToHash("0x02")}},
+	}
+	signers := []common.Address{signer1, signer2}
+	if err := list.Verify(genesis, signers, 1); !errors.Is(err, errCheckpointSignatures) {
+		t.Fatalf("unsigned list accepted: %v", err)
+	}
+	list.Sign(key1)
+	list.Sign(key1)
+	list.Sign(other)
+	if err := list.Verify(genesis, signers, 1); err != nil {
+		t.Fatalf("signed list rejected: %v", err)
+	}
+	if err := list.Verify(genesis, signers, 2); !errors.Is(err, errCheckpointSignatures) {
+		t.Fatalf("duplicate signatures counted: %v", err)
+	}
+	list.Sign(key2)
+	if err := list.Verify(genesis, signers, 2); err != nil {
+		t.Fatalf("signed list rejected: %v", err)
+	}
+	if err := list.Verify(common.HexToHash("0x03"), signers, 1); !errors.Is(err, errCheckpointWrongGenesis) {
+		t.Fatalf("list of another network accepted: %v", err)
+	}
+	if err := list.Verify(genesis, nil, 1); !errors.Is(err, errNoCheckpointSigners) {
+		t.Fatalf("list accepted without signers: %v", err)
+	}
+	// Tampering with the checkpoints invalidates the signatures
+	list.Checkpoints[0].Hash = common.HexToHash("0x04")
+	if err := list.Verify(genesis, signers, 1); !errors.Is(err, errCheckpointSignatures) {
+		t.Fatalf("tampered list accepted: %v", err)
+	}
+}
+
+func TestSortSyncCheckpoints(t *testing.T) {
+	a := SyncCheckpoint{Number: 1, Hash: common.HexToHash("0x01")}
+	b := SyncCheckpoint{Number: 2, Hash: common.HexToHash("0x02")}
+
+	sorted, err := SortSyncCheckpoints([]SyncCheckpoint{b, a, b})
+	if err != nil {
+		t.Fatalf("failed to sort checkpoints: %v", err)
+	}
+	if len(sorted) != 2 || sorted[0] != a || sorted[1] != b {
+		t.Errorf("sorted checkpoints mismatch: %v", sorted)
+	}
+	conflict := SyncCheckpoint{Number: 2, Hash: common.HexToHash("0x03")}
+	if _, err := SortSyncCheckpoints([]SyncCheckpoint{a, b, conflict}); err == nil {
+		t.Errorf("conflicting checkpoints accepted")
+	}
+}
@@ -41,6 +41,7 @@ type clientConfig struct {
 	idgen              func() ID
 	batchItemLimit     int
 	batchResponseLimit int
+	resultCache        ResultCache
 }
 
 func (cfg *clientConfig) initHeaders() {
@@ -62,6 +62,7 @@ type handler struct {
 	allowSubscribe       bool
 	batchRequestLimit    int
 	batchResponseMaxSize int
+	resultCache          ResultCache // optional cache of immutable call results
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -503,13 +504,22 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if callb == nil {
 		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
 	}
-
+	cacheable := h.resultCache != nil && callb != h.unsubscribeCb
+	if cacheable {
+		if result, ok := h.resultCache.Get(msg.Method, msg.Params); ok {
+			resultCacheHitMeter.Mark(1)
+			return &jsonrpcMessage{Version: vsn, ID: msg.ID, Result: result}
+		}
+	}
 	args, err := parsePositionalArguments(msg.Params, callb.argTypes)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
 	start := time.Now()
 	answer := h.runMethod(cp.ctx, msg, callb, args)
+	if cacheable && answer.Error == nil {
+		h.resultCache.Put(msg.Method, msg.Params, answer.Result)
+	}
 
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
@@ -32,6 +32,8 @@ var (
 	serveTimeHistName = "rpc/duration"
 
 	rpcServingTimer = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+
+	resultCacheHitMeter = metrics.NewRegisteredMeter("rpc/cache/hit", nil)
 )
 
 // updateServeTimeHistogram tracks the serving time of a remote RPC call.
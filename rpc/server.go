@@ -52,6 +52,7 @@ type Server struct {
 	batchItemLimit     int
 	batchResponseLimit int
 	httpBodyLimit      int
+	resultCache        ResultCache
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -87,6 +88,16 @@ func (s *Server) SetHTTPBodyLimit(limit int) {
 	s.httpBodyLimit = limit
 }
 
+// SetResultCache installs a cache consulted before executing method calls. Successful
+// results are offered to the cache after execution; it is up to the cache to decide
+// whether a result is safe to retain.
+//
+// This method should be called before processing any requests via ServeCodec, ServeHTTP,
+// ServeListener etc.
+func (s *Server) SetResultCache(cache ResultCache) {
+	s.resultCache = cache
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // methods on the given receiver match the criteria to be either a RPC method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -112,6 +123,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 		idgen:              s.idgen,
 		batchItemLimit:     s.batchItemLimit,
 		batchResponseLimit: s.batchResponseLimit,
+		resultCache:        s.resultCache,
 	}
 	c := initClient(codec, &s.services, cfg)
 	<-codec.closed()
@@ -147,6 +159,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services, s.batchItemLimit, s.batchResponseLimit)
 	h.allowSubscribe = false
+	h.resultCache = s.resultCache
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()
@@ -19,11 +19,13 @@ package rpc
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"io"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -192,3 +194,60 @@ func TestServerBatchResponseSizeLimit(t *testing.T) {
 		}
 	}
 }
+
+// mapResultCache is a ResultCache retaining every result offered to it.
+type mapResultCache struct {
+	mu   sync.Mutex
+	data map[string]json.RawMessage
+	gets int
+}
+
+func (c *mapResultCache) Get(method string, params json.RawMessage) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	res, ok := c.data[method+string(params)]
+	return res, ok
+}
+
+func (c *mapResultCache) Put(method string, params json.RawMessage, result json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[method+string(params)] = result
+}
+
+func TestServerResultCache(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	cache := &mapResultCache{data: make(map[string]json.RawMessage)}
+	server.SetResultCache(cache)
+	client := DialInProc(server)
+	defer client.Close()
+
+	// The first call must be executed and offered to the cache.
+	var res echoResult
+	if err := client.Call(&res, "test_echo", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.data) != 1 {
+		t.Fatalf("wrong number of cached results: have %d, want 1", len(cache.data))
+	}
+	// Tamper with the cached answer and ensure the next call is served from it.
+	for key := range cache.data {
+		cache.data[key] = json.RawMessage(`{"String":"cached","Int":2,"Args":null}`)
+	}
+	if err := client.Call(&res, "test_echo", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if res.String != "cached" || res.Int != 2 {
+		t.Fatalf("result not served from cache: %+v", res)
+	}
+	// Failed calls must not be offered to the cache.
+	if err := client.Call(nil, "test_returnError"); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(cache.data) != 1 {
+		t.Fatalf("failed call was cached")
+	}
+}
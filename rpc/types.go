@@ -60,6 +60,18 @@ type jsonWriter interface {
 	remoteAddr() string
 }
 
+// ResultCache stores the encoded results of method calls whose answers can no longer
+// change. Implementations must be safe for concurrent use.
+type ResultCache interface {
+	// Get retrieves a previously stored result for a call of method with the given
+	// raw positional parameters.
+	Get(method string, params json.RawMessage) (json.RawMessage, bool)
+
+	// Put offers the successful result of a call for storage. The cache is free to
+	// ignore results it does not consider immutable.
+	Put(method string, params json.RawMessage, result json.RawMessage)
+}
+
 type BlockNumber int64
 
 const (
@@ -256,6 +256,18 @@ func (db *Database) Dereference(root common.Hash) error {
 	return nil
 }
 
+// SetNodeTracker installs a callback which is invoked with the hash of every trie
+// node before it's persisted, nil to remove it. It's only supported by hash-based
+// database and will return an error for others.
+func (db *Database) SetNodeTracker(tracker func(hash common.Hash)) error {
+	hdb, ok := db.backend.(*hashdb.Database)
+	if !ok {
+		return errors.New("not supported")
+	}
+	hdb.SetTracker(tracker)
+	return nil
+}
+
 // Recover rollbacks the database to a specified historical point. The state is
 // supported as the rollback destination only if it's canonical state and the
 // corresponding trie histories are existent. It's only supported by path-based
@@ -286,6 +298,17 @@ func (db *Database) Recoverable(root common.Hash) (bool, error) {
 	return pdb.Recoverable(root), nil
 }
 
+// HistoricReader constructs a reader for a historic state, resolving accounts
+// and storage slots from the state histories rather than trie nodes. It's only
+// supported by path-based database and will return an error for others.
+func (db *Database) HistoricReader(root common.Hash) (*pathdb.HistoricalStateReader, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return nil, errors.New("not supported")
+	}
+	return pdb.HistoricReader(root)
+}
+
 // Disable deactivates the database and invalidates all available state layers
 // as stale to prevent access to the persistent state, which is in the syncing
 // stage.
@@ -102,6 +102,8 @@ type Database struct {
 	dirtiesSize  common.StorageSize // Storage size of the dirty node cache (exc. metadata)
 	childrenSize common.StorageSize // Storage size of the external children tracking
 
+	tracker func(hash common.Hash) // Optional callback notified of nodes before being flushed
+
 	lock sync.RWMutex
 }
 
@@ -326,6 +328,15 @@ func (db *Database) dereference(hash common.Hash) {
 	}
 }
 
+// SetTracker installs a callback which is invoked with the hash of every node
+// before it's flushed to disk. A nil callback removes the installed one.
+func (db *Database) SetTracker(tracker func(hash common.Hash)) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.tracker = tracker
+}
+
 // Cap iteratively flushes old but still referenced trie nodes until the total
 // memory usage goes below the given threshold.
 func (db *Database) Cap(limit common.StorageSize) error {
@@ -350,6 +361,9 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	for size > limit && oldest != (common.Hash{}) {
 		// Fetch the oldest referenced node and push into the batch
 		node := db.dirties[oldest]
+		if db.tracker != nil {
+			db.tracker(oldest)
+		}
 		rawdb.WriteLegacyTrieNode(batch, oldest, node.node)
 
 		// If we exceeded the ideal batch size, commit and reset
@@ -473,6 +487,9 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 		return err
 	}
 	// If we've reached an optimal batch size, commit and start over
+	if db.tracker != nil {
+		db.tracker(hash)
+	}
 	rawdb.WriteLegacyTrieNode(batch, hash, node.node)
 	if batch.ValueSize() >= ethdb.IdealBatchSize {
 		if err := batch.Write(); err != nil {
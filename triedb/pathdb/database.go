@@ -136,6 +136,10 @@ type Database struct {
 	tree       *layerTree               // The group for all known layers
 	freezer    *rawdb.ResettableFreezer // Freezer for storing trie histories, nil possible in tests
 	lock       sync.RWMutex             // Lock to prevent mutations from happening at the same time
+
+	indexLock sync.Mutex    // Lock to serialize background history indexing with truncations
+	indexQuit chan struct{} // Quit channel of the background history indexer, nil if not running
+	indexDone chan struct{} // Channel closed once the background history indexer terminated
 }
 
 // New attempts to load an already existing layer from a persistent key-value
@@ -196,6 +200,7 @@ func New(diskdb ethdb.Database, config *Config) *Database {
 				log.Warn("Truncated extra state histories", "number", pruned)
 			}
 		}
+		db.startHistoryIndexer()
 	}
 	// Disable database in case node is still in the initial state sync stage.
 	if rawdb.ReadSnapSyncStatusFlag(diskdb) == rawdb.StateSyncRunning && !db.readOnly {
@@ -314,9 +319,11 @@ func (db *Database) Enable(root common.Hash) error {
 	// mappings can be huge and might take a while to clear
 	// them, just leave them in disk and wait for overwriting.
 	if db.freezer != nil {
+		db.stopHistoryIndexer()
 		if err := db.freezer.Reset(); err != nil {
 			return err
 		}
+		rawdb.WriteStateHistoryIndexTail(db.diskdb, 1)
 	}
 	// Re-construct a new disk layer backed by persistent state
 	// with **empty clean cache and node buffer**.
@@ -368,7 +375,9 @@ func (db *Database) Recover(root common.Hash, loader triestate.TrieLoader) error
 		db.tree.reset(dl)
 	}
 	rawdb.DeleteTrieJournal(db.diskdb)
+	db.indexLock.Lock()
 	_, err := truncateFromHead(db.diskdb, db.freezer, dl.stateID())
+	db.indexLock.Unlock()
 	if err != nil {
 		return err
 	}
@@ -422,6 +431,7 @@ func (db *Database) Close() error {
 	if db.freezer == nil {
 		return nil
 	}
+	db.stopHistoryIndexer()
 	return db.freezer.Close()
 }
 
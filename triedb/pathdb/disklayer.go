@@ -180,7 +180,7 @@ func (dl *diskLayer) commit(bottom *diffLayer, force bool) (*diskLayer, error) {
 		oldest   uint64
 	)
 	if dl.db.freezer != nil {
-		err := writeHistory(dl.db.freezer, bottom)
+		err := writeHistory(dl.db.diskdb, dl.db.freezer, bottom)
 		if err != nil {
 			return nil, err
 		}
@@ -225,7 +225,9 @@ func (dl *diskLayer) commit(bottom *diffLayer, force bool) (*diskLayer, error) {
 	// To remove outdated history objects from the end, we set the 'tail' parameter
 	// to 'oldest-1' due to the offset between the freezer index and the history ID.
 	if overflow {
+		ndl.db.indexLock.Lock()
 		pruned, err := truncateFromTail(ndl.db.diskdb, ndl.db.freezer, oldest-1)
+		ndl.db.indexLock.Unlock()
 		if err != nil {
 			return nil, err
 		}
@@ -46,6 +46,14 @@ var (
 	// a destination without associated state history available.
 	errStateUnrecoverable = errors.New("state is unrecoverable")
 
+	// errStateNotIndexed is returned if a historic state is requested whose state
+	// histories have not been indexed yet.
+	errStateNotIndexed = errors.New("state history not indexed yet")
+
+	// errIncompleteHistory is returned if a historic storage slot is requested
+	// whose value was not recorded due to the destruction of a large contract.
+	errIncompleteHistory = errors.New("incomplete state history")
+
 	// errUnexpectedNode is returned if the requested node with specified path is
 	// not hash matched with expectation.
 	errUnexpectedNode = errors.New("unexpected node")
@@ -512,8 +512,9 @@ func readHistory(freezer *rawdb.ResettableFreezer, id uint64) (*history, error)
 	return &dec, nil
 }
 
-// writeHistory persists the state history with the provided state set.
-func writeHistory(freezer *rawdb.ResettableFreezer, dl *diffLayer) error {
+// writeHistory persists the state history with the provided state set and
+// indexes it for serving historic states.
+func writeHistory(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, dl *diffLayer) error {
 	// Short circuit if state set is not available.
 	if dl.states == nil {
 		return errors.New("state change set is not available")
@@ -529,6 +530,12 @@ func writeHistory(freezer *rawdb.ResettableFreezer, dl *diffLayer) error {
 	// Write history data into five freezer table respectively.
 	rawdb.WriteStateHistory(freezer, dl.stateID(), history.meta.encode(), accountIndex, storageIndex, accountData, storageData)
 
+	batch := db.NewBatch()
+	indexHistory(batch, dl.stateID(), history)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
 	historyDataBytesMeter.Mark(int64(dataSize))
 	historyIndexBytesMeter.Mark(int64(indexSize))
 	historyBuildTimeMeter.UpdateSince(start)
@@ -566,7 +573,7 @@ func checkHistories(freezer *rawdb.ResettableFreezer, start, count uint64, check
 
 // truncateFromHead removes the extra state histories from the head with the given
 // parameters. It returns the number of items removed from the head.
-func truncateFromHead(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, nhead uint64) (int, error) {
+func truncateFromHead(db ethdb.KeyValueStore, freezer *rawdb.ResettableFreezer, nhead uint64) (int, error) {
 	ohead, err := freezer.Ancients()
 	if err != nil {
 		return 0, err
@@ -588,6 +595,9 @@ func truncateFromHead(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, nhead
 	if err != nil {
 		return 0, err
 	}
+	if err := unindexHistories(db, freezer, nhead+1, ohead); err != nil {
+		return 0, err
+	}
 	batch := db.NewBatch()
 	for _, blob := range blobs {
 		var m meta
@@ -596,6 +606,11 @@ func truncateFromHead(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, nhead
 		}
 		rawdb.DeleteStateID(batch, m.root)
 	}
+	// The histories written after truncation are indexed right away, move
+	// the index tail down if it's above them.
+	if tail := rawdb.ReadStateHistoryIndexTail(db); tail != nil && *tail > nhead+1 {
+		rawdb.WriteStateHistoryIndexTail(batch, nhead+1)
+	}
 	if err := batch.Write(); err != nil {
 		return 0, err
 	}
@@ -608,7 +623,7 @@ func truncateFromHead(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, nhead
 
 // truncateFromTail removes the extra state histories from the tail with the given
 // parameters. It returns the number of items removed from the tail.
-func truncateFromTail(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, ntail uint64) (int, error) {
+func truncateFromTail(db ethdb.KeyValueStore, freezer *rawdb.ResettableFreezer, ntail uint64) (int, error) {
 	ohead, err := freezer.Ancients()
 	if err != nil {
 		return 0, err
@@ -630,6 +645,9 @@ func truncateFromTail(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, ntail
 	if err != nil {
 		return 0, err
 	}
+	if err := unindexHistories(db, freezer, otail+1, ntail); err != nil {
+		return 0, err
+	}
 	batch := db.NewBatch()
 	for _, blob := range blobs {
 		var m meta
@@ -0,0 +1,189 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package pathdb
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// State history index
+//
+// In order to serve historic states, the state histories modifying an account
+// or a storage slot are indexed in the key-value store. Looking up the value of
+// an account at state n then boils down to finding the first history after n
+// that touches the account and reading the recorded previous value from it. If
+// no such history exists, the account has not changed since and can be read
+// from the persistent state.
+//
+// Index entries are written together with the state histories and removed when
+// they are truncated. Stale entries (e.g. left over by a crash) are harmless, as
+// every entry is verified against the referenced history before being used.
+//
+// Histories written before the index existed are indexed in the background,
+// from the newest to the oldest. The index tail tracks the oldest history from
+// which on all histories are indexed.
+
+// indexHistory writes the index entries of the given state history.
+func indexHistory(db ethdb.KeyValueWriter, id uint64, h *history) {
+	for _, addr := range h.accountList {
+		rawdb.WriteStateHistoryAccountIndex(db, addr, id)
+	}
+	for addr, slots := range h.storageList {
+		for _, slot := range slots {
+			rawdb.WriteStateHistoryStorageIndex(db, addr, slot, id)
+		}
+	}
+	for _, addr := range h.meta.incomplete {
+		rawdb.WriteStateHistoryIncompleteIndex(db, addr, id)
+	}
+}
+
+// unindexHistory removes the index entries of the given state history.
+func unindexHistory(db ethdb.KeyValueWriter, id uint64, h *history) {
+	for _, addr := range h.accountList {
+		rawdb.DeleteStateHistoryAccountIndex(db, addr, id)
+	}
+	for addr, slots := range h.storageList {
+		for _, slot := range slots {
+			rawdb.DeleteStateHistoryStorageIndex(db, addr, slot, id)
+		}
+	}
+	for _, addr := range h.meta.incomplete {
+		rawdb.DeleteStateHistoryIncompleteIndex(db, addr, id)
+	}
+}
+
+// unindexHistories removes the index entries of the state histories in range
+// [from, to] that have been indexed.
+func unindexHistories(db ethdb.KeyValueStore, freezer *rawdb.ResettableFreezer, from, to uint64) error {
+	if tail := rawdb.ReadStateHistoryIndexTail(db); tail == nil {
+		return nil
+	} else if *tail > from {
+		from = *tail
+	}
+	batch := db.NewBatch()
+	for id := from; id <= to; id++ {
+		h, err := readHistory(freezer, id)
+		if err != nil {
+			return err
+		}
+		unindexHistory(batch, id, h)
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
+
+// startHistoryIndexer initializes the index tail and starts indexing the state
+// histories written before the index was available in the background.
+func (db *Database) startHistoryIndexer() {
+	head, err := db.freezer.Ancients()
+	if err != nil {
+		log.Crit("Failed to retrieve head of state history", "err", err)
+	}
+	tail, err := db.freezer.Tail()
+	if err != nil {
+		log.Crit("Failed to retrieve tail of state history", "err", err)
+	}
+	// All the histories written from now on are indexed right away, the
+	// existing ones only if the index was maintained already.
+	next := head + 1
+	if stored := rawdb.ReadStateHistoryIndexTail(db.diskdb); stored != nil && *stored < next {
+		next = *stored
+	}
+	rawdb.WriteStateHistoryIndexTail(db.diskdb, next)
+	if next <= tail+1 {
+		return
+	}
+	db.indexQuit, db.indexDone = make(chan struct{}), make(chan struct{})
+	go db.indexHistories(next-1, db.indexQuit, db.indexDone)
+}
+
+// stopHistoryIndexer terminates the background indexing, if running.
+func (db *Database) stopHistoryIndexer() {
+	if db.indexQuit == nil {
+		return
+	}
+	close(db.indexQuit)
+	<-db.indexDone
+	db.indexQuit, db.indexDone = nil, nil
+}
+
+// indexHistories indexes the state histories from the given id backwards until
+// the tail is reached or termination is requested.
+func (db *Database) indexHistories(id uint64, quit chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		indexed int
+	)
+	log.Info("Indexing state histories", "from", id)
+	for ; id > 0; id-- {
+		select {
+		case <-quit:
+			log.Info("Interrupted state history indexing", "indexed", indexed, "next", id)
+			return
+		default:
+		}
+		if finished, err := db.indexHistoryAt(id); err != nil {
+			log.Error("Failed to index state history", "id", id, "err", err)
+			return
+		} else if finished {
+			break
+		}
+		indexed++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing state histories", "indexed", indexed, "next", id-1, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Indexed state histories", "indexed", indexed, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// indexHistoryAt indexes a single state history and moves the index tail onto
+// it. True is returned if the history has been pruned already.
+func (db *Database) indexHistoryAt(id uint64) (bool, error) {
+	db.indexLock.Lock()
+	defer db.indexLock.Unlock()
+
+	tail, err := db.freezer.Tail()
+	if err != nil {
+		return false, err
+	}
+	if id <= tail {
+		return true, nil
+	}
+	h, err := readHistory(db.freezer, id)
+	if err != nil {
+		return false, err
+	}
+	batch := db.diskdb.NewBatch()
+	indexHistory(batch, id, h)
+	rawdb.WriteStateHistoryIndexTail(batch, id)
+	return false, batch.Write()
+}
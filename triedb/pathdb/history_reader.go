@@ -0,0 +1,274 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package pathdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HistoricalStateReader reads the accounts and storage slots of a historic state
+// from the state histories, without requiring its trie nodes.
+//
+// The values are resolved from the histories applied on top of the historic
+// state, up to the disk layer at the time the reader was created (the base). A
+// value not modified by any of them is unchanged since and must be read from
+// the base state by the caller.
+type HistoricalStateReader struct {
+	db     *Database
+	id     uint64      // ID of the historic state
+	base   common.Hash // Root of the state to fall back to for unmodified values
+	baseID uint64      // ID of the state to fall back to
+}
+
+// HistoricReader constructs a reader for the historic state with the given root.
+// The state must be canonical and within the retained and indexed state history.
+func (db *Database) HistoricReader(root common.Hash) (*HistoricalStateReader, error) {
+	if db.freezer == nil {
+		return nil, errors.New("state history is not available")
+	}
+	if db.waitSync {
+		return nil, errDatabaseWaitSync
+	}
+	root = types.TrieRootHash(root)
+	id := rawdb.ReadStateID(db.diskdb, root)
+	if id == nil {
+		return nil, fmt.Errorf("state %#x is not available", root)
+	}
+	dl := db.tree.bottom()
+	switch {
+	case *id > dl.stateID():
+		return nil, fmt.Errorf("state %#x is not available", root)
+	case *id == dl.stateID():
+		if dl.rootHash() != root {
+			return nil, fmt.Errorf("state %#x is not available", root)
+		}
+	default:
+		tail, err := db.freezer.Tail()
+		if err != nil {
+			return nil, err
+		}
+		if *id < tail {
+			return nil, fmt.Errorf("state %#x is not available, history pruned", root)
+		}
+		if indexed := rawdb.ReadStateHistoryIndexTail(db.diskdb); indexed == nil || *id+1 < *indexed {
+			return nil, errStateNotIndexed
+		}
+		// Ensure the state lookup is not a leftover from a previous state
+		// sync, the state must be the parent of the next history.
+		var m meta
+		if err := m.decode(rawdb.ReadStateHistoryMeta(db.freezer, *id+1)); err != nil {
+			return nil, err
+		}
+		if m.parent != root {
+			return nil, fmt.Errorf("state %#x is not available", root)
+		}
+	}
+	return &HistoricalStateReader{
+		db:     db,
+		id:     *id,
+		base:   dl.rootHash(),
+		baseID: dl.stateID(),
+	}, nil
+}
+
+// Base returns the root of the state to read values from which are reported as
+// unmodified by the reader.
+func (r *HistoricalStateReader) Base() common.Hash {
+	return r.base
+}
+
+// Account retrieves the account with the given address in the slim RLP format,
+// nil if it didn't exist. The flag reports whether the account was found in the
+// histories, otherwise it must be read from the base state.
+func (r *HistoricalStateReader) Account(address common.Address) ([]byte, bool, error) {
+	defer historicReadTimer.UpdateSince(time.Now())
+	historicAccountReadMeter.Mark(1)
+
+	var (
+		blob  []byte
+		found bool
+		err   error
+	)
+	iterErr := rawdb.IterateStateHistoryAccountIndex(r.db.diskdb, address, r.id+1, func(id uint64) bool {
+		if id > r.baseID {
+			return false
+		}
+		blob, found, err = readHistoryAccount(r.db.freezer, id, address)
+		return err == nil && !found
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if iterErr != nil {
+		return nil, false, iterErr
+	}
+	return blob, found, nil
+}
+
+// Storage retrieves the RLP-encoded value of the storage slot with the given key
+// hash, nil if it didn't exist. The flag reports whether the slot was found in
+// the histories, otherwise it must be read from the base state.
+func (r *HistoricalStateReader) Storage(address common.Address, slot common.Hash) ([]byte, bool, error) {
+	defer historicReadTimer.UpdateSince(time.Now())
+	historicStorageReadMeter.Mark(1)
+
+	// The storage of a destructed large contract is not recorded. Values
+	// after such a destruction can't be resolved.
+	var (
+		incomplete uint64
+		err        error
+	)
+	iterErr := rawdb.IterateStateHistoryIncompleteIndex(r.db.diskdb, address, r.id+1, func(id uint64) bool {
+		if id > r.baseID {
+			return false
+		}
+		var ok bool
+		if ok, err = readHistoryIncomplete(r.db.freezer, id, address); ok {
+			incomplete = id
+		}
+		return err == nil && !ok
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if iterErr != nil {
+		return nil, false, iterErr
+	}
+	limit := r.baseID
+	if incomplete != 0 {
+		limit = incomplete
+	}
+	var (
+		blob  []byte
+		found bool
+	)
+	iterErr = rawdb.IterateStateHistoryStorageIndex(r.db.diskdb, address, slot, r.id+1, func(id uint64) bool {
+		if id > limit {
+			return false
+		}
+		blob, found, err = readHistoryStorage(r.db.freezer, id, address, slot)
+		return err == nil && !found
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if iterErr != nil {
+		return nil, false, iterErr
+	}
+	if !found && incomplete != 0 {
+		return nil, false, fmt.Errorf("%w: storage of %x in history %d", errIncompleteHistory, address, incomplete)
+	}
+	return blob, found, nil
+}
+
+// readHistoryAccountIndex locates the index of the given account in the specified
+// state history, if the history modifies it.
+func readHistoryAccountIndex(freezer *rawdb.ResettableFreezer, id uint64, address common.Address) (accountIndex, bool, error) {
+	indexes := rawdb.ReadStateAccountIndex(freezer, id)
+	if len(indexes) == 0 || len(indexes)%accountIndexSize != 0 {
+		return accountIndex{}, false, fmt.Errorf("invalid account index of state history %d, len: %d", id, len(indexes))
+	}
+	n := len(indexes) / accountIndexSize
+	pos := sort.Search(n, func(i int) bool {
+		return bytes.Compare(indexes[i*accountIndexSize:i*accountIndexSize+common.AddressLength], address.Bytes()) >= 0
+	})
+	if pos == n || !bytes.Equal(indexes[pos*accountIndexSize:pos*accountIndexSize+common.AddressLength], address.Bytes()) {
+		return accountIndex{}, false, nil
+	}
+	var index accountIndex
+	index.decode(indexes[pos*accountIndexSize : (pos+1)*accountIndexSize])
+	return index, true, nil
+}
+
+// readHistoryAccount retrieves the previous value of the given account recorded
+// in the specified state history.
+func readHistoryAccount(freezer *rawdb.ResettableFreezer, id uint64, address common.Address) ([]byte, bool, error) {
+	index, found, err := readHistoryAccountIndex(freezer, id, address)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	data := rawdb.ReadStateAccountHistory(freezer, id)
+	last := index.offset + uint32(index.length)
+	if uint32(len(data)) < last {
+		return nil, false, fmt.Errorf("account data of state history %d is corrupted", id)
+	}
+	if index.length == 0 {
+		return nil, true, nil
+	}
+	return common.CopyBytes(data[index.offset:last]), true, nil
+}
+
+// readHistoryStorage retrieves the previous value of the given storage slot
+// recorded in the specified state history.
+func readHistoryStorage(freezer *rawdb.ResettableFreezer, id uint64, address common.Address, slot common.Hash) ([]byte, bool, error) {
+	accIndex, found, err := readHistoryAccountIndex(freezer, id, address)
+	if err != nil || !found || accIndex.storageSlots == 0 {
+		return nil, false, err
+	}
+	var (
+		indexes = rawdb.ReadStateStorageIndex(freezer, id)
+		start   = int(accIndex.storageOffset) * slotIndexSize
+		end     = start + int(accIndex.storageSlots)*slotIndexSize
+	)
+	if len(indexes) < end {
+		return nil, false, fmt.Errorf("storage index of state history %d is corrupted", id)
+	}
+	indexes = indexes[start:end]
+
+	n := int(accIndex.storageSlots)
+	pos := sort.Search(n, func(i int) bool {
+		return bytes.Compare(indexes[i*slotIndexSize:i*slotIndexSize+common.HashLength], slot.Bytes()) >= 0
+	})
+	if pos == n || !bytes.Equal(indexes[pos*slotIndexSize:pos*slotIndexSize+common.HashLength], slot.Bytes()) {
+		return nil, false, nil
+	}
+	var index slotIndex
+	index.decode(indexes[pos*slotIndexSize : (pos+1)*slotIndexSize])
+
+	data := rawdb.ReadStateStorageHistory(freezer, id)
+	last := index.offset + uint32(index.length)
+	if uint32(len(data)) < last {
+		return nil, false, fmt.Errorf("storage data of state history %d is corrupted", id)
+	}
+	if index.length == 0 {
+		return nil, true, nil
+	}
+	return common.CopyBytes(data[index.offset:last]), true, nil
+}
+
+// readHistoryIncomplete reports whether the storage changes of the given account
+// are incomplete in the specified state history.
+func readHistoryIncomplete(freezer *rawdb.ResettableFreezer, id uint64, address common.Address) (bool, error) {
+	var m meta
+	if err := m.decode(rawdb.ReadStateHistoryMeta(freezer, id)); err != nil {
+		return false, err
+	}
+	for _, addr := range m.incomplete {
+		if addr == address {
+			return true, nil
+		}
+	}
+	return false, nil
+}
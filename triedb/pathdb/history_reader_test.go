@@ -0,0 +1,180 @@
+// Copyright 2024 The core-geth Authors
+// This file is part of the core-geth library.
+//
+// The core-geth library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The core-geth library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the core-geth library. If not, see <http://www.gnu.org/licenses/>.
+
+package pathdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// stateAt returns the account and storage sets of the state with the given root.
+func (t *tester) stateAt(root common.Hash) (map[common.Hash][]byte, map[common.Hash]map[common.Hash][]byte) {
+	if root == t.lastHash() {
+		return t.accounts, t.storages
+	}
+	return t.snapAccounts[root], t.snapStorages[root]
+}
+
+// verifyHistoricState checks that a sample of the accounts and storage slots
+// ever touched are resolved correctly for the given historic state.
+func (t *tester) verifyHistoricState(root common.Hash) error {
+	reader, err := t.db.HistoricReader(root)
+	if err != nil {
+		return err
+	}
+	var (
+		accounts, storages         = t.stateAt(root)
+		baseAccounts, baseStorages = t.stateAt(reader.Base())
+	)
+	checked := 0
+	for addrHash, addr := range t.preimages {
+		if checked++; checked > 64 {
+			break
+		}
+		blob, found, err := reader.Account(addr)
+		if err != nil {
+			return err
+		}
+		if !found {
+			blob = baseAccounts[addrHash]
+		}
+		if !bytes.Equal(blob, accounts[addrHash]) {
+			return fmt.Errorf("account %x mismatch: have %x, want %x", addr, blob, accounts[addrHash])
+		}
+		slots := make(map[common.Hash]struct{})
+		for slot := range storages[addrHash] {
+			slots[slot] = struct{}{}
+		}
+		for slot := range baseStorages[addrHash] {
+			slots[slot] = struct{}{}
+		}
+		for slot := range slots {
+			blob, found, err := reader.Storage(addr, slot)
+			if err != nil {
+				return err
+			}
+			if !found {
+				blob = baseStorages[addrHash][slot]
+			}
+			if !bytes.Equal(blob, storages[addrHash][slot]) {
+				return fmt.Errorf("slot %x of %x mismatch: have %x, want %x", slot, addr, blob, storages[addrHash][slot])
+			}
+		}
+	}
+	return nil
+}
+
+func TestHistoricReader(t *testing.T) {
+	tester := newTester(t, 0)
+	defer tester.release()
+
+	// Historic states are served both with diff layers on top of the disk
+	// layer and with all the states flattened.
+	for i := 0; i < tester.bottomIndex(); i += 64 {
+		if err := tester.verifyHistoricState(tester.roots[i]); err != nil {
+			t.Fatalf("Invalid historic state %d: %v", i, err)
+		}
+	}
+	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
+		t.Fatalf("Failed to commit database: %v", err)
+	}
+	for i := 0; i < len(tester.roots); i += 64 {
+		if err := tester.verifyHistoricState(tester.roots[i]); err != nil {
+			t.Fatalf("Invalid historic state %d: %v", i, err)
+		}
+	}
+	if _, err := tester.db.HistoricReader(common.Hash{0x1}); err == nil {
+		t.Fatal("Unknown state is served")
+	}
+}
+
+func TestHistoricReaderIndexing(t *testing.T) {
+	tester := newTester(t, 0)
+	defer tester.release()
+
+	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
+		t.Fatalf("Failed to commit database: %v", err)
+	}
+	// Drop the index, as if the histories were written by an old version.
+	head, _ := tester.db.freezer.Ancients()
+	if err := unindexHistories(tester.db.diskdb, tester.db.freezer, 1, head); err != nil {
+		t.Fatalf("Failed to drop history index: %v", err)
+	}
+	rawdb.WriteStateHistoryIndexTail(tester.db.diskdb, head+1)
+
+	if _, err := tester.db.HistoricReader(tester.roots[10]); !errors.Is(err, errStateNotIndexed) {
+		t.Fatalf("Unexpected error for unindexed state: have %v, want %v", err, errStateNotIndexed)
+	}
+	// Reopen the database, the histories should be indexed in the background.
+	if err := tester.db.Journal(tester.lastHash()); err != nil {
+		t.Fatalf("Failed to journal: %v", err)
+	}
+	tester.db.Close()
+	tester.db = New(tester.db.diskdb, nil)
+	if tester.db.indexDone == nil {
+		t.Fatal("History indexer is not running")
+	}
+	<-tester.db.indexDone
+
+	if tail := rawdb.ReadStateHistoryIndexTail(tester.db.diskdb); tail == nil || *tail != 1 {
+		t.Fatalf("Unexpected index tail: %v", tail)
+	}
+	for i := 0; i < len(tester.roots); i += 64 {
+		if err := tester.verifyHistoricState(tester.roots[i]); err != nil {
+			t.Fatalf("Invalid historic state %d: %v", i, err)
+		}
+	}
+}
+
+func TestHistoricReaderPruned(t *testing.T) {
+	tester := newTester(t, 10)
+	defer tester.release()
+
+	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
+		t.Fatalf("Failed to commit database: %v", err)
+	}
+	tail, _ := tester.db.freezer.Tail()
+	if _, err := tester.db.HistoricReader(tester.roots[tail-1]); err == nil {
+		t.Fatal("Pruned state is served")
+	}
+	for i := int(tail); i < len(tester.roots); i++ {
+		if err := tester.verifyHistoricState(tester.roots[i]); err != nil {
+			t.Fatalf("Invalid historic state %d: %v", i, err)
+		}
+	}
+	// The index entries of the pruned histories must be gone.
+	var leftover, checked int
+	for _, addr := range tester.preimages {
+		if checked++; checked > 256 {
+			break
+		}
+		rawdb.IterateStateHistoryAccountIndex(tester.db.diskdb, addr, 0, func(id uint64) bool {
+			if id <= tail {
+				leftover++
+			}
+			return true
+		})
+	}
+	if leftover != 0 {
+		t.Fatalf("Index entries of pruned histories left: %d", leftover)
+	}
+}
@@ -47,4 +47,8 @@ var (
 	historyBuildTimeMeter  = metrics.NewRegisteredTimer("pathdb/history/time", nil)
 	historyDataBytesMeter  = metrics.NewRegisteredMeter("pathdb/history/bytes/data", nil)
 	historyIndexBytesMeter = metrics.NewRegisteredMeter("pathdb/history/bytes/index", nil)
+
+	historicAccountReadMeter = metrics.NewRegisteredMeter("pathdb/historic/account/reads", nil)
+	historicStorageReadMeter = metrics.NewRegisteredMeter("pathdb/historic/storage/reads", nil)
+	historicReadTimer        = metrics.NewRegisteredTimer("pathdb/historic/time", nil)
 )